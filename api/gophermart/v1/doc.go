@@ -0,0 +1,5 @@
+// Package gophermartv1 содержит сообщения и клиент gRPC API gophermart,
+// сгенерированные из gophermart.proto.
+package gophermartv1
+
+//go:generate protoc -I ../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative api/gophermart/v1/gophermart.proto
@@ -40,3 +40,26 @@ func Down(ctx context.Context, db *sql.DB) error {
 	}
 	return nil
 }
+
+// Check возвращает ошибку, если версия схемы БД не совпадает с версией
+// последней встроенной миграции.
+func Check(ctx context.Context, db *sql.DB) error {
+	migrations, err := goose.CollectMigrations(".", 0, goose.MaxVersion)
+	if err != nil {
+		return fmt.Errorf("migrations: collecting migrations: %w", err)
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return fmt.Errorf("migrations: last migration: %w", err)
+	}
+
+	current, err := goose.GetDBVersionContext(ctx, db)
+	if err != nil {
+		return fmt.Errorf("migrations: getting the database version: %w", err)
+	}
+	if current != last.Version {
+		return fmt.Errorf("migrations: database version %d, expected %d", current, last.Version)
+	}
+
+	return nil
+}
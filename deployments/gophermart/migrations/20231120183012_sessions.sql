@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS sessions (
+	id uuid DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+	user_id uuid NOT NULL,
+	refresh_token_hash varchar NOT NULL UNIQUE,
+	expires_at timestamptz NOT NULL,
+	revoked_at timestamptz,
+	created_at timestamptz DEFAULT now(),
+	updated_at timestamptz DEFAULT now(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS sessions;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS login_attempts (
+	key varchar NOT NULL PRIMARY KEY,
+	failures int NOT NULL DEFAULT 0,
+	last_failure_at timestamptz NOT NULL DEFAULT now(),
+	blocked_until timestamptz
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_attempts;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Логины, различающиеся только регистром, нельзя объединить автоматически:
+-- у каждой учётной записи свои заказы и баланс. Миграция останавливается
+-- с перечнем конфликтующих логинов, которые нужно переименовать вручную.
+DO $$
+DECLARE
+	duplicates text;
+BEGIN
+	SELECT string_agg(login, ', ' ORDER BY login) INTO duplicates
+	FROM (
+		SELECT lower(login) AS login
+		FROM users
+		GROUP BY lower(login)
+		HAVING count(*) > 1
+		LIMIT 20
+	) d;
+
+	IF duplicates IS NOT NULL THEN
+		RAISE EXCEPTION 'logins differing only in case must be resolved before migrating: %', duplicates
+			USING HINT = 'Rename the duplicate accounts so that lower(login) is unique, then rerun the migration.';
+	END IF;
+END
+$$;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE UNIQUE INDEX IF NOT EXISTS users_login_lower_idx ON users (lower(login));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_login_lower_idx;
+-- +goose StatementEnd
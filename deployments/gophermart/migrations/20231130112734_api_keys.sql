@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS api_keys (
+	id uuid DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+	user_id uuid NOT NULL,
+	name varchar NOT NULL,
+	key_hash varchar NOT NULL UNIQUE,
+	scopes varchar[] NOT NULL,
+	last_used_at timestamptz,
+	revoked_at timestamptz,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_keys;
+-- +goose StatementEnd
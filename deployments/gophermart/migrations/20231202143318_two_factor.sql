@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_totp (
+	user_id uuid NOT NULL PRIMARY KEY,
+	secret varchar NOT NULL,
+	last_counter bigint NOT NULL DEFAULT 0,
+	enabled_at timestamptz,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	user_id uuid NOT NULL,
+	code_hash varchar NOT NULL,
+	used_at timestamptz,
+	PRIMARY KEY (user_id, code_hash),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS login_challenges (
+	id uuid DEFAULT gen_random_uuid() NOT NULL PRIMARY KEY,
+	user_id uuid NOT NULL,
+	token_hash varchar NOT NULL UNIQUE,
+	attempts integer NOT NULL DEFAULT 0,
+	expires_at timestamptz NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_totp;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+-- Заказы и балансовые операции нужны для учёта, поэтому учётные записи
+-- обезличиваются, а удаление пользователя с финансовой историей запрещено.
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_user_created_fkey;
+ALTER TABLE orders ADD CONSTRAINT orders_user_created_fkey
+	FOREIGN KEY (user_created) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE operations DROP CONSTRAINT IF EXISTS operations_user_created_fkey;
+ALTER TABLE operations ADD CONSTRAINT operations_user_created_fkey
+	FOREIGN KEY (user_created) REFERENCES users(id) ON DELETE RESTRICT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE operations DROP CONSTRAINT IF EXISTS operations_user_created_fkey;
+ALTER TABLE operations ADD CONSTRAINT operations_user_created_fkey
+	FOREIGN KEY (user_created) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_user_created_fkey;
+ALTER TABLE orders ADD CONSTRAINT orders_user_created_fkey
+	FOREIGN KEY (user_created) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
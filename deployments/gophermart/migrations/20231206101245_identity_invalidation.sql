@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Уведомления позволяют экземплярам сервиса сбрасывать кешированные сеансы,
+-- отозванные или изменённые в других экземплярах.
+CREATE OR REPLACE FUNCTION notify_identity_invalidated() RETURNS trigger AS $$
+BEGIN
+	IF TG_TABLE_NAME = 'users' THEN
+		PERFORM pg_notify('identity_invalidated', 'user:' || OLD.id::text);
+	ELSE
+		PERFORM pg_notify('identity_invalidated', 'session:' || OLD.id::text);
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_identity_invalidated
+	AFTER UPDATE ON users
+	FOR EACH ROW
+	WHEN (
+		OLD.role IS DISTINCT FROM NEW.role
+		OR OLD.deleted_at IS DISTINCT FROM NEW.deleted_at
+		OR OLD.password_changed_at IS DISTINCT FROM NEW.password_changed_at
+	)
+	EXECUTE PROCEDURE notify_identity_invalidated();
+
+CREATE TRIGGER sessions_identity_invalidated
+	AFTER UPDATE ON sessions
+	FOR EACH ROW
+	WHEN (OLD.revoked_at IS NULL AND NEW.revoked_at IS NOT NULL)
+	EXECUTE PROCEDURE notify_identity_invalidated();
+
+CREATE TRIGGER sessions_identity_deleted
+	AFTER DELETE ON sessions
+	FOR EACH ROW
+	EXECUTE PROCEDURE notify_identity_invalidated();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS sessions_identity_deleted ON sessions;
+DROP TRIGGER IF EXISTS sessions_identity_invalidated ON sessions;
+DROP TRIGGER IF EXISTS users_identity_invalidated ON users;
+DROP FUNCTION IF EXISTS notify_identity_invalidated();
+-- +goose StatementEnd
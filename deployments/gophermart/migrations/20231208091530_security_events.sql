@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS security_events (
+	id bigserial PRIMARY KEY,
+	type varchar NOT NULL,
+	user_id uuid REFERENCES users(id) ON DELETE RESTRICT,
+	login varchar,
+	ip varchar,
+	user_agent varchar,
+	details varchar,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS security_events_user_id_idx ON security_events (user_id, id);
+CREATE INDEX IF NOT EXISTS security_events_created_at_idx ON security_events (created_at);
+
+-- Журнал только пополняется: изменение и удаление записей запрещены.
+CREATE OR REPLACE FUNCTION forbid_security_events_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'security_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER security_events_append_only
+	BEFORE UPDATE OR DELETE ON security_events
+	FOR EACH ROW
+	EXECUTE PROCEDURE forbid_security_events_change();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS security_events;
+DROP FUNCTION IF EXISTS forbid_security_events_change();
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Заказы, оставшиеся в очереди опроса accrual при остановке сервиса;
+-- восстанавливаются в очередь при следующем запуске.
+CREATE TABLE IF NOT EXISTS order_queue (
+	order_number varchar NOT NULL PRIMARY KEY,
+	request_id varchar NOT NULL DEFAULT '',
+	traceparent varchar NOT NULL DEFAULT '',
+	queued_at timestamptz NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS order_queue;
+-- +goose StatementEnd
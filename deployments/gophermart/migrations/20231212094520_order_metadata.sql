@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Необязательные сведения о покупке, переданные при загрузке заказа.
+ALTER TABLE orders
+	ADD COLUMN IF NOT EXISTS store varchar NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS purchased_at timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders
+	DROP COLUMN IF EXISTS purchased_at,
+	DROP COLUMN IF EXISTS store;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Неудачные коды второго фактора при подтверждении действий учитываются
+-- по пользователю, чтобы код нельзя было подобрать перебором.
+ALTER TABLE user_totp
+	ADD COLUMN IF NOT EXISTS code_attempts integer NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS code_blocked_until timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_totp
+	DROP COLUMN IF EXISTS code_blocked_until,
+	DROP COLUMN IF EXISTS code_attempts;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Журнал по-прежнему только пополняется, но при удалении учётной записи
+-- допускается обезличивание записей: логин, IP-адрес и User-Agent
+-- обнуляются, остальные поля изменять нельзя.
+CREATE OR REPLACE FUNCTION forbid_security_events_change() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'UPDATE'
+		AND NEW.id = OLD.id
+		AND NEW.type = OLD.type
+		AND NEW.user_id IS NOT DISTINCT FROM OLD.user_id
+		AND NEW.details IS NOT DISTINCT FROM OLD.details
+		AND NEW.created_at = OLD.created_at
+		AND NEW.login IS NULL
+		AND NEW.ip IS NULL
+		AND NEW.user_agent IS NULL
+	THEN
+		RETURN NEW;
+	END IF;
+
+	RAISE EXCEPTION 'security_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION forbid_security_events_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'security_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
@@ -40,14 +40,41 @@ func Up(ctx context.Context, db *sql.DB) error {
 	return nil
 }
 
-// Down откатывает миграцию в БД.
+// Down откатывает все миграции в БД.
 func Down(ctx context.Context, db *sql.DB) error {
 	err := lazyInit()
 	if err != nil {
 		return fmt.Errorf("initializing the migrator: %w", err)
 	}
-	if err := goose.DownContext(ctx, db, "."); err != nil {
+	if err := goose.DownToContext(ctx, db, ".", 0); err != nil {
 		return fmt.Errorf("migration down: %w", err)
 	}
 	return nil
 }
+
+// Check возвращает ошибку, если версия схемы БД не совпадает с версией
+// последней встроенной миграции.
+func Check(ctx context.Context, db *sql.DB) error {
+	err := lazyInit()
+	if err != nil {
+		return fmt.Errorf("initializing the migrator: %w", err)
+	}
+	migrations, err := goose.CollectMigrations(".", 0, goose.MaxVersion)
+	if err != nil {
+		return fmt.Errorf("collecting migrations: %w", err)
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return fmt.Errorf("last migration: %w", err)
+	}
+
+	current, err := goose.GetDBVersionContext(ctx, db)
+	if err != nil {
+		return fmt.Errorf("getting the database version: %w", err)
+	}
+	if current != last.Version {
+		return fmt.Errorf("database version %d, expected %d", current, last.Version)
+	}
+
+	return nil
+}
@@ -5,13 +5,20 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 	
 	"github.com/sergeizaitcev/gophermart/internal/accrual/config"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/server"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/service"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/storage/postgres"
 	"github.com/sergeizaitcev/gophermart/pkg/commands"
+	"github.com/sergeizaitcev/gophermart/pkg/health"
 	"github.com/sergeizaitcev/gophermart/pkg/httpserver"
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+	"github.com/sergeizaitcev/gophermart/pkg/tlsutil"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 
@@ -21,6 +28,16 @@ func Run(ctx context.Context) error {
 }
 
 func runAccrual(ctx context.Context, c *config.Config) error {
+	tracer, err := tracing.Setup("accrual", c.TraceExporter, c.TraceEndpoint)
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = tracer.Shutdown(ctx)
+	}()
+
 	storage, err := postgres.Connect(c)
 	if err != nil {
 		return fmt.Errorf("create a new storage: %w", err)
@@ -34,10 +51,63 @@ func runAccrual(ctx context.Context, c *config.Config) error {
 
 	logger := newLogger(c)
 
+	metrics.MustRegister(metrics.NewDBStats(storage.Stats)...)
+
+	probe := health.NewProbe(0)
+	probe.Register("database", storage.Ping)
+	probe.Register("migrations", storage.CheckMigrations)
+
+	// Общий бюджет остановки отсчитывается с начала остановки HTTP-сервера:
+	// сначала завершаются запросы, затем в оставшееся время — обработка
+	// принятых заказов.
+	var stopping time.Time
+
 	service := service.NewService(storage)
-	handler := server.NewHandler(logger, service)
+	defer func() {
+		if stopping.IsZero() {
+			stopping = time.Now()
+		}
+
+		ctx, cancel := context.WithDeadline(context.Background(), stopping.Add(c.ShutdownTimeout))
+		defer cancel()
+
+		if err := service.Close(ctx); err != nil {
+			logger.Error(err.Error(), slog.String("scope", "stopping order processing"))
+		}
+	}()
+
+	handler := server.NewHandler(logger, service, probe)
+
+	tlsConfig, err := tlsutil.LoadServerConfig(c.TLSCertFile, c.TLSKeyFile, c.TLSClientCAFile)
+	if err != nil {
+		return fmt.Errorf("loading the TLS configuration: %w", err)
+	}
+
+	// Проверки состояния доступны без сертификата клиента, чтобы их могли
+	// выполнять оркестратор и балансировщик
+	if c.TLSClientCAFile != "" {
+		handler = tlsutil.RequireClientCert(handler, "/healthz", "/readyz")
+	}
+
+	srv := httpserver.New(handler)
+	srv.TLSConfig = tlsutil.OptionalClientCert(tlsConfig)
+	srv.ShutdownTimeout = c.ShutdownTimeout
+	srv.RegisterOnShutdown(func() { stopping = time.Now() })
+	srv.RegisterOnShutdown(probe.Drain)
+
+	// Сервер метрик работает, пока работает основной сервер, и наоборот
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return srv.ListenAndServe(gctx, c.RunAddress)
+	})
+
+	if c.MetricsAddress != "" {
+		g.Go(func() error {
+			return httpserver.ListenAndServe(gctx, c.MetricsAddress, metrics.ServeMux())
+		})
+	}
 
-	return httpserver.ListenAndServe(ctx, c.RunAddress, handler)
+	return g.Wait()
 }
 
 func newLogger(c *config.Config) *slog.Logger {
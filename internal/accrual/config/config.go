@@ -3,7 +3,11 @@ package config
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
+	"time"
+
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 type Config struct {
@@ -13,8 +17,33 @@ type Config struct {
 	// Адрес запуска сервера
 	RunAddress string `env:"RUN_ADDRESS"`
 
+	// Адрес внутреннего сервера метрик Prometheus (/metrics); если не задан,
+	// то метрики не публикуются. Сервер не использует TLS и авторизацию,
+	// поэтому не должен быть доступен извне
+	MetricsAddress string `env:"METRICS_ADDRESS"`
+
 	// Строка подключения к БД
 	DatabaseURI string `env:"DATABASE_URI"`
+
+	// Экспортёр трассировки: none, stdout, file или otlp
+	TraceExporter string `env:"TRACE_EXPORTER"`
+
+	// Адрес OTLP/HTTP для экспортёра otlp или путь к файлу для экспортёра file
+	TraceEndpoint string `env:"TRACE_ENDPOINT"`
+
+	// Пути к сертификату и закрытому ключу сервера в формате PEM; если
+	// заданы, то сервер принимает только HTTPS-соединения
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+
+	// Путь к корневым сертификатам, которыми должны быть подписаны
+	// сертификаты клиентов, например, gophermart; /healthz и /readyz
+	// доступны без сертификата
+	TLSClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+
+	// Общее время на остановку сервиса: завершение HTTP-запросов, затем
+	// обработку принятых заказов
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT"`
 }
 
 // Validate возвращает ошибку, если одно из полей конфигурации не валидно
@@ -25,11 +54,36 @@ func (c *Config) Validate() error {
 	if c.DatabaseURI == "" {
 		return errors.New("the database uri must not be empty")
 	}
+	switch c.TraceExporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterFile, tracing.ExporterOTLP:
+		if c.TraceEndpoint == "" {
+			return fmt.Errorf("the trace endpoint must not be empty for the %s exporter", c.TraceExporter)
+		}
+	default:
+		return fmt.Errorf("unsupported trace exporter: %q", c.TraceExporter)
+	}
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("the shutdown timeout must be greater than zero")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("the TLS certificate and private key must be set together")
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		return errors.New("the client certificate verification requires a TLS certificate")
+	}
 	return nil
 }
 
 func (c *Config) SetFlags(fs *flag.FlagSet) {
 	fs.StringVar(&c.RunAddress, "a", "", "run address")
+	fs.StringVar(&c.MetricsAddress, "metrics-address", "localhost:9091", "internal metrics server address")
 	fs.StringVar(&c.DatabaseURI, "d", "", "database uri")
 	fs.TextVar(&c.Level, "v", slog.LevelInfo, "logging level")
+	fs.StringVar(&c.TraceExporter, "trace-exporter", tracing.ExporterNone, "trace exporter: none, stdout, file or otlp")
+	fs.StringVar(&c.TraceEndpoint, "trace-endpoint", "", "OTLP endpoint or trace file path")
+	fs.StringVar(&c.TLSCertFile, "tls-cert", "", "TLS certificate path")
+	fs.StringVar(&c.TLSKeyFile, "tls-key", "", "TLS private key path")
+	fs.StringVar(&c.TLSClientCAFile, "tls-client-ca", "", "client certificates CA path")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "graceful shutdown budget")
 }
\ No newline at end of file
@@ -10,26 +10,56 @@ import (
 	"github.com/sergeizaitcev/gophermart/internal/accrual/models"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/service"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/storage"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
 	"github.com/sergeizaitcev/gophermart/pkg/luhn"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
 	"github.com/sergeizaitcev/gophermart/pkg/strutil"
 )
 
+// Ошибки разбора запросов
+var (
+	errMalformedBody     = errors.New("malformed request body")
+	errOrderEmpty        = errors.New("order is empty")
+	errOrderNotDigits    = errors.New("order number must contain only digits")
+	errOrderChecksum     = errors.New("order number checksum is invalid")
+	errOrderNoGoods      = errors.New("order doesnt contain goods")
+	errMatchEmpty        = errors.New("match is empty")
+	errRewardTypeInvalid = errors.New("reward type invalid")
+)
+
+// problemMappings определяет стабильные коды ошибок API
+var problemMappings = []problem.Mapping{
+	{Err: errMalformedBody, Code: "malformed_body", Detail: "request body is malformed"},
+	{Err: errOrderEmpty, Code: "order_number_required", Detail: "order number is required"},
+	{Err: errOrderNotDigits, Code: "order_number_not_digits", Detail: "order number must contain only digits"},
+	{Err: errOrderChecksum, Code: "order_number_invalid_checksum", Detail: "order number checksum is invalid"},
+	{Err: errOrderNoGoods, Code: "order_goods_required", Detail: "order must contain goods"},
+	{Err: errMatchEmpty, Code: "match_required", Detail: "match name and reward type are required"},
+	{Err: errRewardTypeInvalid, Code: "reward_type_invalid", Detail: "reward type must be % or pt"},
+	{Err: service.ErrOrderRegistered, Code: "order_already_registered", Detail: "order is already registered"},
+	{Err: storage.ErrDuplicate, Code: "already_exists", Detail: "resource already exists"},
+	{Err: storage.ErrNotFound, Code: "not_found", Detail: "resource not found"},
+}
+
 // parseOrder парсит запрос на регистрацию заказа и валидирует его
 func parseOrder(r io.Reader) (models.Order, error) {
 	var o models.Order
 
 	err := json.NewDecoder(r).Decode(&o)
 	if err != nil {
-		return models.Order{}, fmt.Errorf("decoding the order: %w", err)
+		return models.Order{}, fmt.Errorf("%w: decoding the order: %s", errMalformedBody, err)
 	}
 	if o.Number == "" {
-		return models.Order{}, errors.New("order is empty")
+		return models.Order{}, errOrderEmpty
 	}
-	if !strutil.OnlyDigits(o.Number) || !luhn.Check(o.Number) {
-		return models.Order{}, errors.New("order number invalid")
+	if !strutil.OnlyDigits(o.Number) {
+		return models.Order{}, errOrderNotDigits
+	}
+	if !luhn.Check(o.Number) {
+		return models.Order{}, errOrderChecksum
 	}
 	if len(o.Goods) == 0 {
-		return models.Order{}, errors.New("order doesnt contain goods")
+		return models.Order{}, errOrderNoGoods
 	}
 	return o, nil
 }
@@ -40,32 +70,34 @@ func parseMatch(r io.Reader) (models.Match, error) {
 
 	err := json.NewDecoder(r).Decode(&m)
 	if err != nil {
-		return models.Match{}, fmt.Errorf("decoding the match: %w", err)
+		return models.Match{}, fmt.Errorf("%w: decoding the match: %s", errMalformedBody, err)
 	}
 	if m.MatchName == "" && m.RewardType == "" {
-		return models.Match{}, fmt.Errorf("match is empty")
+		return models.Match{}, errMatchEmpty
 	}
 	if m.RewardType != "%" && m.RewardType != "pt" {
-		return models.Match{}, fmt.Errorf("reward type invalid")
+		return models.Match{}, errRewardTypeInvalid
 	}
 	return m, nil
 }
 
-// mapErrorToResponse маппит ошибку на соответствующий код ответа
-func mapErrorToResponse(w http.ResponseWriter, err error) {
-	if errors.Is(err, storage.ErrDuplicate) || errors.Is(err, service.ErrOrderRegistered) {
-		w.WriteHeader(http.StatusConflict)
-		return
-	}
-	if errors.Is(err, storage.ErrNotFound) {
-		w.WriteHeader(http.StatusNotFound)
-		return
+// mapErrorToResponse маппит ошибку на соответствующий код ответа и
+// возвращает её в формате RFC 7807
+func mapErrorToResponse(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrDuplicate), errors.Is(err, service.ErrOrderRegistered):
+		status = http.StatusConflict
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
 	}
-	if errors.Is(err, storage.ErrOther) {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+	writeProblem(w, r, status, err)
+}
+
+// writeProblem возвращает ответ об ошибке в формате RFC 7807
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, err error) {
+	werr := problem.FromError(status, err, problemMappings).Write(w, r)
+	if werr != nil {
+		logging.FromContext(r.Context()).Error(werr.Error())
 	}
 }
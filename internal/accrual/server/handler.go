@@ -11,6 +11,12 @@ import (
 
 	"github.com/sergeizaitcev/gophermart/internal/accrual/service"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/storage"
+	"github.com/sergeizaitcev/gophermart/pkg/compress"
+	"github.com/sergeizaitcev/gophermart/pkg/health"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+	"github.com/sergeizaitcev/gophermart/pkg/openapi"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 // handler определяет HTTP-обработчик для accrual
@@ -19,14 +25,20 @@ type handler struct {
 	logger  *slog.Logger
 	mux     *chi.Mux
 	service *service.Service
+	probe   *health.Probe
 }
 
-// NewHandler возвращает новый экземпляр handler
-func NewHandler(logger *slog.Logger, s *service.Service) http.Handler {
+// NewHandler возвращает новый экземпляр handler; probe определяет проверки
+// готовности для /readyz и может быть nil
+func NewHandler(logger *slog.Logger, s *service.Service, probe *health.Probe) http.Handler {
+	if probe == nil {
+		probe = health.NewProbe(0)
+	}
 	r := &handler{
 		logger:  logger,
 		mux:     chi.NewRouter(),
 		service: s,
+		probe:   probe,
 	}
 	r.init()
 	return r
@@ -37,8 +49,20 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handler) init() {
+	h.mux.Use(metrics.Middleware)
+	h.mux.Use(tracing.Middleware)
+	h.mux.Use(logging.Middleware(h.logger))
+	h.mux.Use(compress.Gzip(nil))
+
+	h.mux.Method(http.MethodGet, "/healthz", h.probe.Healthz())
+	h.mux.Method(http.MethodGet, "/readyz", h.probe.Readyz())
+
 	h.mux.Route("/api", func(r chi.Router) {
+		r.Method(http.MethodGet, "/openapi.json", spec)
+
 		r.Group(func(r chi.Router) {
+			r.Use(openapi.Validate(spec))
+
 			r.Post("/orders", h.registerOrder)
 			r.Post("/goods", h.createMatch)
 			r.Get("/orders/{number}", h.getOrder)
@@ -49,7 +73,7 @@ func (h *handler) init() {
 func (h *handler) registerOrder(w http.ResponseWriter, r *http.Request) {
 	o, err := parseOrder(r.Body)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, err)
 		return
 	}
 
@@ -57,18 +81,18 @@ func (h *handler) registerOrder(w http.ResponseWriter, r *http.Request) {
 
 	err = h.service.CheckOrder(ctx, o.Number)
 	if err != nil {
-		mapErrorToResponse(w, err)
+		mapErrorToResponse(w, r, err)
 		return
 	}
 
-	go h.service.CreateOrder(&o)
+	h.service.CreateOrder(ctx, &o)
 	w.WriteHeader(http.StatusAccepted)
 }
 
 func (h *handler) createMatch(w http.ResponseWriter, r *http.Request) {
 	m, err := parseMatch(r.Body)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, err)
 		return
 	}
 
@@ -77,14 +101,14 @@ func (h *handler) createMatch(w http.ResponseWriter, r *http.Request) {
 	err = h.service.CheckMatch(ctx, m.MatchName)
 	if err != nil {
 		if !errors.Is(err, storage.ErrNotFound) {
-			mapErrorToResponse(w, err)
+			mapErrorToResponse(w, r, err)
 			return
 		}
 	}
 
 	err = h.service.CreateMatch(ctx, &m)
 	if err != nil {
-		mapErrorToResponse(w, err)
+		mapErrorToResponse(w, r, err)
 		return
 	}
 
@@ -94,7 +118,7 @@ func (h *handler) createMatch(w http.ResponseWriter, r *http.Request) {
 func (h *handler) getOrder(w http.ResponseWriter, r *http.Request) {
 	orderNumber := chi.URLParam(r, "number")
 	if orderNumber == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, errOrderEmpty)
 		return
 	}
 
@@ -102,7 +126,7 @@ func (h *handler) getOrder(w http.ResponseWriter, r *http.Request) {
 
 	order, err := h.service.GetOrder(ctx, orderNumber)
 	if err != nil {
-		mapErrorToResponse(w, err)
+		mapErrorToResponse(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
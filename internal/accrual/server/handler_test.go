@@ -2,6 +2,7 @@ package server_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -22,6 +23,8 @@ import (
 	"github.com/sergeizaitcev/gophermart/internal/accrual/service"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/storage"
 	mockStorage "github.com/sergeizaitcev/gophermart/internal/accrual/storage/mocks"
+	"github.com/sergeizaitcev/gophermart/pkg/health"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
 )
 
 const (
@@ -41,7 +44,7 @@ func testInitHandle(t *testing.T) (http.Handler, *mockStorage.MockStorage) {
 
 	s := mockStorage.NewMockStorage(c)
 	srv := service.NewService(s)
-	handler := server.NewHandler(testLogger, srv)
+	handler := server.NewHandler(testLogger, srv, nil)
 
 	return handler, s
 }
@@ -218,6 +221,7 @@ func TestRegisterOrder(t *testing.T) {
 		time.Sleep(time.Second)
 
 		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "already_exists", problemCode(t, w))
 	})
 
 	t.Run("internal", func(t *testing.T) {
@@ -250,5 +254,56 @@ func TestRegisterOrder(t *testing.T) {
 		time.Sleep(time.Second)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "order_goods_required", problemCode(t, w))
 	})
+
+	t.Run("invalidChecksum", func(t *testing.T) {
+		h, _ := testInitHandle(t)
+
+		r := httptest.NewRequest(
+			http.MethodPost,
+			trgURL,
+			strings.NewReader(`{"order": "1234567812345671", "goods": [{"description": "item1", "price": 100}]}`),
+		)
+		w := httptest.NewRecorder()
+
+		h.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "order_number_invalid_checksum", problemCode(t, w))
+	})
+}
+
+func TestHealth(t *testing.T) {
+	probe := health.NewProbe(time.Second)
+	probe.Register("database", func(context.Context) error { return nil })
+
+	h := server.NewHandler(slog.Default(), nil, probe)
+
+	do := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, do("/healthz").Code)
+	assert.Equal(t, http.StatusOK, do("/readyz").Code)
+
+	probe.Drain()
+
+	assert.Equal(t, http.StatusOK, do("/healthz").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, do("/readyz").Code)
+}
+
+// problemCode возвращает стабильный код ошибки из ответа в формате RFC 7807
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+
+	var p problem.Problem
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&p))
+	assert.Equal(t, w.Code, p.Status)
+
+	return p.Code
 }
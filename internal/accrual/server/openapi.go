@@ -0,0 +1,13 @@
+package server
+
+import (
+	_ "embed"
+
+	"github.com/sergeizaitcev/gophermart/pkg/openapi"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+// spec определяет спецификацию API accrual, по которой проверяются запросы
+var spec = openapi.MustParse(specJSON)
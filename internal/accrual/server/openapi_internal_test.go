@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSpecDrift проверяет, что маршруты, зарегистрированные в init,
+// совпадают с операциями спецификации
+func TestSpecDrift(t *testing.T) {
+	h := NewHandler(nil, nil, nil).(*handler)
+
+	var registered []string
+	err := chi.Walk(h.mux, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered = append(registered, method+" "+route)
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(registered)
+
+	var documented []string
+	for _, r := range spec.Routes() {
+		documented = append(documented, r.String())
+	}
+	sort.Strings(documented)
+
+	require.Equal(t, documented, registered)
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+)
+
+// Результаты обработки заказа в метриках
+const (
+	outcomeProcessed = "processed"
+	outcomeInvalid   = "invalid"
+	outcomeFailed    = "failed"
+)
+
+var orderProcessingDuration = metrics.NewHistogramVec(
+	"accrual_order_processing_duration_seconds",
+	"Длительность обработки заказа от регистрации до расчёта вознаграждения.",
+	[]float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	"outcome",
+)
+
+func init() {
+	metrics.MustRegister(orderProcessingDuration)
+}
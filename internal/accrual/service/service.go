@@ -4,22 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
-
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/sergeizaitcev/gophermart/internal/accrual/models"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/storage"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
 	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 // Service определяет бизнес-логику accrual
 type Service struct {
-	storage storage.Storage
-	termCh  chan struct{}
-	wg      *sync.WaitGroup
+	storage  storage.Storage
+	termCh   chan struct{}
+	termOnce sync.Once
+	wg       *sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
 }
 
 // NewService возвращает экземпляр Service
@@ -31,13 +37,36 @@ func NewService(s storage.Storage) *Service {
 	}
 }
 
-func (s *Service) Close() {
-	close(s.termCh)
-	s.wg.Wait()
+// Close дожидается обработки принятых заказов; новые заказы после вызова
+// не обрабатываются. Если ctx завершится раньше, то обработка отменяется
+// и возвращается ошибка ctx.
+func (s *Service) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.termOnce.Do(func() { close(s.termCh) })
+	<-done
+
+	return err
 }
 
-func (s *Service) withCancel() (context.Context, context.CancelFunc) {
-	ctx, cancel := context.WithCancel(context.Background())
+func (s *Service) withCancel(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
 	go func() {
 		select {
 		case <-ctx.Done():
@@ -52,7 +81,7 @@ func (s *Service) withCancel() (context.Context, context.CancelFunc) {
 func (s *Service) GetOrder(ctx context.Context, orderNumber string) (*models.OrderOut, error) {
 	order, err := s.storage.GetOrderByNumber(ctx, orderNumber)
 	if err != nil {
-		slog.Error(fmt.Errorf("get order by number %s err: %w", orderNumber, err).Error())
+		logging.FromContext(ctx).Error(fmt.Errorf("get order by number %s err: %w", orderNumber, err).Error())
 		return &models.OrderOut{}, err
 	}
 	return &models.OrderOut{
@@ -83,7 +112,7 @@ func (s *Service) CreateMatch(ctx context.Context, match *models.Match) error {
 		},
 	)
 	if err != nil {
-		slog.Error(fmt.Errorf("create match %s err: %w", match.MatchName, err).Error())
+		logging.FromContext(ctx).Error(fmt.Errorf("create match %s err: %w", match.MatchName, err).Error())
 		return err
 	}
 	return nil
@@ -104,11 +133,43 @@ func (s *Service) CheckOrder(ctx context.Context, orderNumber string) error {
 	return nil
 }
 
-// CreateOrder создает заказ с его товарами и отправляет в очередь
-func (s *Service) CreateOrder(order *models.Order) {
-	ctx, cancel := s.withCancel()
+// CreateOrder принимает заказ в фоновую обработку: создаёт его с товарами
+// и рассчитывает начисление. Обработка не зависит от отмены ctx, но
+// наследует его значения, включая логгер и участок трассы запроса; после
+// Close заказы не принимаются.
+func (s *Service) CreateOrder(ctx context.Context, order *models.Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		logging.FromContext(ctx).Warn("the order was not accepted: the service is stopping",
+			slog.String("order", order.Number))
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.createOrder(ctx, order)
+	}()
+}
+
+func (s *Service) createOrder(ctx context.Context, order *models.Order) {
+	ctx, cancel := s.withCancel(context.WithoutCancel(ctx))
 	defer cancel()
 
+	ctx, span := tracing.Start(ctx, "accrual.processOrder", tracing.KindInternal,
+		tracing.String("order.number", order.Number),
+	)
+
+	start := time.Now()
+	outcome := outcomeProcessed
+	defer func() {
+		orderProcessingDuration.With(outcome).Observe(time.Since(start).Seconds())
+		span.SetAttributes(tracing.String("outcome", outcome))
+		span.End()
+	}()
+
 	matchNames := make([]string, len(order.Goods))
 
 	for i, good := range order.Goods {
@@ -117,13 +178,16 @@ func (s *Service) CreateOrder(order *models.Order) {
 	// Проверяем наличие в БД указанного в заказе match и получаем его ID
 	matches, err := s.storage.GetMatchesByNames(ctx, matchNames)
 	if err != nil {
-		slog.Error(err.Error())
+		logging.FromContext(ctx).Error(err.Error())
 	}
 
 	if len(matches) == 0 {
+		outcome = outcomeInvalid
 		err := s.storage.CreateInvalidOrder(ctx, order.Number)
 		if err != nil {
-			slog.Error(err.Error())
+			outcome = outcomeFailed
+			span.RecordError(err)
+			logging.FromContext(ctx).Error(err.Error())
 			return
 		}
 		return
@@ -151,7 +215,9 @@ func (s *Service) CreateOrder(order *models.Order) {
 
 	orderID, err := s.storage.CreateOrderWithGoods(ctx, order.Number, goods)
 	if err != nil {
-		slog.Error(err.Error())
+		outcome = outcomeFailed
+		span.RecordError(err)
+		logging.FromContext(ctx).Error(err.Error())
 	}
 
 	workOrder := workerOrder{orderID: orderID, goods: workGoods}
@@ -179,7 +245,7 @@ func (s *Service) processing(ctx context.Context, workOrder *workerOrder) {
 		// в бд обновляются рассчитыванные goods в заказе
 		err := s.storage.BatchUpdateGoods(ctx, order.orderID, batchGoods)
 		if err != nil {
-			slog.Error(fmt.Errorf("batch updated goods err: %w", err).Error())
+			logging.FromContext(ctx).Error(fmt.Errorf("batch updated goods err: %w", err).Error())
 		}
 
 		// в бд обновляем общий accrual по заказу и обновляем статус на processed
@@ -191,7 +257,7 @@ func (s *Service) processing(ctx context.Context, workOrder *workerOrder) {
 func (s *Service) updateOrderProcessing(ctx context.Context, orderID uuid.UUID) {
 	err := s.storage.UpdateOrder(ctx, &storage.Order{OrderID: orderID, Status: 2, Accrual: 0})
 	if err != nil {
-		slog.Error(fmt.Errorf("update order status processing err: %w", err).Error())
+		logging.FromContext(ctx).Error(fmt.Errorf("update order status processing err: %w", err).Error())
 	}
 }
 
@@ -202,7 +268,7 @@ func (s *Service) updateOrderProcessed(ctx context.Context, order *workerOrder)
 		Accrual: order.accrual,
 	})
 	if err != nil {
-		slog.Error(fmt.Errorf("update order status processed err: %w", err).Error())
+		logging.FromContext(ctx).Error(fmt.Errorf("update order status processed err: %w", err).Error())
 	}
 }
 
@@ -3,6 +3,7 @@ package service_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
@@ -190,3 +191,73 @@ func TestCheckOrder(t *testing.T) {
 		})
 	})
 }
+
+func TestClose(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStorage := mock_storage.NewMockStorage(ctrl)
+
+	srv := service.NewService(mockStorage)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	mockStorage.EXPECT().
+		GetMatchesByNames(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(context.Context, []string) (map[string]*storage.MatchOut, error) {
+			close(started)
+			<-release
+			return nil, nil
+		})
+	mockStorage.EXPECT().
+		CreateInvalidOrder(gomock.Any(), tOrderNum).
+		DoAndReturn(func(ctx context.Context, _ string) error {
+			// Обработка, начатая до остановки, не отменяется.
+			return ctx.Err()
+		})
+
+	srv.CreateOrder(context.Background(), &models.Order{Number: tOrderNum})
+	<-started
+
+	closed := make(chan error)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		closed <- srv.Close(ctx)
+	}()
+
+	close(release)
+	assert.NoError(t, <-closed)
+
+	// После остановки заказы не принимаются: обращений к хранилищу нет.
+	srv.CreateOrder(context.Background(), &models.Order{Number: tOrderNum})
+	ctrl.Finish()
+}
+
+func TestClose_Timeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockStorage := mock_storage.NewMockStorage(ctrl)
+
+	srv := service.NewService(mockStorage)
+
+	started := make(chan struct{})
+
+	mockStorage.EXPECT().
+		GetMatchesByNames(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ []string) (map[string]*storage.MatchOut, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	mockStorage.EXPECT().
+		CreateInvalidOrder(gomock.Any(), tOrderNum).
+		Return(context.Canceled)
+
+	srv.CreateOrder(context.Background(), &models.Order{Number: tOrderNum})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, srv.Close(ctx), context.DeadlineExceeded)
+	ctrl.Finish()
+}
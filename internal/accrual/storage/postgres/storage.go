@@ -8,6 +8,7 @@ import (
 	"github.com/sergeizaitcev/gophermart/deployments/accrual/migrations"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/config"
 	"github.com/sergeizaitcev/gophermart/internal/accrual/storage"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 var _ storage.Storage = (*Storage)(nil)
@@ -27,7 +28,7 @@ func Connect(c *config.Config) (*Storage, error) {
 }
 
 func connect(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("pgx", dsn)
+	db, err := tracing.OpenDB("pgx", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("create a new connection: %w", err)
 	}
@@ -52,6 +53,21 @@ func (s *Storage) Down(ctx context.Context) error {
 	return migrations.Down(ctx, s.db)
 }
 
+// Ping проверяет соединение с БД.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Stats возвращает статистику пула соединений с БД.
+func (s *Storage) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+// CheckMigrations проверяет, что схема БД соответствует последней миграции.
+func (s *Storage) CheckMigrations(ctx context.Context) error {
+	return migrations.Check(ctx, s.db)
+}
+
 func (s *Storage) transaction(
 	ctx context.Context,
 	fn func(*sql.Tx) error,
@@ -1,6 +1,7 @@
 package accrual
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -8,14 +9,19 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"path"
+	"strconv"
 	"time"
 
 	"log/slog"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/compress"
 	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
 	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 var defaultOption = &ClientOption{
@@ -27,7 +33,7 @@ var defaultOption = &ClientOption{
 
 // ClientOption определяет не обязательные параметры для Client.
 type ClientOption struct {
-	// Логирование ошибок.
+	// Логирование ошибок; логгер из контекста запроса имеет приоритет.
 	Logger *slog.Logger
 
 	// Время ожидания ответа от сервера.
@@ -50,6 +56,11 @@ type ClientOption struct {
 	//
 	// По умолчанию false.
 	Secure bool
+
+	// Индикатор запроса ответов, сжатых gzip.
+	//
+	// По умолчанию false.
+	Compression bool
 }
 
 func (o *ClientOption) clone() *ClientOption {
@@ -112,9 +123,15 @@ func (c *Client) GetAccrualInfo(
 		return domain.AccrualInfo{}, prepareError(res)
 	}
 
+	body, err := responseBody(res)
+	if err != nil {
+		return domain.AccrualInfo{}, fmt.Errorf("decompressing a response body: %w", err)
+	}
+	defer body.Close()
+
 	var data accrualData
 
-	err = json.NewDecoder(res.Body).Decode(&data)
+	err = json.NewDecoder(body).Decode(&data)
 	if err != nil {
 		return domain.AccrualInfo{}, fmt.Errorf("reading a response body: %w", err)
 	}
@@ -122,6 +139,34 @@ func (c *Client) GetAccrualInfo(
 	return toAccrualInfo(data)
 }
 
+// Ping проверяет, что accrual принимает TCP-соединения. Запрос к API
+// не выполняется, чтобы проверка не расходовала ограничение частоты
+// запросов к accrual.
+func (c *Client) Ping(ctx context.Context) error {
+	u, err := url.Parse(c.addr)
+	if err != nil {
+		return fmt.Errorf("parsing the accrual address: %w", err)
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" || c.opts.Secure {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return fmt.Errorf("connecting to the accrual: %w", err)
+	}
+
+	return conn.Close()
+}
+
 type accrualData struct {
 	Order   string        `json:"order"`
 	Status  string        `json:"status"`
@@ -150,6 +195,18 @@ func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
 		return nil, fmt.Errorf("creatign a new request: %w", err)
 	}
 
+	// Идентификатор исходного запроса передаётся в accrual для сквозного
+	// журналирования.
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+
+	// Явный Accept-Encoding отключает прозрачную распаковку в
+	// http.Transport, поэтому ответ распаковывается в responseBody.
+	if c.opts.Compression {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
 	res, err := c.sendRequest(req)
 	if err != nil {
 		return nil, fmt.Errorf("sending a request: %w", err)
@@ -158,23 +215,68 @@ func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
 	return res, nil
 }
 
+// responseBody возвращает тело ответа, распакованное, если сервер сжал его
+// gzip.
+func responseBody(res *http.Response) (io.ReadCloser, error) {
+	if !compress.IsGzip(res.Header.Get("Content-Encoding")) {
+		return io.NopCloser(res.Body), nil
+	}
+	return gzip.NewReader(res.Body)
+}
+
 func (c *Client) sendRequest(req *http.Request) (*http.Response, error) {
 	ctx := req.Context()
 	n := c.opts.Retry
 
 	for n > 0 {
+		start := time.Now()
+
+		// Каждая попытка — отдельный участок, родитель участков системы
+		// начисления.
+		spanCtx, span := tracing.Start(ctx, req.Method+" accrual", tracing.KindClient,
+			tracing.String("http.request.method", req.Method),
+			tracing.String("url.full", req.URL.String()),
+			tracing.Int("http.request.resend_count", c.opts.Retry-n),
+		)
+		tracing.Inject(spanCtx, req.Header)
+
 		res, err := c.client.Do(req)
 		if err == nil {
+			span.SetAttributes(tracing.Int("http.response.status_code", res.StatusCode))
+			if res.StatusCode >= http.StatusInternalServerError {
+				span.RecordError(fmt.Errorf("unexpected status: %s", res.Status))
+			}
+			span.End()
+
+			requestDuration.With(strconv.Itoa(res.StatusCode)).Observe(time.Since(start).Seconds())
+			if res.StatusCode == http.StatusTooManyRequests {
+				rateLimited.With().Inc()
+			}
 			return res, nil
 		}
 
+		span.RecordError(err)
+		span.End()
+
+		requestDuration.With("error").Observe(time.Since(start).Seconds())
+
 		ne, ok := err.(net.Error)
 		if errors.Is(err, io.EOF) || (ok && ne.Timeout()) {
+			logging.FromContextOr(ctx, c.opts.Logger).Warn(
+				err.Error(),
+				slog.String("scope", "accrual request"),
+				slog.String("url", req.URL.String()),
+				slog.Int("attempts_left", n-1),
+			)
+
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
 			case <-time.After(c.opts.Backoff):
 				n--
+				if n > 0 {
+					requestRetries.With().Inc()
+				}
 				continue
 			}
 		}
@@ -2,18 +2,25 @@ package accrual_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/clients/accrual"
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 type TransportMock struct {
@@ -118,3 +125,114 @@ func (suite *ClientSuite) TestInternalServerError() {
 	_, err := suite.client.GetAccrualInfo(context.Background(), "4")
 	suite.ErrorIs(err, domain.ErrInternalServerError)
 }
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestClient_Compression(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(`{"order":"49927398716","status":"PROCESSED","accrual":500}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		require.Equal(t, "gzip", req.Header.Get("Accept-Encoding"))
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Encoding": []string{"gzip"}},
+			Body:       io.NopCloser(bytes.NewReader(buf.Bytes())),
+			Request:    req,
+		}, nil
+	})
+
+	client := accrual.NewClient("//localhost", &accrual.ClientOption{
+		Transport:   transport,
+		Compression: true,
+	})
+
+	info, err := client.GetAccrualInfo(context.Background(), "49927398716")
+	require.NoError(t, err)
+	require.EqualValues(t, "49927398716", info.OrderNumber)
+	require.Equal(t, 500.0, info.Accrual.Float64())
+}
+
+func TestClient_RequestID(t *testing.T) {
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		require.Equal(t, "request-1", req.Header.Get(logging.RequestIDHeader))
+
+		return &http.Response{
+			StatusCode: http.StatusNoContent,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	})
+
+	client := accrual.NewClient("//localhost", &accrual.ClientOption{Transport: transport})
+
+	ctx := logging.WithRequestID(context.Background(), "request-1")
+
+	_, err := client.GetAccrualInfo(ctx, "49927398716")
+	require.ErrorIs(t, err, domain.ErrOrderNotRegistered)
+}
+
+func TestClient_Traceparent(t *testing.T) {
+	ctx, span := tracing.Start(context.Background(), "upload", tracing.KindServer)
+	defer span.End()
+
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sc, err := tracing.ParseTraceparent(req.Header.Get(tracing.TraceparentHeader))
+		require.NoError(t, err)
+		require.Equal(t, span.SpanContext().TraceID, sc.TraceID)
+		require.NotEqual(t, span.SpanContext().SpanID, sc.SpanID)
+
+		return &http.Response{
+			StatusCode: http.StatusNoContent,
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	})
+
+	client := accrual.NewClient("//localhost", &accrual.ClientOption{Transport: transport})
+
+	_, err := client.GetAccrualInfo(ctx, "49927398716")
+	require.ErrorIs(t, err, domain.ErrOrderNotRegistered)
+}
+
+func TestClient_Ping(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+
+	client := accrual.NewClient(srv.URL, nil)
+	require.NoError(t, client.Ping(context.Background()))
+
+	srv.Close()
+	require.Error(t, client.Ping(context.Background()))
+}
+
+func TestClient_Metrics(t *testing.T) {
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"60"}},
+			Body:       io.NopCloser(strings.NewReader("No more than N requests per minute allowed")),
+			Request:    req,
+		}, nil
+	})
+
+	client := accrual.NewClient("//localhost", &accrual.ClientOption{Transport: transport})
+
+	_, err := client.GetAccrualInfo(context.Background(), "49927398716")
+	require.Error(t, err)
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	require.Contains(t, rec.Body.String(), `accrual_client_request_duration_seconds_count{status="429"}`)
+	require.Regexp(t, `(?m)^accrual_client_rate_limited_total [1-9]`, rec.Body.String())
+}
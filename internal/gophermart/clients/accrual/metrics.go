@@ -0,0 +1,26 @@
+package accrual
+
+import (
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+)
+
+var (
+	requestDuration = metrics.NewHistogramVec(
+		"accrual_client_request_duration_seconds",
+		"Длительность попыток запросов в accrual по коду ответа; error — запрос без ответа.",
+		nil,
+		"status",
+	)
+	requestRetries = metrics.NewCounterVec(
+		"accrual_client_retries_total",
+		"Количество повторных попыток запросов в accrual.",
+	)
+	rateLimited = metrics.NewCounterVec(
+		"accrual_client_rate_limited_total",
+		"Количество ответов accrual с кодом 429 Too Many Requests.",
+	)
+)
+
+func init() {
+	metrics.MustRegister(requestDuration, requestRetries, rateLimited)
+}
@@ -5,10 +5,21 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"log/slog"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+	"github.com/sergeizaitcev/gophermart/pkg/sign"
+	"github.com/sergeizaitcev/gophermart/pkg/throttling"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 // Config определяет конфигурацию для gophermart.
@@ -19,6 +30,16 @@ type Config struct {
 	// Адресс запуска сервера.
 	RunAddress string `env:"RUN_ADDRESS"`
 
+	// Адрес запуска gRPC-сервера для внутренних сервисов; если не задан,
+	// то gRPC-сервер не запускается. Использует те же настройки TLS,
+	// что и HTTP-сервер.
+	GRPCAddress string `env:"GRPC_ADDRESS"`
+
+	// Адрес внутреннего сервера метрик Prometheus (/metrics); если не задан,
+	// то метрики не публикуются. Сервер не использует TLS и авторизацию,
+	// поэтому не должен быть доступен извне.
+	MetricsAddress string `env:"METRICS_ADDRESS"`
+
 	// Строка подключения к БД.
 	DatabaseURI string `env:"DATABASE_URI"`
 
@@ -28,18 +49,228 @@ type Config struct {
 	// Путь к файлу с секретным ключом.
 	SecretKeyPath string `env:"SECRET_KEY_PATH"`
 
-	// Время жизни токена.
+	// Путь к директории с ключами подписи. Если задан, то используется вместо
+	// SecretKeyPath.
+	//
+	// Каждый файл с расширением .key содержит секретный ключ HS256 в base64,
+	// а с расширением .pem — закрытый ключ Ed25519 или RSA в формате PEM; имя
+	// файла без расширения является идентификатором ключа. Активным считается
+	// ключ с наибольшим идентификатором в лексикографическом порядке, поэтому
+	// идентификаторы рекомендуется строить из даты выпуска ключа.
+	SecretKeysDir string `env:"SECRET_KEYS_DIR"`
+
+	// Время жизни токена авторизации.
 	TokenTTL time.Duration `env:"TOKEN_TTL"`
+
+	// Время жизни сеанса пользователя и его токена обновления.
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL"`
+
+	// Абсолютное время жизни сеанса с момента входа; обновление токена
+	// не продлевает сеанс дальше этого срока.
+	SessionMaxLifetime time.Duration `env:"SESSION_MAX_LIFETIME"`
+
+	// Количество неудачных попыток входа без задержки.
+	LoginFreeAttempts int `env:"LOGIN_FREE_ATTEMPTS"`
+
+	// Задержка после первой неудачной попытки входа сверх LoginFreeAttempts;
+	// удваивается с каждой следующей попыткой.
+	LoginBaseDelay time.Duration `env:"LOGIN_BASE_DELAY"`
+
+	// Максимальная задержка между попытками входа.
+	LoginMaxDelay time.Duration `env:"LOGIN_MAX_DELAY"`
+
+	// Количество неудачных попыток входа по логину до блокировки; 0 отключает
+	// блокировку по логину.
+	LoginLockoutThreshold int `env:"LOGIN_LOCKOUT_THRESHOLD"`
+
+	// Количество неудачных попыток входа с IP-адреса до блокировки; 0 отключает
+	// блокировку по IP-адресу.
+	IPLockoutThreshold int `env:"IP_LOCKOUT_THRESHOLD"`
+
+	// Время блокировки входа.
+	LoginLockoutDuration time.Duration `env:"LOGIN_LOCKOUT_DURATION"`
+
+	// Время, по истечении которого с последней неудачной попытки входа счётчик
+	// сбрасывается.
+	LoginAttemptsWindow time.Duration `env:"LOGIN_ATTEMPTS_WINDOW"`
+
+	// Алгоритм хеширования паролей: argon2id или bcrypt. Хеш-суммы другого
+	// алгоритма и с устаревшими параметрами пересчитываются при входе.
+	PasswordHash string `env:"PASSWORD_HASH"`
+
+	// Стоимость хеширования bcrypt.
+	BcryptCost int `env:"BCRYPT_COST"`
+
+	// Объём памяти argon2id в КиБ.
+	Argon2Memory uint `env:"ARGON2_MEMORY"`
+
+	// Количество проходов argon2id.
+	Argon2Time uint `env:"ARGON2_TIME"`
+
+	// Степень параллелизма argon2id.
+	Argon2Threads uint `env:"ARGON2_THREADS"`
+
+	// Путь к файлу с серверным секретом для хеширования паролей в base64.
+	// Не обязателен; после установки не должен меняться.
+	PepperPath string `env:"PEPPER_PATH"`
+
+	// Минимальная и максимальная длина логина в символах.
+	LoginMinLen int `env:"LOGIN_MIN_LEN"`
+	LoginMaxLen int `env:"LOGIN_MAX_LEN"`
+
+	// Регулярное выражение, которому должен соответствовать логин; пустое
+	// значение допускает любые символы.
+	LoginPattern string `env:"LOGIN_PATTERN"`
+
+	// Минимальная и максимальная длина пароля в символах.
+	PasswordMinLen int `env:"PASSWORD_MIN_LEN"`
+	PasswordMaxLen int `env:"PASSWORD_MAX_LEN"`
+
+	// Минимальное количество классов символов в пароле: строчные и прописные
+	// буквы, цифры, прочие символы.
+	PasswordMinClasses int `env:"PASSWORD_MIN_CLASSES"`
+
+	// Путь к файлу со списком запрещённых паролей, по одному в строке.
+	BannedPasswordsPath string `env:"BANNED_PASSWORDS_PATH"`
+
+	// Издатель, отображаемый в приложении аутентификации TOTP.
+	TOTPIssuer string `env:"TOTP_ISSUER"`
+
+	// Количество неверных кодов второго фактора подряд при подтверждении
+	// действий, после которого проверка кодов блокируется.
+	TOTPCodeAttempts int `env:"TOTP_CODE_ATTEMPTS"`
+
+	// Время блокировки проверки кодов второго фактора.
+	TOTPCodeLockout time.Duration `env:"TOTP_CODE_LOCKOUT"`
+
+	// Сумма списания в баллах, свыше которой требуется свежий код второго
+	// фактора; 0 отключает проверку.
+	WithdrawalCodeThreshold float64 `env:"WITHDRAWAL_CODE_THRESHOLD"`
+
+	// Максимальное количество сеансов в кеше идентификационных данных;
+	// 0 отключает кеш.
+	IdentityCacheSize int `env:"IDENTITY_CACHE_SIZE"`
+
+	// Время жизни записи в кеше идентификационных данных; 0 отключает кеш.
+	IdentityCacheTTL time.Duration `env:"IDENTITY_CACHE_TTL"`
+
+	// Сети обратных прокси в формате "10.0.0.0/8,192.168.1.10", которым
+	// доверяется заголовок X-Forwarded-For; по умолчанию не доверяется никому.
+	TrustedProxies httputil.TrustedProxies `env:"TRUSTED_PROXIES"`
+
+	// Ограничение частоты запросов без авторизации и неудачных попыток
+	// авторизации с IP-адреса клиента в формате "300/1m"; 0 снимает
+	// ограничение.
+	RateLimitIP throttling.Rate `env:"RATE_LIMIT_IP"`
+
+	// Ограничение частоты авторизованных запросов пользователя; 0 снимает
+	// ограничение. Как и RateLimitIP, действует и на вызовы gRPC API,
+	// но отдельно от HTTP API.
+	RateLimitUser throttling.Rate `env:"RATE_LIMIT_USER"`
+
+	// Ограничения частоты запросов к отдельным маршрутам в формате
+	// "POST /api/user/login=10/1m,POST /api/user/register=5/1m".
+	RateLimitRoutes throttling.RouteRates `env:"RATE_LIMIT_ROUTES"`
+
+	// Время простоя, после которого состояние ограничения клиента удаляется.
+	RateLimitIdle time.Duration `env:"RATE_LIMIT_IDLE"`
+
+	// Максимальный размер тела запроса в байтах для маршрутов без
+	// собственного ограничения.
+	MaxBodySize int64 `env:"MAX_BODY_SIZE"`
+
+	// Максимальные размеры тел запросов к отдельным маршрутам в байтах
+	// в формате "POST /api/user/orders=1024"; заменяют встроенные
+	// ограничения маршрутов.
+	MaxBodySizeRoutes httputil.RouteSizes `env:"MAX_BODY_SIZE_ROUTES"`
+
+	// Экспортёр трассировки: none, stdout, file или otlp.
+	TraceExporter string `env:"TRACE_EXPORTER"`
+
+	// Адрес OTLP/HTTP для экспортёра otlp или путь к файлу для экспортёра
+	// file.
+	TraceEndpoint string `env:"TRACE_ENDPOINT"`
+
+	// Пути к сертификату и закрытому ключу сервера в формате PEM; если
+	// заданы, то сервер принимает только HTTPS-соединения. Файлы
+	// перечитываются при изменении.
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+	TLSKeyFile  string `env:"TLS_KEY_FILE"`
+
+	// Путь к корневым сертификатам, которыми должны быть подписаны
+	// сертификаты клиентов; если не задан, сертификат клиента
+	// не запрашивается. /healthz и /readyz доступны без сертификата.
+	TLSClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+
+	// Путь к корневым сертификатам для проверки сертификата accrual; если
+	// не задан, используются системные.
+	AccrualCAFile string `env:"ACCRUAL_CA_FILE"`
+
+	// Пути к клиентскому сертификату и закрытому ключу, предъявляемым
+	// accrual. Файлы перечитываются при изменении.
+	AccrualCertFile string `env:"ACCRUAL_CERT_FILE"`
+	AccrualKeyFile  string `env:"ACCRUAL_KEY_FILE"`
+
+	// Общее время на остановку сервиса: завершение HTTP-запросов
+	// и gRPC-вызовов, затем обработку текущих заказов и сохранение очереди.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT"`
 }
 
 // SetFlags устанавливает флаги командной строки.
 func (c *Config) SetFlags(fs *flag.FlagSet) {
 	fs.StringVar(&c.RunAddress, "a", "", "run address")
+	fs.StringVar(&c.GRPCAddress, "grpc-address", "", "gRPC run address")
+	fs.StringVar(&c.MetricsAddress, "metrics-address", "localhost:9090", "internal metrics server address")
 	fs.StringVar(&c.DatabaseURI, "d", "", "database uri")
 	fs.StringVar(&c.AccrualSystemAddress, "r", "", "accrual system address")
 	fs.StringVar(&c.SecretKeyPath, "s", "secret_key.txt", "secret key path")
+	fs.StringVar(&c.SecretKeysDir, "secret-keys-dir", "", "signing keys directory")
 	fs.TextVar(&c.Level, "v", slog.LevelInfo, "logging level")
-	fs.DurationVar(&c.TokenTTL, "t", 0, "token lifetime")
+	fs.DurationVar(&c.TokenTTL, "t", 15*time.Minute, "access token lifetime")
+	fs.DurationVar(&c.RefreshTokenTTL, "refresh-ttl", 30*24*time.Hour, "refresh token lifetime")
+	fs.DurationVar(&c.SessionMaxLifetime, "session-max-lifetime", 90*24*time.Hour, "absolute session lifetime")
+	fs.IntVar(&c.LoginFreeAttempts, "login-free-attempts", 3, "failed logins without delay")
+	fs.DurationVar(&c.LoginBaseDelay, "login-base-delay", time.Second, "initial delay after failed logins")
+	fs.DurationVar(&c.LoginMaxDelay, "login-max-delay", time.Minute, "maximum delay between failed logins")
+	fs.IntVar(&c.LoginLockoutThreshold, "login-lockout-threshold", 10, "failed logins per login before lockout")
+	fs.IntVar(&c.IPLockoutThreshold, "ip-lockout-threshold", 50, "failed logins per IP before lockout")
+	fs.DurationVar(&c.LoginLockoutDuration, "login-lockout-duration", 15*time.Minute, "login lockout duration")
+	fs.DurationVar(&c.LoginAttemptsWindow, "login-attempts-window", time.Hour, "failed logins counting window")
+	fs.StringVar(&c.PasswordHash, "password-hash", PasswordHashArgon2id, "password hashing algorithm")
+	fs.IntVar(&c.BcryptCost, "bcrypt-cost", 12, "bcrypt cost")
+	fs.UintVar(&c.Argon2Memory, "argon2-memory", 19*1024, "argon2id memory in KiB")
+	fs.UintVar(&c.Argon2Time, "argon2-time", 2, "argon2id iterations")
+	fs.UintVar(&c.Argon2Threads, "argon2-threads", 1, "argon2id parallelism")
+	fs.StringVar(&c.PepperPath, "pepper", "", "password pepper path")
+	fs.IntVar(&c.LoginMinLen, "login-min-len", 3, "minimum login length")
+	fs.IntVar(&c.LoginMaxLen, "login-max-len", 255, "maximum login length")
+	fs.StringVar(&c.LoginPattern, "login-pattern", "", "login regular expression")
+	fs.IntVar(&c.PasswordMinLen, "password-min-len", 8, "minimum password length")
+	fs.IntVar(&c.PasswordMaxLen, "password-max-len", 72, "maximum password length")
+	fs.IntVar(&c.PasswordMinClasses, "password-min-classes", 0, "minimum password character classes")
+	fs.StringVar(&c.BannedPasswordsPath, "banned-passwords", "", "banned passwords path")
+	fs.StringVar(&c.TOTPIssuer, "totp-issuer", "Gophermart", "TOTP issuer")
+	fs.IntVar(&c.TOTPCodeAttempts, "totp-code-attempts", 5, "invalid two-factor codes before lockout")
+	fs.DurationVar(&c.TOTPCodeLockout, "totp-code-lockout", 15*time.Minute, "two-factor code lockout duration")
+	fs.Float64Var(&c.WithdrawalCodeThreshold, "withdrawal-code-threshold", 0, "withdrawal sum requiring a TOTP code")
+	fs.IntVar(&c.IdentityCacheSize, "identity-cache-size", 10000, "maximum cached sessions")
+	fs.DurationVar(&c.IdentityCacheTTL, "identity-cache-ttl", 30*time.Second, "cached session lifetime")
+	fs.TextVar(&c.TrustedProxies, "trusted-proxies", httputil.TrustedProxies(nil), "reverse proxy networks trusted for X-Forwarded-For")
+	fs.TextVar(&c.RateLimitIP, "rate-limit-ip", throttling.Rate{Requests: 300, Per: time.Minute}, "requests per client IP")
+	fs.TextVar(&c.RateLimitUser, "rate-limit-user", throttling.Rate{Requests: 120, Per: time.Minute}, "requests per user")
+	fs.TextVar(&c.RateLimitRoutes, "rate-limit-routes", defaultRouteRates, "requests per route and client")
+	fs.DurationVar(&c.RateLimitIdle, "rate-limit-idle", 10*time.Minute, "idle rate limit state lifetime")
+	fs.Int64Var(&c.MaxBodySize, "max-body-size", 16<<10, "maximum request body size in bytes")
+	fs.TextVar(&c.MaxBodySizeRoutes, "max-body-size-routes", httputil.RouteSizes{}, "maximum request body size per route in bytes")
+	fs.StringVar(&c.TraceExporter, "trace-exporter", tracing.ExporterNone, "trace exporter: none, stdout, file or otlp")
+	fs.StringVar(&c.TraceEndpoint, "trace-endpoint", "", "OTLP endpoint or trace file path")
+	fs.StringVar(&c.TLSCertFile, "tls-cert", "", "TLS certificate path")
+	fs.StringVar(&c.TLSKeyFile, "tls-key", "", "TLS private key path")
+	fs.StringVar(&c.TLSClientCAFile, "tls-client-ca", "", "client certificates CA path")
+	fs.StringVar(&c.AccrualCAFile, "accrual-ca", "", "accrual server certificate CA path")
+	fs.StringVar(&c.AccrualCertFile, "accrual-cert", "", "accrual client certificate path")
+	fs.StringVar(&c.AccrualKeyFile, "accrual-key", "", "accrual client private key path")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 30*time.Second, "graceful shutdown budget")
 }
 
 // Validate возвращает ошибку, если одно из полей конфигурации не валидно.
@@ -53,18 +284,215 @@ func (c *Config) Validate() error {
 	if c.AccrualSystemAddress == "" {
 		return errors.New("the address of the accrual system must not be empty")
 	}
-	if c.SecretKeyPath == "" {
+	if c.SecretKeyPath == "" && c.SecretKeysDir == "" {
 		return errors.New("the path of the secret key path must not be empty")
 	}
 	if c.TokenTTL < 0 {
 		return errors.New("the token lifetime must be greater than or equal to zero")
 	}
-	return nil
+	if c.RefreshTokenTTL <= 0 {
+		return errors.New("the refresh token lifetime must be greater than zero")
+	}
+	if c.SessionMaxLifetime < c.RefreshTokenTTL {
+		return errors.New("the absolute session lifetime must not be less than the refresh token lifetime")
+	}
+	if c.LoginFreeAttempts < 0 || c.LoginLockoutThreshold < 0 || c.IPLockoutThreshold < 0 {
+		return errors.New("the login attempts thresholds must be greater than or equal to zero")
+	}
+	if c.LoginBaseDelay < 0 || c.LoginMaxDelay < 0 || c.LoginLockoutDuration < 0 {
+		return errors.New("the login delays must be greater than or equal to zero")
+	}
+	if c.LoginAttemptsWindow <= 0 {
+		return errors.New("the login attempts window must be greater than zero")
+	}
+	if c.IdentityCacheSize < 0 || c.IdentityCacheTTL < 0 {
+		return errors.New("the identity cache size and lifetime must be greater than or equal to zero")
+	}
+	if c.ShutdownTimeout <= 0 {
+		return errors.New("the shutdown timeout must be greater than zero")
+	}
+	if c.TOTPCodeAttempts <= 0 || c.TOTPCodeLockout <= 0 {
+		return errors.New("the two-factor code attempts and lockout must be greater than zero")
+	}
+	if c.RateLimitIdle < 0 {
+		return errors.New("the rate limit idle time must be greater than or equal to zero")
+	}
+	if c.MaxBodySize <= 0 {
+		return errors.New("the maximum request body size must be greater than zero")
+	}
+	switch c.PasswordHash {
+	case PasswordHashArgon2id:
+		if c.Argon2Memory == 0 || c.Argon2Time == 0 {
+			return errors.New("the argon2id memory and time must be greater than zero")
+		}
+		if c.Argon2Threads == 0 || c.Argon2Threads > math.MaxUint8 {
+			return fmt.Errorf("the argon2id parallelism must be between 1 and %d", math.MaxUint8)
+		}
+	case PasswordHashBcrypt:
+		if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+			return fmt.Errorf("the bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return fmt.Errorf("unsupported password hashing algorithm: %q", c.PasswordHash)
+	}
+	if c.LoginMinLen < 1 || c.LoginMaxLen < c.LoginMinLen {
+		return errors.New("the login length limits must be positive and min must not exceed max")
+	}
+	if c.PasswordMinLen < 1 || c.PasswordMaxLen < c.PasswordMinLen {
+		return errors.New("the password length limits must be positive and min must not exceed max")
+	}
+	if c.PasswordMinClasses < 0 || c.PasswordMinClasses > 4 {
+		return errors.New("the password character classes must be between 0 and 4")
+	}
+	if c.WithdrawalCodeThreshold < 0 {
+		return errors.New("the withdrawal code threshold must be greater than or equal to zero")
+	}
+	if _, err := c.LoginRegexp(); err != nil {
+		return err
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return errors.New("the TLS certificate and private key must be set together")
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		return errors.New("the client certificate verification requires a TLS certificate")
+	}
+	if (c.AccrualCertFile == "") != (c.AccrualKeyFile == "") {
+		return errors.New("the accrual client certificate and private key must be set together")
+	}
+	return validateTracing(c.TraceExporter, c.TraceEndpoint)
+}
+
+// validateTracing проверяет экспортёр трассировки и его адрес.
+func validateTracing(exporter, endpoint string) error {
+	switch exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+		return nil
+	case tracing.ExporterFile, tracing.ExporterOTLP:
+		if endpoint == "" {
+			return fmt.Errorf("the trace endpoint must not be empty for the %s exporter", exporter)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported trace exporter: %q", exporter)
+	}
+}
+
+// LoginRegexp возвращает скомпилированный LoginPattern или nil, если шаблон
+// не задан.
+func (c *Config) LoginRegexp() (*regexp.Regexp, error) {
+	if c.LoginPattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(c.LoginPattern)
+	if err != nil {
+		return nil, fmt.Errorf("compiling the login pattern: %w", err)
+	}
+	return re, nil
+}
+
+// Ограничения частоты запросов к маршрутам по умолчанию: вход
+// и регистрация ограничиваются сильнее остальных запросов.
+var defaultRouteRates = throttling.RouteRates{
+	"POST /api/user/login":         {Requests: 10, Per: time.Minute},
+	"POST /api/user/login/2fa":     {Requests: 10, Per: time.Minute},
+	"POST /api/user/register":      {Requests: 5, Per: time.Minute},
+	"POST /api/user/token/refresh": {Requests: 30, Per: time.Minute},
+}
+
+// Алгоритмы хеширования паролей.
+const (
+	PasswordHashArgon2id = "argon2id"
+	PasswordHashBcrypt   = "bcrypt"
+)
+
+// Pepper возвращает серверный секрет для хеширования паролей, хранящийся
+// в PepperPath, или nil, если путь не задан.
+func (c *Config) Pepper() ([]byte, error) {
+	if c.PepperPath == "" {
+		return nil, nil
+	}
+	return readSecretKey(c.PepperPath)
 }
 
 // SecretKey возвращает секретный ключ, хранящийся в SecretKeyPath.
 func (c *Config) SecretKey() ([]byte, error) {
-	encodedSecretKey, err := os.ReadFile(c.SecretKeyPath)
+	return readSecretKey(c.SecretKeyPath)
+}
+
+// SigningKeys возвращает идентификатор активного ключа и все ключи подписи:
+// из SecretKeysDir, если директория задана, иначе единственный ключ
+// из SecretKeyPath.
+func (c *Config) SigningKeys() (active string, keys []sign.Key, err error) {
+	if c.SecretKeysDir == "" {
+		secretKey, err := c.SecretKey()
+		if err != nil {
+			return "", nil, err
+		}
+		return "", []sign.Key{{Secret: secretKey}}, nil
+	}
+
+	entries, err := os.ReadDir(c.SecretKeysDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading a directory: %w", err)
+	}
+
+	// os.ReadDir возвращает файлы в лексикографическом порядке.
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != secretKeyExt && ext != privateKeyExt) {
+			continue
+		}
+
+		path := filepath.Join(c.SecretKeysDir, entry.Name())
+		id := strings.TrimSuffix(entry.Name(), ext)
+
+		key, err := readSigningKey(id, path)
+		if err != nil {
+			return "", nil, fmt.Errorf("key %q: %w", path, err)
+		}
+
+		if len(keys) > 0 && keys[len(keys)-1].ID == id {
+			return "", nil, fmt.Errorf("duplicate key %q", id)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return "", nil, fmt.Errorf("no keys found in %q", c.SecretKeysDir)
+	}
+
+	return keys[len(keys)-1].ID, keys, nil
+}
+
+// Расширения файлов с ключами подписи.
+const (
+	secretKeyExt  = ".key"
+	privateKeyExt = ".pem"
+)
+
+// readSigningKey читает ключ подписи из файла в зависимости от его
+// расширения.
+func readSigningKey(id, path string) (sign.Key, error) {
+	if filepath.Ext(path) == secretKeyExt {
+		secretKey, err := readSecretKey(path)
+		if err != nil {
+			return sign.Key{}, err
+		}
+		return sign.Key{ID: id, Algorithm: sign.HS256, Secret: secretKey}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sign.Key{}, fmt.Errorf("reading a file: %w", err)
+	}
+
+	return sign.ParsePrivateKey(id, data)
+}
+
+// readSecretKey читает секретный ключ в base64 из файла.
+func readSecretKey(path string) ([]byte, error) {
+	encodedSecretKey, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading a file: %w", err)
 	}
@@ -72,10 +500,41 @@ func (c *Config) SecretKey() ([]byte, error) {
 	base64 := base64.StdEncoding
 	secretKey := make([]byte, base64.DecodedLen(len(encodedSecretKey)))
 
-	_, err = base64.Decode(secretKey, encodedSecretKey)
+	n, err := base64.Decode(secretKey, encodedSecretKey)
 	if err != nil {
 		return nil, fmt.Errorf("base64 decoding: %w", err)
 	}
 
-	return secretKey, nil
+	return secretKey[:n], nil
+}
+
+// GrantAdminConfig определяет конфигурацию команды назначения
+// администратора.
+type GrantAdminConfig struct {
+	// Уровень логирования.
+	Level slog.Level `env:"LOG_LEVEL"`
+
+	// Строка подключения к БД.
+	DatabaseURI string `env:"DATABASE_URI"`
+
+	// Логин пользователя, которому назначается роль администратора.
+	Login string
+}
+
+// SetFlags устанавливает флаги командной строки.
+func (c *GrantAdminConfig) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.DatabaseURI, "d", "", "database uri")
+	fs.TextVar(&c.Level, "v", slog.LevelInfo, "logging level")
+	fs.StringVar(&c.Login, "login", "", "user login")
+}
+
+// Validate возвращает ошибку, если одно из полей конфигурации не валидно.
+func (c *GrantAdminConfig) Validate() error {
+	if c.DatabaseURI == "" {
+		return errors.New("the database uri must not be empty")
+	}
+	if c.Login == "" {
+		return errors.New("the login must not be empty")
+	}
+	return nil
 }
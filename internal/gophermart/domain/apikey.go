@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scope определяет право доступа ключа API.
+type Scope string
+
+// Права доступа ключей API.
+const (
+	ScopeOrdersRead   Scope = "orders:read"   // Просмотр заказов.
+	ScopeOrdersWrite  Scope = "orders:write"  // Загрузка заказов.
+	ScopeBalanceRead  Scope = "balance:read"  // Просмотр баланса и списаний.
+	ScopeBalanceWrite Scope = "balance:write" // Списание баллов.
+)
+
+// Validate возвращает ошибку, если право доступа не поддерживается.
+func (s Scope) Validate() error {
+	switch s {
+	case ScopeOrdersRead, ScopeOrdersWrite, ScopeBalanceRead, ScopeBalanceWrite:
+		return nil
+	default:
+		return fmt.Errorf("unsupported scope: %q", s)
+	}
+}
+
+// APIKeyID определяет уникальный идентификатор ключа API.
+type APIKeyID = uuid.UUID
+
+var EmptyAPIKeyID = uuid.Nil
+
+// NewAPIKeyID конвертирует строку в уникальный идентификатор ключа API
+// и возвращает его.
+func NewAPIKeyID(s string) (APIKeyID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("parsing API key ID: %w", err)
+	}
+	return id, nil
+}
+
+// APIKey определяет ключ API пользователя.
+type APIKey struct {
+	ID         APIKeyID   `json:"id"`                     // Уникальный идентификатор ключа.
+	UserID     UserID     `json:"-"`                      // Уникальный идентификатор владельца.
+	Name       string     `json:"name"`                   // Название ключа.
+	Scopes     []Scope    `json:"scopes"`                 // Права доступа.
+	Key        string     `json:"key,omitempty"`          // Ключ; возвращается только при создании.
+	CreatedAt  time.Time  `json:"created_at"`             // Время создания.
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Время последнего использования.
+}
+
+// Максимальная длина названия ключа API.
+const maxAPIKeyNameLen = 64
+
+// APIKeyRequest определяет данные для создания ключа API.
+type APIKeyRequest struct {
+	Name   string  `json:"name"`   // Название ключа.
+	Scopes []Scope `json:"scopes"` // Права доступа.
+}
+
+// Validate возвращает ошибку, если данные для создания ключа API
+// не валидны.
+func (r APIKeyRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("name must be not empty")
+	}
+	if len(r.Name) > maxAPIKeyNameLen {
+		return fmt.Errorf("length of name must be less than or equal to %d", maxAPIKeyNameLen)
+	}
+	if len(r.Scopes) == 0 {
+		return errors.New("scopes must be not empty")
+	}
+	for _, scope := range r.Scopes {
+		err := scope.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SecurityEventType определяет тип события безопасности.
+type SecurityEventType string
+
+// Типы событий безопасности.
+const (
+	EventRegistered      SecurityEventType = "registered"       // Регистрация.
+	EventLoginSucceeded  SecurityEventType = "login_succeeded"  // Успешный вход.
+	EventLoginFailed     SecurityEventType = "login_failed"     // Неудачный вход.
+	EventTokenRefreshed  SecurityEventType = "token_refreshed"  // Обновление сеанса.
+	EventLoggedOut       SecurityEventType = "logged_out"       // Выход.
+	EventPasswordChanged SecurityEventType = "password_changed" // Смена пароля.
+	EventWithdrawal      SecurityEventType = "withdrawal"       // Списание баллов.
+	EventRoleChanged     SecurityEventType = "role_changed"     // Смена роли.
+	EventAccountDeleted  SecurityEventType = "account_deleted"  // Удаление учётной записи.
+
+	// Неверный код второго фактора при подтверждении действия.
+	EventTwoFactorFailed SecurityEventType = "two_factor_failed"
+)
+
+// Validate возвращает ошибку, если тип события не поддерживается.
+func (t SecurityEventType) Validate() error {
+	switch t {
+	case EventRegistered, EventLoginSucceeded, EventLoginFailed,
+		EventTokenRefreshed, EventLoggedOut, EventPasswordChanged,
+		EventWithdrawal, EventRoleChanged, EventAccountDeleted,
+		EventTwoFactorFailed:
+		return nil
+	default:
+		return fmt.Errorf("unsupported security event type: %q", t)
+	}
+}
+
+// SecurityEvent определяет запись журнала событий безопасности.
+type SecurityEvent struct {
+	ID        int64             `json:"id"`                   // Уникальный идентификатор записи.
+	Type      SecurityEventType `json:"type"`                 // Тип события.
+	UserID    UserID            `json:"user_id"`              // Пользователь; пуст, если не установлен.
+	Login     string            `json:"login,omitempty"`      // Логин, указанный при неудачном входе.
+	IP        string            `json:"ip,omitempty"`         // IP-адрес клиента.
+	UserAgent string            `json:"user_agent,omitempty"` // Заголовок User-Agent клиента.
+	Details   string            `json:"details,omitempty"`    // Подробности события.
+	CreatedAt time.Time         `json:"created_at"`           // Время события.
+}
+
+// Ограничения количества записей в выборке журнала.
+const (
+	DefaultSecurityEventsLimit = 100
+	MaxSecurityEventsLimit     = 1000
+)
+
+// SecurityEventFilter определяет условия выборки журнала событий
+// безопасности; пустые поля не ограничивают выборку.
+type SecurityEventFilter struct {
+	UserID UserID              // Пользователь.
+	Login  string              // Логин, указанный при неудачном входе.
+	Types  []SecurityEventType // Типы событий.
+	IP     string              // IP-адрес клиента.
+	From   time.Time           // Начало периода включительно.
+	To     time.Time           // Конец периода не включительно.
+	Before int64               // Записи с идентификатором меньше указанного.
+	Limit  int                 // Максимальное количество записей.
+}
+
+// Validate возвращает ошибку, если условия выборки не валидны.
+func (f SecurityEventFilter) Validate() error {
+	for _, t := range f.Types {
+		err := t.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return errors.New("from must be before to")
+	}
+	if f.Before < 0 {
+		return errors.New("before must be greater than or equal to zero")
+	}
+	if f.Limit < 0 || f.Limit > MaxSecurityEventsLimit {
+		return fmt.Errorf("limit must be between 0 and %d", MaxSecurityEventsLimit)
+	}
+	return nil
+}
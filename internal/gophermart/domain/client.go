@@ -0,0 +1,23 @@
+package domain
+
+import "context"
+
+// Client определяет сведения о клиенте, выполняющем запрос.
+type Client struct {
+	IP        string // IP-адрес клиента.
+	UserAgent string // Заголовок User-Agent клиента.
+}
+
+// keyClient определяет ключ для передачи Client через контекст.
+type keyClient struct{}
+
+// WithClient возвращает копию контекста со сведениями о клиенте.
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, keyClient{}, client)
+}
+
+// ClientFromContext возвращает сведения о клиенте из контекста.
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(keyClient{}).(Client)
+	return client
+}
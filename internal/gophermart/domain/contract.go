@@ -18,8 +18,9 @@ type AccrualClient interface {
 //
 //go:generate mockgen -source=contract.go -destination=mocks/mocks.go
 type AuthService interface {
-	// Identify идентифицирует уникальный идентификатор пользователя.
-	Identify(ctx context.Context, id UserID) error
+	// Identify идентифицирует владельца токена авторизации; возвращает
+	// ErrNotFound, если сеанс отозван или истёк.
+	Identify(ctx context.Context, identity Identity) error
 
 	// SignIn выполняет вход пользователя и возвращает его уникальный
 	// идентификатор.
@@ -28,6 +29,96 @@ type AuthService interface {
 	// SignUp выполняет регистрацию нового пользователя и возвращает его
 	// уникальный идентификатор.
 	SignUp(ctx context.Context, auth Authentication) (UserID, error)
+
+	// ChangePassword заменяет пароль пользователя и отзывает все его сеансы;
+	// возвращает ErrInvalidPassword, если текущий пароль не верен.
+	ChangePassword(ctx context.Context, id UserID, change PasswordChange) error
+
+	// CreateSession открывает новый сеанс пользователя.
+	CreateSession(ctx context.Context, id UserID) (Session, error)
+
+	// RefreshSession продлевает сеанс по токену обновления и возвращает его
+	// с новым токеном обновления; предыдущий токен обновления становится
+	// недействительным.
+	RefreshSession(ctx context.Context, refreshToken string) (Session, error)
+
+	// RevokeSession отзывает сеанс пользователя.
+	RevokeSession(ctx context.Context, identity Identity) error
+
+	// DeleteAccount обезличивает учётную запись пользователя: заменяет логин,
+	// удаляет пароль, сеансы, ключи API и второй фактор, сохраняя заказы
+	// и балансовые операции, и обезличивает журнал безопасности; возвращает
+	// ErrInvalidPassword, если пароль не верен.
+	DeleteAccount(ctx context.Context, id UserID, deletion AccountDeletion) error
+}
+
+// APIKeyService описывает интерфейс сервиса ключей API.
+//
+//go:generate mockgen -source=contract.go -destination=mocks/mocks.go
+type APIKeyService interface {
+	// Identify идентифицирует владельца ключа API и отмечает время его
+	// использования; возвращает ErrNotFound, если ключ не найден или отозван.
+	Identify(ctx context.Context, key string) (Identity, error)
+
+	// Create создаёт ключ API пользователя.
+	Create(ctx context.Context, id UserID, req APIKeyRequest) (APIKey, error)
+
+	// CreateForLogin создаёт ключ API пользователя с указанным логином;
+	// возвращает ErrNotFound, если пользователь не найден или удалён.
+	CreateForLogin(ctx context.Context, login string, req APIKeyRequest) (APIKey, error)
+
+	// List возвращает действующие ключи API пользователя без самих ключей.
+	List(ctx context.Context, id UserID) ([]APIKey, error)
+
+	// Revoke отзывает ключ API пользователя.
+	Revoke(ctx context.Context, id UserID, keyID APIKeyID) error
+}
+
+// TwoFactorService описывает интерфейс сервиса двухфакторной аутентификации.
+//
+//go:generate mockgen -source=contract.go -destination=mocks/mocks.go
+type TwoFactorService interface {
+	// Enroll создаёт новый секрет TOTP и коды восстановления пользователя;
+	// второй фактор включается после подтверждения кодом в Enable. Возвращает
+	// ErrDuplicate, если второй фактор уже включён.
+	Enroll(ctx context.Context, id UserID) (TOTPEnrollment, error)
+
+	// Enable включает второй фактор после проверки кода TOTP; возвращает
+	// ErrInvalidCode, если код не верен.
+	Enable(ctx context.Context, id UserID, code string) error
+
+	// Disable отключает второй фактор после проверки кода TOTP или кода
+	// восстановления; возвращает ErrInvalidCode, если код не верен.
+	Disable(ctx context.Context, id UserID, code string) error
+
+	// Verify проверяет свежий код TOTP; повторно использовать код нельзя.
+	// Возвращает ErrTwoFactorDisabled, если второй фактор не включён,
+	// и ErrInvalidCode, если код не верен.
+	Verify(ctx context.Context, id UserID, code string) error
+
+	// Challenge создаёт запрос второго фактора при входе; возвращает
+	// ErrTwoFactorDisabled, если второй фактор не включён.
+	Challenge(ctx context.Context, id UserID) (TwoFactorChallenge, error)
+
+	// CompleteChallenge проверяет ответ на запрос второго фактора и возвращает
+	// уникальный идентификатор пользователя; возвращает ErrNotFound, если
+	// запрос не найден или истёк, ErrInvalidCode, если код не верен,
+	// и *ResourceExhaustedError, если проверка кодов пользователя
+	// заблокирована.
+	CompleteChallenge(ctx context.Context, resp TwoFactorResponse) (UserID, error)
+}
+
+// AuditService описывает интерфейс журнала событий безопасности.
+//
+//go:generate mockgen -source=contract.go -destination=mocks/mocks.go
+type AuditService interface {
+	// Record добавляет событие в журнал; IP-адрес и User-Agent клиента, если
+	// не указаны, берутся из контекста.
+	Record(ctx context.Context, event SecurityEvent) error
+
+	// List возвращает события журнала по условиям выборки, начиная с
+	// последних; возвращает ErrNotFound, если событий нет.
+	List(ctx context.Context, filter SecurityEventFilter) ([]SecurityEvent, error)
 }
 
 // UserService описывает интерфейс сервиса для работы с пользователем.
@@ -36,6 +127,13 @@ type AuthService interface {
 type UserService interface {
 	// GetBalance возвращает баланс пользователя.
 	GetBalance(ctx context.Context, id UserID) (UserBalance, error)
+
+	// Export возвращает выгрузку персональных данных пользователя.
+	Export(ctx context.Context, id UserID) (UserExport, error)
+
+	// SetRole назначает роль пользователю с указанным логином и отзывает
+	// права, выданные токенам авторизации с прежней ролью.
+	SetRole(ctx context.Context, login string, role Role) error
 }
 
 // OrderService описывает интерфейс сервиса обработки заказов пользователя.
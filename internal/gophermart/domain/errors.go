@@ -15,7 +15,7 @@ var (
 )
 
 // ResourceExhaustedError возвращается, если клиент превысил лимит запросов
-// в минуту.
+// в минуту или лимит неудачных попыток входа.
 type ResourceExhaustedError struct {
 	Message    string
 	RetryAfter time.Duration
@@ -25,6 +25,17 @@ func (err *ResourceExhaustedError) Error() string {
 	return err.Message
 }
 
+// Ошибки валидации номера заказа.
+var (
+	// ErrOrderNumberNotDigits возвращается, если номер заказа содержит
+	// не только цифры.
+	ErrOrderNumberNotDigits = errors.New("order number must contain only digits")
+
+	// ErrOrderNumberChecksum возвращается, если контрольная сумма номера
+	// заказа по алгоритму Луна не верна.
+	ErrOrderNumberChecksum = errors.New("order number checksum is invalid")
+)
+
 // Ошибки, возвращаемые хранилищем gophermart.
 var (
 	// ErrDuplicate возвращается, когда заказ уже был загружен.
@@ -44,4 +55,12 @@ var (
 	// ErrInvalidPassword возвращается, когда пользователь передал не верный
 	// пароль.
 	ErrInvalidPassword = errors.New("invalid password")
+
+	// ErrInvalidCode возвращается, когда пользователь передал не верный
+	// или уже использованный код второго фактора.
+	ErrInvalidCode = errors.New("invalid two-factor code")
+
+	// ErrTwoFactorDisabled возвращается, когда у пользователя не включена
+	// двухфакторная аутентификация.
+	ErrTwoFactorDisabled = errors.New("two-factor authentication is disabled")
 )
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// UserExport определяет выгрузку персональных данных пользователя.
+type UserExport struct {
+	ExportedAt time.Time   `json:"exported_at"` // Время выгрузки.
+	Profile    UserProfile `json:"profile"`     // Профиль пользователя.
+	Orders     []Order     `json:"orders"`      // Заказы пользователя.
+	Operations []Operation `json:"withdrawals"` // Списания пользователя.
+}
+
+// UserProfile определяет профиль пользователя в выгрузке.
+type UserProfile struct {
+	ID                UserID      `json:"id"`                  // Уникальный идентификатор.
+	Login             string      `json:"login"`               // Логин.
+	Role              Role        `json:"role"`                // Роль.
+	Balance           UserBalance `json:"balance"`             // Баланс.
+	PasswordChangedAt time.Time   `json:"password_changed_at"` // Время последней смены пароля.
+	TwoFactorEnabled  bool        `json:"two_factor_enabled"`  // Включён ли второй фактор.
+	APIKeys           []APIKey    `json:"api_keys"`            // Действующие ключи API.
+}
+
+// AccountDeletion определяет данные для удаления учётной записи.
+type AccountDeletion struct {
+	Password string `json:"password"` // Текущий пароль.
+}
+
+// Validate возвращает ошибку, если пароль пуст.
+func (d AccountDeletion) Validate() error {
+	if d.Password == "" {
+		return errors.New("password must be not empty")
+	}
+	return nil
+}
@@ -73,18 +73,90 @@ func (m *MockAuthService) EXPECT() *MockAuthServiceMockRecorder {
 	return m.recorder
 }
 
+// ChangePassword mocks base method.
+func (m *MockAuthService) ChangePassword(ctx context.Context, id domain.UserID, change domain.PasswordChange) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, id, change)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockAuthServiceMockRecorder) ChangePassword(ctx, id, change interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockAuthService)(nil).ChangePassword), ctx, id, change)
+}
+
+// CreateSession mocks base method.
+func (m *MockAuthService) CreateSession(ctx context.Context, id domain.UserID) (domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, id)
+	ret0, _ := ret[0].(domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockAuthServiceMockRecorder) CreateSession(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockAuthService)(nil).CreateSession), ctx, id)
+}
+
+// DeleteAccount mocks base method.
+func (m *MockAuthService) DeleteAccount(ctx context.Context, id domain.UserID, deletion domain.AccountDeletion) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccount", ctx, id, deletion)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccount indicates an expected call of DeleteAccount.
+func (mr *MockAuthServiceMockRecorder) DeleteAccount(ctx, id, deletion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockAuthService)(nil).DeleteAccount), ctx, id, deletion)
+}
+
 // Identify mocks base method.
-func (m *MockAuthService) Identify(ctx context.Context, id domain.UserID) error {
+func (m *MockAuthService) Identify(ctx context.Context, identity domain.Identity) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Identify", ctx, id)
+	ret := m.ctrl.Call(m, "Identify", ctx, identity)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Identify indicates an expected call of Identify.
-func (mr *MockAuthServiceMockRecorder) Identify(ctx, id interface{}) *gomock.Call {
+func (mr *MockAuthServiceMockRecorder) Identify(ctx, identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Identify", reflect.TypeOf((*MockAuthService)(nil).Identify), ctx, identity)
+}
+
+// RefreshSession mocks base method.
+func (m *MockAuthService) RefreshSession(ctx context.Context, refreshToken string) (domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshSession", ctx, refreshToken)
+	ret0, _ := ret[0].(domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshSession indicates an expected call of RefreshSession.
+func (mr *MockAuthServiceMockRecorder) RefreshSession(ctx, refreshToken interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Identify", reflect.TypeOf((*MockAuthService)(nil).Identify), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSession", reflect.TypeOf((*MockAuthService)(nil).RefreshSession), ctx, refreshToken)
+}
+
+// RevokeSession mocks base method.
+func (m *MockAuthService) RevokeSession(ctx context.Context, identity domain.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockAuthServiceMockRecorder) RevokeSession(ctx, identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockAuthService)(nil).RevokeSession), ctx, identity)
 }
 
 // SignIn mocks base method.
@@ -117,6 +189,265 @@ func (mr *MockAuthServiceMockRecorder) SignUp(ctx, auth interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignUp", reflect.TypeOf((*MockAuthService)(nil).SignUp), ctx, auth)
 }
 
+// MockAPIKeyService is a mock of APIKeyService interface.
+type MockAPIKeyService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyServiceMockRecorder
+}
+
+// MockAPIKeyServiceMockRecorder is the mock recorder for MockAPIKeyService.
+type MockAPIKeyServiceMockRecorder struct {
+	mock *MockAPIKeyService
+}
+
+// NewMockAPIKeyService creates a new mock instance.
+func NewMockAPIKeyService(ctrl *gomock.Controller) *MockAPIKeyService {
+	mock := &MockAPIKeyService{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAPIKeyService) EXPECT() *MockAPIKeyServiceMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockAPIKeyService) Create(ctx context.Context, id domain.UserID, req domain.APIKeyRequest) (domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, id, req)
+	ret0, _ := ret[0].(domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockAPIKeyServiceMockRecorder) Create(ctx, id, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAPIKeyService)(nil).Create), ctx, id, req)
+}
+
+// CreateForLogin mocks base method.
+func (m *MockAPIKeyService) CreateForLogin(ctx context.Context, login string, req domain.APIKeyRequest) (domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateForLogin", ctx, login, req)
+	ret0, _ := ret[0].(domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateForLogin indicates an expected call of CreateForLogin.
+func (mr *MockAPIKeyServiceMockRecorder) CreateForLogin(ctx, login, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateForLogin", reflect.TypeOf((*MockAPIKeyService)(nil).CreateForLogin), ctx, login, req)
+}
+
+// Identify mocks base method.
+func (m *MockAPIKeyService) Identify(ctx context.Context, key string) (domain.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Identify", ctx, key)
+	ret0, _ := ret[0].(domain.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Identify indicates an expected call of Identify.
+func (mr *MockAPIKeyServiceMockRecorder) Identify(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Identify", reflect.TypeOf((*MockAPIKeyService)(nil).Identify), ctx, key)
+}
+
+// List mocks base method.
+func (m *MockAPIKeyService) List(ctx context.Context, id domain.UserID) ([]domain.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, id)
+	ret0, _ := ret[0].([]domain.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAPIKeyServiceMockRecorder) List(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAPIKeyService)(nil).List), ctx, id)
+}
+
+// Revoke mocks base method.
+func (m *MockAPIKeyService) Revoke(ctx context.Context, id domain.UserID, keyID domain.APIKeyID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, id, keyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockAPIKeyServiceMockRecorder) Revoke(ctx, id, keyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockAPIKeyService)(nil).Revoke), ctx, id, keyID)
+}
+
+// MockTwoFactorService is a mock of TwoFactorService interface.
+type MockTwoFactorService struct {
+	ctrl     *gomock.Controller
+	recorder *MockTwoFactorServiceMockRecorder
+}
+
+// MockTwoFactorServiceMockRecorder is the mock recorder for MockTwoFactorService.
+type MockTwoFactorServiceMockRecorder struct {
+	mock *MockTwoFactorService
+}
+
+// NewMockTwoFactorService creates a new mock instance.
+func NewMockTwoFactorService(ctrl *gomock.Controller) *MockTwoFactorService {
+	mock := &MockTwoFactorService{ctrl: ctrl}
+	mock.recorder = &MockTwoFactorServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTwoFactorService) EXPECT() *MockTwoFactorServiceMockRecorder {
+	return m.recorder
+}
+
+// Challenge mocks base method.
+func (m *MockTwoFactorService) Challenge(ctx context.Context, id domain.UserID) (domain.TwoFactorChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Challenge", ctx, id)
+	ret0, _ := ret[0].(domain.TwoFactorChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Challenge indicates an expected call of Challenge.
+func (mr *MockTwoFactorServiceMockRecorder) Challenge(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Challenge", reflect.TypeOf((*MockTwoFactorService)(nil).Challenge), ctx, id)
+}
+
+// CompleteChallenge mocks base method.
+func (m *MockTwoFactorService) CompleteChallenge(ctx context.Context, resp domain.TwoFactorResponse) (domain.UserID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteChallenge", ctx, resp)
+	ret0, _ := ret[0].(domain.UserID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompleteChallenge indicates an expected call of CompleteChallenge.
+func (mr *MockTwoFactorServiceMockRecorder) CompleteChallenge(ctx, resp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteChallenge", reflect.TypeOf((*MockTwoFactorService)(nil).CompleteChallenge), ctx, resp)
+}
+
+// Disable mocks base method.
+func (m *MockTwoFactorService) Disable(ctx context.Context, id domain.UserID, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Disable", ctx, id, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Disable indicates an expected call of Disable.
+func (mr *MockTwoFactorServiceMockRecorder) Disable(ctx, id, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Disable", reflect.TypeOf((*MockTwoFactorService)(nil).Disable), ctx, id, code)
+}
+
+// Enable mocks base method.
+func (m *MockTwoFactorService) Enable(ctx context.Context, id domain.UserID, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enable", ctx, id, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enable indicates an expected call of Enable.
+func (mr *MockTwoFactorServiceMockRecorder) Enable(ctx, id, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enable", reflect.TypeOf((*MockTwoFactorService)(nil).Enable), ctx, id, code)
+}
+
+// Enroll mocks base method.
+func (m *MockTwoFactorService) Enroll(ctx context.Context, id domain.UserID) (domain.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enroll", ctx, id)
+	ret0, _ := ret[0].(domain.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Enroll indicates an expected call of Enroll.
+func (mr *MockTwoFactorServiceMockRecorder) Enroll(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enroll", reflect.TypeOf((*MockTwoFactorService)(nil).Enroll), ctx, id)
+}
+
+// Verify mocks base method.
+func (m *MockTwoFactorService) Verify(ctx context.Context, id domain.UserID, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, id, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockTwoFactorServiceMockRecorder) Verify(ctx, id, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockTwoFactorService)(nil).Verify), ctx, id, code)
+}
+
+// MockAuditService is a mock of AuditService interface.
+type MockAuditService struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuditServiceMockRecorder
+}
+
+// MockAuditServiceMockRecorder is the mock recorder for MockAuditService.
+type MockAuditServiceMockRecorder struct {
+	mock *MockAuditService
+}
+
+// NewMockAuditService creates a new mock instance.
+func NewMockAuditService(ctrl *gomock.Controller) *MockAuditService {
+	mock := &MockAuditService{ctrl: ctrl}
+	mock.recorder = &MockAuditServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuditService) EXPECT() *MockAuditServiceMockRecorder {
+	return m.recorder
+}
+
+// List mocks base method.
+func (m *MockAuditService) List(ctx context.Context, filter domain.SecurityEventFilter) ([]domain.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, filter)
+	ret0, _ := ret[0].([]domain.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockAuditServiceMockRecorder) List(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockAuditService)(nil).List), ctx, filter)
+}
+
+// Record mocks base method.
+func (m *MockAuditService) Record(ctx context.Context, event domain.SecurityEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Record", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockAuditServiceMockRecorder) Record(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockAuditService)(nil).Record), ctx, event)
+}
+
 // MockUserService is a mock of UserService interface.
 type MockUserService struct {
 	ctrl     *gomock.Controller
@@ -140,6 +471,21 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 	return m.recorder
 }
 
+// Export mocks base method.
+func (m *MockUserService) Export(ctx context.Context, id domain.UserID) (domain.UserExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx, id)
+	ret0, _ := ret[0].(domain.UserExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockUserServiceMockRecorder) Export(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockUserService)(nil).Export), ctx, id)
+}
+
 // GetBalance mocks base method.
 func (m *MockUserService) GetBalance(ctx context.Context, id domain.UserID) (domain.UserBalance, error) {
 	m.ctrl.T.Helper()
@@ -155,6 +501,20 @@ func (mr *MockUserServiceMockRecorder) GetBalance(ctx, id interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBalance", reflect.TypeOf((*MockUserService)(nil).GetBalance), ctx, id)
 }
 
+// SetRole mocks base method.
+func (m *MockUserService) SetRole(ctx context.Context, login string, role domain.Role) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRole", ctx, login, role)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRole indicates an expected call of SetRole.
+func (mr *MockUserServiceMockRecorder) SetRole(ctx, login, role interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRole", reflect.TypeOf((*MockUserService)(nil).SetRole), ctx, login, role)
+}
+
 // MockOrderService is a mock of OrderService interface.
 type MockOrderService struct {
 	ctrl     *gomock.Controller
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sergeizaitcev/gophermart/pkg/luhn"
 	"github.com/sergeizaitcev/gophermart/pkg/monetary"
@@ -20,6 +21,10 @@ type Order struct {
 	Status     OrderStatus   `json:"status"`
 	Accrual    monetary.Unit `json:"accrual,omitempty"`
 	UploadedAt time.Time     `json:"uploaded_at,omitempty"`
+
+	// Необязательные сведения о покупке, переданные при загрузке заказа.
+	Store       string     `json:"store,omitempty"`        // Магазин.
+	PurchasedAt *time.Time `json:"purchased_at,omitempty"` // Время покупки.
 }
 
 // IsEmpty возвращает true, если заказ пользователя пуст.
@@ -31,7 +36,51 @@ func (o Order) IsEmpty() bool {
 func (o Order) Equal(x Order) bool {
 	return o.UserID == x.UserID && o.Number == x.Number && o.Status == x.Status &&
 		o.Accrual == x.Accrual &&
-		o.UploadedAt.Equal(x.UploadedAt)
+		o.UploadedAt.Equal(x.UploadedAt) &&
+		o.Store == x.Store &&
+		equalTimes(o.PurchasedAt, x.PurchasedAt)
+}
+
+// equalTimes возвращает true, если оба времени не заданы или равны.
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Максимальная длина названия магазина.
+const maxStoreLen = 128
+
+// OrderUpload определяет тело запроса на загрузку заказа в формате JSON.
+type OrderUpload struct {
+	Number      OrderNumber `json:"order"`                  // Номер заказа.
+	Store       string      `json:"store,omitempty"`        // Магазин.
+	PurchasedAt *time.Time  `json:"purchased_at,omitempty"` // Время покупки.
+}
+
+// Validate проверяет сведения о покупке и возвращает *ValidationError; номер
+// заказа проверяется отдельно через OrderNumber.Validate.
+func (u OrderUpload) Validate(now time.Time) error {
+	verr := &ValidationError{}
+	if utf8.RuneCountInString(u.Store) > maxStoreLen {
+		verr.add("store", CodeTooLong, "store must be at most %d characters", maxStoreLen)
+	}
+	if u.PurchasedAt != nil && u.PurchasedAt.After(now) {
+		verr.add("purchased_at", CodeInFuture, "purchased_at must not be in the future")
+	}
+	return verr.errorOrNil()
+}
+
+// Order возвращает новый заказ пользователя id.
+func (u OrderUpload) Order(id UserID) Order {
+	return Order{
+		UserID:      id,
+		Number:      u.Number,
+		Status:      OrderStatusNew,
+		Store:       u.Store,
+		PurchasedAt: u.PurchasedAt,
+	}
 }
 
 // OrderNumber определяет номер заказа.
@@ -50,10 +99,10 @@ func NewOrderNumber(s string) (OrderNumber, error) {
 func (o OrderNumber) Validate() error {
 	str := string(o)
 	if !strutil.OnlyDigits(str) {
-		return fmt.Errorf("order number must contain only digits: %q", str)
+		return fmt.Errorf("%w: %q", ErrOrderNumberNotDigits, str)
 	}
 	if !luhn.Check(str) {
-		return fmt.Errorf("hash amount of the order number is invalid: %q", str)
+		return fmt.Errorf("%w: %q", ErrOrderNumberChecksum, str)
 	}
 	return nil
 }
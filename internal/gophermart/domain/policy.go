@@ -0,0 +1,215 @@
+package domain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды ошибок валидации полей.
+const (
+	CodeRequired          = "required"
+	CodeTooShort          = "too_short"
+	CodeTooLong           = "too_long"
+	CodeInvalidCharacters = "invalid_characters"
+	CodeTooWeak           = "too_weak"
+	CodeBanned            = "banned"
+	CodeInFuture          = "in_future"
+)
+
+// FieldError определяет ошибку валидации поля.
+type FieldError struct {
+	Field   string `json:"field"`   // Имя поля в теле запроса.
+	Code    string `json:"code"`    // Код ошибки.
+	Message string `json:"message"` // Описание ошибки.
+}
+
+// ValidationError возвращается, если одно или несколько полей не прошли
+// валидацию.
+type ValidationError struct {
+	Fields []FieldError `json:"errors"`
+}
+
+func (err *ValidationError) Error() string {
+	msgs := make([]string, len(err.Fields))
+	for i, f := range err.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// add добавляет ошибку валидации поля.
+func (err *ValidationError) add(field, code, format string, args ...any) {
+	err.Fields = append(err.Fields, FieldError{
+		Field:   field,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// errorOrNil возвращает nil, если ошибок валидации нет.
+func (err *ValidationError) errorOrNil() error {
+	if len(err.Fields) == 0 {
+		return nil
+	}
+	return err
+}
+
+// Ограничения длины логина и пароля по умолчанию.
+const (
+	defaultLoginMinLen    = 3
+	defaultPasswordMinLen = 8
+	defaultPasswordMaxLen = 72
+)
+
+// CredentialsPolicy определяет политику логинов и паролей пользователей.
+//
+// Нулевые значения ограничений отключают их, кроме LoginMinLen,
+// PasswordMinLen и PasswordMaxLen, которые заменяются значениями
+// по умолчанию.
+type CredentialsPolicy struct {
+	// Минимальная и максимальная длина логина в символах.
+	//
+	// По умолчанию минимальная длина 3.
+	LoginMinLen, LoginMaxLen int
+
+	// Допустимый формат логина.
+	LoginPattern *regexp.Regexp
+
+	// Минимальная длина пароля в символах.
+	//
+	// По умолчанию 8.
+	PasswordMinLen int
+
+	// Максимальная длина пароля в символах.
+	//
+	// По умолчанию 72.
+	PasswordMaxLen int
+
+	// Минимальное количество классов символов в пароле: строчные и прописные
+	// буквы, цифры, прочие символы.
+	PasswordMinClasses int
+
+	// Запрещённые пароли.
+	BannedPasswords BannedPasswords
+}
+
+func (p CredentialsPolicy) loginMinLen() int {
+	if p.LoginMinLen <= 0 {
+		return defaultLoginMinLen
+	}
+	return p.LoginMinLen
+}
+
+func (p CredentialsPolicy) passwordMinLen() int {
+	if p.PasswordMinLen <= 0 {
+		return defaultPasswordMinLen
+	}
+	return p.PasswordMinLen
+}
+
+func (p CredentialsPolicy) passwordMaxLen() int {
+	if p.PasswordMaxLen <= 0 {
+		return defaultPasswordMaxLen
+	}
+	return p.PasswordMaxLen
+}
+
+// validateLogin проверяет логин на соответствие политике.
+func (p CredentialsPolicy) validateLogin(verr *ValidationError, field, login string) {
+	n := utf8.RuneCountInString(login)
+	switch {
+	case n == 0:
+		verr.add(field, CodeRequired, "login must be not empty")
+	case n < p.loginMinLen():
+		verr.add(field, CodeTooShort, "login must be at least %d characters", p.loginMinLen())
+	case p.LoginMaxLen > 0 && n > p.LoginMaxLen:
+		verr.add(field, CodeTooLong, "login must be at most %d characters", p.LoginMaxLen)
+	case p.LoginPattern != nil && !p.LoginPattern.MatchString(login):
+		verr.add(field, CodeInvalidCharacters, "login contains invalid characters")
+	}
+}
+
+// validatePassword проверяет пароль на соответствие политике.
+func (p CredentialsPolicy) validatePassword(verr *ValidationError, field, password string) {
+	n := utf8.RuneCountInString(password)
+	switch {
+	case n == 0:
+		verr.add(field, CodeRequired, "password must be not empty")
+	case n < p.passwordMinLen():
+		verr.add(field, CodeTooShort, "password must be at least %d characters", p.passwordMinLen())
+	case n > p.passwordMaxLen():
+		verr.add(field, CodeTooLong, "password must be at most %d characters", p.passwordMaxLen())
+	case passwordClasses(password) < p.PasswordMinClasses:
+		verr.add(
+			field,
+			CodeTooWeak,
+			"password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols",
+			p.PasswordMinClasses,
+		)
+	case p.BannedPasswords.Contains(password):
+		verr.add(field, CodeBanned, "password is too common")
+	}
+}
+
+// validateRequired проверяет наличие значения и его максимальную длину.
+func validateRequired(verr *ValidationError, field, value string, maxLen int) {
+	n := utf8.RuneCountInString(value)
+	switch {
+	case n == 0:
+		verr.add(field, CodeRequired, "%s must be not empty", field)
+	case maxLen > 0 && n > maxLen:
+		verr.add(field, CodeTooLong, "%s must be at most %d characters", field, maxLen)
+	}
+}
+
+// passwordClasses возвращает количество классов символов в пароле.
+func passwordClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+// BannedPasswords определяет список запрещённых паролей; сравнение
+// выполняется без учёта регистра.
+type BannedPasswords map[string]struct{}
+
+// ReadBannedPasswords читает список запрещённых паролей по одному в строке;
+// пустые строки и строки, начинающиеся с #, пропускаются.
+func ReadBannedPasswords(r io.Reader) (BannedPasswords, error) {
+	banned := make(BannedPasswords)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		banned[strings.ToLower(line)] = struct{}{}
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("reading banned passwords: %w", err)
+	}
+	return banned, nil
+}
+
+// Contains возвращает true, если пароль запрещён.
+func (b BannedPasswords) Contains(password string) bool {
+	_, ok := b[strings.ToLower(password)]
+	return ok
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "fmt"
+
+// Role определяет роль пользователя.
+type Role string
+
+// Роли пользователей.
+const (
+	RoleUser  Role = "user"  // Пользователь.
+	RoleAdmin Role = "admin" // Администратор.
+)
+
+// Validate возвращает ошибку, если роль не поддерживается.
+func (r Role) Validate() error {
+	switch r {
+	case RoleUser, RoleAdmin:
+		return nil
+	default:
+		return fmt.Errorf("unsupported role: %q", r)
+	}
+}
+
+// RoleChange определяет данные для смены роли пользователя.
+type RoleChange struct {
+	Role Role `json:"role"` // Новая роль.
+}
@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionID определяет уникальный идентификатор сеанса пользователя.
+type SessionID = uuid.UUID
+
+var EmptySessionID = uuid.Nil
+
+// Session определяет сеанс пользователя.
+type Session struct {
+	ID           SessionID // Уникальный идентификатор сеанса.
+	UserID       UserID    // Уникальный идентификатор пользователя.
+	Role         Role      // Роль пользователя.
+	RefreshToken string    // Токен обновления сеанса.
+	ExpiresAt    time.Time // Время истечения сеанса.
+}
+
+// Identity возвращает идентификационные данные владельца сеанса.
+func (s Session) Identity() Identity {
+	return Identity{
+		UserID:    s.UserID,
+		SessionID: s.ID,
+		Role:      s.Role,
+	}
+}
+
+// Identity определяет идентификационные данные владельца токена авторизации
+// или ключа API.
+type Identity struct {
+	UserID    UserID    `json:"uid"`  // Уникальный идентификатор пользователя.
+	SessionID SessionID `json:"sid"`  // Уникальный идентификатор сеанса.
+	Role      Role      `json:"role"` // Роль пользователя.
+
+	// Уникальный идентификатор и права доступа ключа API; не передаются
+	// в токене авторизации.
+	APIKeyID APIKeyID `json:"-"`
+	Scopes   []Scope  `json:"-"`
+}
+
+// IsEmpty возвращает true, если идентификационные данные пусты.
+func (i Identity) IsEmpty() bool {
+	return i.UserID == EmptyUserID ||
+		(i.SessionID == EmptySessionID && i.APIKeyID == EmptyAPIKeyID)
+}
+
+// IsAPIKey возвращает true, если запрос авторизован ключом API.
+func (i Identity) IsAPIKey() bool {
+	return i.APIKeyID != EmptyAPIKeyID
+}
+
+// HasScope возвращает true, если владельцу разрешено действие scope; сеансу
+// пользователя разрешены все действия.
+func (i Identity) HasScope(scope Scope) bool {
+	if !i.IsAPIKey() {
+		return true
+	}
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
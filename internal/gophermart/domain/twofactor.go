@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// TOTPEnrollment определяет данные для подключения второго фактора TOTP.
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`         // Секрет в base32.
+	URI           string   `json:"uri"`            // otpauth URI для приложения аутентификации.
+	RecoveryCodes []string `json:"recovery_codes"` // Одноразовые коды восстановления.
+}
+
+// TwoFactorCode определяет код второго фактора.
+type TwoFactorCode struct {
+	Code string `json:"code"` // Код TOTP или код восстановления.
+}
+
+// Validate возвращает ошибку, если код пуст.
+func (c TwoFactorCode) Validate() error {
+	if c.Code == "" {
+		return errors.New("code must be not empty")
+	}
+	return nil
+}
+
+// TwoFactorChallenge определяет запрос второго фактора при входе.
+type TwoFactorChallenge struct {
+	Token     string    `json:"challenge_token"` // Токен запроса.
+	ExpiresAt time.Time `json:"expires_at"`      // Время истечения запроса.
+}
+
+// TwoFactorResponse определяет ответ на запрос второго фактора при входе.
+type TwoFactorResponse struct {
+	Token string `json:"challenge_token"` // Токен запроса.
+	Code  string `json:"code"`            // Код TOTP или код восстановления.
+}
+
+// Validate возвращает ошибку, если ответ на запрос не валиден.
+func (r TwoFactorResponse) Validate() error {
+	if r.Token == "" {
+		return errors.New("challenge token must be not empty")
+	}
+	return TwoFactorCode{Code: r.Code}.Validate()
+}
@@ -1,26 +1,46 @@
 package domain
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
 
 	"github.com/sergeizaitcev/gophermart/pkg/monetary"
-	"github.com/sergeizaitcev/gophermart/pkg/passwords"
 )
 
 // User определяет пользователя.
 type User struct {
 	ID             UserID      // Уникальный идентификатор пользователя.
 	Login          string      // Логин пользователя.
+	Role           Role        // Роль пользователя.
 	HashedPassword string      // Хеш-сумма пароля пользователя.
 	Balance        UserBalance // Баланс пользователя.
 }
 
-// ComparePassword возвращает true, если пароль содержит хеш-сумму пароля.
-func (u User) ComparePassword(password string) bool {
-	return passwords.Compare(u.HashedPassword, password)
+// PasswordHasher описывает интерфейс хеширования паролей.
+type PasswordHasher interface {
+	// Hash возвращает хеш-сумму пароля.
+	Hash(password string) (string, error)
+
+	// Verify сравнивает пароль и его хеш-сумму; rehash равен true, если
+	// пароль верен, но хеш-сумма должна быть пересчитана.
+	Verify(hashedPassword, password string) (ok, rehash bool)
+}
+
+// VerifyPassword возвращает true, если пароль соответствует хеш-сумме пароля
+// пользователя; rehash равен true, если хеш-сумма устарела.
+func (u User) VerifyPassword(hasher PasswordHasher, password string) (ok, rehash bool) {
+	return hasher.Verify(u.HashedPassword, password)
+}
+
+// SetPassword заменяет хеш-сумму пароля пользователя.
+func (u *User) SetPassword(hasher PasswordHasher, password string) error {
+	hashedPassword, err := hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("hashing password: %w", err)
+	}
+	u.HashedPassword = hashedPassword
+	return nil
 }
 
 // UserBalance определяет баланс пользователя.
@@ -30,14 +50,12 @@ type UserBalance struct {
 }
 
 // NewUser конвертирует данные аутентификации в пользователя и возвращает его.
-func NewUser(auth Authentication) (User, error) {
-	var user User
-	hashedPassword, err := passwords.Hash(auth.Password)
+func NewUser(auth Authentication, hasher PasswordHasher) (User, error) {
+	user := User{Login: auth.Login}
+	err := user.SetPassword(hasher, auth.Password)
 	if err != nil {
-		return user, fmt.Errorf("hashing password: %w", err)
+		return User{}, err
 	}
-	user.Login = auth.Login
-	user.HashedPassword = hashedPassword
 	return user, nil
 }
 
@@ -62,16 +80,36 @@ type Authentication struct {
 	Password string `json:"password"` // Пароль пользователя.
 }
 
-func (a Authentication) Validate() error {
-	const maxPassLen = 72
-	if a.Login == "" {
-		return errors.New("login must be not empty")
-	}
-	if a.Password == "" {
-		return errors.New("password must be not empty")
-	}
-	if len(a.Password) > maxPassLen {
-		return fmt.Errorf("length of pass must be is less than or equal to %d", maxPassLen)
-	}
-	return nil
+// Validate проверяет данные аутентификации на соответствие политике при
+// регистрации и возвращает *ValidationError.
+func (a Authentication) Validate(p CredentialsPolicy) error {
+	verr := &ValidationError{}
+	p.validateLogin(verr, "login", a.Login)
+	p.validatePassword(verr, "password", a.Password)
+	return verr.errorOrNil()
+}
+
+// ValidateSignIn проверяет данные аутентификации при входе и возвращает
+// *ValidationError. Проверяются только наличие и максимальная длина полей,
+// чтобы пользователи, зарегистрированные до ужесточения политики, могли войти.
+func (a Authentication) ValidateSignIn(p CredentialsPolicy) error {
+	verr := &ValidationError{}
+	validateRequired(verr, "login", a.Login, p.LoginMaxLen)
+	validateRequired(verr, "password", a.Password, p.passwordMaxLen())
+	return verr.errorOrNil()
+}
+
+// PasswordChange определяет данные для смены пароля пользователя.
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password"` // Текущий пароль.
+	NewPassword     string `json:"new_password"`     // Новый пароль.
+}
+
+// Validate проверяет данные для смены пароля на соответствие политике
+// и возвращает *ValidationError.
+func (c PasswordChange) Validate(p CredentialsPolicy) error {
+	verr := &ValidationError{}
+	validateRequired(verr, "current_password", c.CurrentPassword, p.passwordMaxLen())
+	p.validatePassword(verr, "new_password", c.NewPassword)
+	return verr.errorOrNil()
 }
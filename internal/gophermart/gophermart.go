@@ -2,41 +2,102 @@ package gophermart
 
 import (
 	"context"
+	"crypto/x509"
+	"database/sql"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"log/slog"
 
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	grpccredentials "google.golang.org/grpc/credentials"
 
 	"github.com/sergeizaitcev/gophermart/deployments/gophermart/migrations"
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/clients/accrual"
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/config"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/grpcapi"
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/handler"
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
 	"github.com/sergeizaitcev/gophermart/pkg/commands"
+	"github.com/sergeizaitcev/gophermart/pkg/grpcserver"
+	"github.com/sergeizaitcev/gophermart/pkg/health"
 	"github.com/sergeizaitcev/gophermart/pkg/httpserver"
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/passwords"
 	"github.com/sergeizaitcev/gophermart/pkg/postgres"
 	"github.com/sergeizaitcev/gophermart/pkg/sign"
 	"github.com/sergeizaitcev/gophermart/pkg/throttling"
+	"github.com/sergeizaitcev/gophermart/pkg/tlsutil"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
+	"github.com/sergeizaitcev/gophermart/pkg/ttlcache"
 )
 
 // Run запускает gophermart и блокируется до тех пор, пока не сработает
 // контекст или функция не вернёт ошибку.
+//
+// Подкоманда grant-admin назначает роль администратора существующему
+// пользователю:
+//
+//	gophermart grant-admin -d <database uri> -login <login>
 func Run(ctx context.Context) error {
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "grant-admin" {
+		cmd := commands.New("gophermart grant-admin", runGrantAdmin)
+		return cmd.ExecuteArgs(ctx, args[1:])
+	}
+
 	cmd := commands.New("gophermart", runGophermart)
 	return cmd.Execute(ctx)
 }
 
+func runGrantAdmin(ctx context.Context, c *config.GrantAdminConfig) error {
+	setupLogger(c.Level)
+
+	db, err := postgres.Connect(c.DatabaseURI)
+	if err != nil {
+		return fmt.Errorf("connecting to the postgres: %w", err)
+	}
+	defer db.Close()
+
+	err = migrations.Up(ctx, db)
+	if err != nil {
+		return fmt.Errorf("migration up: %w", err)
+	}
+
+	err = service.NewUsers(db).SetRole(ctx, c.Login, domain.RoleAdmin)
+	if err != nil {
+		return fmt.Errorf("granting the admin role to %q: %w", c.Login, err)
+	}
+
+	slog.Info("admin role granted", slog.String("login", c.Login))
+
+	return nil
+}
+
 func runGophermart(ctx context.Context, c *config.Config) error {
-	setupLogger(c)
+	setupLogger(c.Level)
+
+	tracer, err := tracing.Setup("gophermart", c.TraceExporter, c.TraceEndpoint)
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	defer shutdownTracer(tracer)
 
-	signer, err := newSigner(c)
+	signer, keys, err := newSigner(c)
 	if err != nil {
 		return fmt.Errorf("creating a new signer: %w", err)
 	}
+	go reloadSigningKeys(ctx, c, keys)
 
 	db, err := postgres.Connect(c.DatabaseURI)
 	if err != nil {
@@ -49,43 +110,405 @@ func runGophermart(ctx context.Context, c *config.Config) error {
 		return fmt.Errorf("migration up: %w", err)
 	}
 
-	accrual := newAccrualClient(c)
+	hasher, err := newPasswordHasher(c)
+	if err != nil {
+		return fmt.Errorf("creating a password hasher: %w", err)
+	}
+
+	credentials, err := newCredentialsPolicy(c)
+	if err != nil {
+		return fmt.Errorf("creating a credentials policy: %w", err)
+	}
+
+	identities := service.NewIdentityCache(c.IdentityCacheSize, c.IdentityCacheTTL)
+	go listenIdentityInvalidation(ctx, c, identities)
+
+	accrual, err := newAccrualClient(c)
+	if err != nil {
+		return fmt.Errorf("creating an accrual client: %w", err)
+	}
+
+	// Общий бюджет остановки отсчитывается с начала остановки первого из
+	// серверов: сначала завершаются запросы, затем в оставшееся время
+	// обработчики завершают текущие заказы.
+	var (
+		stopOnce sync.Once
+		stopping time.Time
+	)
+	markStopping := func() {
+		stopOnce.Do(func() { stopping = time.Now() })
+	}
 
 	orders := service.NewOrders(db, accrual)
-	defer orders.Close()
+	defer func() {
+		if stopping.IsZero() {
+			stopping = time.Now()
+		}
+
+		ctx, cancel := context.WithDeadline(context.Background(), stopping.Add(c.ShutdownTimeout))
+		defer cancel()
+
+		if err := orders.Close(ctx); err != nil {
+			slog.Error(err.Error(), slog.String("scope", "stopping order processing"))
+		}
+	}()
+
+	restored, err := orders.Restore(ctx)
+	if err != nil {
+		return fmt.Errorf("restoring the order queue: %w", err)
+	}
+	if restored > 0 {
+		slog.Info("order queue restored", slog.Int("orders", restored))
+	}
+
+	metrics.MustRegister(metrics.NewDBStats(db.Stats)...)
+	metrics.MustRegister(newOrderStatusMetric(orders))
+
+	probe := health.NewProbe(0)
+	probe.Register("database", db.PingContext)
+	probe.Register("migrations", func(ctx context.Context) error {
+		return migrations.Check(ctx, db)
+	})
+	probe.Register("accrual", accrual.Ping)
+	probe.Register("orders_queue", orders.CheckQueue)
+
+	var (
+		auth       = newAuth(c, db, hasher, identities)
+		apiKeys    = service.NewAPIKeys(db)
+		twoFactor  = newTwoFactor(c, db)
+		users      = service.NewUsers(db)
+		audit      = service.NewAudit(db)
+		operations = service.NewOperations(db)
+
+		withdrawalCodeThreshold = monetary.Format(c.WithdrawalCodeThreshold)
+	)
 
 	handler := handler.New(handler.HandlerOptions{
-		Auth:       service.NewAuth(db),
-		Orders:     orders,
-		Users:      service.NewUsers(db),
-		Operations: service.NewOperations(db),
-		Signer:     signer,
+		Auth:        auth,
+		APIKeys:     apiKeys,
+		TwoFactor:   twoFactor,
+		Orders:      orders,
+		Users:       users,
+		Audit:       audit,
+		Operations:  operations,
+		Signer:      signer,
+		Keys:        keys,
+		Credentials: credentials,
+
+		WithdrawalCodeThreshold: withdrawalCodeThreshold,
+
+		TrustedProxies: c.TrustedProxies,
+		RateLimits: handler.RateLimits{
+			IP:          c.RateLimitIP,
+			User:        c.RateLimitUser,
+			Routes:      c.RateLimitRoutes,
+			IdleTimeout: c.RateLimitIdle,
+		},
+		BodyLimits: handler.BodyLimits{
+			Default: c.MaxBodySize,
+			Routes:  c.MaxBodySizeRoutes,
+		},
+
+		Health: probe,
+	})
+
+	tlsConfig, err := tlsutil.LoadServerConfig(c.TLSCertFile, c.TLSKeyFile, c.TLSClientCAFile)
+	if err != nil {
+		return fmt.Errorf("loading the TLS configuration: %w", err)
+	}
+
+	// HTTP-сервер проверяет сертификат клиента на каждом маршруте, кроме
+	// проверок состояния; gRPC-сервер по-прежнему требует его при рукопожатии.
+	if c.TLSClientCAFile != "" {
+		handler = tlsutil.RequireClientCert(handler, "/healthz", "/readyz")
+	}
+
+	srv := httpserver.New(handler)
+	srv.TLSConfig = tlsutil.OptionalClientCert(tlsConfig)
+	srv.ShutdownTimeout = c.ShutdownTimeout
+	srv.RegisterOnShutdown(markStopping)
+	srv.RegisterOnShutdown(probe.Drain)
+
+	// Серверы останавливаются вместе: ошибка одного из них останавливает
+	// другой.
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return srv.ListenAndServe(gctx, c.RunAddress)
+	})
+
+	if c.MetricsAddress != "" {
+		g.Go(func() error {
+			return httpserver.ListenAndServe(gctx, c.MetricsAddress, metrics.ServeMux())
+		})
+	}
+
+	if c.GRPCAddress != "" {
+		var opts []grpc.ServerOption
+		if tlsConfig != nil {
+			opts = append(opts, grpc.Creds(grpccredentials.NewTLS(tlsConfig)))
+		}
+
+		grpcSrv := grpcserver.New(grpcapi.New(grpcapi.ServerOptions{
+			Auth:        auth,
+			APIKeys:     apiKeys,
+			TwoFactor:   twoFactor,
+			Orders:      orders,
+			Users:       users,
+			Audit:       audit,
+			Operations:  operations,
+			Signer:      signer,
+			Credentials: credentials,
+
+			WithdrawalCodeThreshold: withdrawalCodeThreshold,
+
+			RateLimits: grpcapi.RateLimits{
+				IP:          c.RateLimitIP,
+				User:        c.RateLimitUser,
+				IdleTimeout: c.RateLimitIdle,
+			},
+		}, opts...))
+		grpcSrv.ShutdownTimeout = c.ShutdownTimeout
+		grpcSrv.RegisterOnShutdown(markStopping)
+
+		g.Go(func() error {
+			return grpcSrv.ListenAndServe(gctx, c.GRPCAddress)
+		})
+	}
+
+	return g.Wait()
+}
+
+// shutdownTracer отправляет накопленные участки трассировки.
+func shutdownTracer(tracer *tracing.Tracer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := tracer.Shutdown(ctx); err != nil {
+		slog.Error(err.Error(), slog.String("scope", "tracing"))
+	}
+}
+
+// Время, в течение которого переиспользуется подсчёт заказов по статусам,
+// чтобы частые сборы метрик не нагружали БД.
+const orderStatusMetricTTL = 30 * time.Second
+
+// newOrderStatusMetric возвращает метрику количества заказов по статусам;
+// подсчёт в БД выполняется не чаще одного раза за orderStatusMetricTTL.
+func newOrderStatusMetric(orders *service.Orders) metrics.Collector {
+	var mu sync.Mutex
+	cache := ttlcache.New[struct{}, []metrics.Sample](1, orderStatusMetricTTL)
+
+	return metrics.NewGaugeFunc(
+		"orders_by_status",
+		"Количество заказов по статусам.",
+		[]string{"status"},
+		func() []metrics.Sample {
+			// Одновременные сборы ждут одного подсчёта.
+			mu.Lock()
+			defer mu.Unlock()
+
+			if samples, ok := cache.Get(struct{}{}); ok {
+				return samples
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			counts, err := orders.CountByStatus(ctx)
+			if err != nil {
+				slog.Error(err.Error(), slog.String("scope", "orders metrics"))
+				return nil
+			}
+
+			samples := make([]metrics.Sample, 0, len(counts))
+			for status, count := range counts {
+				samples = append(samples, metrics.Sample{
+					Values: []string{status.String()},
+					Value:  float64(count),
+				})
+			}
+			cache.Set(struct{}{}, samples)
+
+			return samples
+		},
+	)
+}
+
+func newAuth(
+	c *config.Config,
+	db *sql.DB,
+	hasher *passwords.Hasher,
+	identities *service.IdentityCache,
+) *service.Auth {
+	return service.NewAuth(db, service.AuthOptions{
+		SessionTTL:         c.RefreshTokenTTL,
+		SessionMaxLifetime: c.SessionMaxLifetime,
+		Passwords:          hasher,
+		Identities:         identities,
+		Throttling: service.LoginThrottling{
+			FreeAttempts:          c.LoginFreeAttempts,
+			BaseDelay:             c.LoginBaseDelay,
+			MaxDelay:              c.LoginMaxDelay,
+			LoginLockoutThreshold: c.LoginLockoutThreshold,
+			IPLockoutThreshold:    c.IPLockoutThreshold,
+			LockoutDuration:       c.LoginLockoutDuration,
+			Window:                c.LoginAttemptsWindow,
+		},
+	})
+}
+
+func newTwoFactor(c *config.Config, db *sql.DB) *service.TwoFactor {
+	return service.NewTwoFactor(db, service.TwoFactorOptions{
+		Issuer:       c.TOTPIssuer,
+		CodeAttempts: c.TOTPCodeAttempts,
+		CodeLockout:  c.TOTPCodeLockout,
 	})
+}
+
+// listenIdentityInvalidation сбрасывает кешированные сеансы по уведомлениям
+// базы данных и блокируется до тех пор, пока не сработает контекст. Без
+// подписки изменения других экземпляров учитываются по истечении времени
+// жизни записей кеша.
+func listenIdentityInvalidation(
+	ctx context.Context,
+	c *config.Config,
+	identities *service.IdentityCache,
+) {
+	err := service.ListenIdentityInvalidation(ctx, c.DatabaseURI, identities)
+	if err != nil {
+		slog.Error(err.Error(), slog.String("scope", "identity invalidation"))
+	}
+}
+
+func newPasswordHasher(c *config.Config) (*passwords.Hasher, error) {
+	pepper, err := c.Pepper()
+	if err != nil {
+		return nil, fmt.Errorf("getting a pepper: %w", err)
+	}
+
+	argon2id := passwords.Argon2id{
+		Memory:  uint32(c.Argon2Memory),
+		Time:    uint32(c.Argon2Time),
+		Threads: uint8(c.Argon2Threads),
+	}
 
-	return httpserver.ListenAndServe(ctx, c.RunAddress, handler)
+	if c.PasswordHash == config.PasswordHashBcrypt {
+		return passwords.New(
+			passwords.Bcrypt{Cost: c.BcryptCost},
+			passwords.WithPepper(pepper),
+			passwords.WithLegacy(argon2id),
+		), nil
+	}
+
+	return passwords.New(argon2id, passwords.WithPepper(pepper)), nil
 }
 
-func setupLogger(c *config.Config) {
-	opts := &slog.HandlerOptions{Level: c.Level}
+func newCredentialsPolicy(c *config.Config) (domain.CredentialsPolicy, error) {
+	loginPattern, err := c.LoginRegexp()
+	if err != nil {
+		return domain.CredentialsPolicy{}, err
+	}
+
+	policy := domain.CredentialsPolicy{
+		LoginMinLen:        c.LoginMinLen,
+		LoginMaxLen:        c.LoginMaxLen,
+		LoginPattern:       loginPattern,
+		PasswordMinLen:     c.PasswordMinLen,
+		PasswordMaxLen:     c.PasswordMaxLen,
+		PasswordMinClasses: c.PasswordMinClasses,
+	}
+
+	if c.BannedPasswordsPath != "" {
+		f, err := os.Open(c.BannedPasswordsPath)
+		if err != nil {
+			return domain.CredentialsPolicy{}, fmt.Errorf("opening banned passwords: %w", err)
+		}
+		defer f.Close()
+
+		policy.BannedPasswords, err = domain.ReadBannedPasswords(f)
+		if err != nil {
+			return domain.CredentialsPolicy{}, err
+		}
+	}
+
+	return policy, nil
+}
+
+func setupLogger(level slog.Level) {
+	opts := &slog.HandlerOptions{Level: level}
 	handler := slog.NewJSONHandler(os.Stdout, opts)
 	slog.SetDefault(slog.New(handler))
 }
 
-func newSigner(c *config.Config) (sign.Signer, error) {
-	secretKey, err := c.SecretKey()
+func newSigner(c *config.Config) (sign.Signer, *sign.KeySet, error) {
+	active, keys, err := c.SigningKeys()
 	if err != nil {
-		return nil, fmt.Errorf("getting a secret key: %w", err)
+		return nil, nil, fmt.Errorf("getting signing keys: %w", err)
+	}
+	keySet, err := sign.NewKeySet(active, keys...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating a key set: %w", err)
+	}
+	return sign.NewWithKeySet(keySet, sign.WithTTL(c.TokenTTL)), keySet, nil
+}
+
+// reloadSigningKeys перечитывает ключи подписи при получении сигнала SIGHUP
+// и блокируется до тех пор, пока не сработает контекст.
+func reloadSigningKeys(ctx context.Context, c *config.Config, keySet *sign.KeySet) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+		}
+
+		active, keys, err := c.SigningKeys()
+		if err == nil {
+			err = keySet.Update(active, keys...)
+		}
+		if err != nil {
+			slog.Error(err.Error(), slog.String("scope", "reloading signing keys"))
+			continue
+		}
+
+		slog.Info("signing keys reloaded", slog.String("active", active))
 	}
-	return sign.New(secretKey, sign.WithTTL(c.TokenTTL)), nil
 }
 
-func newAccrualClient(c *config.Config) *accrual.Client {
+func newAccrualClient(c *config.Config) (*accrual.Client, error) {
 	limiter := rate.NewLimiter(rate.Every(time.Second), 1)
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 
+	if c.AccrualCAFile != "" || c.AccrualCertFile != "" {
+		var (
+			kp      *tlsutil.KeyPair
+			rootCAs *x509.CertPool
+			err     error
+		)
+		if c.AccrualCertFile != "" {
+			kp, err = tlsutil.LoadKeyPair(c.AccrualCertFile, c.AccrualKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading the client certificate: %w", err)
+			}
+		}
+		if c.AccrualCAFile != "" {
+			rootCAs, err = tlsutil.LoadCertPool(c.AccrualCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("loading the accrual CA: %w", err)
+			}
+		}
+		transport.TLSClientConfig = tlsutil.ClientConfig(kp, rootCAs)
+	}
+
 	opts := &accrual.ClientOption{
-		Transport: throttling.NewTransport(transport, limiter),
+		Transport:   throttling.NewTransport(transport, limiter),
+		Secure:      strings.HasPrefix(c.AccrualSystemAddress, "https://"),
+		Compression: true,
 	}
 
-	return accrual.NewClient(c.AccrualSystemAddress, opts)
+	return accrual.NewClient(c.AccrualSystemAddress, opts), nil
 }
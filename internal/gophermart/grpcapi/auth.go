@@ -0,0 +1,218 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	gophermartv1 "github.com/sergeizaitcev/gophermart/api/gophermart/v1"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+)
+
+// Ключи метаданных с токеном авторизации и ключом API.
+const (
+	authorizationKey = "authorization"
+	apiKeyKey        = "x-api-key"
+)
+
+// Ошибки авторизации.
+var (
+	errUnauthenticated    = errors.New("authentication required")
+	errInvalidCredentials = errors.New("invalid login or password")
+	errChallengeExpired   = errors.New("two-factor challenge expired")
+	errInsufficientScope  = errors.New("insufficient scope")
+)
+
+// publicMethods определяет методы, доступные без авторизации; остальные
+// методы, в том числе добавленные позже, требуют ключа API или токена.
+var publicMethods = map[string]struct{}{
+	gophermartv1.GophermartService_Register_FullMethodName:       {},
+	gophermartv1.GophermartService_Login_FullMethodName:          {},
+	gophermartv1.GophermartService_LoginTwoFactor_FullMethodName: {},
+}
+
+// methodScopes определяет права доступа ключа API, необходимые для вызова
+// метода; метод, отсутствующий в списке, доступен только по токену сеанса.
+var methodScopes = map[string]domain.Scope{
+	gophermartv1.GophermartService_UploadOrder_FullMethodName:     domain.ScopeOrdersWrite,
+	gophermartv1.GophermartService_ListOrders_FullMethodName:      domain.ScopeOrdersRead,
+	gophermartv1.GophermartService_GetBalance_FullMethodName:      domain.ScopeBalanceRead,
+	gophermartv1.GophermartService_Withdraw_FullMethodName:        domain.ScopeBalanceWrite,
+	gophermartv1.GophermartService_ListWithdrawals_FullMethodName: domain.ScopeBalanceRead,
+}
+
+// keyIdentity определяет ключ для передачи domain.Identity через контекст.
+type keyIdentity struct{}
+
+// userFromContext возвращает уникальный идентификатор пользователя
+// из контекста.
+func userFromContext(ctx context.Context) domain.UserID {
+	identity, _ := ctx.Value(keyIdentity{}).(domain.Identity)
+	return identity.UserID
+}
+
+// authorization проверяет наличие ключа API или токена авторизации
+// в метаданных вызова и прокидывает в контекст идентификационные данные
+// владельца; если ключ или токен не действителен или сеанс отозван, то
+// возвращает codes.Unauthenticated, а при превышении ограничения на IP-адрес
+// клиента — codes.ResourceExhausted.
+func (s *server) authorization(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if _, ok := publicMethods[info.FullMethod]; ok {
+		return handler(ctx, req)
+	}
+
+	identity, err := s.identify(ctx)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			logging.FromContext(ctx).Error(err.Error())
+			// Неудачная попытка расходует ограничение на IP-адрес, чтобы
+			// перебор токенов и ключей не обходил его.
+			if err := s.allowClient(ctx); err != nil {
+				return nil, err
+			}
+			return nil, status.Error(codes.Unauthenticated, errUnauthenticated.Error())
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	if !identity.HasScope(methodScopes[info.FullMethod]) {
+		return nil, status.Error(codes.PermissionDenied, errInsufficientScope.Error())
+	}
+
+	ctx = context.WithValue(ctx, keyIdentity{}, identity)
+	ctx = logging.With(ctx, slog.String("user_id", identity.UserID.String()))
+
+	return handler(ctx, req)
+}
+
+// identify возвращает идентификационные данные владельца ключа API или
+// токена авторизации из метаданных вызова; ключ API имеет приоритет.
+func (s *server) identify(ctx context.Context) (domain.Identity, error) {
+	return s.authn.Identify(ctx, metadataValue(ctx, apiKeyKey), metadataValue(ctx, authorizationKey))
+}
+
+// session возвращает токены сеанса в представлении API.
+func session(tokens service.SessionTokens) *gophermartv1.Session {
+	return &gophermartv1.Session{
+		AccessToken:      tokens.AccessToken,
+		TokenType:        service.TokenType,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: timestamppb.New(tokens.ExpiresAt),
+	}
+}
+
+// Register регистрирует пользователя и открывает его сеанс.
+func (s *server) Register(
+	ctx context.Context,
+	req *gophermartv1.RegisterRequest,
+) (*gophermartv1.RegisterResponse, error) {
+	auth := domain.Authentication{Login: req.GetLogin(), Password: req.GetPassword()}
+
+	err := auth.Validate(s.credentials)
+	if err != nil {
+		return nil, validationError(ctx, err)
+	}
+
+	tokens, err := s.authn.Register(ctx, auth)
+	if err != nil {
+		if errors.Is(err, domain.ErrDuplicate) {
+			return nil, statusError(ctx, codes.AlreadyExists, err)
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	return &gophermartv1.RegisterResponse{Session: session(tokens)}, nil
+}
+
+// Login выполняет вход пользователя и открывает его сеанс; если у
+// пользователя включён второй фактор, то возвращает запрос второго фактора.
+func (s *server) Login(
+	ctx context.Context,
+	req *gophermartv1.LoginRequest,
+) (*gophermartv1.LoginResponse, error) {
+	auth := domain.Authentication{Login: req.GetLogin(), Password: req.GetPassword()}
+
+	err := auth.ValidateSignIn(s.credentials)
+	if err != nil {
+		return nil, validationError(ctx, err)
+	}
+
+	res, err := s.authn.Login(ctx, auth)
+	if err != nil {
+		var exhausted *domain.ResourceExhaustedError
+		if errors.As(err, &exhausted) {
+			logging.FromContext(ctx).Error(err.Error())
+			return nil, retryAfterError(exhausted)
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			logging.FromContext(ctx).Error(err.Error())
+			return nil, status.Error(codes.Unauthenticated, errInvalidCredentials.Error())
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	if res.Challenge != nil {
+		return &gophermartv1.LoginResponse{
+			Result: &gophermartv1.LoginResponse_Challenge{
+				Challenge: &gophermartv1.TwoFactorChallenge{
+					ChallengeToken: res.Challenge.Token,
+					ExpiresAt:      timestamppb.New(res.Challenge.ExpiresAt),
+				},
+			},
+		}, nil
+	}
+
+	return &gophermartv1.LoginResponse{
+		Result: &gophermartv1.LoginResponse_Session{Session: session(res.Tokens)},
+	}, nil
+}
+
+// LoginTwoFactor завершает вход пользователя с включённым вторым фактором
+// и открывает его сеанс.
+func (s *server) LoginTwoFactor(
+	ctx context.Context,
+	req *gophermartv1.LoginTwoFactorRequest,
+) (*gophermartv1.LoginTwoFactorResponse, error) {
+	if s.twoFactor == nil {
+		return nil, status.Error(codes.Unimplemented, domain.ErrTwoFactorDisabled.Error())
+	}
+
+	resp := domain.TwoFactorResponse{Token: req.GetChallengeToken(), Code: req.GetCode()}
+
+	err := resp.Validate()
+	if err != nil {
+		return nil, statusError(ctx, codes.InvalidArgument, err)
+	}
+
+	tokens, err := s.authn.CompleteLogin(ctx, resp)
+	if err != nil {
+		var exhausted *domain.ResourceExhaustedError
+		switch {
+		case errors.As(err, &exhausted):
+			logging.FromContext(ctx).Error(err.Error())
+			return nil, retryAfterError(exhausted)
+		case errors.Is(err, domain.ErrNotFound):
+			logging.FromContext(ctx).Error(err.Error())
+			return nil, status.Error(codes.Unauthenticated, errChallengeExpired.Error())
+		case errors.Is(err, domain.ErrInvalidCode),
+			errors.Is(err, domain.ErrTwoFactorDisabled):
+			return nil, statusError(ctx, codes.Unauthenticated, err)
+		default:
+			return nil, internalError(ctx, err)
+		}
+	}
+
+	return &gophermartv1.LoginTwoFactorResponse{Session: session(tokens)}, nil
+}
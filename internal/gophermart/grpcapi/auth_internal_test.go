@@ -0,0 +1,58 @@
+package grpcapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	gophermartv1 "github.com/sergeizaitcev/gophermart/api/gophermart/v1"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+)
+
+type apiKeysStub struct {
+	domain.APIKeyService
+	identity domain.Identity
+}
+
+func (s apiKeysStub) Identify(context.Context, string) (domain.Identity, error) {
+	return s.identity, nil
+}
+
+// TestAuthorization_UnlistedMethod проверяет, что метод, отсутствующий
+// в publicMethods и methodScopes, не вызывается без авторизации и не
+// доступен по ключу API.
+func TestAuthorization_UnlistedMethod(t *testing.T) {
+	s := &server{authn: service.NewAuthenticator(service.AuthenticatorOptions{
+		APIKeys: apiKeysStub{identity: domain.Identity{
+			UserID:   uuid.New(),
+			APIKeyID: uuid.New(),
+			Scopes:   []domain.Scope{domain.ScopeOrdersRead, domain.ScopeOrdersWrite},
+		}},
+	})}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/gophermart.v1.GophermartService/Unlisted"}
+	handler := func(context.Context, any) (any, error) {
+		t.Fatal("handler called")
+		return nil, nil
+	}
+
+	_, err := s.authorization(context.Background(), nil, info, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(apiKeyKey, "key"))
+	_, err = s.authorization(ctx, nil, info, handler)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	info.FullMethod = gophermartv1.GophermartService_Login_FullMethodName
+	_, err = s.authorization(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+}
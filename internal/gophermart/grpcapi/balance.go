@@ -0,0 +1,116 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	gophermartv1 "github.com/sergeizaitcev/gophermart/api/gophermart/v1"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+)
+
+// errNonPositiveSum возвращается, если сумма списания не больше нуля.
+var errNonPositiveSum = errors.New("sum must be greater than zero")
+
+// GetBalance возвращает баланс пользователя.
+func (s *server) GetBalance(
+	ctx context.Context,
+	_ *gophermartv1.GetBalanceRequest,
+) (*gophermartv1.GetBalanceResponse, error) {
+	balance, err := s.users.GetBalance(ctx, userFromContext(ctx))
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, statusError(ctx, codes.NotFound, err)
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	return &gophermartv1.GetBalanceResponse{
+		Current:   int64(balance.Current),
+		Withdrawn: int64(balance.Withdrawn),
+	}, nil
+}
+
+// Withdraw списывает баллы пользователя в счёт оплаты заказа.
+func (s *server) Withdraw(
+	ctx context.Context,
+	req *gophermartv1.WithdrawRequest,
+) (*gophermartv1.WithdrawResponse, error) {
+	operation := domain.Operation{
+		UserID:      userFromContext(ctx),
+		OrderNumber: domain.OrderNumber(req.GetOrder()),
+		Sum:         monetary.Unit(req.GetSum()),
+	}
+
+	err := operation.OrderNumber.Validate()
+	if err != nil {
+		return nil, statusError(ctx, codes.InvalidArgument, err)
+	}
+	if operation.Sum <= 0 {
+		return nil, statusError(ctx, codes.InvalidArgument, errNonPositiveSum)
+	}
+
+	err = s.verifyWithdrawal(ctx, operation.Sum, req.GetTotpCode())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.operations.Perform(ctx, operation)
+	if err != nil {
+		if errors.Is(err, domain.ErrBalanceBelowZero) {
+			return nil, statusError(ctx, codes.FailedPrecondition, err)
+		}
+		return nil, internalError(ctx, err)
+	}
+
+	return &gophermartv1.WithdrawResponse{}, nil
+}
+
+// verifyWithdrawal проверяет свежий код второго фактора, если сумма
+// списания превышает порог.
+func (s *server) verifyWithdrawal(ctx context.Context, sum monetary.Unit, code string) error {
+	err := s.authn.VerifyWithdrawal(ctx, userFromContext(ctx), sum, code)
+	if err == nil {
+		return nil
+	}
+
+	var exhausted *domain.ResourceExhaustedError
+	switch {
+	case errors.As(err, &exhausted):
+		logging.FromContext(ctx).Error(err.Error())
+		return retryAfterError(exhausted)
+	case errors.Is(err, domain.ErrInvalidCode):
+		return statusError(ctx, codes.PermissionDenied, err)
+	default:
+		return internalError(ctx, err)
+	}
+}
+
+// ListWithdrawals возвращает списания пользователя; если списаний нет, то
+// возвращает пустой список.
+func (s *server) ListWithdrawals(
+	ctx context.Context,
+	_ *gophermartv1.ListWithdrawalsRequest,
+) (*gophermartv1.ListWithdrawalsResponse, error) {
+	operations, err := s.operations.GetOperations(ctx, userFromContext(ctx))
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, internalError(ctx, err)
+	}
+
+	resp := &gophermartv1.ListWithdrawalsResponse{
+		Withdrawals: make([]*gophermartv1.Withdrawal, len(operations)),
+	}
+	for i, operation := range operations {
+		resp.Withdrawals[i] = &gophermartv1.Withdrawal{
+			Order:       string(operation.OrderNumber),
+			Sum:         int64(operation.Sum),
+			ProcessedAt: timestamppb.New(operation.ProcessedAt),
+		}
+	}
+
+	return resp, nil
+}
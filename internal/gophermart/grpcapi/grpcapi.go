@@ -0,0 +1,273 @@
+// Package grpcapi реализует gRPC API gophermart для внутренних сервисов
+// поверх тех же сервисов предметной области, что и HTTP-обработчик.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	gophermartv1 "github.com/sergeizaitcev/gophermart/api/gophermart/v1"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/sign"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
+)
+
+// ServerOptions определяет опции для gRPC-сервера.
+type ServerOptions struct {
+	Auth       domain.AuthService
+	APIKeys    domain.APIKeyService
+	TwoFactor  domain.TwoFactorService
+	Operations domain.OperationService
+	Orders     domain.OrderService
+	Users      domain.UserService
+	Audit      domain.AuditService
+	Signer     sign.Signer
+
+	// Логгер журнала вызовов, передаваемый обработчикам через контекст.
+	//
+	// По умолчанию slog.Default().
+	Logger *slog.Logger
+
+	// Политика логинов и паролей пользователей.
+	Credentials domain.CredentialsPolicy
+
+	// Сумма списания, свыше которой требуется свежий код второго фактора;
+	// 0 отключает проверку.
+	WithdrawalCodeThreshold monetary.Unit
+
+	// Ограничения частоты вызовов.
+	RateLimits RateLimits
+}
+
+// server реализует gophermartv1.GophermartServiceServer.
+type server struct {
+	gophermartv1.UnimplementedGophermartServiceServer
+
+	authn    *service.Authenticator
+	logger   *slog.Logger
+	limiters rateLimiters
+
+	credentials domain.CredentialsPolicy
+
+	twoFactor  domain.TwoFactorService
+	operations domain.OperationService
+	orders     domain.OrderService
+	users      domain.UserService
+}
+
+// New возвращает новый gRPC-сервер с зарегистрированным
+// gophermartv1.GophermartService; opts дополняют опции сервера, например,
+// учётными данными TLS.
+func New(opt ServerOptions, opts ...grpc.ServerOption) *grpc.Server {
+	if opt.Logger == nil {
+		opt.Logger = slog.Default()
+	}
+	s := &server{
+		authn: service.NewAuthenticator(service.AuthenticatorOptions{
+			Auth:      opt.Auth,
+			APIKeys:   opt.APIKeys,
+			TwoFactor: opt.TwoFactor,
+			Audit:     opt.Audit,
+			Signer:    opt.Signer,
+
+			WithdrawalCodeThreshold: opt.WithdrawalCodeThreshold,
+		}),
+		logger:      opt.Logger,
+		limiters:    newRateLimiters(opt.RateLimits),
+		credentials: opt.Credentials,
+		twoFactor:   opt.TwoFactor,
+		operations:  opt.Operations,
+		orders:      opt.Orders,
+		users:       opt.Users,
+	}
+
+	opts = append(opts, grpc.ChainUnaryInterceptor(
+		traceCall,
+		observeCall,
+		s.logCall,
+		clientInfo,
+		s.limitClient,
+		s.authorization,
+		s.limitUser,
+	))
+
+	srv := grpc.NewServer(opts...)
+	gophermartv1.RegisterGophermartServiceServer(srv, s)
+
+	return srv
+}
+
+// metadataValue возвращает первое значение ключа key из метаданных вызова.
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// traceCall продолжает трассу из метаданных traceparent или начинает новую
+// и оборачивает вызов серверным участком с именем метода.
+func traceCall(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if sc, err := tracing.ParseTraceparent(metadataValue(ctx, tracing.TraceparentHeader)); err == nil {
+		ctx = tracing.ContextWithRemote(ctx, sc)
+	}
+
+	ctx, span := tracing.Start(ctx, info.FullMethod, tracing.KindServer,
+		tracing.String("rpc.system", "grpc"),
+		tracing.String("rpc.method", info.FullMethod),
+	)
+	defer span.End()
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	span.SetAttributes(tracing.Int("rpc.grpc.status_code", int(code)))
+	if isServerError(code) {
+		span.RecordError(err)
+	}
+
+	return resp, err
+}
+
+// logCall принимает идентификатор запроса из метаданных x-request-id или
+// создаёт новый, передаёт в контекст логгер и по завершении вызова
+// записывает в журнал метод, код ответа и длительность обработки.
+func (s *server) logCall(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	start := time.Now()
+
+	id := metadataValue(ctx, logging.RequestIDHeader)
+	if !logging.ValidRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(logging.RequestIDHeader, id))
+
+	ctx = logging.WithLogger(ctx, s.logger)
+	ctx = logging.WithRequestID(ctx, id)
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		ctx = logging.With(ctx, slog.String("trace_id", sc.TraceID.String()))
+	}
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	if isServerError(code) {
+		level = slog.LevelError
+	}
+
+	logging.FromContext(ctx).Log(ctx, level, "call",
+		slog.String("method", info.FullMethod),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+	)
+
+	return resp, err
+}
+
+// clientInfo прокидывает в контекст сведения о клиенте, выполняющем вызов.
+func clientInfo(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	var client domain.Client
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ip, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			ip = p.Addr.String()
+		}
+		client.IP = ip
+	}
+	client.UserAgent = metadataValue(ctx, "user-agent")
+
+	return handler(domain.WithClient(ctx, client), req)
+}
+
+// isServerError возвращает true, если код ответа означает ошибку сервера.
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unimplemented:
+		return true
+	default:
+		return false
+	}
+}
+
+// internalError записывает ошибку в журнал и возвращает codes.Internal без
+// подробностей.
+func internalError(ctx context.Context, err error) error {
+	logging.FromContext(ctx).Error(err.Error())
+	return status.Error(codes.Internal, "internal error")
+}
+
+// statusError записывает ошибку в журнал и возвращает её с кодом code.
+func statusError(ctx context.Context, code codes.Code, err error) error {
+	logging.FromContext(ctx).Error(err.Error())
+	return status.Error(code, err.Error())
+}
+
+// validationError записывает ошибку в журнал и возвращает
+// codes.InvalidArgument; ошибки валидации полей передаются в деталях
+// errdetails.BadRequest.
+func validationError(ctx context.Context, err error) error {
+	logging.FromContext(ctx).Error(err.Error())
+
+	var verr *domain.ValidationError
+	if !errors.As(err, &verr) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	details := &errdetails.BadRequest{}
+	for _, f := range verr.Fields {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       f.Field,
+			Description: f.Message,
+		})
+	}
+
+	st, detailsErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(details)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return st.Err()
+}
+
+// retryAfterError возвращает codes.ResourceExhausted со временем до
+// следующей попытки в деталях errdetails.RetryInfo.
+func retryAfterError(exhausted *domain.ResourceExhaustedError) error {
+	st, err := status.New(codes.ResourceExhausted, exhausted.Message).WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(exhausted.RetryAfter)},
+	)
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, exhausted.Message)
+	}
+	return st.Err()
+}
@@ -0,0 +1,583 @@
+package grpcapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	gophermartv1 "github.com/sergeizaitcev/gophermart/api/gophermart/v1"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	mock_domain "github.com/sergeizaitcev/gophermart/internal/gophermart/domain/mocks"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/grpcapi"
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/throttling"
+)
+
+type signerStub struct {
+	identity domain.Identity
+}
+
+func (*signerStub) Sign(payload string) (token string, err error) {
+	return "token", nil
+}
+
+func (s *signerStub) Parse(token string) (payload string, err error) {
+	b, err := json.Marshal(s.identity)
+	return string(b), err
+}
+
+type ServerSuite struct {
+	suite.Suite
+
+	ctrl *gomock.Controller
+
+	auth       *mock_domain.MockAuthService
+	apiKeys    *mock_domain.MockAPIKeyService
+	twoFactor  *mock_domain.MockTwoFactorService
+	operations *mock_domain.MockOperationService
+	orders     *mock_domain.MockOrderService
+	users      *mock_domain.MockUserService
+	audit      *mock_domain.MockAuditService
+
+	srv    *grpc.Server
+	conn   *grpc.ClientConn
+	client gophermartv1.GophermartServiceClient
+
+	userID   domain.UserID
+	identity domain.Identity
+}
+
+func TestServer(t *testing.T) {
+	suite.Run(t, new(ServerSuite))
+}
+
+func (suite *ServerSuite) SetupSuite() {
+	suite.ctrl = gomock.NewController(suite.T())
+
+	suite.auth = mock_domain.NewMockAuthService(suite.ctrl)
+	suite.apiKeys = mock_domain.NewMockAPIKeyService(suite.ctrl)
+	suite.twoFactor = mock_domain.NewMockTwoFactorService(suite.ctrl)
+	suite.operations = mock_domain.NewMockOperationService(suite.ctrl)
+	suite.orders = mock_domain.NewMockOrderService(suite.ctrl)
+	suite.users = mock_domain.NewMockUserService(suite.ctrl)
+	suite.audit = mock_domain.NewMockAuditService(suite.ctrl)
+
+	suite.userID = uuid.New()
+	suite.identity = domain.Identity{
+		UserID:    suite.userID,
+		SessionID: uuid.New(),
+		Role:      domain.RoleUser,
+	}
+
+	suite.srv = grpcapi.New(grpcapi.ServerOptions{
+		Auth:       suite.auth,
+		APIKeys:    suite.apiKeys,
+		TwoFactor:  suite.twoFactor,
+		Operations: suite.operations,
+		Orders:     suite.orders,
+		Users:      suite.users,
+		Audit:      suite.audit,
+		Signer:     &signerStub{identity: suite.identity},
+		Credentials: domain.CredentialsPolicy{
+			LoginMinLen:    3,
+			LoginMaxLen:    32,
+			LoginPattern:   regexp.MustCompile(`^[a-z0-9_]+$`),
+			PasswordMinLen: 8,
+		},
+		WithdrawalCodeThreshold: monetary.Format(1000),
+	})
+
+	l := bufconn.Listen(1 << 20)
+	go func() { _ = suite.srv.Serve(l) }()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	suite.Require().NoError(err)
+
+	suite.conn = conn
+	suite.client = gophermartv1.NewGophermartServiceClient(conn)
+}
+
+func (suite *ServerSuite) TearDownSuite() {
+	suite.conn.Close()
+	suite.srv.Stop()
+}
+
+// authorized возвращает контекст с токеном авторизации в метаданных.
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+}
+
+func (suite *ServerSuite) TestRegister() {
+	suite.Run("success", func() {
+		auth := domain.Authentication{Login: "gopher", Password: "s3cr3t_pass"}
+		session := domain.Session{
+			ID:           suite.identity.SessionID,
+			UserID:       suite.userID,
+			Role:         domain.RoleUser,
+			RefreshToken: "refresh",
+			ExpiresAt:    time.Now().Add(time.Hour).UTC(),
+		}
+
+		suite.auth.EXPECT().SignUp(gomock.Any(), auth).Return(suite.userID, nil)
+		suite.audit.EXPECT().Record(gomock.Any(), gomock.Any()).Return(nil)
+		suite.auth.EXPECT().CreateSession(gomock.Any(), suite.userID).Return(session, nil)
+
+		res, err := suite.client.Register(context.Background(), &gophermartv1.RegisterRequest{
+			Login:    auth.Login,
+			Password: auth.Password,
+		})
+		if suite.NoError(err) {
+			suite.Equal("token", res.Session.AccessToken)
+			suite.Equal("Bearer", res.Session.TokenType)
+			suite.Equal("refresh", res.Session.RefreshToken)
+			suite.True(session.ExpiresAt.Equal(res.Session.RefreshExpiresAt.AsTime()))
+		}
+	})
+
+	suite.Run("invalid fields", func() {
+		_, err := suite.client.Register(context.Background(), &gophermartv1.RegisterRequest{
+			Login:    "Go",
+			Password: "short",
+		})
+
+		st := status.Convert(err)
+		suite.Equal(codes.InvalidArgument, st.Code())
+		if suite.Len(st.Details(), 1) {
+			details, ok := st.Details()[0].(*errdetails.BadRequest)
+			if suite.True(ok) {
+				suite.NotEmpty(details.FieldViolations)
+			}
+		}
+	})
+
+	suite.Run("duplicate", func() {
+		suite.auth.EXPECT().SignUp(gomock.Any(), gomock.Any()).Return(domain.EmptyUserID, domain.ErrDuplicate)
+
+		_, err := suite.client.Register(context.Background(), &gophermartv1.RegisterRequest{
+			Login:    "gopher",
+			Password: "s3cr3t_pass",
+		})
+		suite.Equal(codes.AlreadyExists, status.Code(err))
+	})
+}
+
+func (suite *ServerSuite) TestLogin() {
+	req := &gophermartv1.LoginRequest{Login: "gopher", Password: "s3cr3t_pass"}
+
+	suite.Run("two-factor challenge", func() {
+		challenge := domain.TwoFactorChallenge{Token: "challenge", ExpiresAt: time.Now().Add(time.Minute)}
+
+		suite.auth.EXPECT().SignIn(gomock.Any(), gomock.Any()).Return(suite.userID, nil)
+		suite.twoFactor.EXPECT().Challenge(gomock.Any(), suite.userID).Return(challenge, nil)
+
+		res, err := suite.client.Login(context.Background(), req)
+		if suite.NoError(err) {
+			suite.Equal("challenge", res.GetChallenge().GetChallengeToken())
+			suite.Nil(res.GetSession())
+		}
+	})
+
+	suite.Run("invalid credentials", func() {
+		suite.auth.EXPECT().SignIn(gomock.Any(), gomock.Any()).Return(domain.EmptyUserID, domain.ErrNotFound)
+		suite.audit.EXPECT().Record(gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := suite.client.Login(context.Background(), req)
+		suite.Equal(codes.Unauthenticated, status.Code(err))
+	})
+
+	suite.Run("throttled", func() {
+		exhausted := &domain.ResourceExhaustedError{Message: "too many attempts", RetryAfter: 3 * time.Second}
+
+		suite.auth.EXPECT().SignIn(gomock.Any(), gomock.Any()).Return(domain.EmptyUserID, exhausted)
+		suite.audit.EXPECT().Record(gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := suite.client.Login(context.Background(), req)
+
+		st := status.Convert(err)
+		suite.Equal(codes.ResourceExhausted, st.Code())
+		if suite.Len(st.Details(), 1) {
+			info, ok := st.Details()[0].(*errdetails.RetryInfo)
+			if suite.True(ok) {
+				suite.Equal(3*time.Second, info.RetryDelay.AsDuration())
+			}
+		}
+	})
+}
+
+func (suite *ServerSuite) TestLoginTwoFactor() {
+	req := &gophermartv1.LoginTwoFactorRequest{ChallengeToken: "challenge", Code: "123456"}
+	resp := domain.TwoFactorResponse{Token: "challenge", Code: "123456"}
+
+	suite.Run("invalid code", func() {
+		suite.twoFactor.EXPECT().CompleteChallenge(gomock.Any(), resp).Return(domain.EmptyUserID, domain.ErrInvalidCode)
+		suite.audit.EXPECT().Record(gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := suite.client.LoginTwoFactor(context.Background(), req)
+		suite.Equal(codes.Unauthenticated, status.Code(err))
+	})
+
+	suite.Run("too many invalid codes", func() {
+		exhausted := &domain.ResourceExhaustedError{Message: "too many invalid two-factor codes", RetryAfter: time.Minute}
+
+		suite.twoFactor.EXPECT().CompleteChallenge(gomock.Any(), resp).Return(domain.EmptyUserID, exhausted)
+		suite.audit.EXPECT().Record(gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := suite.client.LoginTwoFactor(context.Background(), req)
+		suite.Equal(codes.ResourceExhausted, status.Code(err))
+	})
+}
+
+func (suite *ServerSuite) TestAuthorization() {
+	suite.Run("no token", func() {
+		_, err := suite.client.ListOrders(context.Background(), &gophermartv1.ListOrdersRequest{})
+		suite.Equal(codes.Unauthenticated, status.Code(err))
+	})
+
+	suite.Run("revoked session", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound)
+
+		_, err := suite.client.ListOrders(authorized(), &gophermartv1.ListOrdersRequest{})
+		suite.Equal(codes.Unauthenticated, status.Code(err))
+	})
+
+	suite.Run("insufficient scope", func() {
+		suite.apiKeys.EXPECT().Identify(gomock.Any(), "key").Return(domain.Identity{
+			UserID:   suite.userID,
+			APIKeyID: uuid.New(),
+			Scopes:   []domain.Scope{domain.ScopeOrdersRead},
+		}, nil)
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "key")
+
+		_, err := suite.client.GetBalance(ctx, &gophermartv1.GetBalanceRequest{})
+		suite.Equal(codes.PermissionDenied, status.Code(err))
+	})
+}
+
+func (suite *ServerSuite) TestUploadOrder() {
+	number := "49927398716"
+
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.orders.EXPECT().Process(gomock.Any(), domain.Order{
+			UserID: suite.userID,
+			Number: domain.OrderNumber(number),
+			Status: domain.OrderStatusNew,
+		}).Return(nil)
+
+		res, err := suite.client.UploadOrder(authorized(), &gophermartv1.UploadOrderRequest{Number: number})
+		if suite.NoError(err) {
+			suite.False(res.AlreadyUploaded)
+		}
+	})
+
+	suite.Run("already uploaded", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.orders.EXPECT().Process(gomock.Any(), gomock.Any()).Return(domain.ErrDuplicate)
+
+		res, err := suite.client.UploadOrder(authorized(), &gophermartv1.UploadOrderRequest{Number: number})
+		if suite.NoError(err) {
+			suite.True(res.AlreadyUploaded)
+		}
+	})
+
+	suite.Run("uploaded by other user", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.orders.EXPECT().Process(gomock.Any(), gomock.Any()).Return(domain.ErrDuplicateOtherUser)
+
+		_, err := suite.client.UploadOrder(authorized(), &gophermartv1.UploadOrderRequest{Number: number})
+		suite.Equal(codes.AlreadyExists, status.Code(err))
+	})
+
+	suite.Run("invalid checksum", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+
+		_, err := suite.client.UploadOrder(authorized(), &gophermartv1.UploadOrderRequest{Number: "49927398717"})
+		suite.Equal(codes.InvalidArgument, status.Code(err))
+	})
+
+	suite.Run("with metadata", func() {
+		purchasedAt := time.Date(2023, 12, 1, 10, 30, 0, 0, time.UTC)
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.orders.EXPECT().Process(gomock.Any(), domain.Order{
+			UserID:      suite.userID,
+			Number:      domain.OrderNumber(number),
+			Status:      domain.OrderStatusNew,
+			Store:       "Bork",
+			PurchasedAt: &purchasedAt,
+		}).Return(nil)
+
+		_, err := suite.client.UploadOrder(authorized(), &gophermartv1.UploadOrderRequest{
+			Number:      number,
+			Store:       "Bork",
+			PurchasedAt: timestamppb.New(purchasedAt),
+		})
+		suite.NoError(err)
+	})
+
+	suite.Run("purchase in the future", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+
+		_, err := suite.client.UploadOrder(authorized(), &gophermartv1.UploadOrderRequest{
+			Number:      number,
+			PurchasedAt: timestamppb.New(time.Now().Add(time.Hour)),
+		})
+		suite.Equal(codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func (suite *ServerSuite) TestListOrders() {
+	suite.Run("success", func() {
+		uploadedAt := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.orders.EXPECT().GetOrders(gomock.Any(), suite.userID).Return([]domain.Order{{
+			Number:     "49927398716",
+			Status:     domain.OrderStatusProcessed,
+			Accrual:    monetary.Format(500.5),
+			UploadedAt: uploadedAt,
+		}}, nil)
+
+		res, err := suite.client.ListOrders(authorized(), &gophermartv1.ListOrdersRequest{})
+		if suite.NoError(err) && suite.Len(res.Orders, 1) {
+			order := res.Orders[0]
+			suite.Equal("49927398716", order.Number)
+			suite.Equal(gophermartv1.OrderStatus_ORDER_STATUS_PROCESSED, order.Status)
+			suite.EqualValues(50050, order.Accrual)
+			suite.Equal(uploadedAt, order.UploadedAt.AsTime())
+		}
+	})
+
+	suite.Run("no orders", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.orders.EXPECT().GetOrders(gomock.Any(), suite.userID).Return(nil, domain.ErrNotFound)
+
+		res, err := suite.client.ListOrders(authorized(), &gophermartv1.ListOrdersRequest{})
+		if suite.NoError(err) {
+			suite.Empty(res.Orders)
+		}
+	})
+}
+
+func (suite *ServerSuite) TestGetBalance() {
+	suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+	suite.users.EXPECT().GetBalance(gomock.Any(), suite.userID).Return(domain.UserBalance{
+		Current:   monetary.Format(500.5),
+		Withdrawn: monetary.Format(42),
+	}, nil)
+
+	res, err := suite.client.GetBalance(authorized(), &gophermartv1.GetBalanceRequest{})
+	if suite.NoError(err) {
+		suite.EqualValues(50050, res.Current)
+		suite.EqualValues(4200, res.Withdrawn)
+	}
+}
+
+func (suite *ServerSuite) TestWithdraw() {
+	number := "2377225624"
+
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.operations.EXPECT().Perform(gomock.Any(), domain.Operation{
+			UserID:      suite.userID,
+			OrderNumber: domain.OrderNumber(number),
+			Sum:         monetary.Format(751),
+		}).Return(nil)
+
+		_, err := suite.client.Withdraw(authorized(), &gophermartv1.WithdrawRequest{Order: number, Sum: 75100})
+		suite.NoError(err)
+	})
+
+	suite.Run("insufficient balance", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.operations.EXPECT().Perform(gomock.Any(), gomock.Any()).Return(domain.ErrBalanceBelowZero)
+
+		_, err := suite.client.Withdraw(authorized(), &gophermartv1.WithdrawRequest{Order: number, Sum: 75100})
+		suite.Equal(codes.FailedPrecondition, status.Code(err))
+	})
+
+	suite.Run("non-positive sum", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+
+		_, err := suite.client.Withdraw(authorized(), &gophermartv1.WithdrawRequest{Order: number, Sum: -100})
+		suite.Equal(codes.InvalidArgument, status.Code(err))
+	})
+
+	suite.Run("invalid two-factor code", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.twoFactor.EXPECT().Verify(gomock.Any(), suite.userID, "000000").Return(domain.ErrInvalidCode)
+
+		_, err := suite.client.Withdraw(authorized(), &gophermartv1.WithdrawRequest{
+			Order:    number,
+			Sum:      200000,
+			TotpCode: "000000",
+		})
+		suite.Equal(codes.PermissionDenied, status.Code(err))
+	})
+
+	suite.Run("two-factor codes locked out", func() {
+		exhausted := &domain.ResourceExhaustedError{Message: "too many invalid two-factor codes", RetryAfter: time.Minute}
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+		suite.twoFactor.EXPECT().Verify(gomock.Any(), suite.userID, "000000").Return(exhausted)
+
+		_, err := suite.client.Withdraw(authorized(), &gophermartv1.WithdrawRequest{
+			Order:    number,
+			Sum:      200000,
+			TotpCode: "000000",
+		})
+		suite.Equal(codes.ResourceExhausted, status.Code(err))
+	})
+}
+
+func (suite *ServerSuite) TestListWithdrawals() {
+	processedAt := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+
+	suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil)
+	suite.operations.EXPECT().GetOperations(gomock.Any(), suite.userID).Return([]domain.Operation{{
+		OrderNumber: "2377225624",
+		Sum:         monetary.Format(500),
+		ProcessedAt: processedAt,
+	}}, nil)
+
+	res, err := suite.client.ListWithdrawals(authorized(), &gophermartv1.ListWithdrawalsRequest{})
+	if suite.NoError(err) && suite.Len(res.Withdrawals, 1) {
+		suite.Equal("2377225624", res.Withdrawals[0].Order)
+		suite.EqualValues(50000, res.Withdrawals[0].Sum)
+		suite.Equal(processedAt, res.Withdrawals[0].ProcessedAt.AsTime())
+	}
+}
+
+// dial запускает srv и возвращает подключённого к нему клиента; сервер
+// и соединение закрываются по завершении теста.
+func dial(t *testing.T, srv *grpc.Server) gophermartv1.GophermartServiceClient {
+	t.Helper()
+
+	l := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(l) }()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+	})
+
+	return gophermartv1.NewGophermartServiceClient(conn)
+}
+
+func (suite *ServerSuite) TestRateLimits() {
+	newClient := func(limits grpcapi.RateLimits) gophermartv1.GophermartServiceClient {
+		return dial(suite.T(), grpcapi.New(grpcapi.ServerOptions{
+			Auth:       suite.auth,
+			Users:      suite.users,
+			Audit:      suite.audit,
+			Signer:     &signerStub{identity: suite.identity},
+			RateLimits: limits,
+		}))
+	}
+
+	retryDelay := func(err error) time.Duration {
+		st := status.Convert(err)
+		suite.Require().Equal(codes.ResourceExhausted, st.Code())
+		suite.Require().Len(st.Details(), 1)
+		info, ok := st.Details()[0].(*errdetails.RetryInfo)
+		suite.Require().True(ok)
+		return info.RetryDelay.AsDuration()
+	}
+
+	suite.Run("public method", func() {
+		client := newClient(grpcapi.RateLimits{IP: throttling.Rate{Requests: 1, Per: time.Minute}})
+
+		req := &gophermartv1.LoginRequest{Login: "gopher", Password: "s3cr3t_pass"}
+
+		suite.auth.EXPECT().SignIn(gomock.Any(), gomock.Any()).Return(domain.EmptyUserID, domain.ErrNotFound)
+		suite.audit.EXPECT().Record(gomock.Any(), gomock.Any()).Return(nil)
+
+		_, err := client.Login(context.Background(), req)
+		suite.Equal(codes.Unauthenticated, status.Code(err))
+
+		_, err = client.Login(context.Background(), req)
+		suite.InDelta(time.Minute, retryDelay(err), float64(time.Second))
+	})
+
+	suite.Run("authorized client", func() {
+		client := newClient(grpcapi.RateLimits{IP: throttling.Rate{Requests: 1, Per: time.Minute}})
+
+		gomock.InOrder(
+			suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(2),
+			suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound).Times(2),
+		)
+		suite.users.EXPECT().GetBalance(gomock.Any(), suite.userID).Return(domain.UserBalance{}, nil).Times(2)
+
+		call := func() codes.Code {
+			_, err := client.GetBalance(authorized(), &gophermartv1.GetBalanceRequest{})
+			return status.Code(err)
+		}
+
+		// Авторизованные вызовы не расходуют ограничение на IP-адрес,
+		// неудачные попытки авторизации расходуют.
+		suite.Equal(codes.OK, call())
+		suite.Equal(codes.OK, call())
+		suite.Equal(codes.Unauthenticated, call())
+		suite.Equal(codes.ResourceExhausted, call())
+	})
+
+	suite.Run("user", func() {
+		client := newClient(grpcapi.RateLimits{User: throttling.Rate{Requests: 1, Per: time.Minute}})
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(2)
+		suite.users.EXPECT().GetBalance(gomock.Any(), suite.userID).Return(domain.UserBalance{}, nil)
+
+		_, err := client.GetBalance(authorized(), &gophermartv1.GetBalanceRequest{})
+		suite.NoError(err)
+
+		_, err = client.GetBalance(authorized(), &gophermartv1.GetBalanceRequest{})
+		suite.InDelta(time.Minute, retryDelay(err), float64(time.Second))
+	})
+}
+
+func (suite *ServerSuite) TestMetrics() {
+	_, err := suite.client.ListOrders(context.Background(), &gophermartv1.ListOrdersRequest{})
+	suite.Require().Equal(codes.Unauthenticated, status.Code(err))
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	suite.Contains(rec.Body.String(),
+		`grpc_server_handled_total{method="/gophermart.v1.GophermartService/ListOrders",code="Unauthenticated"}`)
+	suite.Contains(rec.Body.String(),
+		`grpc_server_handling_seconds_count{method="/gophermart.v1.GophermartService/ListOrders",code="Unauthenticated"}`)
+}
@@ -0,0 +1,98 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	gophermartv1 "github.com/sergeizaitcev/gophermart/api/gophermart/v1"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+)
+
+// UploadOrder добавляет заказ пользователя в обработку.
+func (s *server) UploadOrder(
+	ctx context.Context,
+	req *gophermartv1.UploadOrderRequest,
+) (*gophermartv1.UploadOrderResponse, error) {
+	upload := domain.OrderUpload{
+		Number: domain.OrderNumber(req.GetNumber()),
+		Store:  req.GetStore(),
+	}
+	if req.GetPurchasedAt() != nil {
+		purchasedAt := req.GetPurchasedAt().AsTime()
+		upload.PurchasedAt = &purchasedAt
+	}
+
+	err := upload.Number.Validate()
+	if err != nil {
+		return nil, statusError(ctx, codes.InvalidArgument, err)
+	}
+
+	err = upload.Validate(time.Now())
+	if err != nil {
+		return nil, validationError(ctx, err)
+	}
+
+	err = s.orders.Process(ctx, upload.Order(userFromContext(ctx)))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrDuplicate):
+			return &gophermartv1.UploadOrderResponse{AlreadyUploaded: true}, nil
+		case errors.Is(err, domain.ErrDuplicateOtherUser):
+			return nil, statusError(ctx, codes.AlreadyExists, err)
+		default:
+			return nil, internalError(ctx, err)
+		}
+	}
+
+	return &gophermartv1.UploadOrderResponse{}, nil
+}
+
+// ListOrders возвращает заказы пользователя; если заказов нет, то
+// возвращает пустой список.
+func (s *server) ListOrders(
+	ctx context.Context,
+	_ *gophermartv1.ListOrdersRequest,
+) (*gophermartv1.ListOrdersResponse, error) {
+	orders, err := s.orders.GetOrders(ctx, userFromContext(ctx))
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, internalError(ctx, err)
+	}
+
+	resp := &gophermartv1.ListOrdersResponse{
+		Orders: make([]*gophermartv1.Order, len(orders)),
+	}
+	for i, order := range orders {
+		resp.Orders[i] = &gophermartv1.Order{
+			Number:     string(order.Number),
+			Status:     orderStatus(order.Status),
+			Accrual:    int64(order.Accrual),
+			UploadedAt: timestamppb.New(order.UploadedAt),
+			Store:      order.Store,
+		}
+		if order.PurchasedAt != nil {
+			resp.Orders[i].PurchasedAt = timestamppb.New(*order.PurchasedAt)
+		}
+	}
+
+	return resp, nil
+}
+
+// orderStatus конвертирует статус заказа в gophermartv1.OrderStatus.
+func orderStatus(s domain.OrderStatus) gophermartv1.OrderStatus {
+	switch s {
+	case domain.OrderStatusNew:
+		return gophermartv1.OrderStatus_ORDER_STATUS_NEW
+	case domain.OrderStatusProcessing:
+		return gophermartv1.OrderStatus_ORDER_STATUS_PROCESSING
+	case domain.OrderStatusProcessed:
+		return gophermartv1.OrderStatus_ORDER_STATUS_PROCESSED
+	case domain.OrderStatusInvalid:
+		return gophermartv1.OrderStatus_ORDER_STATUS_INVALID
+	default:
+		return gophermartv1.OrderStatus_ORDER_STATUS_UNSPECIFIED
+	}
+}
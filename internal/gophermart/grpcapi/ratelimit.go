@@ -0,0 +1,99 @@
+package grpcapi
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/throttling"
+)
+
+// RateLimits определяет ограничения частоты вызовов; нулевые значения
+// снимают ограничения.
+type RateLimits struct {
+	// Ограничение на IP-адрес клиента для вызовов публичных методов
+	// и неудачных попыток авторизации.
+	IP throttling.Rate
+
+	// Ограничение на пользователя для авторизованных вызовов.
+	User throttling.Rate
+
+	// Время простоя, после которого корзина клиента удаляется.
+	IdleTimeout time.Duration
+}
+
+// rateLimiters определяет ограничители частоты вызовов.
+type rateLimiters struct {
+	ip   *throttling.KeyedLimiter
+	user *throttling.KeyedLimiter
+}
+
+func newRateLimiters(limits RateLimits) rateLimiters {
+	return rateLimiters{
+		ip:   throttling.NewKeyedLimiter(limits.IP, limits.IdleTimeout),
+		user: throttling.NewKeyedLimiter(limits.User, limits.IdleTimeout),
+	}
+}
+
+// limitClient ограничивает частоту вызовов публичных методов с IP-адреса
+// клиента; вызовы остальных методов учитываются в authorization только
+// при неудачной авторизации.
+func (s *server) limitClient(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if _, ok := publicMethods[info.FullMethod]; ok {
+		if err := s.allowClient(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return handler(ctx, req)
+}
+
+// allowClient расходует токен ограничения на IP-адрес клиента и возвращает
+// codes.ResourceExhausted, если ограничение превышено.
+func (s *server) allowClient(ctx context.Context) error {
+	ip := domain.ClientFromContext(ctx).IP
+	if ip == "" {
+		return nil
+	}
+	return allow(s.limiters.ip, "ip:"+ip)
+}
+
+// limitUser ограничивает частоту вызовов пользователя. Используется после
+// authorization.
+func (s *server) limitUser(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	if userID := userFromContext(ctx); userID != domain.EmptyUserID {
+		if err := allow(s.limiters.user, "user:"+userID.String()); err != nil {
+			return nil, err
+		}
+	}
+	return handler(ctx, req)
+}
+
+// allow расходует токен корзины ключа key; nil-ограничитель пропускает
+// вызов.
+func allow(l *throttling.KeyedLimiter, key string) error {
+	if l == nil {
+		return nil
+	}
+
+	d := l.Allow(key)
+	if d.Allowed {
+		return nil
+	}
+
+	return retryAfterError(&domain.ResourceExhaustedError{
+		Message:    "request rate limit exceeded",
+		RetryAfter: max(d.RetryAfter, time.Second),
+	})
+}
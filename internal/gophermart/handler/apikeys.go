@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+)
+
+// createAPIKey создаёт ключ API авторизованного пользователя и возвращает
+// его в теле ответа; ключ возвращается только один раз.
+func (h *handler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+
+	req, ok := decodeAPIKeyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := h.apiKeys.Create(ctx, userID, req)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	writeAPIKey(w, r, key)
+}
+
+// createUserAPIKey создаёт ключ API пользователя с указанным логином.
+func (h *handler) createUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeAPIKeyRequest(w, r)
+	if !ok {
+		return
+	}
+
+	login := chi.URLParam(r, "login")
+
+	key, err := h.apiKeys.CreateForLogin(r.Context(), login, req)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, err)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	writeAPIKey(w, r, key)
+}
+
+// getAPIKeys возвращает действующие ключи API авторизованного пользователя.
+func (h *handler) getAPIKeys(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+
+	keys, err := h.apiKeys.List(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(keys)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
+
+// revokeAPIKey отзывает ключ API авторизованного пользователя.
+func (h *handler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+
+	keyID, err := domain.NewAPIKeyID(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Errorf("%w: %s", errInvalidID, err))
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	err = h.apiKeys.Revoke(ctx, userID, keyID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, err)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeAPIKeyRequest декодирует и проверяет данные для создания ключа API;
+// в случае ошибки возвращает http.StatusBadRequest.
+func decodeAPIKeyRequest(w http.ResponseWriter, r *http.Request) (domain.APIKeyRequest, bool) {
+	var req domain.APIKeyRequest
+
+	err := decodeJSON(r, &req)
+	if err == nil {
+		err = req.Validate()
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return domain.APIKeyRequest{}, false
+	}
+
+	return req, true
+}
+
+// writeAPIKey возвращает созданный ключ API в теле ответа.
+func writeAPIKey(w http.ResponseWriter, r *http.Request, key domain.APIKey) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	err := json.NewEncoder(w).Encode(key)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
@@ -0,0 +1,230 @@
+package handler_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+)
+
+func (suite *HandlerSuite) TestAPIKeyAuthorization() {
+	identity := domain.Identity{
+		UserID:   suite.userID,
+		APIKeyID: uuid.New(),
+		Scopes:   []domain.Scope{domain.ScopeOrdersWrite},
+	}
+
+	suite.Run("success", func() {
+		suite.apiKeys.EXPECT().Identify(gomock.Any(), "key").Return(identity, nil).Times(1)
+		suite.orders.EXPECT().Process(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader("12345678903"))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("X-API-Key", "key")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusAccepted, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("missing scope", func() {
+		suite.apiKeys.EXPECT().Identify(gomock.Any(), "key").Return(identity, nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/balance", http.NoBody)
+		req.Header.Set("X-API-Key", "key")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusForbidden, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("session only", func() {
+		suite.apiKeys.EXPECT().Identify(gomock.Any(), "key").Return(identity, nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/api-keys", http.NoBody)
+		req.Header.Set("X-API-Key", "key")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusForbidden, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("revoked", func() {
+		suite.apiKeys.EXPECT().Identify(gomock.Any(), "key").Return(
+			domain.Identity{}, domain.ErrNotFound,
+		).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/orders", http.NoBody)
+		req.Header.Set("X-API-Key", "key")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusUnauthorized, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
+
+func (suite *HandlerSuite) TestCreateAPIKey() {
+	req := domain.APIKeyRequest{
+		Name:   "pos",
+		Scopes: []domain.Scope{domain.ScopeOrdersWrite, domain.ScopeBalanceRead},
+	}
+
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.apiKeys.EXPECT().Create(gomock.Any(), suite.userID, req).Return(domain.APIKey{
+			ID:     uuid.New(),
+			Name:   req.Name,
+			Scopes: req.Scopes,
+			Key:    "gmk_key",
+		}, nil).Times(1)
+
+		body := `{"name":"pos","scopes":["orders:write","balance:read"]}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/api-keys", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusCreated, rec.Code) {
+			suite.Contains(rec.Body.String(), `"key":"gmk_key"`)
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("unsupported scope", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		body := `{"name":"pos","scopes":["users:write"]}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/api-keys", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("internal server error", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.apiKeys.EXPECT().Create(gomock.Any(), suite.userID, req).Return(
+			domain.APIKey{}, errors.New("error"),
+		).Times(1)
+
+		body := `{"name":"pos","scopes":["orders:write","balance:read"]}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/api-keys", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusInternalServerError, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
+
+func (suite *HandlerSuite) TestGetAPIKeys() {
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.apiKeys.EXPECT().List(gomock.Any(), suite.userID).Return([]domain.APIKey{
+			{ID: uuid.New(), Name: "pos", Scopes: []domain.Scope{domain.ScopeOrdersWrite}},
+		}, nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/api-keys", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.Contains(rec.Body.String(), `"name":"pos"`)
+			suite.NotContains(rec.Body.String(), `"key"`)
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("no content", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.apiKeys.EXPECT().List(gomock.Any(), suite.userID).Return(nil, domain.ErrNotFound).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/api-keys", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusNoContent, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
+
+func (suite *HandlerSuite) TestRevokeAPIKey() {
+	keyID := uuid.New()
+
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.apiKeys.EXPECT().Revoke(gomock.Any(), suite.userID, keyID).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/api-keys/"+keyID.String(), http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusNoContent, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("not found", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.apiKeys.EXPECT().Revoke(gomock.Any(), suite.userID, keyID).Return(domain.ErrNotFound).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/api-keys/"+keyID.String(), http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusNotFound, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("bad request", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/api-keys/invalid", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
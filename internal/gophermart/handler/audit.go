@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+)
+
+// getSecurityEvents возвращает события безопасности авторизованного
+// пользователя.
+func (h *handler) getSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+
+	query := r.URL.Query()
+	query.Del("user_id")
+	query.Del("login")
+
+	filter, err := parseSecurityEventFilter(query)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Errorf("%w: %s", errInvalidQuery, err))
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+	filter.UserID = userID
+
+	h.writeSecurityEvents(w, r, filter)
+}
+
+// getAllSecurityEvents возвращает события безопасности всех пользователей
+// по условиям выборки из параметров запроса.
+func (h *handler) getAllSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSecurityEventFilter(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Errorf("%w: %s", errInvalidQuery, err))
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	h.writeSecurityEvents(w, r, filter)
+}
+
+func (h *handler) writeSecurityEvents(
+	w http.ResponseWriter,
+	r *http.Request,
+	filter domain.SecurityEventFilter,
+) {
+	if h.audit == nil {
+		writeProblem(w, r, http.StatusNotFound, domain.ErrNotFound)
+		return
+	}
+
+	events, err := h.audit.List(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(events)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
+
+// parseSecurityEventFilter возвращает условия выборки журнала из параметров
+// запроса: user_id, login, type (через запятую или повторением), ip, from
+// и to в формате RFC 3339, before и limit.
+func parseSecurityEventFilter(query url.Values) (domain.SecurityEventFilter, error) {
+	var (
+		filter domain.SecurityEventFilter
+		err    error
+	)
+
+	if s := query.Get("user_id"); s != "" {
+		filter.UserID, err = domain.NewUserID(s)
+		if err != nil {
+			return domain.SecurityEventFilter{}, err
+		}
+	}
+
+	filter.Login = query.Get("login")
+	filter.IP = query.Get("ip")
+
+	for _, v := range query["type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t != "" {
+				filter.Types = append(filter.Types, domain.SecurityEventType(t))
+			}
+		}
+	}
+
+	if s := query.Get("from"); s != "" {
+		filter.From, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return domain.SecurityEventFilter{}, fmt.Errorf("parsing from: %w", err)
+		}
+	}
+	if s := query.Get("to"); s != "" {
+		filter.To, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			return domain.SecurityEventFilter{}, fmt.Errorf("parsing to: %w", err)
+		}
+	}
+
+	if s := query.Get("before"); s != "" {
+		filter.Before, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return domain.SecurityEventFilter{}, fmt.Errorf("parsing before: %w", err)
+		}
+	}
+	if s := query.Get("limit"); s != "" {
+		filter.Limit, err = strconv.Atoi(s)
+		if err != nil {
+			return domain.SecurityEventFilter{}, fmt.Errorf("parsing limit: %w", err)
+		}
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return domain.SecurityEventFilter{}, err
+	}
+
+	return filter, nil
+}
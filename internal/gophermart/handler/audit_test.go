@@ -0,0 +1,143 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/golang/mock/gomock"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+)
+
+// securityEventMatcher сопоставляет событие безопасности по типу.
+type securityEventMatcher domain.SecurityEventType
+
+func securityEvent(t domain.SecurityEventType) gomock.Matcher {
+	return securityEventMatcher(t)
+}
+
+func (m securityEventMatcher) Matches(x any) bool {
+	event, ok := x.(domain.SecurityEvent)
+	return ok && event.Type == domain.SecurityEventType(m)
+}
+
+func (m securityEventMatcher) String() string {
+	return fmt.Sprintf("security event of type %q", string(m))
+}
+
+func (suite *HandlerSuite) TestGetSecurityEvents() {
+	suite.Run("success", func() {
+		filter := domain.SecurityEventFilter{
+			UserID: suite.userID,
+			Types:  []domain.SecurityEventType{domain.EventLoginFailed, domain.EventLoginSucceeded},
+			Limit:  10,
+		}
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.audit.EXPECT().List(gomock.Any(), filter).Return([]domain.SecurityEvent{
+			{ID: 1, Type: domain.EventLoginFailed, UserID: suite.userID},
+		}, nil).Times(1)
+
+		// Пользователь не может запросить события чужой учётной записи.
+		url := "/api/user/security-events?type=login_failed,login_succeeded&limit=10&login=other"
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.Contains(rec.Body.String(), `"type":"login_failed"`)
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("no content", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.audit.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, domain.ErrNotFound).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/security-events", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusNoContent, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("bad request", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/security-events?type=unknown", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
+
+func (suite *HandlerSuite) TestGetAllSecurityEvents() {
+	admin := suite.identity
+	admin.Role = domain.RoleAdmin
+
+	suite.Run("forbidden", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/security-events", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusForbidden, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.signer.identity = admin
+	defer func() { suite.signer.identity = suite.identity }()
+
+	suite.Run("success", func() {
+		filter := domain.SecurityEventFilter{Login: "login", IP: "10.0.0.1"}
+
+		suite.auth.EXPECT().Identify(gomock.Any(), admin).Return(nil).Times(1)
+		suite.audit.EXPECT().List(gomock.Any(), filter).Return([]domain.SecurityEvent{
+			{ID: 1, Type: domain.EventLoginFailed, Login: "login", IP: "10.0.0.1"},
+		}, nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/security-events?login=login&ip=10.0.0.1", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.Contains(rec.Body.String(), `"login":"login"`)
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("invalid period", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), admin).Return(nil).Times(1)
+
+		url := "/api/admin/security-events?from=2023-12-02T00:00:00Z&to=2023-12-01T00:00:00Z"
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, url, http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
@@ -4,192 +4,385 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
-	"strings"
+	"time"
 
 	"log/slog"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
 )
 
-// Тип токена авторизации.
-const tokenType = "Bearer"
+// keyIdentity определяет ключ для передачи domain.Identity через контекст.
+var keyIdentity struct{}
 
-// token определяет токен авторизации.
-type token struct {
-	value string
+// identityFromContext возвращает идентификационные данные владельца сеанса
+// из контекста.
+func identityFromContext(ctx context.Context) domain.Identity {
+	identity, ok := ctx.Value(keyIdentity).(domain.Identity)
+	if !ok {
+		return domain.Identity{}
+	}
+	return identity
 }
 
-func (t token) String() string {
-	return tokenType + " " + t.value
+// userFromContext возвращает уникальный идентификатор пользователя
+// из контекста.
+func userFromContext(ctx context.Context) domain.UserID {
+	return identityFromContext(ctx).UserID
 }
 
-// parseToken парсит строку и возвращает токен авторизации.
-func parseToken(s string) (token, error) {
-	split := strings.SplitN(s, " ", 2)
-	if len(split) != 2 || split[0] != tokenType {
-		return token{}, fmt.Errorf("unsupported token type: %q", split[0])
+// Заголовок запроса с ключом API.
+const apiKeyHeader = "X-API-Key"
+
+// authorization проверяет наличие ключа API или токена авторизации в запросе
+// и прокидывает в контекст идентификационные данные владельца по ключу
+// keyIdentity; если ключ или токен не действителен или сеанс отозван, то
+// возвращает http.StatusUnauthorized, а при превышении ограничения на
+// IP-адрес клиента — http.StatusTooManyRequests.
+func (h *handler) authorization(next http.Handler) http.Handler {
+	auth := func(w http.ResponseWriter, r *http.Request) {
+		identity, err := h.identify(r)
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err.Error())
+			if errors.Is(err, domain.ErrNotFound) {
+				if h.allowClient(w, r) {
+					writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+				}
+			} else {
+				writeProblem(w, r, http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), keyIdentity, identity)
+		ctx = logging.With(ctx, slog.String("user_id", identity.UserID.String()))
+		*r = *r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
 	}
-	return token{value: split[1]}, nil
+
+	return http.HandlerFunc(auth)
 }
 
-// toToken упаковывает уникальный идентификатор пользователя в токен
-// авторизации и возвращает его.
-func (h *handler) toToken(id domain.UserID) (token, error) {
-	tokenValue, err := h.signer.Sign(id.String())
-	if err != nil {
-		return token{}, err
+// identify возвращает идентификационные данные владельца ключа API или
+// токена авторизации из запроса; ключ API имеет приоритет.
+func (h *handler) identify(r *http.Request) (domain.Identity, error) {
+	return h.authn.Identify(r.Context(), r.Header.Get(apiKeyHeader), r.Header.Get("Authorization"))
+}
+
+// requireSession пропускает запрос, только если он авторизован токеном
+// сеанса; запросы с ключом API отклоняются с http.StatusForbidden.
+// Используется после authorization.
+func requireSession(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if identityFromContext(r.Context()).IsAPIKey() {
+			writeProblem(w, r, http.StatusForbidden, errSessionRequired)
+			return
+		}
+		next.ServeHTTP(w, r)
 	}
-	return token{value: tokenValue}, nil
+	return http.HandlerFunc(fn)
 }
 
-// parseToken парсит токен авторизации и возвращает уникальный идентификатор
-// пользователя.
-func (h *handler) parseToken(token string) (domain.UserID, error) {
-	tk, err := parseToken(token)
-	if err != nil {
-		return domain.EmptyUserID, err
+// requireScope пропускает запрос, только если владельцу разрешено действие
+// scope; иначе возвращает http.StatusForbidden. Используется после
+// authorization.
+func requireScope(scope domain.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !identityFromContext(r.Context()).HasScope(scope) {
+				writeProblem(w, r, http.StatusForbidden, errInsufficientScope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// requireRole пропускает запрос, только если роль владельца сеанса входит
+// в roles; иначе возвращает http.StatusForbidden. Используется после
+// authorization.
+func requireRole(roles ...domain.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			identity := identityFromContext(r.Context())
+			if identity.IsEmpty() {
+				writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+				return
+			}
+
+			for _, role := range roles {
+				if identity.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			writeProblem(w, r, http.StatusForbidden, errForbidden)
+		}
+		return http.HandlerFunc(fn)
 	}
-	payload, err := h.signer.Parse(tk.value)
+}
+
+// tokens определяет тело ответа с токенами сеанса.
+type tokens struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"refresh_expires_at"`
+}
+
+// writeSession возвращает в заголовке ответа токен авторизации, а в теле
+// ответа — токены сеанса.
+func writeSession(w http.ResponseWriter, r *http.Request, session service.SessionTokens) {
+	w.Header().Set("Authorization", service.TokenType+" "+session.AccessToken)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err := json.NewEncoder(w).Encode(tokens{
+		AccessToken:  session.AccessToken,
+		TokenType:    service.TokenType,
+		RefreshToken: session.RefreshToken,
+		ExpiresAt:    session.ExpiresAt,
+	})
 	if err != nil {
-		return domain.EmptyUserID, err
+		logging.FromContext(r.Context()).Error(err.Error())
 	}
-	userID, err := domain.NewUserID(payload)
+}
+
+// startSession открывает новый сеанс пользователя и возвращает его токены.
+func (h *handler) startSession(w http.ResponseWriter, r *http.Request, id domain.UserID) {
+	session, err := h.authn.StartSession(r.Context(), id)
 	if err != nil {
-		return domain.EmptyUserID, err
+		writeProblem(w, r, http.StatusInternalServerError, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
 	}
-	return userID, nil
+	writeSession(w, r, session)
 }
 
-// keyUserID определяет ключ для передачи domain.UserID через контекст.
-var keyUserID struct{}
+// register выполняет регистрацию пользователя и возвращает в заголовке
+// ответа токен авторизации, а в теле ответа — токены сеанса.
+func (h *handler) register(w http.ResponseWriter, r *http.Request) {
+	var auth domain.Authentication
 
-// userFromContext возвращает уникальный идентификатор пользователя
-// из контекста.
-func userFromContext(ctx context.Context) domain.UserID {
-	userID, ok := ctx.Value(keyUserID).(domain.UserID)
-	if !ok {
-		return domain.EmptyUserID
+	err := decodeJSON(r, &auth)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
 	}
-	return userID
-}
 
-// authorization проверяет наличие токена авторизации в запросе и прокидывает
-// в контекст уникальный идентификатор пользователя по ключу keyUserID; если
-// токен авторизации не действителен, то возвращает http.StatusUnauthorized.
-func (h *handler) authorization(next http.Handler) http.Handler {
-	auth := func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("Authorization")
+	err = auth.Validate(h.credentials)
+	if err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
 
-		userID, err := h.parseToken(token)
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			slog.Error(err.Error())
-			return
+	session, err := h.authn.Register(r.Context(), auth)
+	if err != nil {
+		if errors.Is(err, domain.ErrDuplicate) {
+			writeProblem(w, r, http.StatusConflict, err)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
 		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
 
-		ctx := r.Context()
+	writeSession(w, r, session)
+}
 
-		err = h.auth.Identify(ctx, userID)
-		if err != nil {
-			if errors.Is(err, domain.ErrNotFound) {
-				w.WriteHeader(http.StatusUnauthorized)
-			} else {
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-			slog.Error(err.Error())
-			return
-		}
+// login выполняет аутентификацию пользователя и возвращает в заголовке
+// ответа токен авторизации, а в теле ответа — токены сеанса; если у
+// пользователя включён второй фактор, то возвращает запрос второго фактора.
+func (h *handler) login(w http.ResponseWriter, r *http.Request) {
+	var auth domain.Authentication
 
-		*r = *r.WithContext(context.WithValue(ctx, keyUserID, userID))
+	err := decodeJSON(r, &auth)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
 
-		next.ServeHTTP(w, r)
+	err = auth.ValidateSignIn(h.credentials)
+	if err != nil {
+		writeValidationError(w, r, err)
+		return
 	}
 
-	return http.HandlerFunc(auth)
+	res, err := h.authn.Login(r.Context(), auth)
+	if err != nil {
+		var exhausted *domain.ResourceExhaustedError
+		if errors.As(err, &exhausted) {
+			writeRetryAfter(w, r, exhausted)
+		} else if errors.Is(err, domain.ErrNotFound) {
+			writeProblem(w, r, http.StatusUnauthorized, errInvalidCredentials)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	if res.Challenge != nil {
+		writeChallenge(w, r, *res.Challenge)
+		return
+	}
+
+	writeSession(w, r, res.Tokens)
 }
 
-// register выполняет регистрацию пользователя и возвращает в заголовке
-// ответа токен авторизации.
-func (h *handler) register(w http.ResponseWriter, r *http.Request) {
-	var auth domain.Authentication
+// refreshRequest определяет тело запроса на обновление сеанса.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refresh продлевает сеанс пользователя по токену обновления и возвращает
+// новые токены сеанса.
+func (h *handler) refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
 
-	err := json.NewDecoder(r.Body).Decode(&auth)
+	err := decodeJSON(r, &req)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		slog.Error(err.Error())
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
 		return
 	}
 
-	err = auth.Validate()
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		slog.Error(err.Error())
+	if req.RefreshToken == "" {
+		writeProblem(w, r, http.StatusBadRequest, errMalformedBody)
 		return
 	}
 
 	ctx := r.Context()
 
-	userID, err := h.auth.SignUp(ctx, auth)
+	session, err := h.auth.RefreshSession(ctx, req.RefreshToken)
 	if err != nil {
-		if errors.Is(err, domain.ErrDuplicate) {
-			w.WriteHeader(http.StatusConflict)
+		if errors.Is(err, domain.ErrNotFound) {
+			writeProblem(w, r, http.StatusUnauthorized, errInvalidRefreshToken)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, err)
 		}
-		slog.Error(err.Error())
+		logging.FromContext(r.Context()).Error(err.Error())
 		return
 	}
 
-	token, err := h.toToken(userID)
+	tokens, err := h.authn.IssueTokens(session)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		slog.Error(err.Error())
+		writeProblem(w, r, http.StatusInternalServerError, err)
+		logging.FromContext(r.Context()).Error(err.Error())
 		return
 	}
 
-	w.Header().Set("Authorization", token.String())
+	h.authn.Record(ctx, domain.SecurityEvent{Type: domain.EventTokenRefreshed, UserID: session.UserID})
+
+	writeSession(w, r, tokens)
 }
 
-// login выполняет аутентификацию пользователя и возвращает в заголовке
-// ответа токен авторизации.
-func (h *handler) login(w http.ResponseWriter, r *http.Request) {
-	var auth domain.Authentication
+// logout отзывает текущий сеанс авторизованного пользователя.
+func (h *handler) logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
-	err := json.NewDecoder(r.Body).Decode(&auth)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		slog.Error(err.Error())
+	identity := identityFromContext(ctx)
+	if identity.IsEmpty() {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
 		return
 	}
 
-	err = auth.Validate()
+	err := h.auth.RevokeSession(ctx, identity)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		slog.Error(err.Error())
+		if errors.Is(err, domain.ErrNotFound) {
+			writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
 		return
 	}
 
+	h.authn.Record(ctx, domain.SecurityEvent{Type: domain.EventLoggedOut, UserID: identity.UserID})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// changePassword заменяет пароль авторизованного пользователя, отзывает все
+// его сеансы и открывает новый сеанс, токены которого возвращает в ответе.
+func (h *handler) changePassword(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	userID, err := h.auth.SignIn(ctx, auth)
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+
+	var change domain.PasswordChange
+
+	err := decodeJSON(r, &change)
 	if err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			w.WriteHeader(http.StatusUnauthorized)
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	err = change.Validate(h.credentials)
+	if err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	err = h.auth.ChangePassword(ctx, userID, change)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPassword) {
+			writeProblem(w, r, http.StatusForbidden, err)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, err)
 		}
-		slog.Error(err.Error())
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	h.startSession(w, r, userID)
+}
+
+// deleteAccount обезличивает учётную запись авторизованного пользователя
+// после проверки пароля.
+func (h *handler) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
 		return
 	}
 
-	token, err := h.toToken(userID)
+	var deletion domain.AccountDeletion
+
+	err := decodeJSON(r, &deletion)
+	if err == nil {
+		err = deletion.Validate()
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	err = h.auth.DeleteAccount(ctx, userID, deletion)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		slog.Error(err.Error())
+		if errors.Is(err, domain.ErrInvalidPassword) {
+			writeProblem(w, r, http.StatusForbidden, err)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
 		return
 	}
 
-	w.Header().Set("Authorization", token.String())
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -1,16 +1,19 @@
 package handler_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/handler"
 	"github.com/sergeizaitcev/gophermart/pkg/randutil"
 )
 
@@ -18,10 +21,18 @@ func (suite *HandlerSuite) TestRegister() {
 	suite.Run("success", func() {
 		body := `{"login":"login","password":"password"}`
 
+		userID := uuid.New()
+
 		suite.auth.EXPECT().SignUp(
 			gomock.Any(),
 			domain.Authentication{Login: "login", Password: "password"},
-		).Return(uuid.New(), nil).Times(1)
+		).Return(userID, nil).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventRegistered)).Return(nil).Times(1)
+		suite.auth.EXPECT().CreateSession(gomock.Any(), userID).Return(domain.Session{
+			ID:           uuid.New(),
+			UserID:       userID,
+			RefreshToken: "refresh",
+		}, nil).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(body))
@@ -30,6 +41,7 @@ func (suite *HandlerSuite) TestRegister() {
 
 		if suite.Equal(http.StatusOK, rec.Code) {
 			suite.NotEmpty(rec.Header().Get("Authorization"))
+			suite.Contains(rec.Body.String(), `"refresh_token":"refresh"`)
 			suite.ctrl.Finish()
 		}
 	})
@@ -89,16 +101,109 @@ func (suite *HandlerSuite) TestRegister() {
 
 		suite.Equal(http.StatusBadRequest, rec.Code)
 	})
+
+	suite.Run("policy violation", func() {
+		testCases := []struct {
+			name  string
+			body  string
+			field string
+			code  string
+		}{
+			{
+				name:  "short login",
+				body:  `{"login":"lo","password":"password"}`,
+				field: "login",
+				code:  domain.CodeTooShort,
+			},
+			{
+				name:  "invalid login",
+				body:  `{"login":"Login!","password":"password"}`,
+				field: "login",
+				code:  domain.CodeInvalidCharacters,
+			},
+			{
+				name:  "short password",
+				body:  `{"login":"login","password":"pass"}`,
+				field: "password",
+				code:  domain.CodeTooShort,
+			},
+			{
+				name:  "banned password",
+				body:  `{"login":"login","password":"PASSWORD1"}`,
+				field: "password",
+				code:  domain.CodeBanned,
+			},
+		}
+
+		for _, tc := range testCases {
+			suite.Run(tc.name, func() {
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(tc.body))
+
+				suite.handler.ServeHTTP(rec, req)
+
+				var verr domain.ValidationError
+
+				if suite.Equal(http.StatusBadRequest, rec.Code) &&
+					suite.NoError(json.Unmarshal(rec.Body.Bytes(), &verr)) &&
+					suite.Len(verr.Fields, 1) {
+					suite.Equal(tc.field, verr.Fields[0].Field)
+					suite.Equal(tc.code, verr.Fields[0].Code)
+					suite.NotEmpty(verr.Fields[0].Message)
+				}
+			})
+		}
+	})
+
+	suite.Run("default policy", func() {
+		h := handler.New(handler.HandlerOptions{
+			Auth:   suite.auth,
+			Signer: suite.signer,
+		})
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(`{"login":"l","password":"p4ss"}`))
+
+		h.ServeHTTP(rec, req)
+
+		var verr domain.ValidationError
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) &&
+			suite.NoError(json.Unmarshal(rec.Body.Bytes(), &verr)) &&
+			suite.Len(verr.Fields, 2) {
+			suite.Equal(domain.FieldError{
+				Field:   "login",
+				Code:    domain.CodeTooShort,
+				Message: "login must be at least 3 characters",
+			}, verr.Fields[0])
+			suite.Equal(domain.FieldError{
+				Field:   "password",
+				Code:    domain.CodeTooShort,
+				Message: "password must be at least 8 characters",
+			}, verr.Fields[1])
+		}
+	})
 }
 
 func (suite *HandlerSuite) TestLogin() {
 	suite.Run("success", func() {
 		body := `{"login":"login","password":"password"}`
 
+		userID := uuid.New()
+
 		suite.auth.EXPECT().SignIn(
 			gomock.Any(),
 			domain.Authentication{Login: "login", Password: "password"},
-		).Return(uuid.New(), nil).Times(1)
+		).Return(userID, nil).Times(1)
+		suite.twoFactor.EXPECT().Challenge(gomock.Any(), userID).Return(
+			domain.TwoFactorChallenge{}, domain.ErrTwoFactorDisabled,
+		).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventLoginSucceeded)).Return(nil).Times(1)
+		suite.auth.EXPECT().CreateSession(gomock.Any(), userID).Return(domain.Session{
+			ID:           uuid.New(),
+			UserID:       userID,
+			RefreshToken: "refresh",
+		}, nil).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body))
@@ -107,6 +212,7 @@ func (suite *HandlerSuite) TestLogin() {
 
 		if suite.Equal(http.StatusOK, rec.Code) {
 			suite.NotEmpty(rec.Header().Get("Authorization"))
+			suite.Contains(rec.Body.String(), `"refresh_token":"refresh"`)
 			suite.ctrl.Finish()
 		}
 	})
@@ -118,16 +224,68 @@ func (suite *HandlerSuite) TestLogin() {
 			gomock.Any(),
 			domain.Authentication{Login: "login", Password: "password"},
 		).Return(uuid.Nil, domain.ErrNotFound).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventLoginFailed)).Return(nil).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body))
 
 		suite.handler.ServeHTTP(rec, req)
 
-		suite.Equal(http.StatusUnauthorized, rec.Code)
+		if suite.Equal(http.StatusUnauthorized, rec.Code) {
+			suite.Equal("invalid_credentials", suite.problemCode(rec))
+		}
 		suite.ctrl.Finish()
 	})
 
+	suite.Run("too many attempts", func() {
+		body := `{"login":"login","password":"password"}`
+
+		suite.auth.EXPECT().SignIn(
+			gomock.Any(),
+			domain.Authentication{Login: "login", Password: "password"},
+		).Return(uuid.Nil, &domain.ResourceExhaustedError{
+			Message:    "too many failed login attempts",
+			RetryAfter: 1500 * time.Millisecond,
+		}).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventLoginFailed)).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusTooManyRequests, rec.Code) {
+			suite.Equal("2", rec.Header().Get("Retry-After"))
+			suite.Equal("too_many_requests", suite.problemCode(rec))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("two-factor challenge", func() {
+		body := `{"login":"login","password":"password"}`
+
+		userID := uuid.New()
+
+		suite.auth.EXPECT().SignIn(
+			gomock.Any(),
+			domain.Authentication{Login: "login", Password: "password"},
+		).Return(userID, nil).Times(1)
+		suite.twoFactor.EXPECT().Challenge(gomock.Any(), userID).Return(
+			domain.TwoFactorChallenge{Token: "challenge"}, nil,
+		).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusAccepted, rec.Code) {
+			suite.Empty(rec.Header().Get("Authorization"))
+			suite.Contains(rec.Body.String(), `"challenge_token":"challenge"`)
+			suite.ctrl.Finish()
+		}
+	})
+
 	suite.Run("internal server error", func() {
 		body := `{"login":"login","password":"password"}`
 
@@ -167,3 +325,191 @@ func (suite *HandlerSuite) TestLogin() {
 		suite.Equal(http.StatusBadRequest, rec.Code)
 	})
 }
+
+func (suite *HandlerSuite) TestRefresh() {
+	suite.Run("success", func() {
+		body := `{"refresh_token":"refresh"}`
+
+		suite.auth.EXPECT().RefreshSession(gomock.Any(), "refresh").Return(domain.Session{
+			ID:           suite.identity.SessionID,
+			UserID:       suite.userID,
+			RefreshToken: "refresh2",
+		}, nil).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventTokenRefreshed)).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", strings.NewReader(body))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.NotEmpty(rec.Header().Get("Authorization"))
+			suite.Contains(rec.Body.String(), `"refresh_token":"refresh2"`)
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("revoked", func() {
+		body := `{"refresh_token":"refresh"}`
+
+		suite.auth.EXPECT().RefreshSession(gomock.Any(), "refresh").
+			Return(domain.Session{}, domain.ErrNotFound).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", strings.NewReader(body))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		suite.Equal(http.StatusUnauthorized, rec.Code)
+		suite.ctrl.Finish()
+	})
+
+	suite.Run("bad request", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", strings.NewReader(`{}`))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		suite.Equal(http.StatusBadRequest, rec.Code)
+	})
+}
+
+func (suite *HandlerSuite) TestLogout() {
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.auth.EXPECT().RevokeSession(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventLoggedOut)).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/logout", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		suite.Equal(http.StatusOK, rec.Code)
+		suite.ctrl.Finish()
+	})
+
+	suite.Run("revoked session", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/logout", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		suite.Equal(http.StatusUnauthorized, rec.Code)
+		suite.ctrl.Finish()
+	})
+}
+
+func (suite *HandlerSuite) TestChangePassword() {
+	change := domain.PasswordChange{CurrentPassword: "password", NewPassword: "password2"}
+
+	suite.Run("success", func() {
+		body := `{"current_password":"password","new_password":"password2"}`
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.auth.EXPECT().ChangePassword(gomock.Any(), suite.userID, change).Return(nil).Times(1)
+		suite.auth.EXPECT().CreateSession(gomock.Any(), suite.userID).Return(domain.Session{
+			ID:           uuid.New(),
+			UserID:       suite.userID,
+			RefreshToken: "refresh",
+		}, nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/password", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.NotEmpty(rec.Header().Get("Authorization"))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("invalid password", func() {
+		body := `{"current_password":"password","new_password":"password2"}`
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.auth.EXPECT().ChangePassword(gomock.Any(), suite.userID, change).
+			Return(domain.ErrInvalidPassword).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/password", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		suite.Equal(http.StatusForbidden, rec.Code)
+		suite.ctrl.Finish()
+	})
+
+	suite.Run("bad request", func() {
+		body := `{"current_password":"password"}`
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/password", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		suite.Equal(http.StatusBadRequest, rec.Code)
+		suite.ctrl.Finish()
+	})
+}
+
+func (suite *HandlerSuite) TestDeleteAccount() {
+	deletion := domain.AccountDeletion{Password: "password"}
+	body := `{"password":"password"}`
+
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.auth.EXPECT().DeleteAccount(gomock.Any(), suite.userID, deletion).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/api/user", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusNoContent, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("invalid password", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.auth.EXPECT().DeleteAccount(gomock.Any(), suite.userID, deletion).Return(
+			domain.ErrInvalidPassword,
+		).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/api/user", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusForbidden, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("bad request", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodDelete, "/api/user", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+)
+
+// BodyLimits определяет максимальные размеры тел запросов в байтах;
+// нулевые значения заменяются значениями DefaultBodyLimits, а маршруты
+// без собственного ограничения получают ограничение из DefaultBodyLimits.
+type BodyLimits struct {
+	// Ограничение для маршрутов без собственного ограничения.
+	Default int64
+
+	// Ограничения маршрутов вида "POST /api/user/orders".
+	Routes httputil.RouteSizes
+}
+
+// DefaultBodyLimits определяет ограничения размеров тел запросов
+// по умолчанию: тела запросов API состоят из нескольких коротких полей.
+var DefaultBodyLimits = BodyLimits{
+	Default: 16 << 10,
+	Routes: httputil.RouteSizes{
+		"POST /api/user/register":         1 << 10,
+		"POST /api/user/login":            1 << 10,
+		"POST /api/user/login/2fa":        1 << 10,
+		"POST /api/user/token/refresh":    1 << 10,
+		"POST /api/user/orders":           1 << 10,
+		"POST /api/user/balance/withdraw": 1 << 10,
+	},
+}
+
+// limit возвращает ограничение размера тела запроса к маршруту route.
+func (l BodyLimits) limit(route string) int64 {
+	if n, ok := l.Routes[route]; ok && n > 0 {
+		return n
+	}
+	if n, ok := DefaultBodyLimits.Routes[route]; ok {
+		return n
+	}
+	if l.Default > 0 {
+		return l.Default
+	}
+	return DefaultBodyLimits.Default
+}
+
+// limitBody ограничивает размер тела запроса к маршруту; если тело больше
+// ограничения, то возвращает http.StatusRequestEntityTooLarge. Используется
+// внутри групп маршрутов, где шаблон маршрута уже известен, и до проверки
+// запроса по спецификации.
+func (h *handler) limitBody(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		route := r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+		limit := h.bodyLimits.limit(route)
+
+		if r.ContentLength > limit {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, &http.MaxBytesError{Limit: limit})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// decodeJSON декодирует тело запроса в v; неизвестные поля и данные после
+// значения JSON считаются ошибкой.
+func decodeJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err != nil {
+		return err
+	}
+
+	if _, err = dec.Token(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: unexpected data after the JSON value", errMalformedBody)
+	}
+
+	return nil
+}
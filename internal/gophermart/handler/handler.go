@@ -1,43 +1,126 @@
 package handler
 
 import (
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+	"github.com/sergeizaitcev/gophermart/pkg/compress"
+	"github.com/sergeizaitcev/gophermart/pkg/health"
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/openapi"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
 	"github.com/sergeizaitcev/gophermart/pkg/sign"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 // HandlerOptions определяет опции для HTTP-обработчика.
 type HandlerOptions struct {
 	Auth       domain.AuthService
+	APIKeys    domain.APIKeyService
+	TwoFactor  domain.TwoFactorService
 	Operations domain.OperationService
 	Orders     domain.OrderService
 	Users      domain.UserService
+	Audit      domain.AuditService
 	Signer     sign.Signer
+
+	// Логгер журнала запросов, передаваемый обработчикам через контекст.
+	//
+	// По умолчанию slog.Default().
+	Logger *slog.Logger
+
+	// Набор ключей подписи, открытые ключи которого публикуются
+	// в /.well-known/jwks.json.
+	Keys *sign.KeySet
+
+	// Политика логинов и паролей пользователей.
+	Credentials domain.CredentialsPolicy
+
+	// Сумма списания, свыше которой требуется свежий код второго фактора;
+	// 0 отключает проверку.
+	WithdrawalCodeThreshold monetary.Unit
+
+	// Сети обратных прокси, которым доверяется X-Forwarded-For при
+	// определении IP-адреса клиента.
+	//
+	// По умолчанию адресом клиента считается адрес соединения.
+	TrustedProxies httputil.TrustedProxies
+
+	// Ограничения частоты запросов.
+	RateLimits RateLimits
+
+	// Ограничения размеров тел запросов.
+	BodyLimits BodyLimits
+
+	// Проверки готовности для /readyz.
+	//
+	// По умолчанию проверки отсутствуют.
+	Health *health.Probe
 }
 
 // handler определяет HTTP-обработчик для gophermart.
 type handler struct {
 	mux    *chi.Mux
-	signer sign.Signer
+	authn  *service.Authenticator
+	keys   *sign.KeySet
+	logger *slog.Logger
+
+	proxies    httputil.TrustedProxies
+	limiters   rateLimiters
+	bodyLimits BodyLimits
+	probe      *health.Probe
+
+	credentials domain.CredentialsPolicy
 
 	auth       domain.AuthService
+	apiKeys    domain.APIKeyService
+	twoFactor  domain.TwoFactorService
 	operations domain.OperationService
 	orders     domain.OrderService
 	users      domain.UserService
+	audit      domain.AuditService
 }
 
 // New возвращает новый HTTP-обработчик.
 func New(opt HandlerOptions) http.Handler {
+	if opt.Health == nil {
+		opt.Health = health.NewProbe(0)
+	}
 	r := &handler{
-		mux:        chi.NewRouter(),
-		signer:     opt.Signer,
-		auth:       opt.Auth,
-		users:      opt.Users,
-		orders:     opt.Orders,
-		operations: opt.Operations,
+		mux: chi.NewRouter(),
+		authn: service.NewAuthenticator(service.AuthenticatorOptions{
+			Auth:      opt.Auth,
+			APIKeys:   opt.APIKeys,
+			TwoFactor: opt.TwoFactor,
+			Audit:     opt.Audit,
+			Signer:    opt.Signer,
+
+			WithdrawalCodeThreshold: opt.WithdrawalCodeThreshold,
+		}),
+		keys:        opt.Keys,
+		logger:      opt.Logger,
+		proxies:     opt.TrustedProxies,
+		limiters:    newRateLimiters(opt.RateLimits),
+		bodyLimits:  opt.BodyLimits,
+		probe:       opt.Health,
+		credentials: opt.Credentials,
+		auth:        opt.Auth,
+		apiKeys:     opt.APIKeys,
+		twoFactor:   opt.TwoFactor,
+		users:       opt.Users,
+		orders:      opt.Orders,
+		operations:  opt.Operations,
+		audit:       opt.Audit,
 	}
 	r.init()
 	return r
@@ -49,22 +132,128 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handler) init() {
+	h.mux.Use(metrics.Middleware)
+	h.mux.Use(tracing.PublicMiddleware)
+	h.mux.Use(logging.Middleware(h.logger))
+	h.mux.Use(h.clientInfo)
+	h.mux.Use(compress.Gzip(nil))
+
+	h.mux.Method(http.MethodGet, "/healthz", h.probe.Healthz())
+	h.mux.Method(http.MethodGet, "/readyz", h.probe.Readyz())
+
+	// Ограничение на IP-адрес клиента действует только до авторизации:
+	// пробы и метрики не ограничиваются, а авторизованные запросы
+	// ограничиваются по пользователю.
+	h.mux.With(h.limitClient).Get("/.well-known/jwks.json", h.getJWKS)
+	h.mux.With(h.limitClient).Method(http.MethodGet, "/api/openapi.json", spec)
+
+	// Запросы проверяются по спецификации после авторизации, чтобы
+	// неавторизованный клиент не получал подробностей о схеме.
+	validate := openapi.Validate(spec)
+
 	h.mux.Route("/api/user", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
+			r.Use(h.limitClient)
+			r.Use(h.limitRoute)
+			r.Use(h.limitBody)
+			r.Use(validate)
+
 			r.Post("/register", h.register)
 			r.Post("/login", h.login)
+			r.Post("/login/2fa", h.loginTwoFactor)
+			r.Post("/token/refresh", h.refresh)
 		})
 
 		r.Group(func(r chi.Router) {
 			r.Use(h.authorization)
+			r.Use(h.limitUser)
+			r.Use(h.limitRoute)
+			r.Use(h.limitBody)
+			r.Use(validate)
+
+			r.Group(func(r chi.Router) {
+				r.Use(requireSession)
+
+				r.Post("/logout", h.logout)
+				r.Post("/password", h.changePassword)
+
+				r.Post("/api-keys", h.createAPIKey)
+				r.Get("/api-keys", h.getAPIKeys)
+				r.Delete("/api-keys/{id}", h.revokeAPIKey)
+
+				r.Post("/2fa/totp", h.enrollTOTP)
+				r.Post("/2fa/totp/enable", h.enableTOTP)
+				r.Post("/2fa/totp/disable", h.disableTOTP)
+
+				r.Get("/security-events", h.getSecurityEvents)
 
-			r.Post("/orders", h.orderProcess)
-			r.Get("/orders", h.getOrders)
+				r.Get("/export", h.exportUser)
+				r.Delete("/", h.deleteAccount)
+			})
 
-			r.Get("/balance", h.getBalance)
+			r.With(requireScope(domain.ScopeOrdersWrite)).Post("/orders", h.orderProcess)
+			r.With(requireScope(domain.ScopeOrdersRead)).Get("/orders", h.getOrders)
 
-			r.Post("/balance/withdraw", h.operationPerform)
-			r.Get("/withdrawals", h.getOperations)
+			r.With(requireScope(domain.ScopeBalanceRead)).Get("/balance", h.getBalance)
+
+			r.With(requireScope(domain.ScopeBalanceWrite)).Post("/balance/withdraw", h.operationPerform)
+			r.With(requireScope(domain.ScopeBalanceRead)).Get("/withdrawals", h.getOperations)
 		})
 	})
+
+	h.mux.Route("/api/admin", func(r chi.Router) {
+		r.Use(h.authorization)
+		r.Use(requireRole(domain.RoleAdmin))
+		r.Use(h.limitUser)
+
+		r.Group(func(r chi.Router) {
+			r.Use(h.limitRoute)
+			r.Use(h.limitBody)
+			r.Use(validate)
+
+			r.Put("/users/{login}/role", h.setUserRole)
+			r.Post("/users/{login}/api-keys", h.createUserAPIKey)
+
+			r.Get("/security-events", h.getAllSecurityEvents)
+		})
+	})
+}
+
+// clientInfo прокидывает в контекст сведения о клиенте, выполняющем запрос;
+// адрес клиента за доверенным прокси берётся из X-Forwarded-For.
+func (h *handler) clientInfo(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := domain.WithClient(r.Context(), domain.Client{
+			IP:        h.proxies.ClientIP(r),
+			UserAgent: r.UserAgent(),
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// writeRetryAfter возвращает http.StatusTooManyRequests с заголовком
+// Retry-After.
+func writeRetryAfter(w http.ResponseWriter, r *http.Request, exhausted *domain.ResourceExhaustedError) {
+	seconds := int(math.Ceil(exhausted.RetryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeProblemBody(w, r, problem.New(http.StatusTooManyRequests, "", exhausted.Message))
+}
+
+// writeValidationError возвращает http.StatusBadRequest; ошибки валидации
+// полей передаются в теле ответа.
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error(err.Error())
+
+	var verr *domain.ValidationError
+	if !errors.As(err, &verr) {
+		writeProblem(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	p := problem.FromError(http.StatusBadRequest, errValidation, problemMappings)
+	p.Errors = verr.Fields
+
+	writeProblemBody(w, r, p)
 }
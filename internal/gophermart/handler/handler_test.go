@@ -1,7 +1,10 @@
 package handler_test
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"regexp"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -11,10 +14,12 @@ import (
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
 	mock_domain "github.com/sergeizaitcev/gophermart/internal/gophermart/domain/mocks"
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/handler"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
 )
 
 type signerStub struct {
-	userID domain.UserID
+	identity domain.Identity
 }
 
 func (*signerStub) Sign(payload string) (token string, err error) {
@@ -22,7 +27,8 @@ func (*signerStub) Sign(payload string) (token string, err error) {
 }
 
 func (s *signerStub) Parse(token string) (payload string, err error) {
-	return s.userID.String(), nil
+	b, err := json.Marshal(s.identity)
+	return string(b), err
 }
 
 type HandlerSuite struct {
@@ -31,12 +37,17 @@ type HandlerSuite struct {
 	ctrl *gomock.Controller
 
 	auth       *mock_domain.MockAuthService
+	apiKeys    *mock_domain.MockAPIKeyService
+	twoFactor  *mock_domain.MockTwoFactorService
 	operations *mock_domain.MockOperationService
 	orders     *mock_domain.MockOrderService
 	users      *mock_domain.MockUserService
+	audit      *mock_domain.MockAuditService
 
-	handler http.Handler
-	userID  domain.UserID
+	handler  http.Handler
+	signer   *signerStub
+	userID   domain.UserID
+	identity domain.Identity
 }
 
 func TestHandler(t *testing.T) {
@@ -47,17 +58,49 @@ func (suite *HandlerSuite) SetupSuite() {
 	suite.ctrl = gomock.NewController(suite.T())
 
 	suite.auth = mock_domain.NewMockAuthService(suite.ctrl)
+	suite.apiKeys = mock_domain.NewMockAPIKeyService(suite.ctrl)
+	suite.twoFactor = mock_domain.NewMockTwoFactorService(suite.ctrl)
 	suite.operations = mock_domain.NewMockOperationService(suite.ctrl)
 	suite.orders = mock_domain.NewMockOrderService(suite.ctrl)
 	suite.users = mock_domain.NewMockUserService(suite.ctrl)
+	suite.audit = mock_domain.NewMockAuditService(suite.ctrl)
 
 	suite.userID = uuid.New()
+	suite.identity = domain.Identity{
+		UserID:    suite.userID,
+		SessionID: uuid.New(),
+		Role:      domain.RoleUser,
+	}
+	suite.signer = &signerStub{identity: suite.identity}
 
 	suite.handler = handler.New(handler.HandlerOptions{
 		Auth:       suite.auth,
+		APIKeys:    suite.apiKeys,
+		TwoFactor:  suite.twoFactor,
 		Operations: suite.operations,
 		Orders:     suite.orders,
 		Users:      suite.users,
-		Signer:     &signerStub{userID: suite.userID},
+		Audit:      suite.audit,
+		Signer:     suite.signer,
+		Credentials: domain.CredentialsPolicy{
+			LoginMinLen:     3,
+			LoginMaxLen:     32,
+			LoginPattern:    regexp.MustCompile(`^[a-z0-9_]+$`),
+			PasswordMinLen:  8,
+			BannedPasswords: domain.BannedPasswords{"password1": {}},
+		},
+		WithdrawalCodeThreshold: monetary.Format(1000),
 	})
 }
+
+// problemCode возвращает стабильный код ошибки из ответа в формате
+// RFC 7807.
+func (suite *HandlerSuite) problemCode(rec *httptest.ResponseRecorder) string {
+	suite.Equal(problem.ContentType, rec.Header().Get("Content-Type"))
+
+	var p problem.Problem
+	suite.NoError(json.NewDecoder(rec.Body).Decode(&p))
+	suite.Equal(rec.Code, p.Status)
+
+	return p.Code
+}
@@ -0,0 +1,41 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/handler"
+	"github.com/sergeizaitcev/gophermart/pkg/health"
+)
+
+func TestHealth(t *testing.T) {
+	probe := health.NewProbe(0)
+	probe.Register("database", func(context.Context) error { return nil })
+	probe.Register("accrual", func(context.Context) error { return errors.New("connection refused") })
+
+	h := handler.New(handler.HandlerOptions{Health: probe})
+
+	do := func(target string) (int, health.Report) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+
+		var report health.Report
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		return rec.Code, report
+	}
+
+	code, _ := do("/healthz")
+	require.Equal(t, http.StatusOK, code)
+
+	code, report := do("/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.Equal(t, health.StatusOK, report.Checks["database"].Status)
+	require.Equal(t, health.StatusFail, report.Checks["accrual"].Status)
+	require.Equal(t, "connection refused", report.Checks["accrual"].Error)
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/sign"
+)
+
+// getJWKS возвращает открытые ключи, которыми можно проверить подпись
+// токенов авторизации.
+func (h *handler) getJWKS(w http.ResponseWriter, r *http.Request) {
+	jwks := sign.JWKS{Keys: []sign.JWK{}}
+	if h.keys != nil {
+		jwks = h.keys.JWKS()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+
+	err := json.NewEncoder(w).Encode(jwks)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
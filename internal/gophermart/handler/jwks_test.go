@@ -0,0 +1,18 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+func (suite *HandlerSuite) TestGetJWKS() {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", http.NoBody)
+
+	suite.handler.ServeHTTP(rec, req)
+
+	if suite.Equal(http.StatusOK, rec.Code) {
+		suite.Equal("application/json", rec.Header().Get("Content-Type"))
+		suite.JSONEq(`{"keys":[]}`, rec.Body.String())
+	}
+}
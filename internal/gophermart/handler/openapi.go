@@ -0,0 +1,14 @@
+package handler
+
+import (
+	_ "embed"
+
+	"github.com/sergeizaitcev/gophermart/pkg/openapi"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+// spec определяет спецификацию API, по которой проверяются запросы;
+// публикуется в /api/openapi.json.
+var spec = openapi.MustParse(specJSON)
@@ -0,0 +1,85 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/golang/mock/gomock"
+)
+
+func (suite *HandlerSuite) TestOpenAPI() {
+	suite.Run("spec", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/openapi.json", http.NoBody)
+
+		suite.handler.ServeHTTP(rec, req)
+
+		var doc struct {
+			OpenAPI string         `json:"openapi"`
+			Paths   map[string]any `json:"paths"`
+		}
+
+		if suite.Equal(http.StatusOK, rec.Code) &&
+			suite.NoError(json.NewDecoder(rec.Body).Decode(&doc)) {
+			suite.Equal("3.0.3", doc.OpenAPI)
+			suite.Contains(doc.Paths, "/api/user/orders")
+		}
+	})
+
+	suite.Run("schema violation", func() {
+		body := `{"login":1,"password":"password"}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.Equal("schema_violation", suite.problemCode(rec))
+		}
+	})
+
+	suite.Run("domain validation", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(`{"login":"user"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.Equal("validation_failed", suite.problemCode(rec))
+		}
+	})
+
+	suite.Run("unsupported content type", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/api/user/balance/withdraw",
+			strings.NewReader(`{"order":"2377225624","sum":751}`),
+		)
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.Equal("unsupported_content_type", suite.problemCode(rec))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("unauthorized before validation", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(`[]`))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		suite.Equal(http.StatusUnauthorized, rec.Code)
+	})
+}
@@ -5,9 +5,8 @@ import (
 	"errors"
 	"net/http"
 
-	"log/slog"
-
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
 )
 
 // operationPerform выполняет балансовую операцию авторизованного пользователя.
@@ -16,23 +15,27 @@ func (h *handler) operationPerform(w http.ResponseWriter, r *http.Request) {
 
 	userID := userFromContext(ctx)
 	if userID == domain.EmptyUserID {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
 		return
 	}
 
 	var operation domain.Operation
 
-	err := json.NewDecoder(r.Body).Decode(&operation)
+	err := decodeJSON(r, &operation)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		slog.Error(err.Error())
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
 		return
 	}
 
 	err = operation.OrderNumber.Validate()
 	if err != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
-		slog.Error(err.Error())
+		writeProblem(w, r, http.StatusUnprocessableEntity, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	if !h.verifyWithdrawal(w, r, operation.Sum) {
 		return
 	}
 
@@ -41,11 +44,11 @@ func (h *handler) operationPerform(w http.ResponseWriter, r *http.Request) {
 	err = h.operations.Perform(ctx, operation)
 	if err != nil {
 		if errors.Is(err, domain.ErrBalanceBelowZero) {
-			w.WriteHeader(http.StatusPaymentRequired)
+			writeProblem(w, r, http.StatusPaymentRequired, err)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, err)
 		}
-		slog.Error(err.Error())
+		logging.FromContext(r.Context()).Error(err.Error())
 	}
 }
 
@@ -55,7 +58,7 @@ func (h *handler) getOperations(w http.ResponseWriter, r *http.Request) {
 
 	userID := userFromContext(ctx)
 	if userID == domain.EmptyUserID {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
 		return
 	}
 
@@ -64,9 +67,9 @@ func (h *handler) getOperations(w http.ResponseWriter, r *http.Request) {
 		if errors.Is(err, domain.ErrNotFound) {
 			w.WriteHeader(http.StatusNoContent)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, err)
 		}
-		slog.Error(err.Error())
+		logging.FromContext(r.Context()).Error(err.Error())
 		return
 	}
 
@@ -75,6 +78,6 @@ func (h *handler) getOperations(w http.ResponseWriter, r *http.Request) {
 
 	err = json.NewEncoder(w).Encode(operations)
 	if err != nil {
-		slog.Error(err.Error())
+		logging.FromContext(r.Context()).Error(err.Error())
 	}
 }
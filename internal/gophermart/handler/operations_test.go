@@ -17,7 +17,7 @@ func (suite *HandlerSuite) TestPerformOperation() {
 	orderNumber := "49927398716"
 
 	suite.Run("success", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.operations.EXPECT().Perform(gomock.Any(), domain.Operation{
 			UserID:      suite.userID,
 			OrderNumber: domain.OrderNumber(orderNumber),
@@ -42,7 +42,7 @@ func (suite *HandlerSuite) TestPerformOperation() {
 	})
 
 	suite.Run("invalid order number", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 
 		body := fmt.Sprintf(`{"order":%q,"sum":1000}`, "invalid")
 
@@ -62,7 +62,7 @@ func (suite *HandlerSuite) TestPerformOperation() {
 	})
 
 	suite.Run("balance below zero", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.operations.EXPECT().Perform(gomock.Any(), domain.Operation{
 			UserID:      suite.userID,
 			OrderNumber: domain.OrderNumber(orderNumber),
@@ -87,7 +87,7 @@ func (suite *HandlerSuite) TestPerformOperation() {
 	})
 
 	suite.Run("internal server error", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.operations.EXPECT().Perform(gomock.Any(), domain.Operation{
 			UserID:      suite.userID,
 			OrderNumber: domain.OrderNumber(orderNumber),
@@ -112,7 +112,7 @@ func (suite *HandlerSuite) TestPerformOperation() {
 	})
 
 	suite.Run("unauthorized", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(domain.ErrNotFound).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", http.NoBody)
@@ -137,7 +137,7 @@ func (suite *HandlerSuite) TestPerformOperation() {
 
 func (suite *HandlerSuite) TestGerOperations() {
 	suite.Run("success", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.operations.EXPECT().GetOperations(gomock.Any(), suite.userID).Return(
 			[]domain.Operation{}, nil,
 		)
@@ -155,7 +155,7 @@ func (suite *HandlerSuite) TestGerOperations() {
 	})
 
 	suite.Run("not found", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.operations.EXPECT().GetOperations(gomock.Any(), suite.userID).Return(
 			nil, domain.ErrNotFound,
 		)
@@ -172,7 +172,7 @@ func (suite *HandlerSuite) TestGerOperations() {
 	})
 
 	suite.Run("internal server error", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.operations.EXPECT().GetOperations(gomock.Any(), suite.userID).Return(
 			nil, errors.New("error"),
 		)
@@ -189,7 +189,7 @@ func (suite *HandlerSuite) TestGerOperations() {
 	})
 
 	suite.Run("unauthorized", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(domain.ErrNotFound).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/api/user/withdrawals", http.NoBody)
@@ -4,55 +4,72 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"mime"
 	"net/http"
-	"strings"
-
-	"log/slog"
+	"time"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
 )
 
-// orderProcess добавляет заказ авторизованного пользователя в обработку.
+// orderProcess добавляет заказ авторизованного пользователя в обработку;
+// номер заказа передаётся в теле text/plain или в поле order тела
+// application/json вместе с необязательными сведениями о покупке.
 func (h *handler) orderProcess(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	userID := userFromContext(ctx)
 	if userID == domain.EmptyUserID {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
 		return
 	}
 
-	if !strings.Contains(r.Header.Get("Content-Type"), "text/plain") {
-		w.WriteHeader(http.StatusBadRequest)
+	var upload domain.OrderUpload
+
+	// Тип тела определяется так же, как при проверке по спецификации:
+	// без Content-Type номер заказа не принимается.
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/plain":
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err)
+			return
+		}
+		upload.Number = domain.OrderNumber(b)
+	case "application/json":
+		err := decodeJSON(r, &upload)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		writeProblem(w, r, http.StatusBadRequest, errUnsupportedContentType)
 		return
 	}
 
-	b, err := io.ReadAll(r.Body)
+	err := upload.Number.Validate()
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeProblem(w, r, http.StatusUnprocessableEntity, err)
 		return
 	}
 
-	orderNumber, err := domain.NewOrderNumber(string(b))
+	err = upload.Validate(time.Now())
 	if err != nil {
-		w.WriteHeader(http.StatusUnprocessableEntity)
+		writeValidationError(w, r, err)
 		return
 	}
 
-	order := domain.Order{
-		UserID: userID,
-		Number: orderNumber,
-		Status: domain.OrderStatusNew,
-	}
+	order := upload.Order(userID)
 
 	err = h.orders.Process(ctx, order)
 	if err != nil {
 		if errors.Is(err, domain.ErrDuplicate) {
 			w.WriteHeader(http.StatusOK)
 		} else if errors.Is(err, domain.ErrDuplicateOtherUser) {
-			w.WriteHeader(http.StatusConflict)
+			writeProblem(w, r, http.StatusConflict, err)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, err)
 		}
 		return
 	}
@@ -66,7 +83,7 @@ func (h *handler) getOrders(w http.ResponseWriter, r *http.Request) {
 
 	userID := userFromContext(ctx)
 	if userID == domain.EmptyUserID {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
 		return
 	}
 
@@ -75,7 +92,7 @@ func (h *handler) getOrders(w http.ResponseWriter, r *http.Request) {
 		if errors.Is(err, domain.ErrNotFound) {
 			w.WriteHeader(http.StatusNoContent)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, err)
 		}
 		return
 	}
@@ -85,6 +102,6 @@ func (h *handler) getOrders(w http.ResponseWriter, r *http.Request) {
 
 	err = json.NewEncoder(w).Encode(orders)
 	if err != nil {
-		slog.Error(err.Error())
+		logging.FromContext(r.Context()).Error(err.Error())
 	}
 }
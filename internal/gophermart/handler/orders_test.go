@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	"github.com/golang/mock/gomock"
 
@@ -15,7 +16,7 @@ func (suite *HandlerSuite) TestOrderProcess() {
 	orderNumber := "49927398716"
 
 	suite.Run("success", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.orders.EXPECT().Process(gomock.Any(), domain.Order{
 			UserID: suite.userID,
 			Number: domain.OrderNumber(orderNumber),
@@ -39,7 +40,7 @@ func (suite *HandlerSuite) TestOrderProcess() {
 	})
 
 	suite.Run("unsupported media type", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodPost, "/api/user/orders", http.NoBody)
@@ -48,12 +49,116 @@ func (suite *HandlerSuite) TestOrderProcess() {
 		suite.handler.ServeHTTP(rec, req)
 
 		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.Equal("unsupported_content_type", suite.problemCode(rec))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("xml", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader("<order/>"))
+		req.Header.Set("Content-Type", "application/xml")
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.Equal("unsupported_content_type", suite.problemCode(rec))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("json with metadata", func() {
+		purchasedAt := time.Date(2023, 12, 1, 10, 30, 0, 0, time.UTC)
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.orders.EXPECT().Process(gomock.Any(), domain.Order{
+			UserID:      suite.userID,
+			Number:      domain.OrderNumber(orderNumber),
+			Status:      domain.OrderStatusNew,
+			Store:       "Bork",
+			PurchasedAt: &purchasedAt,
+		}).Return(nil)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/api/user/orders",
+			strings.NewReader(`{"order":"49927398716","store":"Bork","purchased_at":"2023-12-01T10:30:00Z"}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusAccepted, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("json unknown field", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/api/user/orders",
+			strings.NewReader(`{"order":"49927398716","accrual":500}`),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.Equal("schema_violation", suite.problemCode(rec))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("purchase in the future", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		body := `{"order":"49927398716","purchased_at":"` +
+			time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `"}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.Equal("validation_failed", suite.problemCode(rec))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("body too large", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/api/user/orders",
+			strings.NewReader(strings.Repeat("4", 2<<10)),
+		)
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusRequestEntityTooLarge, rec.Code) {
+			suite.Equal("body_too_large", suite.problemCode(rec))
 			suite.ctrl.Finish()
 		}
 	})
 
 	suite.Run("invalid order number", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(
@@ -67,12 +172,33 @@ func (suite *HandlerSuite) TestOrderProcess() {
 		suite.handler.ServeHTTP(rec, req)
 
 		if suite.Equal(http.StatusUnprocessableEntity, rec.Code) {
+			suite.Equal("order_number_not_digits", suite.problemCode(rec))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("invalid checksum", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/api/user/orders",
+			strings.NewReader("49927398717"),
+		)
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusUnprocessableEntity, rec.Code) {
+			suite.Equal("order_number_invalid_checksum", suite.problemCode(rec))
 			suite.ctrl.Finish()
 		}
 	})
 
 	suite.Run("duplicate", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.orders.EXPECT().Process(gomock.Any(), domain.Order{
 			UserID: suite.userID,
 			Number: domain.OrderNumber(orderNumber),
@@ -96,7 +222,7 @@ func (suite *HandlerSuite) TestOrderProcess() {
 	})
 
 	suite.Run("conflict", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.orders.EXPECT().Process(gomock.Any(), domain.Order{
 			UserID: suite.userID,
 			Number: domain.OrderNumber(orderNumber),
@@ -115,12 +241,13 @@ func (suite *HandlerSuite) TestOrderProcess() {
 		suite.handler.ServeHTTP(rec, req)
 
 		if suite.Equal(http.StatusConflict, rec.Code) {
+			suite.Equal("order_owned_by_other_user", suite.problemCode(rec))
 			suite.ctrl.Finish()
 		}
 	})
 
 	suite.Run("internal server error", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.orders.EXPECT().Process(gomock.Any(), domain.Order{
 			UserID: suite.userID,
 			Number: domain.OrderNumber(orderNumber),
@@ -144,7 +271,7 @@ func (suite *HandlerSuite) TestOrderProcess() {
 	})
 
 	suite.Run("unauthorized", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(domain.ErrNotFound).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodPost, "/api/user/orders", http.NoBody)
@@ -153,6 +280,7 @@ func (suite *HandlerSuite) TestOrderProcess() {
 		suite.handler.ServeHTTP(rec, req)
 
 		if suite.Equal(http.StatusUnauthorized, rec.Code) {
+			suite.Equal("unauthenticated", suite.problemCode(rec))
 			suite.ctrl.Finish()
 		}
 	})
@@ -169,7 +297,7 @@ func (suite *HandlerSuite) TestOrderProcess() {
 
 func (suite *HandlerSuite) TestGerOrders() {
 	suite.Run("success", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.orders.EXPECT().GetOrders(gomock.Any(), suite.userID).Return(
 			[]domain.Order{}, nil,
 		)
@@ -187,7 +315,7 @@ func (suite *HandlerSuite) TestGerOrders() {
 	})
 
 	suite.Run("not found", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.orders.EXPECT().GetOrders(gomock.Any(), suite.userID).Return(
 			nil, domain.ErrNotFound,
 		)
@@ -204,7 +332,7 @@ func (suite *HandlerSuite) TestGerOrders() {
 	})
 
 	suite.Run("internal server error", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.orders.EXPECT().GetOrders(gomock.Any(), suite.userID).Return(
 			nil, errors.New("error"),
 		)
@@ -221,7 +349,7 @@ func (suite *HandlerSuite) TestGerOrders() {
 	})
 
 	suite.Run("unauthorized", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(domain.ErrNotFound).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/api/user/orders", http.NoBody)
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+)
+
+// Ошибки запроса, не относящиеся к предметной области.
+var (
+	errMalformedBody          = errors.New("malformed request body")
+	errBodyTooLarge           = errors.New("request body too large")
+	errUnsupportedContentType = errors.New("unsupported content type")
+	errUnauthenticated        = errors.New("authentication required")
+	errInvalidCredentials     = errors.New("invalid login or password")
+	errInvalidRefreshToken    = errors.New("invalid refresh token")
+	errChallengeExpired       = errors.New("two-factor challenge expired")
+	errForbidden              = errors.New("access denied")
+	errSessionRequired        = errors.New("session required")
+	errInsufficientScope      = errors.New("insufficient scope")
+	errInvalidQuery           = errors.New("invalid query parameters")
+	errInvalidID              = errors.New("invalid identifier")
+	errInvalidRole            = errors.New("invalid role")
+	errValidation             = errors.New("validation failed")
+)
+
+// problemMappings определяет стабильные коды ошибок API; ошибки запроса
+// проверяются раньше ошибок предметной области.
+var problemMappings = []problem.Mapping{
+	{Err: errMalformedBody, Code: "malformed_body", Detail: "request body is malformed"},
+	{Err: errBodyTooLarge, Code: "body_too_large", Detail: "request body exceeds the size limit"},
+	{Err: errUnsupportedContentType, Code: "unsupported_content_type", Detail: "content type is not supported"},
+	{Err: errUnauthenticated, Code: "unauthenticated", Detail: "valid access token or API key is required"},
+	{Err: errInvalidCredentials, Code: "invalid_credentials", Detail: "login or password is invalid"},
+	{Err: errInvalidRefreshToken, Code: "invalid_refresh_token", Detail: "refresh token is invalid, expired or revoked"},
+	{Err: errChallengeExpired, Code: "challenge_expired", Detail: "two-factor challenge is invalid or expired"},
+	{Err: errForbidden, Code: "forbidden", Detail: "access denied"},
+	{Err: errSessionRequired, Code: "session_required", Detail: "endpoint requires a user session"},
+	{Err: errInsufficientScope, Code: "insufficient_scope", Detail: "API key scopes do not allow this action"},
+	{Err: errInvalidQuery, Code: "invalid_query", Detail: "query parameters are invalid"},
+	{Err: errInvalidID, Code: "invalid_id", Detail: "identifier in the path is invalid"},
+	{Err: errInvalidRole, Code: "invalid_role", Detail: "role is not supported"},
+	{Err: errValidation, Code: "validation_failed", Detail: "request fields are invalid"},
+
+	{Err: domain.ErrOrderNumberNotDigits, Code: "order_number_not_digits", Detail: "order number must contain only digits"},
+	{Err: domain.ErrOrderNumberChecksum, Code: "order_number_invalid_checksum", Detail: "order number checksum is invalid"},
+	{Err: domain.ErrDuplicateOtherUser, Code: "order_owned_by_other_user", Detail: "order was uploaded by another user"},
+	{Err: domain.ErrDuplicate, Code: "already_exists", Detail: "resource already exists"},
+	{Err: domain.ErrBalanceBelowZero, Code: "insufficient_balance", Detail: "balance is insufficient"},
+	{Err: domain.ErrInvalidPassword, Code: "invalid_password", Detail: "password is invalid"},
+	{Err: domain.ErrInvalidCode, Code: "invalid_two_factor_code", Detail: "two-factor code is invalid or already used"},
+	{Err: domain.ErrTwoFactorDisabled, Code: "two_factor_disabled", Detail: "two-factor authentication is disabled"},
+	{Err: domain.ErrNotFound, Code: "not_found", Detail: "resource not found"},
+}
+
+// writeProblem возвращает ответ об ошибке в формате RFC 7807 с кодом ответа
+// status; стабильный код ошибки определяется по err. Неизвестные ошибки
+// с http.StatusBadRequest возникают при разборе тела запроса и получают
+// код malformed_body, а превышение размера тела запроса —
+// http.StatusRequestEntityTooLarge и код body_too_large.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		status, err = http.StatusRequestEntityTooLarge, errBodyTooLarge
+	}
+	if _, ok := problem.Lookup(err, problemMappings); !ok && status == http.StatusBadRequest {
+		err = errMalformedBody
+	}
+	writeProblemBody(w, r, problem.FromError(status, err, problemMappings))
+}
+
+func writeProblemBody(w http.ResponseWriter, r *http.Request, p *problem.Problem) {
+	err := p.Write(w, r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/throttling"
+)
+
+// RateLimits определяет ограничения частоты запросов; нулевые значения
+// снимают ограничения.
+type RateLimits struct {
+	// Ограничение на IP-адрес клиента для запросов без авторизации
+	// и неудачных попыток авторизации.
+	IP throttling.Rate
+
+	// Ограничение на пользователя для авторизованных запросов.
+	User throttling.Rate
+
+	// Ограничения маршрутов вида "POST /api/user/login"; действуют
+	// на пользователя, если запрос авторизован, иначе на IP-адрес клиента.
+	Routes throttling.RouteRates
+
+	// Время простоя, после которого корзина клиента удаляется.
+	IdleTimeout time.Duration
+}
+
+// rateLimiters определяет ограничители частоты запросов.
+type rateLimiters struct {
+	ip     *throttling.KeyedLimiter
+	user   *throttling.KeyedLimiter
+	routes map[string]*throttling.KeyedLimiter
+}
+
+func newRateLimiters(limits RateLimits) rateLimiters {
+	l := rateLimiters{
+		ip:     throttling.NewKeyedLimiter(limits.IP, limits.IdleTimeout),
+		user:   throttling.NewKeyedLimiter(limits.User, limits.IdleTimeout),
+		routes: make(map[string]*throttling.KeyedLimiter),
+	}
+	for route, rate := range limits.Routes {
+		if limiter := throttling.NewKeyedLimiter(rate, limits.IdleTimeout); limiter != nil {
+			l.routes[route] = limiter
+		}
+	}
+	return l
+}
+
+// limitClient ограничивает частоту запросов с IP-адреса клиента.
+// Используется для маршрутов без авторизации.
+func (h *handler) limitClient(next http.Handler) http.Handler {
+	return throttling.Limit(h.limiters.ip, clientKey)(next)
+}
+
+// allowClient учитывает неудачную попытку авторизации в ограничении
+// на IP-адрес клиента, чтобы перебор токенов и ключей не обходил его.
+// Если ограничение превышено, то отвечает http.StatusTooManyRequests
+// и возвращает false.
+func (h *handler) allowClient(w http.ResponseWriter, r *http.Request) bool {
+	key := clientKey(r)
+	if h.limiters.ip == nil || key == "" {
+		return true
+	}
+
+	d := h.limiters.ip.Allow(key)
+	if !d.Allowed {
+		throttling.WriteHeaders(w, d)
+		throttling.WriteLimited(w, r, d)
+		return false
+	}
+
+	return true
+}
+
+// limitUser ограничивает частоту запросов пользователя. Используется после
+// authorization.
+func (h *handler) limitUser(next http.Handler) http.Handler {
+	return throttling.Limit(h.limiters.user, userKey)(next)
+}
+
+// limitRoute ограничивает частоту запросов к маршруту, если для него задано
+// ограничение. Используется внутри групп маршрутов, где шаблон маршрута
+// уже известен.
+func (h *handler) limitRoute(next http.Handler) http.Handler {
+	if len(h.limiters.routes) == 0 {
+		return next
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		route := r.Method + " " + chi.RouteContext(r.Context()).RoutePattern()
+
+		limiter, ok := h.limiters.routes[route]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := userKey(r)
+		if key == "" {
+			key = clientKey(r)
+		}
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		d := limiter.Allow(key)
+		throttling.WriteHeaders(w, d)
+
+		if !d.Allowed {
+			throttling.WriteLimited(w, r, d)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// clientKey возвращает ключ ограничения по IP-адресу клиента.
+func clientKey(r *http.Request) string {
+	ip := domain.ClientFromContext(r.Context()).IP
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
+
+// userKey возвращает ключ ограничения по пользователю или пустую строку,
+// если запрос не авторизован.
+func userKey(r *http.Request) string {
+	userID := userFromContext(r.Context())
+	if userID == domain.EmptyUserID {
+		return ""
+	}
+	return "user:" + userID.String()
+}
@@ -0,0 +1,214 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/handler"
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+	"github.com/sergeizaitcev/gophermart/pkg/throttling"
+)
+
+func (suite *HandlerSuite) TestRateLimits() {
+	proxies, err := httputil.ParseTrustedProxies("10.0.0.0/8")
+	suite.Require().NoError(err)
+
+	newHandler := func(limits handler.RateLimits) http.Handler {
+		return handler.New(handler.HandlerOptions{
+			Auth:           suite.auth,
+			Users:          suite.users,
+			Audit:          suite.audit,
+			Signer:         suite.signer,
+			TrustedProxies: proxies,
+			RateLimits:     limits,
+		})
+	}
+
+	suite.Run("client", func() {
+		h := newHandler(handler.RateLimits{IP: throttling.Rate{Requests: 1, Per: time.Minute}})
+
+		do := func(remoteAddr string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", http.NoBody)
+			req.RemoteAddr = remoteAddr
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := do("10.0.0.1:1234")
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.Equal("1", rec.Header().Get(throttling.LimitHeader))
+			suite.Equal("0", rec.Header().Get(throttling.RemainingHeader))
+		}
+
+		rec = do("10.0.0.1:4321")
+		if suite.Equal(http.StatusTooManyRequests, rec.Code) {
+			suite.Equal("60", rec.Header().Get("Retry-After"))
+			suite.Equal("rate_limited", suite.problemCode(rec))
+		}
+
+		suite.Equal(http.StatusOK, do("10.0.0.2:1234").Code)
+	})
+
+	suite.Run("forwarded client", func() {
+		h := newHandler(handler.RateLimits{IP: throttling.Rate{Requests: 1, Per: time.Minute}})
+
+		do := func(remoteAddr, forwardedFor string) int {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", http.NoBody)
+			req.RemoteAddr = remoteAddr
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			h.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		suite.Equal(http.StatusOK, do("10.0.0.1:1234", "203.0.113.1"))
+		suite.Equal(http.StatusOK, do("10.0.0.1:1234", "203.0.113.2"))
+		suite.Equal(http.StatusTooManyRequests, do("10.0.0.1:1234", "203.0.113.1"))
+
+		// Заголовок от недоверенного адреса игнорируется.
+		suite.Equal(http.StatusOK, do("198.51.100.1:1234", "203.0.113.3"))
+		suite.Equal(http.StatusTooManyRequests, do("198.51.100.1:1234", "203.0.113.4"))
+	})
+
+	suite.Run("probes exempt", func() {
+		h := newHandler(handler.RateLimits{IP: throttling.Rate{Requests: 1, Per: time.Minute}})
+
+		for i := 0; i < 3; i++ {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody))
+			suite.Equal(http.StatusOK, rec.Code)
+			suite.Empty(rec.Header().Get(throttling.LimitHeader))
+		}
+	})
+
+	suite.Run("authorized client", func() {
+		h := newHandler(handler.RateLimits{IP: throttling.Rate{Requests: 1, Per: time.Minute}})
+
+		gomock.InOrder(
+			suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(2),
+			suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound).Times(2),
+		)
+		suite.users.EXPECT().GetBalance(gomock.Any(), suite.userID).Return(domain.UserBalance{}, nil).Times(2)
+
+		do := func() int {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/user/balance", http.NoBody)
+			req.Header.Set("Authorization", "Bearer token")
+			h.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		// Авторизованные запросы не расходуют ограничение на IP-адрес.
+		suite.Equal(http.StatusOK, do())
+		suite.Equal(http.StatusOK, do())
+
+		// Неудачные попытки авторизации расходуют.
+		suite.Equal(http.StatusUnauthorized, do())
+		if suite.Equal(http.StatusTooManyRequests, do()) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("route", func() {
+		h := newHandler(handler.RateLimits{
+			Routes: throttling.RouteRates{
+				"POST /api/user/login": {Requests: 1, Per: time.Minute},
+			},
+		})
+
+		body := `{"login":"login","password":"password"}`
+
+		suite.auth.EXPECT().SignIn(gomock.Any(), gomock.Any()).Return(uuid.Nil, domain.ErrNotFound).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventLoginFailed)).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body)))
+
+		suite.Equal(http.StatusUnauthorized, rec.Code)
+		suite.Equal("0", rec.Header().Get(throttling.RemainingHeader))
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/user/login", strings.NewReader(body)))
+
+		suite.Equal(http.StatusTooManyRequests, rec.Code)
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/user/register", strings.NewReader(`[]`)))
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.Empty(rec.Header().Get(throttling.RemainingHeader))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("admin route", func() {
+		admin := suite.identity
+		admin.Role = domain.RoleAdmin
+
+		suite.signer.identity = admin
+		defer func() { suite.signer.identity = suite.identity }()
+
+		h := newHandler(handler.RateLimits{
+			Routes: throttling.RouteRates{
+				"PUT /api/admin/users/{login}/role": {Requests: 1, Per: time.Minute},
+			},
+		})
+
+		suite.auth.EXPECT().Identify(gomock.Any(), admin).Return(nil).Times(2)
+		suite.users.EXPECT().SetRole(gomock.Any(), "login", domain.RoleAdmin).Return(nil).Times(1)
+
+		do := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/api/admin/users/login/role", strings.NewReader(`{"role":"admin"}`))
+			req.Header.Set("Authorization", "Bearer token")
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := do()
+		if suite.Equal(http.StatusNoContent, rec.Code) {
+			suite.Equal("0", rec.Header().Get(throttling.RemainingHeader))
+		}
+
+		rec = do()
+		if suite.Equal(http.StatusTooManyRequests, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("user", func() {
+		h := newHandler(handler.RateLimits{
+			IP:   throttling.Rate{Requests: 100, Per: time.Minute},
+			User: throttling.Rate{Requests: 1, Per: time.Minute},
+		})
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(2)
+		suite.users.EXPECT().GetBalance(gomock.Any(), suite.userID).Return(domain.UserBalance{}, nil).Times(1)
+
+		do := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/user/balance", http.NoBody)
+			req.Header.Set("Authorization", "Bearer token")
+			h.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := do()
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.Equal("1", rec.Header().Get(throttling.LimitHeader))
+			suite.Equal("0", rec.Header().Get(throttling.RemainingHeader))
+		}
+
+		rec = do()
+		if suite.Equal(http.StatusTooManyRequests, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+)
+
+// Заголовок запроса с кодом второго фактора для подтверждения операций.
+const twoFactorCodeHeader = "X-TOTP-Code"
+
+// enrollTOTP создаёт секрет TOTP авторизованного пользователя и возвращает
+// otpauth URI и коды восстановления.
+func (h *handler) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+
+	enrollment, err := h.twoFactor.Enroll(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrDuplicate) {
+			writeProblem(w, r, http.StatusConflict, err)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(enrollment)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
+
+// enableTOTP включает второй фактор авторизованного пользователя после
+// проверки кода.
+func (h *handler) enableTOTP(w http.ResponseWriter, r *http.Request) {
+	h.changeTOTP(w, r, h.twoFactor.Enable)
+}
+
+// disableTOTP отключает второй фактор авторизованного пользователя после
+// проверки кода.
+func (h *handler) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	h.changeTOTP(w, r, h.twoFactor.Disable)
+}
+
+// changeTOTP декодирует код второго фактора и передаёт его в change.
+func (h *handler) changeTOTP(
+	w http.ResponseWriter,
+	r *http.Request,
+	change func(ctx context.Context, id domain.UserID, code string) error,
+) {
+	ctx := r.Context()
+
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+
+	var code domain.TwoFactorCode
+
+	err := decodeJSON(r, &code)
+	if err == nil {
+		err = code.Validate()
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	err = change(ctx, userID, code.Code)
+	if err != nil {
+		var exhausted *domain.ResourceExhaustedError
+		switch {
+		case errors.As(err, &exhausted):
+			writeRetryAfter(w, r, exhausted)
+		case errors.Is(err, domain.ErrInvalidCode):
+			writeProblem(w, r, http.StatusForbidden, err)
+		case errors.Is(err, domain.ErrTwoFactorDisabled):
+			writeProblem(w, r, http.StatusConflict, err)
+		default:
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loginTwoFactor завершает вход пользователя с включённым вторым фактором
+// и возвращает в заголовке ответа токен авторизации, а в теле ответа —
+// токены сеанса.
+func (h *handler) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var resp domain.TwoFactorResponse
+
+	err := decodeJSON(r, &resp)
+	if err == nil {
+		err = resp.Validate()
+	}
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	session, err := h.authn.CompleteLogin(r.Context(), resp)
+	if err != nil {
+		var exhausted *domain.ResourceExhaustedError
+		switch {
+		case errors.As(err, &exhausted):
+			writeRetryAfter(w, r, exhausted)
+		case errors.Is(err, domain.ErrNotFound):
+			writeProblem(w, r, http.StatusUnauthorized, errChallengeExpired)
+		case errors.Is(err, domain.ErrInvalidCode),
+			errors.Is(err, domain.ErrTwoFactorDisabled):
+			writeProblem(w, r, http.StatusUnauthorized, err)
+		default:
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	writeSession(w, r, session)
+}
+
+// writeChallenge возвращает http.StatusAccepted с запросом второго фактора
+// в теле ответа.
+func writeChallenge(w http.ResponseWriter, r *http.Request, challenge domain.TwoFactorChallenge) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	err := json.NewEncoder(w).Encode(challenge)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
+
+// verifyWithdrawal проверяет свежий код второго фактора в заголовке
+// X-TOTP-Code, если сумма списания превышает порог; возвращает false
+// и записывает ответ, если списание не подтверждено.
+func (h *handler) verifyWithdrawal(w http.ResponseWriter, r *http.Request, sum monetary.Unit) bool {
+	ctx := r.Context()
+
+	err := h.authn.VerifyWithdrawal(ctx, userFromContext(ctx), sum, r.Header.Get(twoFactorCodeHeader))
+	if err == nil {
+		return true
+	}
+
+	var exhausted *domain.ResourceExhaustedError
+	switch {
+	case errors.As(err, &exhausted):
+		writeRetryAfter(w, r, exhausted)
+	case errors.Is(err, domain.ErrInvalidCode):
+		writeProblem(w, r, http.StatusForbidden, err)
+	default:
+		writeProblem(w, r, http.StatusInternalServerError, nil)
+	}
+	logging.FromContext(r.Context()).Error(err.Error())
+
+	return false
+}
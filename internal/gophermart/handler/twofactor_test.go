@@ -0,0 +1,250 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+)
+
+func (suite *HandlerSuite) TestEnrollTOTP() {
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.twoFactor.EXPECT().Enroll(gomock.Any(), suite.userID).Return(domain.TOTPEnrollment{
+			Secret:        "SECRET",
+			URI:           "otpauth://totp/Gophermart:login?secret=SECRET",
+			RecoveryCodes: []string{"code"},
+		}, nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/2fa/totp", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.Contains(rec.Body.String(), `"uri":"otpauth://totp/`)
+			suite.Contains(rec.Body.String(), `"recovery_codes":["code"]`)
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("already enabled", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.twoFactor.EXPECT().Enroll(gomock.Any(), suite.userID).Return(
+			domain.TOTPEnrollment{}, domain.ErrDuplicate,
+		).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/2fa/totp", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusConflict, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
+
+func (suite *HandlerSuite) TestEnableTOTP() {
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.twoFactor.EXPECT().Enable(gomock.Any(), suite.userID, "123456").Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/2fa/totp/enable", strings.NewReader(`{"code":"123456"}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusNoContent, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("invalid code", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.twoFactor.EXPECT().Enable(gomock.Any(), suite.userID, "000000").Return(domain.ErrInvalidCode).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/2fa/totp/enable", strings.NewReader(`{"code":"000000"}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusForbidden, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("bad request", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/2fa/totp/enable", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
+
+func (suite *HandlerSuite) TestLoginTwoFactor() {
+	resp := domain.TwoFactorResponse{Token: "challenge", Code: "123456"}
+	body := `{"challenge_token":"challenge","code":"123456"}`
+
+	suite.Run("success", func() {
+		userID := uuid.New()
+
+		suite.twoFactor.EXPECT().CompleteChallenge(gomock.Any(), resp).Return(userID, nil).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventLoginSucceeded)).Return(nil).Times(1)
+		suite.auth.EXPECT().CreateSession(gomock.Any(), userID).Return(domain.Session{
+			ID:           uuid.New(),
+			UserID:       userID,
+			RefreshToken: "refresh",
+		}, nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", strings.NewReader(body))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.NotEmpty(rec.Header().Get("Authorization"))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("invalid code", func() {
+		suite.twoFactor.EXPECT().CompleteChallenge(gomock.Any(), resp).Return(
+			domain.EmptyUserID, domain.ErrInvalidCode,
+		).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventLoginFailed)).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", strings.NewReader(body))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusUnauthorized, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("too many invalid codes", func() {
+		suite.twoFactor.EXPECT().CompleteChallenge(gomock.Any(), resp).Return(
+			domain.EmptyUserID, &domain.ResourceExhaustedError{
+				Message:    "too many invalid two-factor codes",
+				RetryAfter: time.Minute,
+			},
+		).Times(1)
+		suite.audit.EXPECT().Record(gomock.Any(), securityEvent(domain.EventLoginFailed)).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", strings.NewReader(body))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusTooManyRequests, rec.Code) {
+			suite.Equal("60", rec.Header().Get("Retry-After"))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("bad request", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/login/2fa", strings.NewReader(`{"code":"123456"}`))
+
+		suite.handler.ServeHTTP(rec, req)
+
+		suite.Equal(http.StatusBadRequest, rec.Code)
+	})
+}
+
+func (suite *HandlerSuite) TestWithdrawalTwoFactor() {
+	orderNumber := "12345678903"
+	operation := domain.Operation{
+		UserID:      suite.userID,
+		OrderNumber: domain.OrderNumber(orderNumber),
+		Sum:         monetary.Format(1500),
+	}
+	body := fmt.Sprintf(`{"order":%q,"sum":1500}`, orderNumber)
+
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.twoFactor.EXPECT().Verify(gomock.Any(), suite.userID, "123456").Return(nil).Times(1)
+		suite.operations.EXPECT().Perform(gomock.Any(), operation).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("X-TOTP-Code", "123456")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("two-factor disabled", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.twoFactor.EXPECT().Verify(gomock.Any(), suite.userID, "").Return(domain.ErrTwoFactorDisabled).Times(1)
+		suite.operations.EXPECT().Perform(gomock.Any(), operation).Return(nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("missing code", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.twoFactor.EXPECT().Verify(gomock.Any(), suite.userID, "").Return(domain.ErrInvalidCode).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusForbidden, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("codes locked out", func() {
+		exhausted := &domain.ResourceExhaustedError{Message: "too many invalid two-factor codes", RetryAfter: time.Minute}
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.twoFactor.EXPECT().Verify(gomock.Any(), suite.userID, "123456").Return(exhausted).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("X-TOTP-Code", "123456")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusTooManyRequests, rec.Code) {
+			suite.Equal("60", rec.Header().Get("Retry-After"))
+			suite.ctrl.Finish()
+		}
+	})
+}
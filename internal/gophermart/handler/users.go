@@ -3,11 +3,13 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 
-	"log/slog"
+	"github.com/go-chi/chi/v5"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
 )
 
 // getBalance возвращает баланс авторизованного пользователя.
@@ -16,18 +18,18 @@ func (h *handler) getBalance(w http.ResponseWriter, r *http.Request) {
 
 	userID := userFromContext(ctx)
 	if userID == domain.EmptyUserID {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
 		return
 	}
 
 	balance, err := h.users.GetBalance(ctx, userID)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
-			w.WriteHeader(http.StatusNotFound)
+			writeProblem(w, r, http.StatusNotFound, err)
 		} else {
-			w.WriteHeader(http.StatusInternalServerError)
+			writeProblem(w, r, http.StatusInternalServerError, err)
 		}
-		slog.Error(err.Error())
+		logging.FromContext(r.Context()).Error(err.Error())
 		return
 	}
 
@@ -36,6 +38,73 @@ func (h *handler) getBalance(w http.ResponseWriter, r *http.Request) {
 
 	err = json.NewEncoder(w).Encode(balance)
 	if err != nil {
-		slog.Error(err.Error())
+		logging.FromContext(r.Context()).Error(err.Error())
 	}
 }
+
+// exportUser возвращает выгрузку персональных данных авторизованного
+// пользователя в виде JSON-файла.
+func (h *handler) exportUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID := userFromContext(ctx)
+	if userID == domain.EmptyUserID {
+		writeProblem(w, r, http.StatusUnauthorized, errUnauthenticated)
+		return
+	}
+
+	export, err := h.users.Export(ctx, userID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, err)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="gophermart-export.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(export)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
+
+// setUserRole назначает роль пользователю с указанным логином.
+func (h *handler) setUserRole(w http.ResponseWriter, r *http.Request) {
+	var change domain.RoleChange
+
+	err := decodeJSON(r, &change)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err)
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	err = change.Role.Validate()
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, fmt.Errorf("%w: %s", errInvalidRole, err))
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	login := chi.URLParam(r, "login")
+
+	err = h.users.SetRole(r.Context(), login, change.Role)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeProblem(w, r, http.StatusNotFound, err)
+		} else {
+			writeProblem(w, r, http.StatusInternalServerError, err)
+		}
+		logging.FromContext(r.Context()).Error(err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
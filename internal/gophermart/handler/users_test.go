@@ -4,15 +4,18 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	"github.com/golang/mock/gomock"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/handler"
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
 )
 
 func (suite *HandlerSuite) TestGetBalance() {
 	suite.Run("success", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.users.EXPECT().GetBalance(gomock.Any(), suite.userID).Return(
 			domain.UserBalance{}, nil,
 		)
@@ -30,7 +33,7 @@ func (suite *HandlerSuite) TestGetBalance() {
 	})
 
 	suite.Run("unauthorized", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(domain.ErrNotFound).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(domain.ErrNotFound).Times(1)
 
 		rec := httptest.NewRecorder()
 		req := httptest.NewRequest(http.MethodGet, "/api/user/balance", http.NoBody)
@@ -53,7 +56,7 @@ func (suite *HandlerSuite) TestGetBalance() {
 	})
 
 	suite.Run("not found", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.users.EXPECT().GetBalance(gomock.Any(), suite.userID).Return(
 			domain.UserBalance{}, domain.ErrNotFound,
 		)
@@ -70,7 +73,7 @@ func (suite *HandlerSuite) TestGetBalance() {
 	})
 
 	suite.Run("internal server error", func() {
-		suite.auth.EXPECT().Identify(gomock.Any(), suite.userID).Return(nil).Times(1)
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
 		suite.users.EXPECT().GetBalance(gomock.Any(), suite.userID).Return(
 			domain.UserBalance{}, errors.New("error"),
 		)
@@ -86,3 +89,146 @@ func (suite *HandlerSuite) TestGetBalance() {
 		}
 	})
 }
+
+func (suite *HandlerSuite) TestSetUserRole() {
+	admin := suite.identity
+	admin.Role = domain.RoleAdmin
+
+	suite.signer.identity = admin
+	defer func() { suite.signer.identity = suite.identity }()
+
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), admin).Return(nil).Times(1)
+		suite.users.EXPECT().SetRole(gomock.Any(), "login", domain.RoleAdmin).Return(nil).Times(1)
+
+		body := `{"role":"admin"}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/users/login/role", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusNoContent, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("not found", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), admin).Return(nil).Times(1)
+		suite.users.EXPECT().SetRole(gomock.Any(), "login", domain.RoleUser).Return(domain.ErrNotFound).Times(1)
+
+		body := `{"role":"user"}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/users/login/role", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusNotFound, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("unsupported role", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), admin).Return(nil).Times(1)
+
+		body := `{"role":"root"}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/users/login/role", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusBadRequest, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("route body limit", func() {
+		h := handler.New(handler.HandlerOptions{
+			Auth:   suite.auth,
+			Users:  suite.users,
+			Signer: suite.signer,
+			BodyLimits: handler.BodyLimits{
+				Routes: httputil.RouteSizes{"PUT /api/admin/users/{login}/role": 8},
+			},
+		})
+
+		suite.auth.EXPECT().Identify(gomock.Any(), admin).Return(nil).Times(1)
+
+		body := `{"role":"admin"}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/users/login/role", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		h.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusRequestEntityTooLarge, rec.Code) {
+			suite.Equal("body_too_large", suite.problemCode(rec))
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("forbidden", func() {
+		suite.signer.identity = suite.identity
+		defer func() { suite.signer.identity = admin }()
+
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+
+		body := `{"role":"admin"}`
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/users/login/role", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusForbidden, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
+
+func (suite *HandlerSuite) TestExportUser() {
+	suite.Run("success", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.users.EXPECT().Export(gomock.Any(), suite.userID).Return(domain.UserExport{
+			Profile:    domain.UserProfile{ID: suite.userID, Login: "login"},
+			Orders:     []domain.Order{},
+			Operations: []domain.Operation{},
+		}, nil).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/export", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusOK, rec.Code) {
+			suite.Contains(rec.Header().Get("Content-Disposition"), "attachment")
+			suite.Contains(rec.Body.String(), `"login":"login"`)
+			suite.ctrl.Finish()
+		}
+	})
+
+	suite.Run("internal server error", func() {
+		suite.auth.EXPECT().Identify(gomock.Any(), suite.identity).Return(nil).Times(1)
+		suite.users.EXPECT().Export(gomock.Any(), suite.userID).Return(
+			domain.UserExport{}, errors.New("error"),
+		).Times(1)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/user/export", http.NoBody)
+		req.Header.Set("Authorization", "Bearer token")
+
+		suite.handler.ServeHTTP(rec, req)
+
+		if suite.Equal(http.StatusInternalServerError, rec.Code) {
+			suite.ctrl.Finish()
+		}
+	})
+}
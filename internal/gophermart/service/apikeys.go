@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+)
+
+var _ domain.APIKeyService = (*APIKeys)(nil)
+
+// Префикс ключа API, позволяющий отличить его от других секретов.
+const apiKeyPrefix = "gmk_"
+
+// APIKeys определяет сервис ключей API.
+type APIKeys struct {
+	db *sql.DB
+}
+
+// NewAPIKeys возвращает новый экземпляр APIKeys.
+func NewAPIKeys(db *sql.DB) *APIKeys {
+	return &APIKeys{db: db}
+}
+
+// Identify реализует интерфейс domain.APIKeyService.
+func (k *APIKeys) Identify(ctx context.Context, key string) (domain.Identity, error) {
+	identity, err := useAPIKey(ctx, k.db, key)
+	if err != nil {
+		return domain.Identity{}, fmt.Errorf("API key search: %w", err)
+	}
+	return identity, nil
+}
+
+// Create реализует интерфейс domain.APIKeyService.
+func (k *APIKeys) Create(
+	ctx context.Context,
+	id domain.UserID,
+	req domain.APIKeyRequest,
+) (domain.APIKey, error) {
+	query := `INSERT INTO api_keys (user_id, name, key_hash, scopes)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, user_id, created_at;`
+
+	return k.create(ctx, query, id, req)
+}
+
+// CreateForLogin реализует интерфейс domain.APIKeyService.
+func (k *APIKeys) CreateForLogin(
+	ctx context.Context,
+	login string,
+	req domain.APIKeyRequest,
+) (domain.APIKey, error) {
+	query := `INSERT INTO api_keys (user_id, name, key_hash, scopes)
+	SELECT id, $2, $3, $4 FROM users WHERE lower(login) = lower($1) AND deleted_at IS NULL
+	RETURNING id, user_id, created_at;`
+
+	return k.create(ctx, query, login, req)
+}
+
+// create создаёт ключ API; владелец определяется запросом query по owner.
+func (k *APIKeys) create(
+	ctx context.Context,
+	query string,
+	owner any,
+	req domain.APIKeyRequest,
+) (domain.APIKey, error) {
+	secret, err := newSecretToken()
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("generating an API key: %w", err)
+	}
+
+	key := domain.APIKey{
+		Name:   req.Name,
+		Scopes: req.Scopes,
+		Key:    apiKeyPrefix + secret,
+	}
+
+	err = k.db.QueryRowContext(
+		ctx,
+		query,
+		owner,
+		key.Name,
+		hashSecretToken(key.Key),
+		pq.Array(scopesToStrings(key.Scopes)),
+	).Scan(&key.ID, &key.UserID, &key.CreatedAt)
+	if err != nil {
+		return domain.APIKey{}, fmt.Errorf("creating an API key: %w", errorHandling(err))
+	}
+
+	return key, nil
+}
+
+// List реализует интерфейс domain.APIKeyService.
+func (k *APIKeys) List(ctx context.Context, id domain.UserID) ([]domain.APIKey, error) {
+	keys, err := getAPIKeys(ctx, k.db, id)
+	if err != nil {
+		return nil, fmt.Errorf("API keys search: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke реализует интерфейс domain.APIKeyService.
+func (k *APIKeys) Revoke(ctx context.Context, id domain.UserID, keyID domain.APIKeyID) error {
+	query := `UPDATE api_keys
+	SET revoked_at = now()
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;`
+
+	res, err := k.db.ExecContext(ctx, query, keyID, id)
+	if err != nil {
+		return fmt.Errorf("revoking an API key: %w", errorHandling(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("revoking an API key: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
+
+func getAPIKeys(ctx context.Context, db *sql.DB, id domain.UserID) ([]domain.APIKey, error) {
+	query := `SELECT id, name, scopes, created_at, last_used_at
+	FROM api_keys
+	WHERE user_id = $1 AND revoked_at IS NULL
+	ORDER BY created_at;`
+
+	rows, err := db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, errorHandling(err)
+	}
+	defer rows.Close()
+
+	var keys []domain.APIKey
+
+	for rows.Next() {
+		var (
+			key        = domain.APIKey{UserID: id}
+			scopes     []string
+			lastUsedAt sql.NullTime
+		)
+
+		err = rows.Scan(&key.ID, &key.Name, pq.Array(&scopes), &key.CreatedAt, &lastUsedAt)
+		if err != nil {
+			return nil, fmt.Errorf("copying API key fields: %w", errorHandling(err))
+		}
+
+		key.Scopes = stringsToScopes(scopes)
+		if lastUsedAt.Valid {
+			key.LastUsedAt = &lastUsedAt.Time
+		}
+
+		keys = append(keys, key)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errorHandling(err)
+	}
+
+	if len(keys) == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	return keys, nil
+}
+
+// Минимальный интервал между обновлениями времени последнего использования
+// ключа API; снижает количество записей при частых запросах.
+const apiKeyUsageResolution = time.Minute
+
+func useAPIKey(ctx context.Context, db *sql.DB, key string) (domain.Identity, error) {
+	var (
+		identity domain.Identity
+		scopes   []string
+	)
+
+	query := `WITH k AS (
+		SELECT id, user_id, scopes, last_used_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	), used AS (
+		UPDATE api_keys SET last_used_at = now()
+		FROM k
+		WHERE api_keys.id = k.id
+			AND (k.last_used_at IS NULL OR k.last_used_at < now() - make_interval(secs => $2))
+	)
+	SELECT id, user_id, scopes FROM k;`
+
+	err := db.QueryRowContext(
+		ctx,
+		query,
+		hashSecretToken(key),
+		apiKeyUsageResolution.Seconds(),
+	).Scan(
+		&identity.APIKeyID,
+		&identity.UserID,
+		pq.Array(&scopes),
+	)
+	if err != nil {
+		return domain.Identity{}, errorHandling(err)
+	}
+
+	identity.Scopes = stringsToScopes(scopes)
+
+	return identity, nil
+}
+
+func scopesToStrings(scopes []domain.Scope) []string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+	return s
+}
+
+func stringsToScopes(s []string) []domain.Scope {
+	scopes := make([]domain.Scope, len(s))
+	for i, scope := range s {
+		scopes[i] = domain.Scope(scope)
+	}
+	return scopes
+}
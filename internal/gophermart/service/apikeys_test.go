@@ -0,0 +1,130 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+)
+
+type APIKeySuite struct {
+	CommonSuite
+
+	apiKeys *service.APIKeys
+	userID  domain.UserID
+	key     domain.APIKey
+}
+
+func TestAPIKeys(t *testing.T) {
+	suite.Run(t, new(APIKeySuite))
+}
+
+func (suite *APIKeySuite) SetupSuite() {
+	suite.CommonSuite.SetupSuite()
+	suite.apiKeys = service.NewAPIKeys(suite.CommonSuite.db)
+
+	auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{})
+
+	var err error
+	suite.userID, err = auth.SignUp(
+		context.Background(),
+		domain.Authentication{
+			Login:    "pos",
+			Password: "password",
+		},
+	)
+
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(suite.userID)
+}
+
+func (suite *APIKeySuite) TestA_Create() {
+	ctx := context.Background()
+
+	req := domain.APIKeyRequest{
+		Name:   "pos",
+		Scopes: []domain.Scope{domain.ScopeOrdersWrite},
+	}
+
+	suite.Run("success", func() {
+		var err error
+		suite.key, err = suite.apiKeys.Create(ctx, suite.userID, req)
+		if suite.NoError(err) {
+			suite.NotEmpty(suite.key.ID)
+			suite.True(strings.HasPrefix(suite.key.Key, "gmk_"))
+		}
+	})
+
+	suite.Run("for login", func() {
+		key, err := suite.apiKeys.CreateForLogin(ctx, "POS", req)
+		if suite.NoError(err) {
+			suite.Equal(suite.userID, key.UserID)
+		}
+	})
+
+	suite.Run("login not found", func() {
+		_, err := suite.apiKeys.CreateForLogin(ctx, "unknown", req)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("deleted account", func() {
+		auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{})
+
+		userID, err := auth.SignUp(ctx, domain.Authentication{Login: "deleted_pos", Password: "password"})
+		suite.Require().NoError(err)
+		suite.Require().NoError(auth.DeleteAccount(ctx, userID, domain.AccountDeletion{Password: "password"}))
+
+		_, err = suite.apiKeys.CreateForLogin(ctx, "deleted-"+userID.String(), req)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
+
+func (suite *APIKeySuite) TestB_Identify() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		identity, err := suite.apiKeys.Identify(ctx, suite.key.Key)
+		if suite.NoError(err) {
+			suite.Equal(suite.userID, identity.UserID)
+			suite.Equal(suite.key.ID, identity.APIKeyID)
+			suite.True(identity.HasScope(domain.ScopeOrdersWrite))
+			suite.False(identity.HasScope(domain.ScopeBalanceRead))
+		}
+	})
+
+	suite.Run("last used", func() {
+		keys, err := suite.apiKeys.List(ctx, suite.userID)
+		if suite.NoError(err) && suite.Len(keys, 2) {
+			suite.NotNil(keys[0].LastUsedAt)
+			suite.Empty(keys[0].Key)
+		}
+	})
+
+	suite.Run("not found", func() {
+		_, err := suite.apiKeys.Identify(ctx, "gmk_unknown")
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
+
+func (suite *APIKeySuite) TestC_Revoke() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		err := suite.apiKeys.Revoke(ctx, suite.userID, suite.key.ID)
+		suite.NoError(err)
+	})
+
+	suite.Run("identify revoked", func() {
+		_, err := suite.apiKeys.Identify(ctx, suite.key.Key)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("already revoked", func() {
+		err := suite.apiKeys.Revoke(ctx, suite.userID, suite.key.ID)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
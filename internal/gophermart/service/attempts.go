@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+)
+
+// LoginThrottling определяет политику защиты входа от перебора паролей.
+//
+// Неудачные попытки учитываются отдельно по логину и по IP-адресу клиента.
+// После FreeAttempts неудачных попыток каждая следующая откладывает
+// возможность входа на BaseDelay, удваивающийся с каждой попыткой, но не более
+// MaxDelay; по достижении порога вход блокируется на LockoutDuration.
+type LoginThrottling struct {
+	// Количество неудачных попыток без задержки.
+	FreeAttempts int
+
+	// Задержка после первой неудачной попытки сверх FreeAttempts.
+	BaseDelay time.Duration
+
+	// Максимальная задержка между попытками.
+	MaxDelay time.Duration
+
+	// Количество неудачных попыток по логину до блокировки.
+	LoginLockoutThreshold int
+
+	// Количество неудачных попыток с IP-адреса до блокировки.
+	IPLockoutThreshold int
+
+	// Время блокировки входа.
+	LockoutDuration time.Duration
+
+	// Время, по истечении которого с последней неудачной попытки счётчик
+	// сбрасывается.
+	Window time.Duration
+}
+
+// enabled возвращает true, если защита от перебора включена.
+func (p LoginThrottling) enabled() bool {
+	return p.LoginLockoutThreshold > 0 || p.IPLockoutThreshold > 0
+}
+
+// blockFor возвращает время, на которое блокируется вход после failures
+// неудачных попыток при пороге блокировки threshold.
+func (p LoginThrottling) blockFor(failures, threshold int) time.Duration {
+	if threshold > 0 && failures >= threshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	exp := float64(failures - p.FreeAttempts - 1)
+	delay := time.Duration(float64(p.BaseDelay) * math.Pow(2, exp))
+
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+// attemptKey определяет ключ учёта неудачных попыток входа.
+type attemptKey struct {
+	value     string
+	threshold int
+}
+
+// attemptKeys возвращает ключи учёта неудачных попыток входа для логина
+// и IP-адреса клиента.
+func (p LoginThrottling) attemptKeys(login, ip string) []attemptKey {
+	keys := []attemptKey{{
+		value:     loginAttemptKey(login),
+		threshold: p.LoginLockoutThreshold,
+	}}
+	if ip != "" {
+		keys = append(keys, attemptKey{
+			value:     "ip:" + ip,
+			threshold: p.IPLockoutThreshold,
+		})
+	}
+	return keys
+}
+
+// loginAttemptKey возвращает ключ учёта неудачных попыток входа для логина.
+func loginAttemptKey(login string) string {
+	return "login:" + strings.ToLower(login)
+}
+
+// checkLoginAttempts возвращает *domain.ResourceExhaustedError, если вход
+// по одному из ключей заблокирован.
+func checkLoginAttempts(ctx context.Context, db *sql.DB, keys []attemptKey) error {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		values[i] = key.value
+	}
+
+	query := `SELECT COALESCE(MAX(EXTRACT(EPOCH FROM blocked_until - now())), 0)
+	FROM login_attempts
+	WHERE key = ANY($1) AND blocked_until > now();`
+
+	var seconds float64
+
+	err := db.QueryRowContext(ctx, query, pq.Array(values)).Scan(&seconds)
+	if err != nil {
+		return fmt.Errorf("login attempts search: %w", errorHandling(err))
+	}
+
+	if seconds > 0 {
+		return &domain.ResourceExhaustedError{
+			Message:    "too many failed login attempts",
+			RetryAfter: time.Duration(math.Ceil(seconds)) * time.Second,
+		}
+	}
+
+	return nil
+}
+
+// registerLoginFailure учитывает неудачную попытку входа по всем ключам
+// и блокирует вход согласно политике.
+func registerLoginFailure(
+	ctx context.Context,
+	db *sql.DB,
+	policy LoginThrottling,
+	keys []attemptKey,
+) error {
+	query1 := `INSERT INTO login_attempts (key, failures, last_failure_at)
+	VALUES ($1, 1, now())
+	ON CONFLICT (key) DO UPDATE SET
+		failures = CASE
+			WHEN login_attempts.last_failure_at < now() - make_interval(secs => $2)
+			THEN 1
+			ELSE login_attempts.failures + 1
+		END,
+		last_failure_at = now()
+	RETURNING failures;`
+
+	query2 := `UPDATE login_attempts
+	SET blocked_until = now() + make_interval(secs => $1)
+	WHERE key = $2;`
+
+	return transaction(ctx, db, func(tx *sql.Tx) error {
+		for _, key := range keys {
+			var failures int
+
+			err := tx.QueryRowContext(ctx, query1, key.value, policy.Window.Seconds()).
+				Scan(&failures)
+			if err != nil {
+				return fmt.Errorf("registering a login failure: %w", errorHandling(err))
+			}
+
+			blockFor := policy.blockFor(failures, key.threshold)
+			if blockFor <= 0 {
+				continue
+			}
+
+			_, err = tx.ExecContext(ctx, query2, blockFor.Seconds(), key.value)
+			if err != nil {
+				return fmt.Errorf("blocking a login: %w", errorHandling(err))
+			}
+		}
+		return nil
+	})
+}
+
+// resetLoginAttempts сбрасывает счётчик неудачных попыток входа по ключу.
+func resetLoginAttempts(ctx context.Context, db *sql.DB, key attemptKey) error {
+	query := "DELETE FROM login_attempts WHERE key = $1;"
+
+	_, err := db.ExecContext(ctx, query, key.value)
+	if err != nil {
+		return fmt.Errorf("resetting login attempts: %w", errorHandling(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+)
+
+var _ domain.AuditService = (*Audit)(nil)
+
+// Audit определяет журнал событий безопасности.
+type Audit struct {
+	db *sql.DB
+}
+
+// NewAudit возвращает новый экземпляр Audit.
+func NewAudit(db *sql.DB) *Audit {
+	return &Audit{db: db}
+}
+
+// Record реализует интерфейс domain.AuditService.
+func (a *Audit) Record(ctx context.Context, event domain.SecurityEvent) error {
+	err := recordSecurityEvent(ctx, a.db, event)
+	if err != nil {
+		return fmt.Errorf("recording a security event: %w", err)
+	}
+	return nil
+}
+
+// List реализует интерфейс domain.AuditService.
+func (a *Audit) List(
+	ctx context.Context,
+	filter domain.SecurityEventFilter,
+) ([]domain.SecurityEvent, error) {
+	events, err := getSecurityEvents(ctx, a.db, filter)
+	if err != nil {
+		return nil, fmt.Errorf("security events search: %w", err)
+	}
+	return events, nil
+}
+
+// execer описывает общий интерфейс *sql.DB и *sql.Tx для выполнения
+// запросов без результата.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// recordSecurityEvent добавляет событие в журнал; сведения о клиенте, если
+// не указаны, берутся из контекста. Внутри транзакции событие фиксируется
+// только вместе с изменением, которое оно описывает.
+func recordSecurityEvent(ctx context.Context, db execer, event domain.SecurityEvent) error {
+	client := domain.ClientFromContext(ctx)
+	if event.IP == "" {
+		event.IP = client.IP
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = client.UserAgent
+	}
+
+	query := `INSERT INTO security_events (type, user_id, login, ip, user_agent, details)
+	VALUES ($1, $2, $3, $4, $5, $6);`
+
+	_, err := db.ExecContext(ctx, query,
+		event.Type,
+		nullUserID(event.UserID),
+		nullString(event.Login),
+		nullString(event.IP),
+		nullString(event.UserAgent),
+		nullString(event.Details),
+	)
+	if err != nil {
+		return fmt.Errorf("inserting a security event: %w", errorHandling(err))
+	}
+
+	return nil
+}
+
+func getSecurityEvents(
+	ctx context.Context,
+	db *sql.DB,
+	filter domain.SecurityEventFilter,
+) ([]domain.SecurityEvent, error) {
+	var (
+		conds []string
+		args  []any
+	)
+
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.UserID != domain.EmptyUserID {
+		where("user_id = ?", filter.UserID)
+	}
+	if filter.Login != "" {
+		where("lower(login) = lower(?)", filter.Login)
+	}
+	if len(filter.Types) > 0 {
+		types := make([]string, len(filter.Types))
+		for i, t := range filter.Types {
+			types[i] = string(t)
+		}
+		where("type = ANY(?)", pq.Array(types))
+	}
+	if filter.IP != "" {
+		where("ip = ?", filter.IP)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at < ?", filter.To)
+	}
+	if filter.Before > 0 {
+		where("id < ?", filter.Before)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = domain.DefaultSecurityEventsLimit
+	}
+
+	query := `SELECT
+		id, type, user_id, coalesce(login, ''), coalesce(ip, ''),
+		coalesce(user_agent, ''), coalesce(details, ''), created_at
+	FROM security_events`
+	if len(conds) > 0 {
+		query += "\n\tWHERE " + strings.Join(conds, " AND ")
+	}
+	query += "\n\tORDER BY id DESC\n\tLIMIT " + strconv.Itoa(limit) + ";"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("security events search: %w", errorHandling(err))
+	}
+	defer rows.Close()
+
+	var events []domain.SecurityEvent
+
+	for rows.Next() {
+		var (
+			event  domain.SecurityEvent
+			userID uuid.NullUUID
+		)
+
+		err = rows.Scan(
+			&event.ID,
+			&event.Type,
+			&userID,
+			&event.Login,
+			&event.IP,
+			&event.UserAgent,
+			&event.Details,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("copying security event fields: %w", errorHandling(err))
+		}
+
+		event.UserID = userID.UUID
+		events = append(events, event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errorHandling(err)
+	}
+
+	if len(events) == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	return events, nil
+}
+
+// nullUserID возвращает NULL для пустого идентификатора пользователя.
+func nullUserID(id domain.UserID) any {
+	if id == domain.EmptyUserID {
+		return nil
+	}
+	return id
+}
+
+// nullString возвращает NULL для пустой строки.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
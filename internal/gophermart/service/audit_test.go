@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+)
+
+type AuditSuite struct {
+	CommonSuite
+
+	audit  *service.Audit
+	auth   *service.Auth
+	userID domain.UserID
+}
+
+func TestAudit(t *testing.T) {
+	suite.Run(t, new(AuditSuite))
+}
+
+func (suite *AuditSuite) SetupSuite() {
+	suite.CommonSuite.SetupSuite()
+	suite.audit = service.NewAudit(suite.CommonSuite.db)
+	suite.auth = service.NewAuth(suite.CommonSuite.db, service.AuthOptions{})
+
+	var err error
+	suite.userID, err = suite.auth.SignUp(
+		context.Background(),
+		domain.Authentication{Login: "login", Password: "password"},
+	)
+	suite.Require().NoError(err)
+}
+
+func (suite *AuditSuite) TestA_Record() {
+	ctx := domain.WithClient(context.Background(), domain.Client{
+		IP:        "10.0.0.1",
+		UserAgent: "test",
+	})
+
+	suite.Run("success", func() {
+		err := suite.audit.Record(ctx, domain.SecurityEvent{
+			Type:   domain.EventLoginSucceeded,
+			UserID: suite.userID,
+		})
+		suite.NoError(err)
+	})
+
+	suite.Run("unknown user", func() {
+		err := suite.audit.Record(ctx, domain.SecurityEvent{
+			Type:  domain.EventLoginFailed,
+			Login: "unknown",
+		})
+		suite.NoError(err)
+	})
+
+	suite.Run("password change", func() {
+		err := suite.auth.ChangePassword(ctx, suite.userID, domain.PasswordChange{
+			CurrentPassword: "password",
+			NewPassword:     "new_password",
+		})
+		suite.NoError(err)
+	})
+}
+
+func (suite *AuditSuite) TestB_List() {
+	ctx := context.Background()
+
+	suite.Run("by user", func() {
+		events, err := suite.audit.List(ctx, domain.SecurityEventFilter{UserID: suite.userID})
+		if suite.NoError(err) && suite.Len(events, 2) {
+			suite.Equal(domain.EventPasswordChanged, events[0].Type)
+			suite.Equal(domain.EventLoginSucceeded, events[1].Type)
+			suite.Equal("10.0.0.1", events[1].IP)
+			suite.Equal("test", events[1].UserAgent)
+		}
+	})
+
+	suite.Run("by login and type", func() {
+		events, err := suite.audit.List(ctx, domain.SecurityEventFilter{
+			Login: "UNKNOWN",
+			Types: []domain.SecurityEventType{domain.EventLoginFailed},
+		})
+		if suite.NoError(err) && suite.Len(events, 1) {
+			suite.Equal(domain.EmptyUserID, events[0].UserID)
+		}
+	})
+
+	suite.Run("limit and before", func() {
+		events, err := suite.audit.List(ctx, domain.SecurityEventFilter{Limit: 1})
+		suite.Require().NoError(err)
+		suite.Require().Len(events, 1)
+
+		events, err = suite.audit.List(ctx, domain.SecurityEventFilter{Before: events[0].ID})
+		if suite.NoError(err) {
+			suite.Len(events, 2)
+		}
+	})
+
+	suite.Run("not found", func() {
+		_, err := suite.audit.List(ctx, domain.SecurityEventFilter{IP: "10.0.0.2"})
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
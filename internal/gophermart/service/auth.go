@@ -3,54 +3,199 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
+
+	"log/slog"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/passwords"
 )
 
 var _ domain.AuthService = (*Auth)(nil)
 
+// Время жизни сеанса пользователя по умолчанию.
+const (
+	defaultSessionTTL         = 30 * 24 * time.Hour
+	defaultSessionMaxLifetime = 90 * 24 * time.Hour
+)
+
+// AuthOptions определяет опции для сервиса Auth.
+type AuthOptions struct {
+	// Время жизни сеанса пользователя, по истечении которого токен
+	// обновления становится недействительным.
+	//
+	// По умолчанию 720h.
+	SessionTTL time.Duration
+
+	// Абсолютное время жизни сеанса с момента входа, которое не продлевается
+	// обновлением токена; не может быть меньше SessionTTL.
+	//
+	// По умолчанию 2160h.
+	SessionMaxLifetime time.Duration
+
+	// Политика защиты входа от перебора паролей. Если пороги блокировки
+	// не заданы, то защита отключена.
+	Throttling LoginThrottling
+
+	// Хеширование паролей.
+	//
+	// По умолчанию argon2id без серверного секрета.
+	Passwords domain.PasswordHasher
+
+	// Кеш подтверждённых сеансов, избавляющий Identify от запроса к базе
+	// данных на каждый запрос пользователя.
+	//
+	// По умолчанию кеш отключён.
+	Identities *IdentityCache
+}
+
 // Auth определяет сервис регистрации и аутентификации пользователя.
 type Auth struct {
-	db *sql.DB
+	db                 *sql.DB
+	sessionTTL         time.Duration
+	sessionMaxLifetime time.Duration
+	throttling         LoginThrottling
+	passwords          domain.PasswordHasher
+	identities         *IdentityCache
 }
 
 // NewAuth возвращает новый экземпляр Auth.
-func NewAuth(db *sql.DB) *Auth {
-	return &Auth{db: db}
+func NewAuth(db *sql.DB, opts AuthOptions) *Auth {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = defaultSessionTTL
+	}
+	if opts.SessionMaxLifetime <= 0 {
+		opts.SessionMaxLifetime = defaultSessionMaxLifetime
+	}
+	if opts.SessionMaxLifetime < opts.SessionTTL {
+		opts.SessionMaxLifetime = opts.SessionTTL
+	}
+	if opts.Passwords == nil {
+		opts.Passwords = passwords.New(passwords.Argon2id{})
+	}
+	return &Auth{
+		db:                 db,
+		sessionTTL:         opts.SessionTTL,
+		sessionMaxLifetime: opts.SessionMaxLifetime,
+		throttling:         opts.Throttling,
+		passwords:          opts.Passwords,
+		identities:         opts.Identities,
+	}
 }
 
 // Identify реализует интерфейс domain.AuthService.
-func (a *Auth) Identify(ctx context.Context, id domain.UserID) error {
-	u, err := getUserByID(ctx, a.db, id)
-	if err != nil {
-		return fmt.Errorf("user search: %w", err)
+//
+// Подтверждённый сеанс кешируется, если кеш включён, но не дольше срока
+// действия сеанса; отзыв сеанса, смена пароля или роли и удаление учётной
+// записи удаляют его из кеша. Результат чтения из БД, начатого до
+// инвалидации, в кеш не сохраняется.
+func (a *Auth) Identify(ctx context.Context, identity domain.Identity) error {
+	active, ok := a.identities.get(identity.SessionID)
+	if !ok {
+		generation := a.identities.snapshot()
+
+		var (
+			expiresAt time.Time
+			err       error
+		)
+		active, expiresAt, err = getActiveSessionIdentity(ctx, a.db, identity.SessionID)
+		if err != nil {
+			return fmt.Errorf("session search: %w", err)
+		}
+		a.identities.set(active, expiresAt, generation)
+	}
+
+	if active.UserID != identity.UserID {
+		return fmt.Errorf("%w: user ID not identified: %q", domain.ErrNotFound, identity.UserID)
 	}
 
-	if u.ID != id {
-		return fmt.Errorf("user ID not identified: %q", id)
+	// Токен, выпущенный до смены роли, должен быть обновлён.
+	if active.Role != identity.Role {
+		return fmt.Errorf("%w: role changed: %q", domain.ErrNotFound, identity.Role)
 	}
 
 	return nil
 }
 
 // SignIn реализует интерфейс domain.AuthService.
+//
+// Если защита от перебора включена, то возвращает
+// *domain.ResourceExhaustedError, пока вход по логину или с IP-адреса клиента
+// заблокирован.
 func (a *Auth) SignIn(ctx context.Context, auth domain.Authentication) (domain.UserID, error) {
+	if !a.throttling.enabled() {
+		return a.signIn(ctx, auth)
+	}
+
+	client := domain.ClientFromContext(ctx)
+	keys := a.throttling.attemptKeys(auth.Login, client.IP)
+
+	err := checkLoginAttempts(ctx, a.db, keys)
+	if err != nil {
+		return domain.EmptyUserID, fmt.Errorf("checking login attempts: %w", err)
+	}
+
+	userID, err := a.signIn(ctx, auth)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			rErr := registerLoginFailure(ctx, a.db, a.throttling, keys)
+			if rErr != nil {
+				return domain.EmptyUserID, fmt.Errorf("registering a login failure: %w", rErr)
+			}
+		}
+		return domain.EmptyUserID, err
+	}
+
+	// Успешный вход сбрасывает только счётчик по логину, чтобы владелец
+	// одной учётной записи не мог снять блокировку со своего IP-адреса.
+	err = resetLoginAttempts(ctx, a.db, keys[0])
+	if err != nil {
+		return domain.EmptyUserID, fmt.Errorf("resetting login attempts: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (a *Auth) signIn(ctx context.Context, auth domain.Authentication) (domain.UserID, error) {
 	user, err := getUser(ctx, a.db, auth)
 	if err != nil {
 		return domain.EmptyUserID, fmt.Errorf("user search: %w", err)
 	}
 
-	if user.Login != auth.Login || !user.ComparePassword(auth.Password) {
+	// Логин сравнивается без учёта регистра только в getUser через lower(),
+	// как и в уникальном индексе, чтобы правила сравнения не расходились.
+	ok, rehash := user.VerifyPassword(a.passwords, auth.Password)
+	if !ok {
 		return domain.EmptyUserID, domain.ErrNotFound
 	}
 
+	// Пароль известен только в момент входа, поэтому устаревшая хеш-сумма
+	// пересчитывается сейчас; ошибка пересчёта не мешает входу.
+	if rehash {
+		err = a.rehashPassword(ctx, user, auth.Password)
+		if err != nil {
+			logging.FromContext(ctx).Warn(err.Error(), slog.String("scope", "rehashing password"))
+		}
+	}
+
 	return user.ID, nil
 }
 
+func (a *Auth) rehashPassword(ctx context.Context, user domain.User, password string) error {
+	oldHash := user.HashedPassword
+	err := user.SetPassword(a.passwords, password)
+	if err != nil {
+		return err
+	}
+	return updatePasswordHash(ctx, a.db, user, oldHash)
+}
+
 // SignUp реализует интерфейс domain.AuthService.
 func (a *Auth) SignUp(ctx context.Context, auth domain.Authentication) (domain.UserID, error) {
-	user, err := domain.NewUser(auth)
+	user, err := domain.NewUser(auth, a.passwords)
 	if err != nil {
 		return domain.EmptyUserID, fmt.Errorf("converting to user: %w", err)
 	}
@@ -63,6 +208,91 @@ func (a *Auth) SignUp(ctx context.Context, auth domain.Authentication) (domain.U
 	return uid, nil
 }
 
+// ChangePassword реализует интерфейс domain.AuthService.
+func (a *Auth) ChangePassword(
+	ctx context.Context,
+	id domain.UserID,
+	change domain.PasswordChange,
+) error {
+	err := changePassword(ctx, a.db, a.passwords, id, change)
+	if err != nil {
+		return fmt.Errorf("changing the password: %w", err)
+	}
+	a.identities.InvalidateUser(id)
+	return nil
+}
+
+// DeleteAccount реализует интерфейс domain.AuthService.
+func (a *Auth) DeleteAccount(
+	ctx context.Context,
+	id domain.UserID,
+	deletion domain.AccountDeletion,
+) error {
+	err := deleteAccount(ctx, a.db, a.passwords, id, deletion.Password)
+	if err != nil {
+		return fmt.Errorf("deleting the account: %w", err)
+	}
+	a.identities.InvalidateUser(id)
+	return nil
+}
+
+// CreateSession реализует интерфейс domain.AuthService.
+func (a *Auth) CreateSession(ctx context.Context, id domain.UserID) (domain.Session, error) {
+	refreshToken, err := newSecretToken()
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("generating a refresh token: %w", err)
+	}
+
+	session := domain.Session{
+		UserID:       id,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(a.sessionTTL).UTC(),
+	}
+
+	session, err = createSession(ctx, a.db, session)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("creating a new session: %w", err)
+	}
+
+	return session, nil
+}
+
+// RefreshSession реализует интерфейс domain.AuthService.
+//
+// Обновление продлевает сеанс на SessionTTL, но не дальше абсолютного
+// времени жизни сеанса, отсчитываемого от входа.
+func (a *Auth) RefreshSession(
+	ctx context.Context,
+	refreshToken string,
+) (domain.Session, error) {
+	newToken, err := newSecretToken()
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("generating a refresh token: %w", err)
+	}
+
+	session := domain.Session{
+		RefreshToken: newToken,
+		ExpiresAt:    time.Now().Add(a.sessionTTL).UTC(),
+	}
+
+	session, err = rotateSession(ctx, a.db, refreshToken, session, a.sessionMaxLifetime)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("session rotation: %w", err)
+	}
+
+	return session, nil
+}
+
+// RevokeSession реализует интерфейс domain.AuthService.
+func (a *Auth) RevokeSession(ctx context.Context, identity domain.Identity) error {
+	err := revokeSession(ctx, a.db, identity)
+	if err != nil {
+		return fmt.Errorf("session revocation: %w", err)
+	}
+	a.identities.InvalidateSession(identity.SessionID)
+	return nil
+}
+
 func createUser(
 	ctx context.Context,
 	db *sql.DB,
@@ -80,6 +310,149 @@ func createUser(
 	return userID, nil
 }
 
+func deleteAccount(
+	ctx context.Context,
+	db *sql.DB,
+	hasher domain.PasswordHasher,
+	id domain.UserID,
+	password string,
+) error {
+	query1 := `SELECT login, hashed_password
+	FROM users
+	WHERE id = $1 AND deleted_at IS NULL
+	FOR UPDATE;`
+	query2 := `UPDATE users
+	SET login = 'deleted-' || id::text, hashed_password = '', role = 'user', deleted_at = now()
+	WHERE id = $1;`
+	queries := []string{
+		"DELETE FROM sessions WHERE user_id = $1;",
+		"DELETE FROM api_keys WHERE user_id = $1;",
+		"DELETE FROM login_challenges WHERE user_id = $1;",
+		"DELETE FROM recovery_codes WHERE user_id = $1;",
+		"DELETE FROM user_totp WHERE user_id = $1;",
+	}
+	query3 := "DELETE FROM login_attempts WHERE key = $1;"
+	query4 := `UPDATE security_events
+	SET login = NULL, ip = NULL, user_agent = NULL
+	WHERE (user_id = $1 OR lower(login) = lower($2))
+		AND (login IS NOT NULL OR ip IS NOT NULL OR user_agent IS NOT NULL);`
+
+	// Запускаем транзакцию, чтобы обезличивание и удаление всех средств
+	// входа выполнились атомарно.
+	return transaction(ctx, db, func(tx *sql.Tx) error {
+		user := domain.User{ID: id}
+
+		err := tx.QueryRowContext(ctx, query1, id).Scan(&user.Login, &user.HashedPassword)
+		if err != nil {
+			return fmt.Errorf("user search: %w", errorHandling(err))
+		}
+
+		ok, _ := user.VerifyPassword(hasher, password)
+		if !ok {
+			return domain.ErrInvalidPassword
+		}
+
+		_, err = tx.ExecContext(ctx, query2, id)
+		if err != nil {
+			return fmt.Errorf("anonymizing the user: %w", errorHandling(err))
+		}
+
+		for _, query := range queries {
+			_, err = tx.ExecContext(ctx, query, id)
+			if err != nil {
+				return fmt.Errorf("deleting credentials: %w", errorHandling(err))
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, query3, loginAttemptKey(user.Login))
+		if err != nil {
+			return fmt.Errorf("deleting login attempts: %w", errorHandling(err))
+		}
+
+		// Журнал безопасности сохраняет события удалённой учётной записи,
+		// но без персональных данных.
+		_, err = tx.ExecContext(ctx, query4, id, user.Login)
+		if err != nil {
+			return fmt.Errorf("pseudonymizing security events: %w", errorHandling(err))
+		}
+
+		return recordSecurityEvent(ctx, tx, domain.SecurityEvent{
+			Type:   domain.EventAccountDeleted,
+			UserID: id,
+		})
+	})
+}
+
+func changePassword(
+	ctx context.Context,
+	db *sql.DB,
+	hasher domain.PasswordHasher,
+	id domain.UserID,
+	change domain.PasswordChange,
+) error {
+	query1 := "SELECT hashed_password FROM users WHERE id = $1 FOR UPDATE;"
+	query2 := `UPDATE users
+	SET hashed_password = $1, password_changed_at = now()
+	WHERE id = $2;`
+	query3 := `UPDATE sessions
+	SET revoked_at = now(), updated_at = now()
+	WHERE user_id = $1 AND revoked_at IS NULL;`
+
+	// Запускаем транзакцию, чтобы смена пароля и отзыв всех токенов,
+	// выпущенных до неё, выполнились атомарно.
+	return transaction(ctx, db, func(tx *sql.Tx) error {
+		user := domain.User{ID: id}
+
+		err := tx.QueryRowContext(ctx, query1, id).Scan(&user.HashedPassword)
+		if err != nil {
+			return fmt.Errorf("user search: %w", errorHandling(err))
+		}
+
+		ok, _ := user.VerifyPassword(hasher, change.CurrentPassword)
+		if !ok {
+			return domain.ErrInvalidPassword
+		}
+
+		err = user.SetPassword(hasher, change.NewPassword)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, query2, user.HashedPassword, id)
+		if err != nil {
+			return fmt.Errorf("updating the password: %w", errorHandling(err))
+		}
+
+		_, err = tx.ExecContext(ctx, query3, id)
+		if err != nil {
+			return fmt.Errorf("revoking sessions: %w", errorHandling(err))
+		}
+
+		return recordSecurityEvent(ctx, tx, domain.SecurityEvent{
+			Type:   domain.EventPasswordChanged,
+			UserID: id,
+		})
+	})
+}
+
+// updatePasswordHash заменяет хеш-сумму пароля, если она не изменилась
+// с момента чтения, чтобы не перезаписать параллельную смену пароля.
+func updatePasswordHash(
+	ctx context.Context,
+	db *sql.DB,
+	user domain.User,
+	oldHash string,
+) error {
+	query := "UPDATE users SET hashed_password = $1 WHERE id = $2 AND hashed_password = $3;"
+
+	_, err := db.ExecContext(ctx, query, user.HashedPassword, user.ID, oldHash)
+	if err != nil {
+		return fmt.Errorf("updating the password hash: %w", errorHandling(err))
+	}
+
+	return nil
+}
+
 func getUser(
 	ctx context.Context,
 	db *sql.DB,
@@ -88,13 +461,14 @@ func getUser(
 	var user domain.User
 
 	query := `SELECT
-		id, login, hashed_password, current_balance, withdrawn_balance
+		id, login, role, hashed_password, current_balance, withdrawn_balance
 	FROM users
-	WHERE login = $1;`
+	WHERE lower(login) = lower($1) AND deleted_at IS NULL;`
 
 	err := db.QueryRowContext(ctx, query, auth.Login).Scan(
 		&user.ID,
 		&user.Login,
+		&user.Role,
 		&user.HashedPassword,
 		&user.Balance.Current,
 		&user.Balance.Withdrawn,
@@ -114,13 +488,14 @@ func getUserByID(
 	var user domain.User
 
 	query := `SELECT
-		id, login, hashed_password, current_balance, withdrawn_balance
+		id, login, role, hashed_password, current_balance, withdrawn_balance
 	FROM users
 	WHERE id = $1;`
 
 	err := db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Login,
+		&user.Role,
 		&user.HashedPassword,
 		&user.Balance.Current,
 		&user.Balance.Withdrawn,
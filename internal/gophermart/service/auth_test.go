@@ -2,9 +2,13 @@ package service_test
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
@@ -17,6 +21,7 @@ type AuthSuite struct {
 	auth *service.Auth
 
 	userID         domain.UserID
+	session        domain.Session
 	authentication domain.Authentication
 }
 
@@ -26,7 +31,7 @@ func TestAuth(t *testing.T) {
 
 func (suite *AuthSuite) SetupSuite() {
 	suite.CommonSuite.SetupSuite()
-	suite.auth = service.NewAuth(suite.CommonSuite.db)
+	suite.auth = service.NewAuth(suite.CommonSuite.db, service.AuthOptions{})
 	suite.authentication = domain.Authentication{Login: "login", Password: "password"}
 }
 
@@ -46,17 +51,41 @@ func (suite *AuthSuite) TestA_SignUp() {
 		suite.Error(err)
 	})
 
-	suite.Run("error", func() {
-		invalidPass := randutil.String(100)
+	suite.Run("duplicate in another case", func() {
+		auth := suite.authentication
+		auth.Login = strings.ToUpper(auth.Login)
+		_, err := suite.auth.SignUp(ctx, auth)
+		suite.ErrorIs(err, domain.ErrDuplicate)
+	})
+
+	suite.Run("duplicate with another password", func() {
 		auth := domain.Authentication{
 			Login:    suite.authentication.Login,
-			Password: invalidPass,
+			Password: randutil.String(100),
 		}
 		_, err := suite.auth.SignUp(ctx, auth)
-		suite.Error(err)
+		suite.ErrorIs(err, domain.ErrDuplicate)
+	})
+
+	suite.Run("hasher error", func() {
+		auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{
+			Passwords: failingHasher{},
+		})
+		_, err := auth.SignUp(ctx, domain.Authentication{Login: "hasher", Password: "password"})
+		suite.ErrorIs(err, errHashFailed)
 	})
 }
 
+var errHashFailed = errors.New("hash failed")
+
+// failingHasher определяет хеширование паролей, которое всегда завершается
+// ошибкой.
+type failingHasher struct{}
+
+func (failingHasher) Hash(string) (string, error) { return "", errHashFailed }
+
+func (failingHasher) Verify(string, string) (bool, bool) { return false, false }
+
 func (suite *AuthSuite) TestB_SignIn() {
 	ctx := context.Background()
 
@@ -67,6 +96,15 @@ func (suite *AuthSuite) TestB_SignIn() {
 		}
 	})
 
+	suite.Run("login in another case", func() {
+		auth := suite.authentication
+		auth.Login = strings.ToUpper(auth.Login)
+		uid, err := suite.auth.SignIn(ctx, auth)
+		if suite.NoError(err) {
+			suite.Equal(suite.userID, uid)
+		}
+	})
+
 	suite.Run("not found by login", func() {
 		_, err := suite.auth.SignIn(ctx, domain.Authentication{})
 		suite.Error(err)
@@ -78,16 +116,344 @@ func (suite *AuthSuite) TestB_SignIn() {
 	})
 }
 
-func (suite *AuthSuite) TestC_Identify() {
+func (suite *AuthSuite) TestC_CreateSession() {
 	ctx := context.Background()
 
 	suite.Run("success", func() {
-		err := suite.auth.Identify(ctx, suite.userID)
+		var err error
+		suite.session, err = suite.auth.CreateSession(ctx, suite.userID)
+		if suite.NoError(err) {
+			suite.NotEmpty(suite.session.ID)
+			suite.NotEmpty(suite.session.RefreshToken)
+			suite.Equal(suite.userID, suite.session.UserID)
+			suite.Equal(domain.RoleUser, suite.session.Role)
+		}
+	})
+
+	suite.Run("user not found", func() {
+		_, err := suite.auth.CreateSession(ctx, domain.EmptyUserID)
+		suite.Error(err)
+	})
+}
+
+func (suite *AuthSuite) TestD_Identify() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		err := suite.auth.Identify(ctx, suite.session.Identity())
 		suite.NoError(err)
 	})
 
 	suite.Run("not found", func() {
-		err := suite.auth.Identify(ctx, domain.EmptyUserID)
+		err := suite.auth.Identify(ctx, domain.Identity{})
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("other user", func() {
+		identity := suite.session.Identity()
+		identity.UserID = domain.EmptyUserID
+		err := suite.auth.Identify(ctx, identity)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("other role", func() {
+		identity := suite.session.Identity()
+		identity.Role = domain.RoleAdmin
+		err := suite.auth.Identify(ctx, identity)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
+
+func (suite *AuthSuite) TestE_RefreshSession() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		session, err := suite.auth.RefreshSession(ctx, suite.session.RefreshToken)
+		if suite.NoError(err) {
+			suite.Equal(suite.session.ID, session.ID)
+			suite.Equal(suite.userID, session.UserID)
+			suite.NotEqual(suite.session.RefreshToken, session.RefreshToken)
+		}
+
+		_, err = suite.auth.RefreshSession(ctx, suite.session.RefreshToken)
+		suite.ErrorIs(err, domain.ErrNotFound)
+
+		suite.session = session
+	})
+
+	suite.Run("not found", func() {
+		_, err := suite.auth.RefreshSession(ctx, randutil.String(43))
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("absolute lifetime", func() {
+		auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{
+			SessionTTL:         time.Hour,
+			SessionMaxLifetime: 2 * time.Hour,
+		})
+
+		session, err := auth.CreateSession(ctx, suite.userID)
+		suite.Require().NoError(err)
+
+		backdate := func(d time.Duration) {
+			_, err := suite.CommonSuite.db.ExecContext(ctx,
+				"UPDATE sessions SET created_at = now() - make_interval(secs => $1) WHERE id = $2;",
+				d.Seconds(), session.ID,
+			)
+			suite.Require().NoError(err)
+		}
+
+		// Обновление не продлевает сеанс дальше двух часов с момента входа.
+		backdate(90 * time.Minute)
+		session, err = auth.RefreshSession(ctx, session.RefreshToken)
+		if suite.NoError(err) {
+			suite.WithinDuration(time.Now().Add(30*time.Minute), session.ExpiresAt, time.Minute)
+		}
+
+		backdate(3 * time.Hour)
+		_, err = auth.RefreshSession(ctx, session.RefreshToken)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
+
+func (suite *AuthSuite) TestF_ChangePassword() {
+	ctx := context.Background()
+
+	session, err := suite.auth.CreateSession(ctx, suite.userID)
+	suite.Require().NoError(err)
+
+	suite.Run("invalid password", func() {
+		err := suite.auth.ChangePassword(ctx, suite.userID, domain.PasswordChange{
+			CurrentPassword: "invalid",
+			NewPassword:     "password2",
+		})
+		suite.ErrorIs(err, domain.ErrInvalidPassword)
+	})
+
+	suite.Run("success", func() {
+		change := domain.PasswordChange{
+			CurrentPassword: suite.authentication.Password,
+			NewPassword:     "password2",
+		}
+
+		err := suite.auth.ChangePassword(ctx, suite.userID, change)
+		suite.Require().NoError(err)
+
+		err = suite.auth.Identify(ctx, session.Identity())
+		suite.ErrorIs(err, domain.ErrNotFound)
+
+		_, err = suite.auth.SignIn(ctx, suite.authentication)
 		suite.Error(err)
+
+		suite.authentication.Password = change.NewPassword
+
+		_, err = suite.auth.SignIn(ctx, suite.authentication)
+		suite.NoError(err)
+	})
+}
+
+func (suite *AuthSuite) TestG_RevokeSession() {
+	ctx := context.Background()
+
+	var err error
+	suite.session, err = suite.auth.CreateSession(ctx, suite.userID)
+	suite.Require().NoError(err)
+
+	suite.Run("success", func() {
+		err := suite.auth.RevokeSession(ctx, suite.session.Identity())
+		suite.NoError(err)
+	})
+
+	suite.Run("already revoked", func() {
+		err := suite.auth.RevokeSession(ctx, suite.session.Identity())
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("identify revoked", func() {
+		err := suite.auth.Identify(ctx, suite.session.Identity())
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("refresh revoked", func() {
+		_, err := suite.auth.RefreshSession(ctx, suite.session.RefreshToken)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
+
+func (suite *AuthSuite) TestH_LoginThrottling() {
+	ctx := domain.WithClient(context.Background(), domain.Client{IP: "192.0.2.1"})
+
+	auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{
+		Throttling: service.LoginThrottling{
+			FreeAttempts:          1,
+			LoginLockoutThreshold: 2,
+			IPLockoutThreshold:    10,
+			LockoutDuration:       time.Minute,
+			Window:                time.Hour,
+		},
+	})
+
+	invalid := domain.Authentication{Login: suite.authentication.Login, Password: "invalid"}
+
+	suite.Run("failures", func() {
+		for i := 0; i < 2; i++ {
+			_, err := auth.SignIn(ctx, invalid)
+			suite.ErrorIs(err, domain.ErrNotFound)
+		}
+	})
+
+	suite.Run("locked out", func() {
+		_, err := auth.SignIn(ctx, suite.authentication)
+
+		var exhausted *domain.ResourceExhaustedError
+		if suite.ErrorAs(err, &exhausted) {
+			suite.Greater(exhausted.RetryAfter, time.Duration(0))
+		}
+	})
+}
+
+func (suite *AuthSuite) TestI_Rehash() {
+	ctx := context.Background()
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	suite.Require().NoError(err)
+
+	_, err = suite.db.ExecContext(
+		ctx,
+		"INSERT INTO users (login, hashed_password) VALUES ($1, $2);",
+		"legacy",
+		string(legacy),
+	)
+	suite.Require().NoError(err)
+
+	_, err = suite.auth.SignIn(ctx, domain.Authentication{Login: "legacy", Password: "password"})
+	suite.Require().NoError(err)
+
+	var hashedPassword string
+
+	err = suite.db.QueryRowContext(
+		ctx,
+		"SELECT hashed_password FROM users WHERE login = $1;",
+		"legacy",
+	).Scan(&hashedPassword)
+	if suite.NoError(err) {
+		suite.True(strings.HasPrefix(hashedPassword, "$argon2id$"))
+	}
+}
+
+func (suite *AuthSuite) TestJ_DeleteAccount() {
+	ctx := context.Background()
+
+	auth := domain.Authentication{Login: "deleted", Password: "password"}
+
+	userID, err := suite.auth.SignUp(ctx, auth)
+	suite.Require().NoError(err)
+
+	session, err := suite.auth.CreateSession(ctx, userID)
+	suite.Require().NoError(err)
+
+	_, err = suite.CommonSuite.db.ExecContext(ctx, `INSERT INTO security_events
+	(type, user_id, login, ip, user_agent) VALUES ($1, $2, $3, $4, $5);`,
+		domain.EventLoginSucceeded, userID, auth.Login, "192.0.2.1", "test",
+	)
+	suite.Require().NoError(err)
+
+	// Неудачный вход с логином в другом регистре не привязан к пользователю.
+	var failedEventID int64
+	err = suite.CommonSuite.db.QueryRowContext(ctx, `INSERT INTO security_events
+	(type, login, ip, user_agent) VALUES ($1, $2, $3, $4) RETURNING id;`,
+		domain.EventLoginFailed, "DELETED", "192.0.2.1", "test",
+	).Scan(&failedEventID)
+	suite.Require().NoError(err)
+
+	suite.Run("invalid password", func() {
+		err := suite.auth.DeleteAccount(ctx, userID, domain.AccountDeletion{Password: "invalid"})
+		suite.ErrorIs(err, domain.ErrInvalidPassword)
+	})
+
+	suite.Run("success", func() {
+		err := suite.auth.DeleteAccount(ctx, userID, domain.AccountDeletion{Password: auth.Password})
+		suite.NoError(err)
+	})
+
+	suite.Run("session revoked", func() {
+		err := suite.auth.Identify(ctx, session.Identity())
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("security events pseudonymized", func() {
+		var events, personal int
+		err := suite.CommonSuite.db.QueryRowContext(ctx, `SELECT count(*),
+			count(*) FILTER (WHERE login IS NOT NULL OR ip IS NOT NULL OR user_agent IS NOT NULL)
+		FROM security_events
+		WHERE user_id = $1 OR id = $2;`,
+			userID, failedEventID,
+		).Scan(&events, &personal)
+		if suite.NoError(err) {
+			suite.GreaterOrEqual(events, 3)
+			suite.Zero(personal)
+		}
+	})
+
+	suite.Run("sign in", func() {
+		_, err := suite.auth.SignIn(ctx, auth)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("login is released", func() {
+		uid, err := suite.auth.SignUp(ctx, auth)
+		if suite.NoError(err) {
+			suite.NotEqual(userID, uid)
+		}
+	})
+}
+
+func (suite *AuthSuite) TestK_IdentityCache() {
+	ctx := context.Background()
+
+	auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{
+		Identities: service.NewIdentityCache(10, time.Minute),
+	})
+	authentication := domain.Authentication{Login: "cached", Password: "password"}
+
+	userID, err := auth.SignUp(ctx, authentication)
+	suite.Require().NoError(err)
+
+	session, err := auth.CreateSession(ctx, userID)
+	suite.Require().NoError(err)
+
+	suite.Run("success", func() {
+		suite.NoError(auth.Identify(ctx, session.Identity()))
+		suite.NoError(auth.Identify(ctx, session.Identity()))
+	})
+
+	suite.Run("other user", func() {
+		identity := session.Identity()
+		identity.UserID = domain.EmptyUserID
+		err := auth.Identify(ctx, identity)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("revoked", func() {
+		err := auth.RevokeSession(ctx, session.Identity())
+		suite.Require().NoError(err)
+
+		err = auth.Identify(ctx, session.Identity())
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+
+	suite.Run("password changed", func() {
+		session, err := auth.CreateSession(ctx, userID)
+		suite.Require().NoError(err)
+		suite.Require().NoError(auth.Identify(ctx, session.Identity()))
+
+		err = auth.ChangePassword(ctx, userID, domain.PasswordChange{
+			CurrentPassword: authentication.Password,
+			NewPassword:     "new_password",
+		})
+		suite.Require().NoError(err)
+
+		err = auth.Identify(ctx, session.Identity())
+		suite.ErrorIs(err, domain.ErrNotFound)
 	})
 }
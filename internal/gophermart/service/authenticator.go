@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"log/slog"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/sign"
+)
+
+// TokenType определяет тип токена авторизации.
+const TokenType = "Bearer"
+
+// SessionTokens определяет токены сеанса, выдаваемые клиенту.
+type SessionTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time // Время истечения токена обновления.
+}
+
+// LoginResult определяет результат входа: токены открытого сеанса либо,
+// если у пользователя включён второй фактор, запрос второго фактора.
+type LoginResult struct {
+	Tokens    SessionTokens
+	Challenge *domain.TwoFactorChallenge
+}
+
+// AuthenticatorOptions определяет опции для Authenticator.
+type AuthenticatorOptions struct {
+	Auth      domain.AuthService
+	APIKeys   domain.APIKeyService    // Если nil, то ключи API отключены.
+	TwoFactor domain.TwoFactorService // Если nil, то второй фактор отключён.
+	Audit     domain.AuditService     // Если nil, то события не журналируются.
+	Signer    sign.Signer
+
+	// Сумма списания, свыше которой требуется свежий код второго фактора;
+	// 0 отключает проверку.
+	WithdrawalCodeThreshold monetary.Unit
+}
+
+// Authenticator определяет сценарии аутентификации, не зависящие
+// от транспорта: идентификацию по токену или ключу API, вход со вторым
+// фактором, выдачу токенов сеанса и подтверждение списаний. Используется
+// HTTP- и gRPC-API, чтобы правила входа и журналирования у них не
+// расходились.
+type Authenticator struct {
+	auth      domain.AuthService
+	apiKeys   domain.APIKeyService
+	twoFactor domain.TwoFactorService
+	audit     domain.AuditService
+	signer    sign.Signer
+
+	withdrawalCodeThreshold monetary.Unit
+}
+
+// NewAuthenticator возвращает новый экземпляр Authenticator.
+func NewAuthenticator(opts AuthenticatorOptions) *Authenticator {
+	return &Authenticator{
+		auth:      opts.Auth,
+		apiKeys:   opts.APIKeys,
+		twoFactor: opts.TwoFactor,
+		audit:     opts.Audit,
+		signer:    opts.Signer,
+
+		withdrawalCodeThreshold: opts.WithdrawalCodeThreshold,
+	}
+}
+
+// Identify возвращает идентификационные данные владельца ключа API apiKey
+// или токена авторизации вида "Bearer <token>"; ключ API имеет приоритет.
+// Возвращает domain.ErrNotFound, если ключ или токен не действителен или
+// сеанс отозван.
+func (a *Authenticator) Identify(ctx context.Context, apiKey, authorization string) (domain.Identity, error) {
+	if apiKey != "" {
+		if a.apiKeys == nil {
+			return domain.Identity{}, fmt.Errorf("%w: API keys are disabled", domain.ErrNotFound)
+		}
+		return a.apiKeys.Identify(ctx, apiKey)
+	}
+
+	identity, err := a.parseToken(authorization)
+	if err != nil {
+		return domain.Identity{}, fmt.Errorf("%w: %s", domain.ErrNotFound, err)
+	}
+
+	err = a.auth.Identify(ctx, identity)
+	if err != nil {
+		return domain.Identity{}, err
+	}
+
+	return identity, nil
+}
+
+// parseToken парсит токен авторизации вида "Bearer <token>" и возвращает
+// идентификационные данные владельца сеанса.
+func (a *Authenticator) parseToken(value string) (domain.Identity, error) {
+	split := strings.SplitN(value, " ", 2)
+	if len(split) != 2 || split[0] != TokenType {
+		return domain.Identity{}, fmt.Errorf("unsupported token type: %q", split[0])
+	}
+	payload, err := a.signer.Parse(split[1])
+	if err != nil {
+		return domain.Identity{}, err
+	}
+	var identity domain.Identity
+	err = json.Unmarshal([]byte(payload), &identity)
+	if err != nil {
+		return domain.Identity{}, fmt.Errorf("decoding the token payload: %w", err)
+	}
+	if identity.IsEmpty() {
+		return domain.Identity{}, errors.New("token payload is empty")
+	}
+	return identity, nil
+}
+
+// IssueTokens упаковывает идентификационные данные владельца сеанса
+// в токен авторизации и возвращает его вместе с токеном обновления.
+func (a *Authenticator) IssueTokens(session domain.Session) (SessionTokens, error) {
+	payload, err := json.Marshal(session.Identity())
+	if err != nil {
+		return SessionTokens{}, fmt.Errorf("encoding the token payload: %w", err)
+	}
+	token, err := a.signer.Sign(string(payload))
+	if err != nil {
+		return SessionTokens{}, fmt.Errorf("signing the token: %w", err)
+	}
+	return SessionTokens{
+		AccessToken:  token,
+		RefreshToken: session.RefreshToken,
+		ExpiresAt:    session.ExpiresAt,
+	}, nil
+}
+
+// StartSession открывает новый сеанс пользователя и возвращает его токены.
+func (a *Authenticator) StartSession(ctx context.Context, id domain.UserID) (SessionTokens, error) {
+	session, err := a.auth.CreateSession(ctx, id)
+	if err != nil {
+		return SessionTokens{}, fmt.Errorf("creating a session: %w", err)
+	}
+	return a.IssueTokens(session)
+}
+
+// Record добавляет событие в журнал событий безопасности; ошибка журнала
+// записывается в лог и не прерывает операцию.
+func (a *Authenticator) Record(ctx context.Context, event domain.SecurityEvent) {
+	if a.audit == nil {
+		return
+	}
+	err := a.audit.Record(ctx, event)
+	if err != nil {
+		logging.FromContext(ctx).Error(err.Error(), slog.String("scope", "recording a security event"))
+	}
+}
+
+// recordLoginFailure добавляет в журнал неудачную попытку входа.
+func (a *Authenticator) recordLoginFailure(ctx context.Context, login, reason string) {
+	a.Record(ctx, domain.SecurityEvent{
+		Type:    domain.EventLoginFailed,
+		Login:   login,
+		Details: reason,
+	})
+}
+
+// Register регистрирует пользователя и открывает его сеанс; учётные данные
+// должны быть проверены вызывающим. Возвращает domain.ErrDuplicate, если
+// логин занят.
+func (a *Authenticator) Register(ctx context.Context, auth domain.Authentication) (SessionTokens, error) {
+	userID, err := a.auth.SignUp(ctx, auth)
+	if err != nil {
+		return SessionTokens{}, err
+	}
+
+	a.Record(ctx, domain.SecurityEvent{Type: domain.EventRegistered, UserID: userID})
+
+	return a.StartSession(ctx, userID)
+}
+
+// Login выполняет вход пользователя и открывает его сеанс; если у
+// пользователя включён второй фактор, то вместо сеанса возвращает запрос
+// второго фактора. Возвращает domain.ErrNotFound, если логин или пароль
+// не верны, и *domain.ResourceExhaustedError, если вход временно
+// заблокирован.
+func (a *Authenticator) Login(ctx context.Context, auth domain.Authentication) (LoginResult, error) {
+	userID, err := a.auth.SignIn(ctx, auth)
+	if err != nil {
+		var exhausted *domain.ResourceExhaustedError
+		if errors.As(err, &exhausted) {
+			a.recordLoginFailure(ctx, auth.Login, "login throttled")
+		} else if errors.Is(err, domain.ErrNotFound) {
+			a.recordLoginFailure(ctx, auth.Login, "invalid credentials")
+		}
+		return LoginResult{}, err
+	}
+
+	if a.twoFactor != nil {
+		challenge, err := a.twoFactor.Challenge(ctx, userID)
+		if err == nil {
+			return LoginResult{Challenge: &challenge}, nil
+		}
+		if !errors.Is(err, domain.ErrTwoFactorDisabled) {
+			return LoginResult{}, fmt.Errorf("creating a two-factor challenge: %w", err)
+		}
+	}
+
+	a.Record(ctx, domain.SecurityEvent{Type: domain.EventLoginSucceeded, UserID: userID})
+
+	tokens, err := a.StartSession(ctx, userID)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	return LoginResult{Tokens: tokens}, nil
+}
+
+// CompleteLogin завершает вход по ответу на запрос второго фактора
+// и открывает сеанс пользователя. Возвращает domain.ErrNotFound, если
+// запрос не найден или истёк, domain.ErrInvalidCode или
+// domain.ErrTwoFactorDisabled, если второй фактор не подтверждён,
+// и *domain.ResourceExhaustedError, если проверка кодов заблокирована.
+func (a *Authenticator) CompleteLogin(ctx context.Context, resp domain.TwoFactorResponse) (SessionTokens, error) {
+	if a.twoFactor == nil {
+		return SessionTokens{}, domain.ErrTwoFactorDisabled
+	}
+
+	userID, err := a.twoFactor.CompleteChallenge(ctx, resp)
+	if err != nil {
+		var exhausted *domain.ResourceExhaustedError
+		if errors.As(err, &exhausted) {
+			a.recordLoginFailure(ctx, "", "second factor throttled")
+		} else if errors.Is(err, domain.ErrNotFound) ||
+			errors.Is(err, domain.ErrInvalidCode) ||
+			errors.Is(err, domain.ErrTwoFactorDisabled) {
+			a.recordLoginFailure(ctx, "", "invalid second factor")
+		}
+		return SessionTokens{}, err
+	}
+
+	a.Record(ctx, domain.SecurityEvent{
+		Type:    domain.EventLoginSucceeded,
+		UserID:  userID,
+		Details: "second factor",
+	})
+
+	return a.StartSession(ctx, userID)
+}
+
+// VerifyWithdrawal проверяет свежий код второго фактора пользователя, если
+// сумма списания превышает порог и второй фактор включён. Возвращает
+// domain.ErrInvalidCode, если код не верен, и
+// *domain.ResourceExhaustedError, если проверка кодов заблокирована.
+func (a *Authenticator) VerifyWithdrawal(
+	ctx context.Context,
+	id domain.UserID,
+	sum monetary.Unit,
+	code string,
+) error {
+	if a.twoFactor == nil || a.withdrawalCodeThreshold <= 0 || sum <= a.withdrawalCodeThreshold {
+		return nil
+	}
+
+	err := a.twoFactor.Verify(ctx, id, code)
+	if err == nil || errors.Is(err, domain.ErrTwoFactorDisabled) {
+		return nil
+	}
+
+	return err
+}
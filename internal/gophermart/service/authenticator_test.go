@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	mock_domain "github.com/sergeizaitcev/gophermart/internal/gophermart/domain/mocks"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+	"github.com/sergeizaitcev/gophermart/pkg/monetary"
+	"github.com/sergeizaitcev/gophermart/pkg/sign"
+)
+
+// securityEventMatcher сопоставляет событие безопасности по типу
+// и подробностям.
+type securityEventMatcher struct {
+	typ     domain.SecurityEventType
+	details string
+}
+
+func (m securityEventMatcher) Matches(x any) bool {
+	event, ok := x.(domain.SecurityEvent)
+	return ok && event.Type == m.typ && event.Details == m.details
+}
+
+func (m securityEventMatcher) String() string {
+	return fmt.Sprintf("security event of type %q with details %q", string(m.typ), m.details)
+}
+
+func TestAuthenticator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	auth := mock_domain.NewMockAuthService(ctrl)
+	twoFactor := mock_domain.NewMockTwoFactorService(ctrl)
+	audit := mock_domain.NewMockAuditService(ctrl)
+
+	authn := service.NewAuthenticator(service.AuthenticatorOptions{
+		Auth:      auth,
+		TwoFactor: twoFactor,
+		Audit:     audit,
+		Signer:    sign.New([]byte("secret")),
+
+		WithdrawalCodeThreshold: monetary.Format(1000),
+	})
+
+	ctx := context.Background()
+	userID := uuid.New()
+	credentials := domain.Authentication{Login: "gopher", Password: "s3cr3t_pass"}
+	session := domain.Session{
+		ID:           uuid.New(),
+		UserID:       userID,
+		Role:         domain.RoleUser,
+		RefreshToken: "refresh",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	event := func(typ domain.SecurityEventType, details string) gomock.Matcher {
+		return securityEventMatcher{typ: typ, details: details}
+	}
+
+	t.Run("token round trip", func(t *testing.T) {
+		tokens, err := authn.IssueTokens(session)
+		require.NoError(t, err)
+		require.Equal(t, "refresh", tokens.RefreshToken)
+
+		auth.EXPECT().Identify(ctx, session.Identity()).Return(nil)
+
+		identity, err := authn.Identify(ctx, "", service.TokenType+" "+tokens.AccessToken)
+		require.NoError(t, err)
+		require.Equal(t, session.Identity(), identity)
+
+		_, err = authn.Identify(ctx, "", "Basic "+tokens.AccessToken)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+
+		// Ключи API отключены.
+		_, err = authn.Identify(ctx, "key", "")
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("login", func(t *testing.T) {
+		auth.EXPECT().SignIn(ctx, credentials).Return(userID, nil)
+		twoFactor.EXPECT().Challenge(ctx, userID).Return(domain.TwoFactorChallenge{}, domain.ErrTwoFactorDisabled)
+		audit.EXPECT().Record(ctx, event(domain.EventLoginSucceeded, "")).Return(nil)
+		auth.EXPECT().CreateSession(ctx, userID).Return(session, nil)
+
+		res, err := authn.Login(ctx, credentials)
+		require.NoError(t, err)
+		require.Nil(t, res.Challenge)
+		require.NotEmpty(t, res.Tokens.AccessToken)
+	})
+
+	t.Run("login with second factor", func(t *testing.T) {
+		challenge := domain.TwoFactorChallenge{Token: "challenge", ExpiresAt: time.Now().Add(time.Minute)}
+
+		auth.EXPECT().SignIn(ctx, credentials).Return(userID, nil)
+		twoFactor.EXPECT().Challenge(ctx, userID).Return(challenge, nil)
+
+		res, err := authn.Login(ctx, credentials)
+		require.NoError(t, err)
+		require.Equal(t, &challenge, res.Challenge)
+
+		resp := domain.TwoFactorResponse{Token: "challenge", Code: "123456"}
+
+		twoFactor.EXPECT().CompleteChallenge(ctx, resp).Return(userID, nil)
+		audit.EXPECT().Record(ctx, event(domain.EventLoginSucceeded, "second factor")).Return(nil)
+		auth.EXPECT().CreateSession(ctx, userID).Return(session, nil)
+
+		tokens, err := authn.CompleteLogin(ctx, resp)
+		require.NoError(t, err)
+		require.Equal(t, "refresh", tokens.RefreshToken)
+
+		twoFactor.EXPECT().CompleteChallenge(ctx, resp).Return(domain.EmptyUserID, domain.ErrInvalidCode)
+		audit.EXPECT().Record(ctx, event(domain.EventLoginFailed, "invalid second factor")).Return(nil)
+
+		_, err = authn.CompleteLogin(ctx, resp)
+		require.ErrorIs(t, err, domain.ErrInvalidCode)
+
+		exhausted := &domain.ResourceExhaustedError{Message: "too many invalid two-factor codes", RetryAfter: time.Minute}
+
+		twoFactor.EXPECT().CompleteChallenge(ctx, resp).Return(domain.EmptyUserID, exhausted)
+		audit.EXPECT().Record(ctx, event(domain.EventLoginFailed, "second factor throttled")).Return(nil)
+
+		_, err = authn.CompleteLogin(ctx, resp)
+		require.ErrorAs(t, err, &exhausted)
+	})
+
+	t.Run("login failures", func(t *testing.T) {
+		exhausted := &domain.ResourceExhaustedError{Message: "too many attempts", RetryAfter: time.Second}
+
+		auth.EXPECT().SignIn(ctx, credentials).Return(domain.EmptyUserID, domain.ErrNotFound)
+		audit.EXPECT().Record(ctx, event(domain.EventLoginFailed, "invalid credentials")).Return(nil)
+
+		_, err := authn.Login(ctx, credentials)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+
+		auth.EXPECT().SignIn(ctx, credentials).Return(domain.EmptyUserID, exhausted)
+		audit.EXPECT().Record(ctx, event(domain.EventLoginFailed, "login throttled")).Return(errors.New("audit unavailable"))
+
+		_, err = authn.Login(ctx, credentials)
+		require.ErrorAs(t, err, &exhausted)
+	})
+
+	t.Run("withdrawal", func(t *testing.T) {
+		// Суммы до порога не требуют кода.
+		require.NoError(t, authn.VerifyWithdrawal(ctx, userID, monetary.Format(1000), ""))
+
+		twoFactor.EXPECT().Verify(ctx, userID, "").Return(domain.ErrTwoFactorDisabled)
+		require.NoError(t, authn.VerifyWithdrawal(ctx, userID, monetary.Format(1001), ""))
+
+		twoFactor.EXPECT().Verify(ctx, userID, "000000").Return(domain.ErrInvalidCode)
+		require.ErrorIs(t, authn.VerifyWithdrawal(ctx, userID, monetary.Format(1001), "000000"), domain.ErrInvalidCode)
+	})
+}
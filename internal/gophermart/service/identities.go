@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/ttlcache"
+)
+
+// Канал PostgreSQL, в который триггеры отправляют уведомления об отзыве
+// сеансов и изменении пользователей.
+const identityInvalidationChannel = "identity_invalidated"
+
+// Префиксы полезной нагрузки уведомления об инвалидации.
+const (
+	invalidateUserPrefix    = "user:"
+	invalidateSessionPrefix = "session:"
+)
+
+// IdentityCache определяет кеш подтверждённых идентификационных данных
+// сеансов. Нулевой указатель — отключённый кеш.
+type IdentityCache struct {
+	ttl   time.Duration
+	cache *ttlcache.Cache[domain.SessionID, domain.Identity]
+
+	// Поколение увеличивается при каждой инвалидации; запись, прочитанная
+	// из БД до инвалидации, не попадает в кеш.
+	mu         sync.Mutex
+	generation uint64
+}
+
+// NewIdentityCache возвращает новый экземпляр IdentityCache, хранящий не
+// более size сеансов в течение ttl. Если size или ttl не положительны, то
+// возвращает nil.
+func NewIdentityCache(size int, ttl time.Duration) *IdentityCache {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &IdentityCache{
+		ttl:   ttl,
+		cache: ttlcache.New[domain.SessionID, domain.Identity](size, ttl),
+	}
+}
+
+func (c *IdentityCache) get(id domain.SessionID) (domain.Identity, bool) {
+	if c == nil {
+		return domain.Identity{}, false
+	}
+	return c.cache.Get(id)
+}
+
+// snapshot возвращает текущее поколение кеша; его нужно получить до чтения
+// сеанса из БД и передать в set.
+func (c *IdentityCache) snapshot() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// set сохраняет сеанс, если с момента snapshot не было инвалидаций. Запись
+// живёт не дольше самого сеанса.
+func (c *IdentityCache) set(identity domain.Identity, expiresAt time.Time, generation uint64) {
+	if c == nil {
+		return
+	}
+
+	ttl := min(c.ttl, time.Until(expiresAt))
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	c.cache.SetWithTTL(identity.SessionID, domain.Identity{
+		UserID:    identity.UserID,
+		SessionID: identity.SessionID,
+		Role:      identity.Role,
+	}, ttl)
+}
+
+// InvalidateSession удаляет сеанс из кеша.
+func (c *IdentityCache) InvalidateSession(id domain.SessionID) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.cache.Delete(id)
+}
+
+// InvalidateUser удаляет из кеша все сеансы пользователя.
+func (c *IdentityCache) InvalidateUser(id domain.UserID) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.cache.DeleteFunc(func(_ domain.SessionID, identity domain.Identity) bool {
+		return identity.UserID == id
+	})
+}
+
+// Purge очищает кеш.
+func (c *IdentityCache) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.cache.Purge()
+}
+
+// invalidate обрабатывает полезную нагрузку уведомления об инвалидации.
+func (c *IdentityCache) invalidate(payload string) error {
+	switch {
+	case strings.HasPrefix(payload, invalidateUserPrefix):
+		id, err := domain.NewUserID(strings.TrimPrefix(payload, invalidateUserPrefix))
+		if err != nil {
+			return err
+		}
+		c.InvalidateUser(id)
+	case strings.HasPrefix(payload, invalidateSessionPrefix):
+		id, err := uuid.Parse(strings.TrimPrefix(payload, invalidateSessionPrefix))
+		if err != nil {
+			return fmt.Errorf("parsing session ID: %w", err)
+		}
+		c.InvalidateSession(id)
+	default:
+		return fmt.Errorf("unknown payload: %q", payload)
+	}
+	return nil
+}
+
+// ListenIdentityInvalidation подписывается на уведомления PostgreSQL об
+// отзыве сеансов и изменении пользователей и удаляет соответствующие записи
+// из кеша, пока не будет отменён ctx. Так изменения, сделанные другими
+// экземплярами сервиса, не ждут истечения времени жизни записей.
+//
+// После переподключения к базе данных кеш очищается полностью, так как
+// уведомления за время разрыва потеряны.
+func ListenIdentityInvalidation(ctx context.Context, dsn string, cache *IdentityCache) error {
+	if cache == nil {
+		return nil
+	}
+
+	listener := pq.NewListener(dsn, time.Second, time.Minute,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				slog.Warn(err.Error(), slog.String("scope", "identity invalidation listener"))
+			}
+			if event == pq.ListenerEventReconnected {
+				cache.Purge()
+			}
+		},
+	)
+	defer listener.Close()
+
+	err := listener.Listen(identityInvalidationChannel)
+	if err != nil {
+		return fmt.Errorf("listening to %s: %w", identityInvalidationChannel, err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// nil приходит после переподключения.
+			if n == nil {
+				cache.Purge()
+				continue
+			}
+			err = cache.invalidate(n.Extra)
+			if err != nil {
+				slog.Warn(err.Error(), slog.String("scope", "identity invalidation"))
+			}
+		}
+	}
+}
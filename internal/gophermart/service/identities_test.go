@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+)
+
+func TestIdentityCache_Set(t *testing.T) {
+	identity := domain.Identity{
+		UserID:    uuid.New(),
+		SessionID: uuid.New(),
+		Role:      domain.RoleUser,
+	}
+
+	t.Run("stale read", func(t *testing.T) {
+		c := NewIdentityCache(10, time.Minute)
+
+		generation := c.snapshot()
+		c.InvalidateUser(identity.UserID)
+		c.set(identity, time.Now().Add(time.Hour), generation)
+
+		_, ok := c.get(identity.SessionID)
+		require.False(t, ok)
+	})
+
+	t.Run("session expiry", func(t *testing.T) {
+		c := NewIdentityCache(10, time.Minute)
+
+		c.set(identity, time.Now().Add(10*time.Millisecond), c.snapshot())
+		_, ok := c.get(identity.SessionID)
+		require.True(t, ok)
+
+		time.Sleep(20 * time.Millisecond)
+		_, ok = c.get(identity.SessionID)
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+)
+
+// Результаты опроса accrual о заказе.
+const (
+	pollProcessed   = "processed"    // Начисление рассчитано.
+	pollInvalid     = "invalid"      // Заказ отклонён accrual.
+	pollPending     = "pending"      // Начисление ещё рассчитывается.
+	pollUnknown     = "unknown"      // Заказ не зарегистрирован в accrual.
+	pollRateLimited = "rate_limited" // Accrual ограничил частоту запросов.
+	pollError       = "error"        // Запрос в accrual завершился ошибкой.
+)
+
+var (
+	ordersQueueDepth = metrics.NewGaugeVec(
+		"orders_queue_depth",
+		"Количество заказов в очереди опроса accrual.",
+	)
+	ordersPolls = metrics.NewCounterVec(
+		"orders_accrual_polls_total",
+		"Количество опросов accrual о заказах по результату.",
+		"outcome",
+	)
+)
+
+func init() {
+	metrics.MustRegister(ordersQueueDepth, ordersPolls)
+}
@@ -75,7 +75,11 @@ func performOperation(ctx context.Context, db *sql.DB, operation domain.Operatio
 			return fmt.Errorf("updating the user balance: %w", errorHandling(err))
 		}
 
-		return nil
+		return recordSecurityEvent(ctx, tx, domain.SecurityEvent{
+			Type:    domain.EventWithdrawal,
+			UserID:  operation.UserID,
+			Details: fmt.Sprintf("order %s, sum %s", operation.OrderNumber, operation.Sum),
+		})
 	})
 }
 
@@ -42,11 +42,11 @@ func (suite *OperationSuite) SetupSuite() {
 		}, nil,
 	).Times(1)
 
-	auth := service.NewAuth(suite.CommonSuite.db)
+	auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{})
 	suite.operations = service.NewOperations(suite.CommonSuite.db)
 
 	orders := service.NewOrders(suite.CommonSuite.db, accrual)
-	defer orders.Close()
+	defer orders.Close(context.Background())
 
 	var err error
 	ctx := context.Background()
@@ -6,13 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"log/slog"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
 	"github.com/sergeizaitcev/gophermart/pkg/queue"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
 var _ domain.OrderService = (*Orders)(nil)
@@ -22,9 +27,37 @@ type Orders struct {
 	db      *sql.DB
 	accrual domain.AccrualClient
 
-	orders queue.FIFO[domain.Order]
+	orders queue.FIFO[queuedOrder]
 	wg     *sync.WaitGroup
-	termCh chan struct{}
+
+	// stopCh прекращает извлечение заказов из очереди, termCh отменяет
+	// запросы текущих заказов.
+	stopCh   chan struct{}
+	termCh   chan struct{}
+	stopOnce sync.Once
+	termOnce sync.Once
+
+	// Количество обработчиков, ожидающих заказы, и время последнего
+	// извлечения заказа из очереди в наносекундах Unix.
+	idle        atomic.Int32
+	lastDequeue atomic.Int64
+}
+
+// queueStallTimeout определяет время, в течение которого очередь с заказами
+// и занятыми обработчиками может не продвигаться.
+const queueStallTimeout = 2 * time.Minute
+
+// saveQueueTimeout определяет время на сохранение очереди, если бюджет
+// остановки уже исчерпан.
+const saveQueueTimeout = 5 * time.Second
+
+// queuedOrder определяет заказ в очереди обработки вместе с
+// идентификатором запроса и участком трассы, в котором он был загружен,
+// чтобы запросы в accrual можно было сопоставить с исходным запросом.
+type queuedOrder struct {
+	order     domain.Order
+	requestID string
+	trace     tracing.SpanContext
 }
 
 // NewOrders возвращает новый экземпляр Order.
@@ -33,9 +66,12 @@ func NewOrders(db *sql.DB, accrual domain.AccrualClient) *Orders {
 		db:      db,
 		accrual: accrual,
 		wg:      &sync.WaitGroup{},
+		stopCh:  make(chan struct{}),
 		termCh:  make(chan struct{}),
 	}
 
+	o.lastDequeue.Store(time.Now().UnixNano())
+
 	for i := 0; i < 2; i++ {
 		o.wg.Add(1)
 		go func() {
@@ -47,19 +83,94 @@ func NewOrders(db *sql.DB, accrual domain.AccrualClient) *Orders {
 	return o
 }
 
-// Close сигнализирует всем процессам о завершении работы и блокируется
-// до тех пор, пока они не будут завершены.
-func (o *Orders) Close() {
+// Close останавливает обработку заказов: обработчики перестают извлекать
+// заказы из очереди и завершают текущие, после чего оставшиеся в очереди
+// заказы сохраняются в БД, чтобы Restore вернул их в очередь при следующем
+// запуске. Если ctx завершится раньше, то запросы текущих заказов
+// отменяются, а заказы возвращаются в очередь и сохраняются.
+func (o *Orders) Close(ctx context.Context) error {
+	o.stopOnce.Do(func() { close(o.stopCh) })
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	o.termOnce.Do(func() { close(o.termCh) })
+	<-done
+
+	pending := o.orders.Drain()
+	o.updateQueueDepth()
+
+	if len(pending) == 0 {
+		return err
+	}
+
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveQueueTimeout)
+	defer cancel()
+
+	if err := saveQueue(saveCtx, o.db, pending); err != nil {
+		return fmt.Errorf("saving %d queued orders: %w", len(pending), err)
+	}
+
+	slog.Info("order queue saved", slog.Int("orders", len(pending)))
+
+	return err
+}
+
+// Restore возвращает в очередь заказы, сохранённые при предыдущей
+// остановке, и возвращает их количество.
+func (o *Orders) Restore(ctx context.Context) (int, error) {
+	queued, err := loadQueue(ctx, o.db)
+	if err != nil {
+		return 0, fmt.Errorf("loading queued orders: %w", err)
+	}
+
+	for _, q := range queued {
+		err = o.orders.Enqueue(ctx, q)
+		if err != nil {
+			return 0, err
+		}
+	}
+	o.updateQueueDepth()
+
+	return len(queued), nil
+}
+
+// CheckQueue возвращает ошибку, если обработка заказов остановлена или
+// очередь не продвигается: в ней есть заказы, все обработчики заняты, и
+// ни один заказ не был извлечён дольше queueStallTimeout.
+func (o *Orders) CheckQueue(context.Context) error {
 	if o.closed() {
-		return
+		return errors.New("order processing is stopped")
+	}
+
+	size := o.orders.Size()
+	if size == 0 || o.idle.Load() > 0 {
+		return nil
+	}
+
+	stalled := time.Since(time.Unix(0, o.lastDequeue.Load()))
+	if stalled > queueStallTimeout {
+		return fmt.Errorf("order queue is not draining: %d orders queued, last dequeued %s ago",
+			size, stalled.Round(time.Second))
 	}
-	close(o.termCh)
-	o.wg.Wait()
+
+	return nil
 }
 
 func (o *Orders) closed() bool {
 	select {
-	case <-o.termCh:
+	case <-o.stopCh:
 		return true
 	default:
 		return false
@@ -82,26 +193,34 @@ func (o *Orders) Process(ctx context.Context, order domain.Order) error {
 		return fmt.Errorf("creating a new order: %w", err)
 	}
 
+	queued := queuedOrder{
+		order:     order,
+		requestID: logging.RequestID(ctx),
+		trace:     tracing.SpanContextFromContext(ctx),
+	}
+
 	o.wg.Add(1)
 
 	go func() {
-		ctx, cancel := o.withCancel()
+		ctx, cancel := o.withCancel(o.termCh)
 		defer cancel()
 
-		_ = o.orders.Enqueue(ctx, order)
+		_ = o.orders.Enqueue(ctx, queued)
+		o.updateQueueDepth()
 		o.wg.Done()
 	}()
 
 	return nil
 }
 
-func (o *Orders) withCancel() (context.Context, context.CancelFunc) {
+// withCancel возвращает контекст, отменяемый при закрытии ch.
+func (o *Orders) withCancel(ch <-chan struct{}) (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 	o.wg.Add(1)
 	go func() {
 		select {
 		case <-ctx.Done():
-		case <-o.termCh:
+		case <-ch:
 			cancel()
 		}
 		o.wg.Done()
@@ -110,11 +229,18 @@ func (o *Orders) withCancel() (context.Context, context.CancelFunc) {
 }
 
 func (o *Orders) processing() {
-	ctx, cancel := o.withCancel()
+	// Извлечение заказов прекращается при остановке, а запросы текущего
+	// заказа отменяются, только если бюджет остановки исчерпан.
+	ctx, cancel := o.withCancel(o.termCh)
 	defer cancel()
 
-	for {
-		order, err := o.orders.Dequeue(ctx)
+	dequeueCtx, cancelDequeue := o.withCancel(o.stopCh)
+	defer cancelDequeue()
+
+	for !o.closed() {
+		o.idle.Add(1)
+		queued, err := o.orders.Dequeue(dequeueCtx)
+		o.idle.Add(-1)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				break
@@ -127,37 +253,60 @@ func (o *Orders) processing() {
 			continue
 		}
 
-		order, err = o.tryProcessOrder(ctx, order)
+		o.lastDequeue.Store(time.Now().UnixNano())
+		o.updateQueueDepth()
+
+		orderCtx := logging.WithRequestID(ctx, queued.requestID)
+		orderCtx = tracing.ContextWithRemote(orderCtx, queued.trace)
+
+		orderCtx, span := tracing.Start(orderCtx, "orders.poll", tracing.KindInternal,
+			tracing.String("order.number", string(queued.order.Number)),
+		)
+		queued.order, err = o.tryProcessOrder(orderCtx, queued.order)
+		if !errors.Is(err, context.Canceled) {
+			span.RecordError(err)
+		}
+		span.End()
+
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
+				// Прерванный заказ возвращается в очередь, чтобы Close
+				// сохранил его.
+				_ = o.orders.Enqueue(context.Background(), queued)
 				break
 			}
-			slog.Debug(err.Error(), slog.String("scope", "updating order"))
+			logging.FromContext(orderCtx).Debug(err.Error(), slog.String("scope", "updating order"))
 		}
-		if order.IsEmpty() {
+		if queued.order.IsEmpty() {
 			continue
 		}
 
-		err = o.orders.Enqueue(ctx, order)
-		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				break
-			}
-			slog.Debug(
-				err.Error(),
-				slog.String("scope", "queue"),
-				slog.String("method", "enqueu"),
-			)
-		}
+		// Заказ возвращается в очередь и при остановке: Enqueue не ждёт
+		// потребителей, поэтому контекст без отмены не блокирует обработчик.
+		_ = o.orders.Enqueue(context.Background(), queued)
+		o.updateQueueDepth()
 	}
 }
 
+// updateQueueDepth обновляет метрику глубины очереди.
+func (o *Orders) updateQueueDepth() {
+	ordersQueueDepth.With().Set(float64(o.orders.Size()))
+}
+
 func (o *Orders) tryProcessOrder(ctx context.Context, order domain.Order) (domain.Order, error) {
 	info, err := o.accrual.GetAccrualInfo(ctx, order.Number)
 	if err != nil {
+		var exhausted *domain.ResourceExhaustedError
+		if errors.As(err, &exhausted) {
+			ordersPolls.With(pollRateLimited).Inc()
+		} else {
+			ordersPolls.With(pollError).Inc()
+		}
 		return order, err
 	}
 
+	ordersPolls.With(pollOutcome(info.Status)).Inc()
+
 	switch info.Status {
 	case domain.AccrualStatusUnknown:
 		return order, nil
@@ -183,9 +332,137 @@ func (o *Orders) tryProcessOrder(ctx context.Context, order domain.Order) (domai
 	return domain.Order{}, nil
 }
 
+// pollOutcome возвращает результат опроса accrual по статусу начисления.
+func pollOutcome(status domain.AccrualStatus) string {
+	switch status {
+	case domain.AccrualStatusProcessed:
+		return pollProcessed
+	case domain.AccrualStatusInvalid:
+		return pollInvalid
+	case domain.AccrualStatusRegistered, domain.AccrualStatusProcessing:
+		return pollPending
+	default:
+		return pollUnknown
+	}
+}
+
+// CountByStatus возвращает количество заказов по статусам.
+func (o *Orders) CountByStatus(ctx context.Context) (map[domain.OrderStatus]int, error) {
+	query := "SELECT status, count(*) FROM orders GROUP BY status;"
+
+	rows, err := o.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("counting orders: %w", errorHandling(err))
+	}
+	defer rows.Close()
+
+	counts := make(map[domain.OrderStatus]int)
+
+	for rows.Next() {
+		var (
+			status domain.OrderStatus
+			count  int
+		)
+		err = rows.Scan(&status, &count)
+		if err != nil {
+			return nil, fmt.Errorf("copying order counts: %w", errorHandling(err))
+		}
+		counts[status] = count
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errorHandling(err)
+	}
+
+	return counts, nil
+}
+
+// saveQueue сохраняет заказы из очереди; уже сохранённые заказы
+// пропускаются.
+func saveQueue(ctx context.Context, db *sql.DB, queued []queuedOrder) error {
+	query := `INSERT INTO order_queue (order_number, request_id, traceparent)
+	SELECT * FROM unnest($1::varchar[], $2::varchar[], $3::varchar[])
+	ON CONFLICT (order_number) DO NOTHING;`
+
+	numbers := make([]string, len(queued))
+	requestIDs := make([]string, len(queued))
+	traceparents := make([]string, len(queued))
+
+	for i, q := range queued {
+		numbers[i] = string(q.order.Number)
+		requestIDs[i] = q.requestID
+		if q.trace.IsValid() {
+			traceparents[i] = q.trace.Traceparent()
+		}
+	}
+
+	_, err := db.ExecContext(ctx, query,
+		pq.Array(numbers), pq.Array(requestIDs), pq.Array(traceparents))
+	if err != nil {
+		return fmt.Errorf("saving queued orders: %w", errorHandling(err))
+	}
+
+	return nil
+}
+
+// loadQueue извлекает сохранённые заказы, которые ещё не обработаны.
+// Записи удаляются в том же запросе, поэтому при одновременном запуске
+// нескольких экземпляров каждый заказ восстанавливает только один из них.
+func loadQueue(ctx context.Context, db *sql.DB) ([]queuedOrder, error) {
+	query := `WITH claimed AS (
+		DELETE FROM order_queue RETURNING order_number, request_id, traceparent
+	)
+	SELECT
+		o.order_number, o.user_created, o.status, o.accrual, o.created_at,
+		c.request_id, c.traceparent
+	FROM claimed c
+	JOIN orders o ON o.order_number = c.order_number
+	WHERE o.status IN ('NEW', 'PROCESSING');`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("queued orders search: %w", errorHandling(err))
+	}
+	defer rows.Close()
+
+	var queued []queuedOrder
+
+	for rows.Next() {
+		var (
+			q           queuedOrder
+			traceparent string
+		)
+		err = rows.Scan(
+			&q.order.Number,
+			&q.order.UserID,
+			&q.order.Status,
+			&q.order.Accrual,
+			&q.order.UploadedAt,
+			&q.requestID,
+			&traceparent,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("copying queued order fields: %w", errorHandling(err))
+		}
+
+		// Трасса продолжается, если контекст удалось разобрать.
+		q.trace, _ = tracing.ParseTraceparent(traceparent)
+
+		queued = append(queued, q)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, errorHandling(err)
+	}
+
+	return queued, nil
+}
+
 func getOrdersByUser(ctx context.Context, db *sql.DB, id domain.UserID) ([]domain.Order, error) {
 	query := `SELECT
-		order_number, status, accrual, created_at
+		order_number, status, accrual, created_at, store, purchased_at
 	FROM orders
 	WHERE user_created = $1;`
 
@@ -200,16 +477,24 @@ func getOrdersByUser(ctx context.Context, db *sql.DB, id domain.UserID) ([]domai
 	for rows.Next() {
 		order := domain.Order{UserID: id}
 
+		var purchasedAt sql.NullTime
+
 		err = rows.Scan(
 			&order.Number,
 			&order.Status,
 			&order.Accrual,
 			&order.UploadedAt,
+			&order.Store,
+			&purchasedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("copying order fields: %w", errorHandling(err))
 		}
 
+		if purchasedAt.Valid {
+			order.PurchasedAt = &purchasedAt.Time
+		}
+
 		orders = append(orders, order)
 	}
 
@@ -227,7 +512,8 @@ func getOrdersByUser(ctx context.Context, db *sql.DB, id domain.UserID) ([]domai
 
 func createOrder(ctx context.Context, db *sql.DB, order domain.Order) error {
 	query1 := "SELECT user_created FROM orders WHERE order_number = $1;"
-	query2 := "INSERT INTO orders (order_number, user_created) VALUES ($1, $2);"
+	query2 := `INSERT INTO orders (order_number, user_created, store, purchased_at)
+	VALUES ($1, $2, $3, $4);`
 
 	// Запускаем транзакцию, чтобы сначала проверить наличие в БД добавляемого
 	// номера заказа и кто его добавил, а затем добавляем запись, если ее нет.
@@ -249,7 +535,7 @@ func createOrder(ctx context.Context, db *sql.DB, order domain.Order) error {
 			return domain.ErrDuplicateOtherUser
 		}
 
-		_, err = tx.ExecContext(ctx, query2, order.Number, order.UserID)
+		_, err = tx.ExecContext(ctx, query2, order.Number, order.UserID, order.Store, order.PurchasedAt)
 		if err != nil {
 			return fmt.Errorf("creating a new order: %w", errorHandling(err))
 		}
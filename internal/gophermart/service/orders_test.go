@@ -7,6 +7,7 @@ import (
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
@@ -36,7 +37,7 @@ func (suite *OrderSuite) SetupSuite() {
 	suite.accrual = mock_domain.NewMockAccrualClient(suite.ctrl)
 	suite.orders = service.NewOrders(suite.CommonSuite.db, suite.accrual)
 
-	auth := service.NewAuth(suite.CommonSuite.db)
+	auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{})
 
 	var err error
 	suite.userID, err = auth.SignUp(
@@ -50,7 +51,7 @@ func (suite *OrderSuite) SetupSuite() {
 
 func (suite *OrderSuite) TearDownSuite() {
 	suite.CommonSuite.TearDownSuite()
-	suite.orders.Close()
+	suite.orders.Close(context.Background())
 }
 
 func (suite *OrderSuite) TestA_Process() {
@@ -164,3 +165,97 @@ func (suite *OrderSuite) TestB_GetOrders() {
 		}
 	})
 }
+
+func (suite *OrderSuite) TestC_CountByStatus() {
+	counts, err := suite.orders.CountByStatus(context.Background())
+	if suite.NoError(err) {
+		suite.Equal(map[domain.OrderStatus]int{
+			domain.OrderStatusProcessed: 2,
+			domain.OrderStatusInvalid:   1,
+		}, counts)
+	}
+}
+
+func (suite *OrderSuite) TestD_CloseAndRestore() {
+	ctx := context.Background()
+
+	order := domain.Order{
+		UserID: suite.userID,
+		Number: domain.OrderNumber("5"),
+	}
+
+	ctrl := gomock.NewController(suite.T())
+	accrual := mock_domain.NewMockAccrualClient(ctrl)
+
+	polling := make(chan struct{})
+	release := make(chan struct{})
+
+	accrual.EXPECT().GetAccrualInfo(gomock.Any(), order.Number).DoAndReturn(
+		func(context.Context, domain.OrderNumber) (domain.AccrualInfo, error) {
+			close(polling)
+			<-release
+			return domain.AccrualInfo{
+				OrderNumber: order.Number,
+				Status:      domain.AccrualStatusRegistered,
+			}, nil
+		},
+	).Times(1)
+
+	orders := service.NewOrders(suite.CommonSuite.db, accrual)
+	suite.Require().NoError(orders.Process(ctx, order))
+
+	<-polling
+
+	// Остановка дожидается текущего опроса и сохраняет заказ, оставшийся
+	// в очереди.
+	closed := make(chan error)
+	go func() {
+		closeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		closed <- orders.Close(closeCtx)
+	}()
+
+	close(release)
+	suite.Require().NoError(<-closed)
+	ctrl.Finish()
+
+	ctrl = gomock.NewController(suite.T())
+	accrual = mock_domain.NewMockAccrualClient(ctrl)
+
+	accrual.EXPECT().GetAccrualInfo(gomock.Any(), order.Number).Return(
+		domain.AccrualInfo{
+			OrderNumber: order.Number,
+			Status:      domain.AccrualStatusProcessed,
+			Accrual:     monetary.Format(500),
+		}, nil,
+	).Times(1)
+
+	restored := service.NewOrders(suite.CommonSuite.db, accrual)
+
+	n, err := restored.Restore(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(1, n)
+
+	time.Sleep(time.Second)
+	suite.NoError(restored.Close(ctx))
+	ctrl.Finish()
+
+	// Обработанный заказ при остановке не сохраняется.
+	n, err = restored.Restore(ctx)
+	if suite.NoError(err) {
+		suite.Zero(n)
+	}
+}
+
+func TestOrders_CheckQueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	orders := service.NewOrders(nil, mock_domain.NewMockAccrualClient(ctrl))
+
+	require.NoError(t, orders.CheckQueue(context.Background()))
+
+	require.NoError(t, orders.Close(context.Background()))
+
+	require.Error(t, orders.CheckQueue(context.Background()))
+}
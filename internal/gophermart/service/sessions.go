@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+)
+
+// Длина секретного токена (токена обновления или ключа API) в байтах.
+const secretTokenSize = 32
+
+// newSecretToken генерирует новый секретный токен.
+func newSecretToken() (string, error) {
+	buf := make([]byte, secretTokenSize)
+	_, err := io.ReadFull(rand.Reader, buf)
+	if err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashSecretToken возвращает хеш-сумму секретного токена для хранения в БД.
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func createSession(
+	ctx context.Context,
+	db *sql.DB,
+	session domain.Session,
+) (domain.Session, error) {
+	query := `WITH s AS (
+		INSERT INTO sessions (user_id, refresh_token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, user_id
+	)
+	SELECT s.id, u.role FROM s JOIN users u ON u.id = s.user_id;`
+
+	err := db.QueryRowContext(
+		ctx,
+		query,
+		session.UserID,
+		hashSecretToken(session.RefreshToken),
+		session.ExpiresAt,
+	).Scan(&session.ID, &session.Role)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("creating a new session: %w", errorHandling(err))
+	}
+
+	return session, nil
+}
+
+// rotateSession заменяет токен обновления сеанса и продлевает сеанс до
+// session.ExpiresAt, но не дальше maxLifetime с момента создания сеанса.
+func rotateSession(
+	ctx context.Context,
+	db *sql.DB,
+	oldRefreshToken string,
+	session domain.Session,
+	maxLifetime time.Duration,
+) (domain.Session, error) {
+	query := `UPDATE sessions s
+	SET refresh_token_hash = $1,
+		expires_at = LEAST($2, s.created_at + make_interval(secs => $4)),
+		updated_at = now()
+	FROM users u
+	WHERE u.id = s.user_id
+		AND s.refresh_token_hash = $3 AND s.revoked_at IS NULL AND s.expires_at > now()
+		AND s.created_at + make_interval(secs => $4) > now()
+	RETURNING s.id, s.user_id, u.role, s.expires_at;`
+
+	err := db.QueryRowContext(
+		ctx,
+		query,
+		hashSecretToken(session.RefreshToken),
+		session.ExpiresAt,
+		hashSecretToken(oldRefreshToken),
+		maxLifetime.Seconds(),
+	).Scan(&session.ID, &session.UserID, &session.Role, &session.ExpiresAt)
+	if err != nil {
+		return domain.Session{}, fmt.Errorf("updating a session: %w", errorHandling(err))
+	}
+	session.ExpiresAt = session.ExpiresAt.UTC()
+
+	return session, nil
+}
+
+// getActiveSessionIdentity возвращает идентификационные данные активного
+// сеанса и время его истечения.
+func getActiveSessionIdentity(
+	ctx context.Context,
+	db *sql.DB,
+	id domain.SessionID,
+) (domain.Identity, time.Time, error) {
+	identity := domain.Identity{SessionID: id}
+	var expiresAt time.Time
+
+	query := `SELECT s.user_id, u.role, s.expires_at
+	FROM sessions s
+	JOIN users u ON u.id = s.user_id
+	WHERE s.id = $1 AND s.revoked_at IS NULL AND s.expires_at > now();`
+
+	err := db.QueryRowContext(ctx, query, id).Scan(&identity.UserID, &identity.Role, &expiresAt)
+	if err != nil {
+		return domain.Identity{}, time.Time{}, fmt.Errorf("session search: %w", errorHandling(err))
+	}
+
+	return identity, expiresAt, nil
+}
+
+func revokeSession(ctx context.Context, db *sql.DB, identity domain.Identity) error {
+	query := `UPDATE sessions
+	SET revoked_at = now(), updated_at = now()
+	WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;`
+
+	res, err := db.ExecContext(ctx, query, identity.SessionID, identity.UserID)
+	if err != nil {
+		return fmt.Errorf("revoking a session: %w", errorHandling(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("revoking a session: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,608 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/pkg/totp"
+)
+
+var _ domain.TwoFactorService = (*TwoFactor)(nil)
+
+// Значения по умолчанию для TwoFactorOptions.
+const (
+	defaultTOTPIssuer         = "Gophermart"
+	defaultChallengeTTL       = 5 * time.Minute
+	defaultChallengeAttempts  = 5
+	defaultCodeAttempts       = 5
+	defaultCodeLockout        = 15 * time.Minute
+	defaultRecoveryCodesCount = 10
+)
+
+// TwoFactorOptions определяет опции сервиса двухфакторной аутентификации.
+type TwoFactorOptions struct {
+	// Издатель, отображаемый в приложении аутентификации.
+	//
+	// По умолчанию Gophermart.
+	Issuer string
+
+	// Параметры кодов TOTP.
+	TOTP totp.Config
+
+	// Время жизни запроса второго фактора при входе.
+	//
+	// По умолчанию 5 минут.
+	ChallengeTTL time.Duration
+
+	// Количество попыток ответа на запрос второго фактора.
+	//
+	// По умолчанию 5.
+	ChallengeAttempts int
+
+	// Количество неверных кодов подряд при входе и подтверждении действий
+	// (включение и отключение второго фактора, списание свыше порога), после
+	// которого проверка кодов пользователя блокируется на CodeLockout.
+	//
+	// По умолчанию 5.
+	CodeAttempts int
+
+	// Время блокировки проверки кодов после CodeAttempts неверных кодов.
+	//
+	// По умолчанию 15 минут.
+	CodeLockout time.Duration
+
+	// Количество кодов восстановления.
+	//
+	// По умолчанию 10.
+	RecoveryCodes int
+}
+
+// TwoFactor определяет сервис двухфакторной аутентификации.
+type TwoFactor struct {
+	db   *sql.DB
+	totp totp.Config
+
+	issuer            string
+	challengeTTL      time.Duration
+	challengeAttempts int
+	codeAttempts      int
+	codeLockout       time.Duration
+	recoveryCodes     int
+}
+
+// NewTwoFactor возвращает новый экземпляр TwoFactor.
+func NewTwoFactor(db *sql.DB, opts TwoFactorOptions) *TwoFactor {
+	if opts.Issuer == "" {
+		opts.Issuer = defaultTOTPIssuer
+	}
+	if opts.ChallengeTTL <= 0 {
+		opts.ChallengeTTL = defaultChallengeTTL
+	}
+	if opts.ChallengeAttempts <= 0 {
+		opts.ChallengeAttempts = defaultChallengeAttempts
+	}
+	if opts.CodeAttempts <= 0 {
+		opts.CodeAttempts = defaultCodeAttempts
+	}
+	if opts.CodeLockout <= 0 {
+		opts.CodeLockout = defaultCodeLockout
+	}
+	if opts.RecoveryCodes <= 0 {
+		opts.RecoveryCodes = defaultRecoveryCodesCount
+	}
+	return &TwoFactor{
+		db:                db,
+		totp:              opts.TOTP,
+		issuer:            opts.Issuer,
+		challengeTTL:      opts.ChallengeTTL,
+		challengeAttempts: opts.ChallengeAttempts,
+		codeAttempts:      opts.CodeAttempts,
+		codeLockout:       opts.CodeLockout,
+		recoveryCodes:     opts.RecoveryCodes,
+	}
+}
+
+// Enroll реализует интерфейс domain.TwoFactorService.
+func (f *TwoFactor) Enroll(ctx context.Context, id domain.UserID) (domain.TOTPEnrollment, error) {
+	user, err := getUserByID(ctx, f.db, id)
+	if err != nil {
+		return domain.TOTPEnrollment{}, fmt.Errorf("user search: %w", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return domain.TOTPEnrollment{}, fmt.Errorf("generating a secret: %w", err)
+	}
+
+	codes := make([]string, f.recoveryCodes)
+	for i := range codes {
+		codes[i], err = newRecoveryCode()
+		if err != nil {
+			return domain.TOTPEnrollment{}, fmt.Errorf("generating a recovery code: %w", err)
+		}
+	}
+
+	err = enrollTOTP(ctx, f.db, id, totp.EncodeSecret(secret), codes)
+	if err != nil {
+		return domain.TOTPEnrollment{}, fmt.Errorf("enrolling TOTP: %w", err)
+	}
+
+	return domain.TOTPEnrollment{
+		Secret:        totp.EncodeSecret(secret),
+		URI:           f.totp.URI(f.issuer, user.Login, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// Enable реализует интерфейс domain.TwoFactorService.
+func (f *TwoFactor) Enable(ctx context.Context, id domain.UserID, code string) error {
+	query := "UPDATE user_totp SET enabled_at = now() WHERE user_id = $1;"
+
+	err := f.confirm(ctx, id, "enable", func(tx *sql.Tx) error {
+		err := f.useTOTPCode(ctx, tx, id, code, false)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return fmt.Errorf("enabling TOTP: %w", errorHandling(err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("enabling two-factor authentication: %w", err)
+	}
+
+	return nil
+}
+
+// Disable реализует интерфейс domain.TwoFactorService.
+func (f *TwoFactor) Disable(ctx context.Context, id domain.UserID, code string) error {
+	query1 := "DELETE FROM user_totp WHERE user_id = $1;"
+	query2 := "DELETE FROM recovery_codes WHERE user_id = $1;"
+
+	err := f.confirm(ctx, id, "disable", func(tx *sql.Tx) error {
+		err := f.useCode(ctx, tx, id, code)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, query1, id)
+		if err != nil {
+			return fmt.Errorf("deleting TOTP: %w", errorHandling(err))
+		}
+
+		_, err = tx.ExecContext(ctx, query2, id)
+		if err != nil {
+			return fmt.Errorf("deleting recovery codes: %w", errorHandling(err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("disabling two-factor authentication: %w", err)
+	}
+
+	return nil
+}
+
+// Verify реализует интерфейс domain.TwoFactorService.
+func (f *TwoFactor) Verify(ctx context.Context, id domain.UserID, code string) error {
+	err := f.confirm(ctx, id, "verify", func(tx *sql.Tx) error {
+		return f.useTOTPCode(ctx, tx, id, code, true)
+	})
+	if err != nil {
+		return fmt.Errorf("verifying a two-factor code: %w", err)
+	}
+	return nil
+}
+
+// Challenge реализует интерфейс domain.TwoFactorService.
+func (f *TwoFactor) Challenge(
+	ctx context.Context,
+	id domain.UserID,
+) (domain.TwoFactorChallenge, error) {
+	token, err := newSecretToken()
+	if err != nil {
+		return domain.TwoFactorChallenge{}, fmt.Errorf("generating a challenge token: %w", err)
+	}
+
+	challenge := domain.TwoFactorChallenge{
+		Token:     token,
+		ExpiresAt: time.Now().Add(f.challengeTTL).UTC(),
+	}
+
+	query := `INSERT INTO login_challenges (user_id, token_hash, expires_at)
+	SELECT user_id, $2, $3 FROM user_totp WHERE user_id = $1 AND enabled_at IS NOT NULL;`
+
+	res, err := f.db.ExecContext(ctx, query, id, hashSecretToken(token), challenge.ExpiresAt)
+	if err != nil {
+		return domain.TwoFactorChallenge{}, fmt.Errorf("creating a challenge: %w", errorHandling(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return domain.TwoFactorChallenge{}, fmt.Errorf("creating a challenge: %w", err)
+	}
+	if n == 0 {
+		return domain.TwoFactorChallenge{}, domain.ErrTwoFactorDisabled
+	}
+
+	return challenge, nil
+}
+
+// CompleteChallenge реализует интерфейс domain.TwoFactorService.
+func (f *TwoFactor) CompleteChallenge(
+	ctx context.Context,
+	resp domain.TwoFactorResponse,
+) (domain.UserID, error) {
+	var (
+		challengeID string
+		userID      domain.UserID
+		invalid     bool
+	)
+
+	query1 := `SELECT id, user_id
+	FROM login_challenges
+	WHERE token_hash = $1 AND expires_at > now()
+	FOR UPDATE;`
+	query2 := "DELETE FROM login_challenges WHERE id = $1;"
+
+	// Неверные коды учитываются не только в запросе, но и в общем счётчике
+	// пользователя: иначе, запрашивая новые запросы при входе, коды можно
+	// подбирать без ограничений.
+	err := transaction(ctx, f.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query1, hashSecretToken(resp.Token)).Scan(&challengeID, &userID)
+		if err != nil {
+			return fmt.Errorf("challenge search: %w", errorHandling(err))
+		}
+
+		err = checkCodeAttempts(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		err = f.useCode(ctx, tx, userID, resp.Code)
+		if errors.Is(err, domain.ErrInvalidCode) {
+			invalid = true
+
+			err = f.registerCodeFailure(ctx, tx, userID, "login")
+			if err != nil {
+				return err
+			}
+
+			return f.registerChallengeFailure(ctx, tx, challengeID)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = resetCodeAttempts(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, query2, challengeID)
+		if err != nil {
+			return fmt.Errorf("deleting a challenge: %w", errorHandling(err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return domain.EmptyUserID, fmt.Errorf("completing a challenge: %w", err)
+	}
+	if invalid {
+		return domain.EmptyUserID, fmt.Errorf("completing a challenge: %w", domain.ErrInvalidCode)
+	}
+
+	return userID, nil
+}
+
+// registerChallengeFailure увеличивает счётчик неудачных ответов на запрос
+// и удаляет запрос, если попытки исчерпаны.
+func (f *TwoFactor) registerChallengeFailure(ctx context.Context, tx *sql.Tx, challengeID string) error {
+	query1 := "UPDATE login_challenges SET attempts = attempts + 1 WHERE id = $1;"
+	query2 := "DELETE FROM login_challenges WHERE id = $1 AND attempts >= $2;"
+
+	_, err := tx.ExecContext(ctx, query1, challengeID)
+	if err != nil {
+		return fmt.Errorf("registering a challenge failure: %w", errorHandling(err))
+	}
+
+	_, err = tx.ExecContext(ctx, query2, challengeID, f.challengeAttempts)
+	if err != nil {
+		return fmt.Errorf("registering a challenge failure: %w", errorHandling(err))
+	}
+
+	return nil
+}
+
+// confirm выполняет use в транзакции с учётом неверных кодов пользователя
+// id: пока проверка кодов заблокирована, возвращает
+// *domain.ResourceExhaustedError; неверный код фиксируется вместе с событием
+// безопасности, а верный сбрасывает счётчик. Строка user_totp блокируется
+// до конца транзакции, поэтому параллельные попытки учитываются по очереди.
+func (f *TwoFactor) confirm(
+	ctx context.Context,
+	id domain.UserID,
+	action string,
+	use func(tx *sql.Tx) error,
+) error {
+	var invalid bool
+
+	err := transaction(ctx, f.db, func(tx *sql.Tx) error {
+		err := checkCodeAttempts(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		err = use(tx)
+		if errors.Is(err, domain.ErrInvalidCode) {
+			invalid = true
+			return f.registerCodeFailure(ctx, tx, id, action)
+		}
+		if err != nil {
+			return err
+		}
+
+		return resetCodeAttempts(ctx, tx, id)
+	})
+	if err != nil {
+		return err
+	}
+	if invalid {
+		return domain.ErrInvalidCode
+	}
+
+	return nil
+}
+
+// checkCodeAttempts возвращает *domain.ResourceExhaustedError, если
+// проверка кодов пользователя заблокирована.
+func checkCodeAttempts(ctx context.Context, tx *sql.Tx, id domain.UserID) error {
+	query := `SELECT COALESCE(EXTRACT(EPOCH FROM code_blocked_until - now()), 0)
+	FROM user_totp
+	WHERE user_id = $1
+	FOR UPDATE;`
+
+	var seconds float64
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(&seconds)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("code attempts search: %w", errorHandling(err))
+	}
+
+	if seconds > 0 {
+		return &domain.ResourceExhaustedError{
+			Message:    "too many invalid two-factor codes",
+			RetryAfter: time.Duration(math.Ceil(seconds)) * time.Second,
+		}
+	}
+
+	return nil
+}
+
+// registerCodeFailure учитывает неверный код пользователя и блокирует
+// проверку кодов, если попытки исчерпаны.
+func (f *TwoFactor) registerCodeFailure(
+	ctx context.Context,
+	tx *sql.Tx,
+	id domain.UserID,
+	action string,
+) error {
+	query := `UPDATE user_totp SET
+		code_attempts = CASE WHEN code_attempts + 1 >= $2 THEN 0 ELSE code_attempts + 1 END,
+		code_blocked_until = CASE
+			WHEN code_attempts + 1 >= $2 THEN now() + make_interval(secs => $3)
+			ELSE code_blocked_until
+		END
+	WHERE user_id = $1
+	RETURNING code_attempts = 0;`
+
+	var blocked bool
+
+	err := tx.QueryRowContext(ctx, query, id, f.codeAttempts, f.codeLockout.Seconds()).Scan(&blocked)
+	if err != nil {
+		return fmt.Errorf("registering a code failure: %w", errorHandling(err))
+	}
+
+	details := action + ": invalid code"
+	if blocked {
+		details += fmt.Sprintf(", blocked for %s", f.codeLockout)
+	}
+
+	return recordSecurityEvent(ctx, tx, domain.SecurityEvent{
+		Type:    domain.EventTwoFactorFailed,
+		UserID:  id,
+		Details: details,
+	})
+}
+
+// resetCodeAttempts сбрасывает счётчик неверных кодов пользователя.
+func resetCodeAttempts(ctx context.Context, tx *sql.Tx, id domain.UserID) error {
+	query := "UPDATE user_totp SET code_attempts = 0 WHERE user_id = $1;"
+
+	_, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("resetting code attempts: %w", errorHandling(err))
+	}
+
+	return nil
+}
+
+// useCode принимает код TOTP или код восстановления включённого второго
+// фактора.
+func (f *TwoFactor) useCode(ctx context.Context, tx *sql.Tx, id domain.UserID, code string) error {
+	err := f.useTOTPCode(ctx, tx, id, code, true)
+	if !errors.Is(err, domain.ErrInvalidCode) {
+		return err
+	}
+	return useRecoveryCode(ctx, tx, id, code)
+}
+
+// useTOTPCode проверяет код TOTP и запоминает его период, чтобы код нельзя
+// было использовать повторно; если enabled равен true, то второй фактор
+// должен быть включён.
+func (f *TwoFactor) useTOTPCode(
+	ctx context.Context,
+	tx *sql.Tx,
+	id domain.UserID,
+	code string,
+	enabled bool,
+) error {
+	var (
+		encodedSecret string
+		lastCounter   uint64
+		enabledAt     sql.NullTime
+	)
+
+	query1 := `SELECT secret, last_counter, enabled_at
+	FROM user_totp
+	WHERE user_id = $1
+	FOR UPDATE;`
+	query2 := "UPDATE user_totp SET last_counter = $1 WHERE user_id = $2;"
+
+	err := tx.QueryRowContext(ctx, query1, id).Scan(&encodedSecret, &lastCounter, &enabledAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.ErrTwoFactorDisabled
+		}
+		return fmt.Errorf("TOTP search: %w", errorHandling(err))
+	}
+	if enabled && !enabledAt.Valid {
+		return domain.ErrTwoFactorDisabled
+	}
+
+	secret, err := totp.DecodeSecret(encodedSecret)
+	if err != nil {
+		return err
+	}
+
+	counter, ok := f.totp.Verify(secret, code, time.Now())
+	if !ok || counter <= lastCounter {
+		return domain.ErrInvalidCode
+	}
+
+	_, err = tx.ExecContext(ctx, query2, counter, id)
+	if err != nil {
+		return fmt.Errorf("updating TOTP: %w", errorHandling(err))
+	}
+
+	return nil
+}
+
+func enrollTOTP(
+	ctx context.Context,
+	db *sql.DB,
+	id domain.UserID,
+	secret string,
+	recoveryCodes []string,
+) error {
+	query1 := `INSERT INTO user_totp (user_id, secret)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE
+	SET secret = excluded.secret, last_counter = 0, created_at = now()
+	WHERE user_totp.enabled_at IS NULL;`
+	query2 := "DELETE FROM recovery_codes WHERE user_id = $1;"
+	query3 := "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2);"
+
+	return transaction(ctx, db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query1, id, secret)
+		if err != nil {
+			return fmt.Errorf("saving a secret: %w", errorHandling(err))
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("saving a secret: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: two-factor authentication is already enabled", domain.ErrDuplicate)
+		}
+
+		_, err = tx.ExecContext(ctx, query2, id)
+		if err != nil {
+			return fmt.Errorf("deleting recovery codes: %w", errorHandling(err))
+		}
+
+		for _, code := range recoveryCodes {
+			_, err = tx.ExecContext(ctx, query3, id, hashRecoveryCode(code))
+			if err != nil {
+				return fmt.Errorf("saving a recovery code: %w", errorHandling(err))
+			}
+		}
+
+		return nil
+	})
+}
+
+func useRecoveryCode(ctx context.Context, tx *sql.Tx, id domain.UserID, code string) error {
+	query := `UPDATE recovery_codes
+	SET used_at = now()
+	WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;`
+
+	res, err := tx.ExecContext(ctx, query, id, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("using a recovery code: %w", errorHandling(err))
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("using a recovery code: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrInvalidCode
+	}
+
+	return nil
+}
+
+// Длина кода восстановления в байтах; в base32 даёт 16 символов.
+const recoveryCodeSize = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCode генерирует новый код восстановления вида xxxx-xxxx-xxxx-xxxx.
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, recoveryCodeSize)
+	_, err := io.ReadFull(rand.Reader, buf)
+	if err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+
+	s := strings.ToLower(recoveryEncoding.EncodeToString(buf))
+
+	groups := make([]string, 0, len(s)/4)
+	for i := 0; i < len(s); i += 4 {
+		groups = append(groups, s[i:i+4])
+	}
+
+	return strings.Join(groups, "-"), nil
+}
+
+// hashRecoveryCode возвращает хеш-сумму кода восстановления; регистр,
+// пробелы и дефисы не учитываются.
+func hashRecoveryCode(code string) string {
+	code = strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+	return hashSecretToken(code)
+}
@@ -0,0 +1,254 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
+	"github.com/sergeizaitcev/gophermart/internal/gophermart/service"
+	"github.com/sergeizaitcev/gophermart/pkg/totp"
+)
+
+type TwoFactorSuite struct {
+	CommonSuite
+
+	twoFactor  *service.TwoFactor
+	userID     domain.UserID
+	enrollment domain.TOTPEnrollment
+	challenge  domain.TwoFactorChallenge
+}
+
+func TestTwoFactor(t *testing.T) {
+	suite.Run(t, new(TwoFactorSuite))
+}
+
+func (suite *TwoFactorSuite) SetupSuite() {
+	suite.CommonSuite.SetupSuite()
+	suite.twoFactor = service.NewTwoFactor(suite.CommonSuite.db, service.TwoFactorOptions{})
+
+	auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{})
+
+	var err error
+	suite.userID, err = auth.SignUp(
+		context.Background(),
+		domain.Authentication{
+			Login:    "totp",
+			Password: "password",
+		},
+	)
+
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(suite.userID)
+}
+
+// code возвращает код TOTP для секрета, выданного при подключении; offset
+// смещает время на количество периодов.
+func (suite *TwoFactorSuite) code(offset int) string {
+	secret, err := totp.DecodeSecret(suite.enrollment.Secret)
+	suite.Require().NoError(err)
+	return totp.Config{}.Code(secret, time.Now().Add(time.Duration(offset)*totp.DefaultPeriod))
+}
+
+func (suite *TwoFactorSuite) TestA_Enroll() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		var err error
+		suite.enrollment, err = suite.twoFactor.Enroll(ctx, suite.userID)
+		if suite.NoError(err) {
+			suite.Contains(suite.enrollment.URI, "otpauth://totp/Gophermart:totp")
+			suite.Len(suite.enrollment.RecoveryCodes, 10)
+		}
+	})
+
+	suite.Run("challenge before enabling", func() {
+		_, err := suite.twoFactor.Challenge(ctx, suite.userID)
+		suite.ErrorIs(err, domain.ErrTwoFactorDisabled)
+	})
+}
+
+func (suite *TwoFactorSuite) TestB_Enable() {
+	ctx := context.Background()
+
+	suite.Run("invalid code", func() {
+		err := suite.twoFactor.Enable(ctx, suite.userID, "000000")
+		suite.ErrorIs(err, domain.ErrInvalidCode)
+	})
+
+	suite.Run("success", func() {
+		err := suite.twoFactor.Enable(ctx, suite.userID, suite.code(-1))
+		suite.NoError(err)
+	})
+
+	suite.Run("enroll again", func() {
+		_, err := suite.twoFactor.Enroll(ctx, suite.userID)
+		suite.ErrorIs(err, domain.ErrDuplicate)
+	})
+}
+
+func (suite *TwoFactorSuite) TestC_Verify() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		err := suite.twoFactor.Verify(ctx, suite.userID, suite.code(0))
+		suite.NoError(err)
+	})
+
+	suite.Run("replay", func() {
+		err := suite.twoFactor.Verify(ctx, suite.userID, suite.code(0))
+		suite.ErrorIs(err, domain.ErrInvalidCode)
+	})
+
+	suite.Run("recovery code is not accepted", func() {
+		err := suite.twoFactor.Verify(ctx, suite.userID, suite.enrollment.RecoveryCodes[0])
+		suite.ErrorIs(err, domain.ErrInvalidCode)
+	})
+
+	suite.Run("lockout", func() {
+		twoFactor := service.NewTwoFactor(suite.CommonSuite.db, service.TwoFactorOptions{
+			CodeAttempts: 2,
+			CodeLockout:  time.Minute,
+		})
+
+		// Успешная проверка сбрасывает счётчик неверных кодов.
+		suite.Require().NoError(twoFactor.Verify(ctx, suite.userID, suite.code(1)))
+
+		for i := 0; i < 2; i++ {
+			err := twoFactor.Verify(ctx, suite.userID, "000000")
+			suite.ErrorIs(err, domain.ErrInvalidCode)
+		}
+
+		// Пока проверка заблокирована, коды не проверяются.
+		err := twoFactor.Verify(ctx, suite.userID, "000000")
+
+		var exhausted *domain.ResourceExhaustedError
+		if suite.ErrorAs(err, &exhausted) {
+			suite.Greater(exhausted.RetryAfter, time.Duration(0))
+			suite.LessOrEqual(exhausted.RetryAfter, time.Minute)
+		}
+
+		var failures int
+		err = suite.CommonSuite.db.QueryRowContext(ctx,
+			"SELECT count(*) FROM security_events WHERE type = $1 AND user_id = $2;",
+			domain.EventTwoFactorFailed, suite.userID,
+		).Scan(&failures)
+		if suite.NoError(err) {
+			suite.GreaterOrEqual(failures, 2)
+		}
+
+		_, err = suite.CommonSuite.db.ExecContext(ctx,
+			"UPDATE user_totp SET code_blocked_until = NULL WHERE user_id = $1;",
+			suite.userID,
+		)
+		suite.Require().NoError(err)
+	})
+}
+
+func (suite *TwoFactorSuite) TestD_Challenge() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		var err error
+		suite.challenge, err = suite.twoFactor.Challenge(ctx, suite.userID)
+		if suite.NoError(err) {
+			suite.NotEmpty(suite.challenge.Token)
+		}
+	})
+
+	suite.Run("invalid code", func() {
+		_, err := suite.twoFactor.CompleteChallenge(ctx, domain.TwoFactorResponse{
+			Token: suite.challenge.Token,
+			Code:  "000000",
+		})
+		suite.ErrorIs(err, domain.ErrInvalidCode)
+	})
+
+	suite.Run("recovery code", func() {
+		uid, err := suite.twoFactor.CompleteChallenge(ctx, domain.TwoFactorResponse{
+			Token: suite.challenge.Token,
+			Code:  suite.enrollment.RecoveryCodes[0],
+		})
+		if suite.NoError(err) {
+			suite.Equal(suite.userID, uid)
+		}
+	})
+
+	suite.Run("lockout across challenges", func() {
+		twoFactor := service.NewTwoFactor(suite.CommonSuite.db, service.TwoFactorOptions{
+			ChallengeAttempts: 2,
+			CodeAttempts:      3,
+			CodeLockout:       time.Minute,
+		})
+
+		// Неверные коды в разных запросах учитываются в общем счётчике
+		// пользователя.
+		for i := 0; i < 2; i++ {
+			challenge, err := twoFactor.Challenge(ctx, suite.userID)
+			suite.Require().NoError(err)
+
+			for j := 0; j < 2; j++ {
+				_, err = twoFactor.CompleteChallenge(ctx, domain.TwoFactorResponse{
+					Token: challenge.Token,
+					Code:  "000000",
+				})
+				if i*2+j < 3 {
+					suite.ErrorIs(err, domain.ErrInvalidCode)
+				} else {
+					var exhausted *domain.ResourceExhaustedError
+					suite.ErrorAs(err, &exhausted)
+				}
+			}
+		}
+
+		// Пока проверка заблокирована, не принимается и верный код.
+		challenge, err := twoFactor.Challenge(ctx, suite.userID)
+		suite.Require().NoError(err)
+
+		_, err = twoFactor.CompleteChallenge(ctx, domain.TwoFactorResponse{
+			Token: challenge.Token,
+			Code:  suite.enrollment.RecoveryCodes[2],
+		})
+
+		var exhausted *domain.ResourceExhaustedError
+		if suite.ErrorAs(err, &exhausted) {
+			suite.Greater(exhausted.RetryAfter, time.Duration(0))
+			suite.LessOrEqual(exhausted.RetryAfter, time.Minute)
+		}
+
+		_, err = suite.CommonSuite.db.ExecContext(ctx,
+			"UPDATE user_totp SET code_blocked_until = NULL WHERE user_id = $1;",
+			suite.userID,
+		)
+		suite.Require().NoError(err)
+	})
+
+	suite.Run("completed", func() {
+		_, err := suite.twoFactor.CompleteChallenge(ctx, domain.TwoFactorResponse{
+			Token: suite.challenge.Token,
+			Code:  suite.enrollment.RecoveryCodes[1],
+		})
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
+
+func (suite *TwoFactorSuite) TestE_Disable() {
+	ctx := context.Background()
+
+	suite.Run("used recovery code", func() {
+		err := suite.twoFactor.Disable(ctx, suite.userID, suite.enrollment.RecoveryCodes[0])
+		suite.ErrorIs(err, domain.ErrInvalidCode)
+	})
+
+	suite.Run("success", func() {
+		err := suite.twoFactor.Disable(ctx, suite.userID, suite.enrollment.RecoveryCodes[1])
+		suite.NoError(err)
+	})
+
+	suite.Run("verify disabled", func() {
+		err := suite.twoFactor.Verify(ctx, suite.userID, suite.code(1))
+		suite.ErrorIs(err, domain.ErrTwoFactorDisabled)
+	})
+}
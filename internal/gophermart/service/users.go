@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/sergeizaitcev/gophermart/internal/gophermart/domain"
 )
@@ -28,3 +30,101 @@ func (u *Users) GetBalance(ctx context.Context, id domain.UserID) (domain.UserBa
 	}
 	return user.Balance, nil
 }
+
+// Export реализует интерфейс domain.UserService.
+func (u *Users) Export(ctx context.Context, id domain.UserID) (domain.UserExport, error) {
+	export, err := exportUser(ctx, u.db, id)
+	if err != nil {
+		return domain.UserExport{}, fmt.Errorf("exporting user data: %w", err)
+	}
+	return export, nil
+}
+
+// SetRole реализует интерфейс domain.UserService.
+func (u *Users) SetRole(ctx context.Context, login string, role domain.Role) error {
+	err := role.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = setUserRole(ctx, u.db, login, role)
+	if err != nil {
+		return fmt.Errorf("setting a role: %w", err)
+	}
+
+	return nil
+}
+
+func setUserRole(ctx context.Context, db *sql.DB, login string, role domain.Role) error {
+	query := `UPDATE users
+	SET role = $1
+	WHERE lower(login) = lower($2) AND deleted_at IS NULL
+	RETURNING id;`
+
+	return transaction(ctx, db, func(tx *sql.Tx) error {
+		var id domain.UserID
+
+		err := tx.QueryRowContext(ctx, query, role, login).Scan(&id)
+		if err != nil {
+			return errorHandling(err)
+		}
+
+		return recordSecurityEvent(ctx, tx, domain.SecurityEvent{
+			Type:    domain.EventRoleChanged,
+			UserID:  id,
+			Details: "role " + string(role),
+		})
+	})
+}
+
+func exportUser(ctx context.Context, db *sql.DB, id domain.UserID) (domain.UserExport, error) {
+	export := domain.UserExport{
+		ExportedAt: time.Now().UTC(),
+		Orders:     []domain.Order{},
+		Operations: []domain.Operation{},
+	}
+
+	profile := &export.Profile
+
+	query := `SELECT
+		u.id, u.login, u.role, u.current_balance, u.withdrawn_balance,
+		u.password_changed_at, t.enabled_at IS NOT NULL
+	FROM users u
+		LEFT JOIN user_totp t ON t.user_id = u.id
+	WHERE u.id = $1 AND u.deleted_at IS NULL;`
+
+	err := db.QueryRowContext(ctx, query, id).Scan(
+		&profile.ID,
+		&profile.Login,
+		&profile.Role,
+		&profile.Balance.Current,
+		&profile.Balance.Withdrawn,
+		&profile.PasswordChangedAt,
+		&profile.TwoFactorEnabled,
+	)
+	if err != nil {
+		return domain.UserExport{}, fmt.Errorf("user search: %w", errorHandling(err))
+	}
+
+	profile.APIKeys, err = getAPIKeys(ctx, db, id)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return domain.UserExport{}, fmt.Errorf("API keys search: %w", err)
+	}
+	if profile.APIKeys == nil {
+		profile.APIKeys = []domain.APIKey{}
+	}
+
+	orders, err := getOrdersByUser(ctx, db, id)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return domain.UserExport{}, err
+	}
+	export.Orders = append(export.Orders, orders...)
+
+	operations, err := getOperations(ctx, db, id)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return domain.UserExport{}, err
+	}
+	export.Operations = append(export.Operations, operations...)
+
+	return export, nil
+}
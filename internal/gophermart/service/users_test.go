@@ -25,7 +25,7 @@ func (suite *UserSuite) SetupSuite() {
 	suite.CommonSuite.SetupSuite()
 	suite.users = service.NewUsers(suite.CommonSuite.db)
 
-	auth := service.NewAuth(suite.CommonSuite.db)
+	auth := service.NewAuth(suite.CommonSuite.db, service.AuthOptions{})
 
 	var err error
 	suite.userID, err = auth.SignUp(
@@ -55,3 +55,42 @@ func (suite *UserSuite) TestGetBalance() {
 		suite.Error(err)
 	})
 }
+
+func (suite *UserSuite) TestSetRole() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		err := suite.users.SetRole(ctx, "LOGIN", domain.RoleAdmin)
+		suite.NoError(err)
+	})
+
+	suite.Run("unsupported role", func() {
+		err := suite.users.SetRole(ctx, "login", domain.Role("root"))
+		suite.Error(err)
+	})
+
+	suite.Run("not found", func() {
+		err := suite.users.SetRole(ctx, "unknown", domain.RoleAdmin)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
+
+func (suite *UserSuite) TestExport() {
+	ctx := context.Background()
+
+	suite.Run("success", func() {
+		export, err := suite.users.Export(ctx, suite.userID)
+		if suite.NoError(err) {
+			suite.Equal(suite.userID, export.Profile.ID)
+			suite.Equal("login", export.Profile.Login)
+			suite.False(export.Profile.TwoFactorEnabled)
+			suite.NotNil(export.Orders)
+			suite.NotNil(export.Operations)
+		}
+	})
+
+	suite.Run("not found", func() {
+		_, err := suite.users.Export(ctx, domain.EmptyUserID)
+		suite.ErrorIs(err, domain.ErrNotFound)
+	})
+}
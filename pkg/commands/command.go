@@ -62,9 +62,8 @@ func (cmd *Command[T]) usage() {
 }
 
 // parseFlags парсит флаги командной строки.
-func (cmd *Command[T]) parseFlags() error {
-	args := cleanArgs(os.Args[1:])
-	return cmd.fs.Parse(args)
+func (cmd *Command[T]) parseFlags(args []string) error {
+	return cmd.fs.Parse(cleanArgs(args))
 }
 
 func cleanArgs(args []string) []string {
@@ -87,9 +86,16 @@ func (cmd *Command[T]) parseEnv() error {
 	return env.Parse(&cmd.config)
 }
 
-// Execute запускает команду и блокируется до её завершения.
+// Execute запускает команду с аргументами командной строки процесса
+// и блокируется до её завершения.
 func (cmd *Command[T]) Execute(ctx context.Context) error {
-	err := cmd.parseFlags()
+	return cmd.ExecuteArgs(ctx, os.Args[1:])
+}
+
+// ExecuteArgs запускает команду с аргументами args и блокируется до её
+// завершения; используется для подкоманд.
+func (cmd *Command[T]) ExecuteArgs(ctx context.Context, args []string) error {
+	err := cmd.parseFlags(args)
 	if err != nil {
 		cmd.usage()
 		return nil
@@ -40,4 +40,18 @@ func ExampleCommand() {
 	//
 	// String=string
 	// Int64=2
-}
\ No newline at end of file
+}
+
+func ExampleCommand_ExecuteArgs() {
+	exec := func(_ context.Context, got *testConfig) error {
+		fmt.Printf("String=%s\n", got.String)
+		return nil
+	}
+
+	cmd := commands.New("test sub", exec)
+	cmd.ExecuteArgs(context.Background(), []string{"-string", "sub"})
+
+	// Output:
+	//
+	// String=sub
+}
@@ -0,0 +1,313 @@
+// Package compress реализует сжатие тел HTTP-запросов и ответов gzip.
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+)
+
+// CodeMalformedBody определяет код ошибки для запроса с повреждённым сжатым
+// телом; совпадает с кодом openapi.CodeMalformedBody.
+const CodeMalformedBody = "malformed_body"
+
+// Options определяет параметры сжатия ответов.
+type Options struct {
+	// Минимальный размер тела ответа для сжатия; ответы меньшего размера
+	// передаются без сжатия.
+	//
+	// По умолчанию 1024.
+	MinLength int
+
+	// Уровень сжатия gzip.
+	//
+	// По умолчанию gzip.DefaultCompression.
+	Level int
+
+	// Типы содержимого, ответы с которыми сжимаются; тип, оканчивающийся
+	// на "/", задаёт все подтипы.
+	//
+	// По умолчанию JSON, XML, JavaScript и текстовые типы.
+	ContentTypes []string
+}
+
+var defaultOptions = Options{
+	MinLength: 1024,
+	Level:     gzip.DefaultCompression,
+	ContentTypes: []string{
+		"text/",
+		"application/json",
+		"application/problem+json",
+		"application/javascript",
+		"application/xml",
+		"image/svg+xml",
+	},
+}
+
+// Gzip возвращает промежуточный обработчик, который распаковывает тела
+// запросов с Content-Encoding: gzip и сжимает ответы клиентам, принимающим
+// gzip, если размер и тип содержимого ответа подходят для сжатия. Запрос
+// с повреждённым сжатым телом отклоняется с http.StatusBadRequest и кодом
+// CodeMalformedBody.
+func Gzip(opts *Options) func(http.Handler) http.Handler {
+	o := defaultOptions
+	if opts != nil {
+		if opts.MinLength > 0 {
+			o.MinLength = opts.MinLength
+		}
+		if opts.Level != 0 {
+			o.Level = opts.Level
+		}
+		if len(opts.ContentTypes) > 0 {
+			o.ContentTypes = opts.ContentTypes
+		}
+	}
+
+	writers := &sync.Pool{
+		New: func() any {
+			gz, err := gzip.NewWriterLevel(io.Discard, o.Level)
+			if err != nil {
+				gz = gzip.NewWriter(io.Discard)
+			}
+			return gz
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if IsGzip(r.Header.Get("Content-Encoding")) {
+				body, err := gzip.NewReader(r.Body)
+				if err != nil {
+					p := problem.New(http.StatusBadRequest, CodeMalformedBody, "request body is malformed")
+					werr := p.Write(w, r)
+					if werr != nil {
+						logging.FromContext(r.Context()).Error(werr.Error())
+					}
+					return
+				}
+				defer body.Close()
+
+				r.Body = body
+				r.ContentLength = -1
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			if !AcceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &responseWriter{
+				ResponseWriter: w,
+				opts:           &o,
+				writers:        writers,
+			}
+			defer gw.close()
+
+			next.ServeHTTP(gw, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// IsGzip возвращает true, если значение заголовка Content-Encoding
+// указывает на сжатие gzip.
+func IsGzip(contentEncoding string) bool {
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "gzip" || coding == "x-gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsGzip возвращает true, если значение заголовка Accept-Encoding
+// разрешает ответ со сжатием gzip.
+func AcceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "x-gzip" && name != "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				f, err := strconv.ParseFloat(value, 64)
+				if err == nil {
+					q = f
+				}
+			}
+		}
+
+		return q > 0
+	}
+	return false
+}
+
+// responseWriter накапливает начало тела ответа, пока не наберётся
+// MinLength байт, и затем решает, сжимать ли ответ.
+type responseWriter struct {
+	http.ResponseWriter
+
+	opts    *Options
+	writers *sync.Pool
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code < http.StatusOK {
+		// Информационные ответы не влияют на итоговый ответ.
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = code
+	if !bodyAllowed(code) {
+		w.decide()
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.opts.MinLength {
+		err := w.decide()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush реализует интерфейс http.Flusher.
+func (w *responseWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap возвращает исходный http.ResponseWriter для http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide отправляет заголовки ответа и накопленное тело, сжимая его, если
+// ответ подходит для сжатия.
+func (w *responseWriter) decide() error {
+	w.decided = true
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	header := w.Header()
+	if len(w.buf) > 0 && header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if w.compressible() {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+
+		w.gz = w.writers.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *responseWriter) compressible() bool {
+	header := w.Header()
+
+	if !bodyAllowed(w.status) || len(w.buf) < w.opts.MinLength {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+
+	for _, t := range w.opts.ContentTypes {
+		if t == mediaType || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// close завершает ответ: отправляет недописанное тело и закрывает gzip.
+func (w *responseWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// Обработчик ничего не записал; net/http сам ответит 200.
+			return
+		}
+		w.decide()
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(io.Discard)
+		w.writers.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// bodyAllowed возвращает true, если ответ с кодом status может иметь тело.
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
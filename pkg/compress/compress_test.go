@@ -0,0 +1,163 @@
+package compress_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/compress"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestGzip_Request(t *testing.T) {
+	handler := compress.Gzip(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(b)
+	}))
+
+	t.Run("compressed", func(t *testing.T) {
+		body := gzipBytes(t, []byte("12345678903"))
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", "gzip")
+
+		handler.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "12345678903", rec.Body.String())
+	})
+
+	t.Run("corrupted", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("plain"))
+		req.Header.Set("Content-Encoding", "gzip")
+
+		handler.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+		require.Contains(t, rec.Body.String(), `"code":"malformed_body"`)
+	})
+}
+
+func TestGzip_Response(t *testing.T) {
+	large := `{"data":"` + strings.Repeat("a", 2048) + `"}`
+
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		status         int
+		body           string
+		compressed     bool
+	}{
+		{
+			name:           "compressed",
+			acceptEncoding: "gzip, deflate",
+			contentType:    "application/json",
+			status:         http.StatusOK,
+			body:           large,
+			compressed:     true,
+		},
+		{
+			name:        "not accepted",
+			contentType: "application/json",
+			status:      http.StatusOK,
+			body:        large,
+		},
+		{
+			name:           "rejected by quality",
+			acceptEncoding: "gzip;q=0",
+			contentType:    "application/json",
+			status:         http.StatusOK,
+			body:           large,
+		},
+		{
+			name:           "below threshold",
+			acceptEncoding: "gzip",
+			contentType:    "application/json",
+			status:         http.StatusOK,
+			body:           `{"data":"a"}`,
+		},
+		{
+			name:           "incompressible type",
+			acceptEncoding: "gzip",
+			contentType:    "image/png",
+			status:         http.StatusOK,
+			body:           large,
+		},
+		{
+			name:           "no content",
+			acceptEncoding: "gzip",
+			status:         http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := compress.Gzip(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.contentType != "" {
+					w.Header().Set("Content-Type", tc.contentType)
+				}
+				w.WriteHeader(tc.status)
+				// Тело пишется частями, чтобы проверить накопление буфера.
+				for i := 0; i < len(tc.body); i += 100 {
+					w.Write([]byte(tc.body[i:min(i+100, len(tc.body))]))
+				}
+			}))
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+
+			handler.ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code)
+			require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+
+			body := rec.Body.Bytes()
+			if tc.compressed {
+				require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+
+				gz, err := gzip.NewReader(bytes.NewReader(body))
+				require.NoError(t, err)
+				body, err = io.ReadAll(gz)
+				require.NoError(t, err)
+			} else {
+				require.Empty(t, rec.Header().Get("Content-Encoding"))
+			}
+
+			require.Equal(t, tc.body, string(body))
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	require.True(t, compress.AcceptsGzip("gzip"))
+	require.True(t, compress.AcceptsGzip("deflate, GZIP;q=0.5"))
+	require.True(t, compress.AcceptsGzip("*"))
+	require.False(t, compress.AcceptsGzip(""))
+	require.False(t, compress.AcceptsGzip("br, gzip;q=0"))
+}
@@ -0,0 +1,122 @@
+// Package grpcserver управляет жизненным циклом gRPC-сервера так же, как
+// httpserver управляет HTTP-сервером.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Server определяет gRPC-сервер.
+type Server struct {
+	Server *grpc.Server
+
+	// Время на завершение активных вызовов при остановке; по истечении
+	// соединения закрываются принудительно. По умолчанию 5s.
+	ShutdownTimeout time.Duration
+
+	mu         sync.Mutex
+	onShutdown []func()
+}
+
+const defaultShutdownTimeout = 5 * time.Second
+
+// New возвращает сервер с тайм-аутом остановки по умолчанию.
+func New(srv *grpc.Server) *Server {
+	return &Server{
+		Server:          srv,
+		ShutdownTimeout: defaultShutdownTimeout,
+	}
+}
+
+// RegisterOnShutdown регистрирует функцию, вызываемую в начале Shutdown
+// до закрытия слушателя.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.mu.Lock()
+	s.onShutdown = append(s.onShutdown, f)
+	s.mu.Unlock()
+}
+
+// ListenAndServe запускает сервер и блокируется до тех пор, пока не сработает
+// контекст или метод не вернёт ошибку.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	errc := make(chan error)
+	go func() { errc <- s.Serve(l) }()
+
+	select {
+	case <-ctx.Done():
+	case err := <-errc:
+		return err
+	}
+
+	select {
+	case err := <-errc:
+		return err
+	default:
+	}
+
+	err = s.Shutdown()
+	<-errc
+
+	return err
+}
+
+// Serve запускает сервер, прослушивая входящие соединения при помощи
+// net.Listener, и блокируется до тех пор, пока метод не вернёт ошибку.
+// После Shutdown возвращает nil.
+func (s *Server) Serve(l net.Listener) error {
+	err := s.Server.Serve(l)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown изящно останавливает сервер: новые вызовы отклоняются, активные
+// завершаются в пределах ShutdownTimeout; по истечении тайм-аута соединения
+// закрываются принудительно и возвращается context.DeadlineExceeded.
+func (s *Server) Shutdown() error {
+	s.mu.Lock()
+	hooks := s.onShutdown
+	s.onShutdown = nil
+	s.mu.Unlock()
+
+	for _, f := range hooks {
+		f()
+	}
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Server.GracefulStop()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		// Вызовы, не завершившиеся за отведённое время, прерываются.
+		s.Server.Stop()
+		<-done
+		return context.DeadlineExceeded
+	}
+}
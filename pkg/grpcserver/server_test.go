@@ -0,0 +1,85 @@
+package grpcserver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/sergeizaitcev/gophermart/pkg/grpcserver"
+	"github.com/sergeizaitcev/gophermart/pkg/tcputil"
+)
+
+func newServer() *grpcserver.Server {
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	return grpcserver.New(srv)
+}
+
+func dial(t *testing.T, addr string) healthpb.HealthClient {
+	t.Helper()
+
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestListenAndServe(t *testing.T) {
+	srv := newServer()
+
+	var calls int
+	srv.RegisterOnShutdown(func() { calls++ })
+
+	port, err := tcputil.FreePort()
+	require.NoError(t, err)
+	addr := net.JoinHostPort("localhost", port)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errc := make(chan error)
+	go func() { errc <- srv.ListenAndServe(ctx, addr) }()
+
+	client := dial(t, addr)
+
+	res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+
+	cancel()
+	require.NoError(t, <-errc)
+	require.Equal(t, 1, calls)
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	srv := newServer()
+	srv.ShutdownTimeout = 50 * time.Millisecond
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+
+	client := dial(t, l.Addr().String())
+
+	// Watch не завершается, пока клиент не отменит вызов.
+	stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+
+	// Зависший вызов прерывается по истечении ShutdownTimeout.
+	require.ErrorIs(t, srv.Shutdown(), context.DeadlineExceeded)
+	_, err = stream.Recv()
+	require.Error(t, err)
+	require.NoError(t, <-errc)
+}
@@ -0,0 +1,166 @@
+// Package health реализует проверки живости и готовности сервиса для
+// оркестратора.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Статусы проверок.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrShuttingDown возвращается проверкой готовности во время остановки
+// сервера.
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// Check проверяет зависимость сервиса; ошибка означает, что сервис не готов
+// обслуживать запросы.
+type Check func(ctx context.Context) error
+
+// Result определяет результат проверки.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report определяет отчёт о проверках.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// OK возвращает true, если все проверки пройдены.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Probe определяет набор проверок готовности сервиса.
+//
+// Проверки регистрируются до начала обслуживания запросов; остальные
+// методы потоко-безопасны.
+type Probe struct {
+	timeout  time.Duration
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// NewProbe возвращает набор проверок, каждая из которых ограничена временем
+// timeout; 0 означает 5s.
+func NewProbe(timeout time.Duration) *Probe {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &Probe{timeout: timeout}
+}
+
+// Register добавляет проверку готовности с именем name.
+func (p *Probe) Register(name string, check Check) {
+	p.checks = append(p.checks, namedCheck{name: name, check: check})
+}
+
+// Drain переводит сервис в состояние остановки: все последующие проверки
+// готовности завершаются ошибкой ErrShuttingDown.
+func (p *Probe) Drain() {
+	p.draining.Store(true)
+}
+
+// Ready выполняет проверки готовности параллельно и возвращает отчёт.
+func (p *Probe) Ready(ctx context.Context) Report {
+	if p.draining.Load() {
+		return Report{
+			Status: StatusFail,
+			Checks: map[string]Result{
+				"shutdown": {Status: StatusFail, Error: ErrShuttingDown.Error(), Duration: "0s"},
+			},
+		}
+	}
+
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]Result, len(p.checks)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for _, c := range p.checks {
+		wg.Add(1)
+		go func(c namedCheck) {
+			defer wg.Done()
+
+			res := p.run(ctx, c.check)
+
+			mu.Lock()
+			report.Checks[c.name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+			mu.Unlock()
+		}(c)
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func (p *Probe) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	elapsed := time.Since(start).Round(time.Microsecond)
+
+	if err != nil {
+		return Result{Status: StatusFail, Error: err.Error(), Duration: elapsed.String()}
+	}
+	return Result{Status: StatusOK, Duration: elapsed.String()}
+}
+
+// Healthz возвращает обработчик проверки живости: процесс отвечает, пока
+// он запущен, в том числе во время остановки.
+func (p *Probe) Healthz() http.Handler {
+	fn := func(w http.ResponseWriter, _ *http.Request) {
+		writeReport(w, Report{Status: StatusOK})
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Readyz возвращает обработчик проверки готовности, отвечающий
+// http.StatusServiceUnavailable, если хотя бы одна проверка не пройдена.
+func (p *Probe) Readyz() http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, p.Ready(r.Context()))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	status := http.StatusOK
+	if !report.OK() {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,71 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/health"
+)
+
+func TestProbe(t *testing.T) {
+	probe := health.NewProbe(50 * time.Millisecond)
+
+	var dbErr error
+	probe.Register("database", func(context.Context) error { return dbErr })
+	probe.Register("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	do := func(h http.Handler) (int, health.Report) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", http.NoBody))
+
+		require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var report health.Report
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		return rec.Code, report
+	}
+
+	t.Run("healthz", func(t *testing.T) {
+		code, report := do(probe.Healthz())
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, health.StatusOK, report.Status)
+	})
+
+	t.Run("readyz", func(t *testing.T) {
+		dbErr = errors.New("connection refused")
+
+		code, report := do(probe.Readyz())
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, health.StatusFail, report.Status)
+		require.Equal(t, "connection refused", report.Checks["database"].Error)
+		require.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		probe := health.NewProbe(0)
+		probe.Register("database", func(context.Context) error { return nil })
+
+		code, report := do(probe.Readyz())
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, health.StatusOK, report.Checks["database"].Status)
+
+		probe.Drain()
+
+		code, report = do(probe.Readyz())
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, health.ErrShuttingDown.Error(), report.Checks["shutdown"].Error)
+
+		code, _ = do(probe.Healthz())
+		require.Equal(t, http.StatusOK, code)
+	})
+}
@@ -2,6 +2,8 @@ package httpserver
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"net"
 	"net/http"
 	"sync"
@@ -16,8 +18,42 @@ type Server struct {
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
 
+	// Время на завершение активных запросов при остановке; по истечении
+	// соединения закрываются принудительно. По умолчанию 5s.
+	ShutdownTimeout time.Duration
+
+	// Конфигурация TLS; если задана, то сервер принимает только
+	// HTTPS-соединения. Сертификат задаётся через Certificates или
+	// GetCertificate, например, tlsutil.ServerConfig.
+	TLSConfig *tls.Config
+
 	once sync.Once
 	srv  *http.Server
+
+	mu         sync.Mutex
+	onShutdown []func()
+}
+
+const defaultShutdownTimeout = 5 * time.Second
+
+// New возвращает сервер с тайм-аутами по умолчанию.
+func New(h http.Handler) *Server {
+	return &Server{
+		Handler:         h,
+		ReadTimeout:     15 * time.Second,
+		WriteTimeout:    30 * time.Second,
+		IdleTimeout:     60 * time.Second,
+		ShutdownTimeout: defaultShutdownTimeout,
+	}
+}
+
+// RegisterOnShutdown регистрирует функцию, вызываемую в начале Shutdown
+// до закрытия слушателя, например, чтобы перевести проверку готовности
+// в состояние остановки.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.mu.Lock()
+	s.onShutdown = append(s.onShutdown, f)
+	s.mu.Unlock()
 }
 
 func (s *Server) lazyInit() {
@@ -27,6 +63,7 @@ func (s *Server) lazyInit() {
 			ReadTimeout:  s.ReadTimeout,
 			WriteTimeout: s.WriteTimeout,
 			IdleTimeout:  s.IdleTimeout,
+			TLSConfig:    s.TLSConfig,
 		}
 	})
 }
@@ -65,6 +102,9 @@ func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 // net.Listener, и блокируется до тех пор, пока метод не вернёт ошибку.
 func (s *Server) Serve(l net.Listener) error {
 	s.lazyInit()
+	if s.srv.TLSConfig != nil {
+		return s.srv.ServeTLS(l, "", "")
+	}
 	return s.srv.Serve(l)
 }
 
@@ -72,20 +112,34 @@ func (s *Server) Serve(l net.Listener) error {
 func (s *Server) Shutdown() error {
 	s.lazyInit()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	s.mu.Lock()
+	hooks := s.onShutdown
+	s.onShutdown = nil
+	s.mu.Unlock()
+
+	for _, f := range hooks {
+		f()
+	}
+
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	return s.srv.Shutdown(ctx)
+	err := s.srv.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// Запросы, не завершившиеся за отведённое время, прерываются.
+		_ = s.srv.Close()
+	}
+
+	return err
 }
 
 // ListenAndServe запускает сервер и блокируется до тех пор, пока не сработает
 // контекст или функция не вернёт ошибку.
 func ListenAndServe(ctx context.Context, addr string, h http.Handler) error {
-	s := &Server{
-		Handler:      h,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
-	return s.ListenAndServe(ctx, addr)
+	return New(h).ListenAndServe(ctx, addr)
 }
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -55,3 +56,48 @@ func TestListenAndServe(t *testing.T) {
 	cancel()
 	assert.NoError(t, <-errCh)
 }
+
+func TestServer_RegisterOnShutdown(t *testing.T) {
+	srv := httpserver.New(http.NotFoundHandler())
+
+	var calls int
+	srv.RegisterOnShutdown(func() { calls++ })
+
+	require.NoError(t, srv.Shutdown())
+	require.Equal(t, 1, calls)
+
+	require.NoError(t, srv.Shutdown())
+	require.Equal(t, 1, calls)
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	started := make(chan struct{})
+
+	srv := httpserver.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}))
+	srv.ShutdownTimeout = 50 * time.Millisecond
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+
+	reqErr := make(chan error, 1)
+	go func() {
+		res, err := http.Get("http://" + l.Addr().String())
+		if err == nil {
+			res.Body.Close()
+		}
+		reqErr <- err
+	}()
+
+	<-started
+
+	// Зависший запрос прерывается по истечении ShutdownTimeout.
+	require.ErrorIs(t, srv.Shutdown(), context.DeadlineExceeded)
+	require.Error(t, <-reqErr)
+	require.ErrorIs(t, <-errc, http.ErrServerClosed)
+}
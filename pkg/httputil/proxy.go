@@ -0,0 +1,110 @@
+package httputil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies определяет сети обратных прокси, которым разрешено
+// передавать адрес клиента в заголовке X-Forwarded-For.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies разбирает список сетей в формате
+// "10.0.0.0/8,192.168.1.10"; адрес без маски задаёт один узел.
+func ParseTrustedProxies(s string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", item, err)
+			}
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", item, err)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) String() string {
+	items := make([]string, len(p))
+	for i, prefix := range p {
+		items[i] = prefix.String()
+	}
+	return strings.Join(items, ",")
+}
+
+func (p TrustedProxies) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *TrustedProxies) UnmarshalText(text []byte) error {
+	parsed, err := ParseTrustedProxies(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// trusted возвращает true, если адрес принадлежит доверенному прокси.
+func (p TrustedProxies) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP возвращает IP-адрес клиента. Если запрос пришёл от доверенного
+// прокси, то адрес берётся из X-Forwarded-For: цепочка просматривается
+// справа налево до первого адреса, не принадлежащего доверенному прокси.
+// Адреса левее него клиент мог подставить сам, поэтому не учитываются.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if len(p) == 0 || !p.trusted(ip) {
+		return ip
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// Повреждённую цепочку дальше не разбираем.
+			return ip
+		}
+		ip = hop
+		if !p.trusted(hop) {
+			break
+		}
+	}
+
+	return ip
+}
@@ -0,0 +1,90 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := httputil.ParseTrustedProxies("10.0.0.0/8, 192.168.1.10,")
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.0/8,192.168.1.10/32", proxies.String())
+
+	_, err = httputil.ParseTrustedProxies("10.0.0.0/33")
+	require.Error(t, err)
+
+	_, err = httputil.ParseTrustedProxies("proxy")
+	require.Error(t, err)
+}
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	proxies, err := httputil.ParseTrustedProxies("10.0.0.0/8")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		proxies      httputil.TrustedProxies
+		remoteAddr   string
+		forwardedFor []string
+		expectedIP   string
+	}{
+		{
+			name:         "no proxies",
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"203.0.113.1"},
+			expectedIP:   "10.0.0.1",
+		},
+		{
+			name:         "untrusted peer",
+			proxies:      proxies,
+			remoteAddr:   "198.51.100.1:1234",
+			forwardedFor: []string{"203.0.113.1"},
+			expectedIP:   "198.51.100.1",
+		},
+		{
+			name:         "trusted peer",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"203.0.113.1"},
+			expectedIP:   "203.0.113.1",
+		},
+		{
+			name:         "spoofed chain",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"192.0.2.1, 203.0.113.1", "10.0.0.2"},
+			expectedIP:   "203.0.113.1",
+		},
+		{
+			name:         "only proxies",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"10.0.0.3, 10.0.0.2"},
+			expectedIP:   "10.0.0.3",
+		},
+		{
+			name:         "malformed chain",
+			proxies:      proxies,
+			remoteAddr:   "10.0.0.1:1234",
+			forwardedFor: []string{"unknown"},
+			expectedIP:   "10.0.0.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.RemoteAddr = tc.remoteAddr
+			for _, value := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			require.Equal(t, tc.expectedIP, tc.proxies.ClientIP(req))
+		})
+	}
+}
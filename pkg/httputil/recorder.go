@@ -0,0 +1,64 @@
+package httputil
+
+import "net/http"
+
+// ResponseRecorder запоминает код и размер ответа для промежуточных
+// обработчиков журнала, метрик и трассировки.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// NewResponseRecorder возвращает ResponseRecorder поверх w. Если w уже
+// является ResponseRecorder, то возвращается он сам, чтобы цепочка
+// промежуточных обработчиков не оборачивала ответ несколько раз.
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	if rec, ok := w.(*ResponseRecorder); ok {
+		return rec
+	}
+	return &ResponseRecorder{ResponseWriter: w}
+}
+
+// Status возвращает код ответа; если обработчик ничего не записал, то
+// возвращает http.StatusOK, который отправит net/http.
+func (w *ResponseRecorder) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Bytes возвращает количество записанных байт тела ответа.
+func (w *ResponseRecorder) Bytes() int64 {
+	return w.bytes
+}
+
+func (w *ResponseRecorder) WriteHeader(code int) {
+	// Информационные ответы не влияют на итоговый код.
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush реализует интерфейс http.Flusher.
+func (w *ResponseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap возвращает исходный http.ResponseWriter для http.ResponseController.
+func (w *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
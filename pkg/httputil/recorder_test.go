@@ -0,0 +1,32 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+)
+
+func TestResponseRecorder(t *testing.T) {
+	t.Run("status", func(t *testing.T) {
+		rec := httputil.NewResponseRecorder(httptest.NewRecorder())
+		require.Equal(t, http.StatusOK, rec.Status())
+
+		rec.WriteHeader(http.StatusCreated)
+		rec.WriteHeader(http.StatusInternalServerError)
+
+		_, err := rec.Write([]byte("body"))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, rec.Status())
+		require.Equal(t, int64(4), rec.Bytes())
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		rec := httputil.NewResponseRecorder(httptest.NewRecorder())
+		require.Same(t, rec, httputil.NewResponseRecorder(rec))
+	})
+}
@@ -0,0 +1,67 @@
+package httputil
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RouteSizes определяет размеры в байтах для маршрутов вида
+// "POST /api/user/orders", например, ограничения размеров тел запросов.
+type RouteSizes map[string]int64
+
+// ParseRouteSizes разбирает размеры маршрутов в формате
+// "POST /api/user/orders=1024,POST /api/user/register=512".
+func ParseRouteSizes(s string) (RouteSizes, error) {
+	sizes := make(RouteSizes)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("route size %q: expected route=bytes", item)
+		}
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || method == "" || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("route size %q: expected METHOD /path", item)
+		}
+
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("route size %q: expected a positive number of bytes", item)
+		}
+		sizes[strings.ToUpper(method)+" "+path] = n
+	}
+	return sizes, nil
+}
+
+func (rs RouteSizes) String() string {
+	routes := make([]string, 0, len(rs))
+	for route := range rs {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	items := make([]string, len(routes))
+	for i, route := range routes {
+		items[i] = route + "=" + strconv.FormatInt(rs[route], 10)
+	}
+	return strings.Join(items, ",")
+}
+
+func (rs RouteSizes) MarshalText() ([]byte, error) {
+	return []byte(rs.String()), nil
+}
+
+func (rs *RouteSizes) UnmarshalText(text []byte) error {
+	parsed, err := ParseRouteSizes(string(text))
+	if err != nil {
+		return err
+	}
+	*rs = parsed
+	return nil
+}
@@ -0,0 +1,29 @@
+package httputil_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+)
+
+func TestParseRouteSizes(t *testing.T) {
+	sizes, err := httputil.ParseRouteSizes("post /api/user/orders=1024, POST /api/user/register=512")
+	require.NoError(t, err)
+	require.Equal(t, httputil.RouteSizes{
+		"POST /api/user/orders":   1024,
+		"POST /api/user/register": 512,
+	}, sizes)
+	require.Equal(t, "POST /api/user/orders=1024,POST /api/user/register=512", sizes.String())
+
+	for _, s := range []string{
+		"POST /api/user/orders",
+		"/api/user/orders=1024",
+		"POST /api/user/orders=0",
+		"POST /api/user/orders=1k",
+	} {
+		_, err := httputil.ParseRouteSizes(s)
+		require.Error(t, err, s)
+	}
+}
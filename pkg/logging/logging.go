@@ -0,0 +1,78 @@
+// Package logging реализует журналирование в рамках запроса: сквозной
+// идентификатор запроса, журнал запросов и логгер, передаваемый через
+// контекст.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+)
+
+// RequestIDHeader определяет заголовок со сквозным идентификатором запроса.
+const RequestIDHeader = "X-Request-ID"
+
+// keyLogger определяет ключ для передачи логгера через контекст.
+type keyLogger struct{}
+
+// keyRequestID определяет ключ для передачи идентификатора запроса через
+// контекст.
+type keyRequestID struct{}
+
+// WithLogger возвращает копию контекста с логгером.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, keyLogger{}, logger)
+}
+
+// FromContext возвращает логгер из контекста или slog.Default(), если
+// логгера в контексте нет.
+func FromContext(ctx context.Context) *slog.Logger {
+	return FromContextOr(ctx, slog.Default())
+}
+
+// FromContextOr возвращает логгер из контекста или fallback, если логгера
+// в контексте нет.
+func FromContextOr(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	logger, ok := ctx.Value(keyLogger{}).(*slog.Logger)
+	if !ok {
+		return fallback
+	}
+	return logger
+}
+
+// With возвращает копию контекста с логгером, дополненным атрибутами args;
+// атрибуты также попадают в запись журнала запросов, если запрос проходит
+// через Middleware.
+func With(ctx context.Context, args ...any) context.Context {
+	if entry, ok := ctx.Value(keyEntry{}).(*entry); ok {
+		entry.add(args...)
+	}
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// WithRequestID возвращает копию контекста с идентификатором запроса
+// и логгером, дополненным атрибутом request_id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, keyRequestID{}, id)
+	return WithLogger(ctx, FromContext(ctx).With(slog.String("request_id", id)))
+}
+
+// RequestID возвращает идентификатор запроса из контекста.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(keyRequestID{}).(string)
+	return id
+}
+
+// NewRequestID возвращает новый случайный идентификатор запроса.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,72 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	var requestID string
+
+	mux := chi.NewRouter()
+	mux.Use(logging.Middleware(logger))
+	mux.Get("/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		ctx := logging.With(r.Context(), slog.String("user_id", "user"))
+		requestID = logging.RequestID(ctx)
+		logging.FromContext(ctx).Info("handled")
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	t.Run("accepted", func(t *testing.T) {
+		buf.Reset()
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", http.NoBody)
+		req.Header.Set(logging.RequestIDHeader, "abc-123")
+
+		mux.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		require.Equal(t, "abc-123", rec.Header().Get(logging.RequestIDHeader))
+		require.Equal(t, "abc-123", requestID)
+
+		dec := json.NewDecoder(&buf)
+
+		var handled, access map[string]any
+		require.NoError(t, dec.Decode(&handled))
+		require.NoError(t, dec.Decode(&access))
+
+		require.Equal(t, "abc-123", handled["request_id"])
+		require.Equal(t, "user", handled["user_id"])
+
+		require.Equal(t, "request", access["msg"])
+		require.Equal(t, "abc-123", access["request_id"])
+		require.Equal(t, "/orders/{number}", access["route"])
+		require.EqualValues(t, http.StatusAccepted, access["status"])
+		require.Equal(t, "user", access["user_id"])
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders/1", http.NoBody)
+		req.Header.Set(logging.RequestIDHeader, "invalid id\n")
+
+		mux.ServeHTTP(rec, req)
+
+		id := rec.Header().Get(logging.RequestIDHeader)
+		require.Len(t, id, 32)
+		require.Equal(t, id, requestID)
+	})
+}
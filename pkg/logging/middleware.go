@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
+)
+
+// Максимальная длина идентификатора запроса, принимаемого от клиента.
+const maxRequestIDLen = 128
+
+// keyEntry определяет ключ для передачи записи журнала запросов через
+// контекст.
+type keyEntry struct{}
+
+// entry определяет дополнительные атрибуты записи журнала запросов.
+type entry struct {
+	mu    sync.Mutex
+	attrs []any
+}
+
+func (e *entry) add(args ...any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.attrs = append(e.attrs, args...)
+}
+
+// Middleware возвращает промежуточный обработчик, который принимает
+// идентификатор запроса из заголовка X-Request-ID или создаёт новый,
+// возвращает его в ответе, передаёт в контекст логгер с атрибутом
+// request_id и по завершении запроса записывает в журнал метод, шаблон
+// маршрута, код ответа и длительность обработки.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(RequestIDHeader)
+			if !ValidRequestID(id) {
+				id = NewRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			e := &entry{}
+
+			ctx := WithLogger(r.Context(), logger)
+			ctx = WithRequestID(ctx, id)
+			ctx = context.WithValue(ctx, keyEntry{}, e)
+
+			// Идентификатор трассы связывает журнал с участками запроса.
+			if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+				ctx = With(ctx, slog.String("trace_id", sc.TraceID.String()))
+			}
+
+			rw := httputil.NewResponseRecorder(w)
+
+			next.ServeHTTP(rw, r.WithContext(ctx))
+
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if pattern := rctx.RoutePattern(); pattern != "" {
+					route = pattern
+				}
+			}
+
+			status := rw.Status()
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			args := []any{
+				slog.String("request_id", id),
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int64("bytes", rw.Bytes()),
+				slog.Duration("latency", time.Since(start)),
+			}
+
+			e.mu.Lock()
+			args = append(args, e.attrs...)
+			e.mu.Unlock()
+
+			logger.Log(ctx, level, "request", args...)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// ValidRequestID возвращает true, если идентификатор запроса от клиента
+// не пуст, не слишком длинный и состоит из безопасных символов.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+// NewDBStats возвращает семейства метрик пула соединений БД, значения
+// которых возвращает stats при каждом сборе, например (*sql.DB).Stats.
+func NewDBStats(stats func() sql.DBStats) []Collector {
+	gauge := func(name, help string, value func(sql.DBStats) float64) Collector {
+		return NewGaugeFunc(name, help, nil, func() []Sample {
+			return []Sample{{Value: value(stats())}}
+		})
+	}
+	counter := func(name, help string, value func(sql.DBStats) float64) Collector {
+		return NewCounterFunc(name, help, nil, func() []Sample {
+			return []Sample{{Value: value(stats())}}
+		})
+	}
+
+	return []Collector{
+		gauge("db_max_open_connections", "Максимальное количество открытых соединений.",
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }),
+		gauge("db_open_connections", "Количество открытых соединений.",
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }),
+		gauge("db_in_use_connections", "Количество используемых соединений.",
+			func(s sql.DBStats) float64 { return float64(s.InUse) }),
+		gauge("db_idle_connections", "Количество простаивающих соединений.",
+			func(s sql.DBStats) float64 { return float64(s.Idle) }),
+		counter("db_wait_count_total", "Количество ожиданий свободного соединения.",
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }),
+		counter("db_wait_duration_seconds_total", "Суммарное время ожидания свободного соединения.",
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }),
+		counter("db_max_idle_closed_total", "Количество соединений, закрытых из-за ограничения простаивающих.",
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }),
+		counter("db_max_lifetime_closed_total", "Количество соединений, закрытых по истечении времени жизни.",
+			func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }),
+	}
+}
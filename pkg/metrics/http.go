@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+)
+
+// UnmatchedRoute определяет значение метки route для запросов, не
+// совпавших ни с одним маршрутом, чтобы произвольные пути не порождали
+// новые ряды метрик.
+const UnmatchedRoute = "unmatched"
+
+// OtherMethod определяет значение метки method для нестандартных методов
+// HTTP, чтобы клиент не мог порождать новые ряды метрик.
+const OtherMethod = "OTHER"
+
+// knownMethods определяет стандартные методы HTTP.
+var knownMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodConnect: {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+}
+
+var (
+	httpRequests = NewCounterVec(
+		"http_requests_total",
+		"Количество обработанных HTTP-запросов.",
+		"method", "route", "status",
+	)
+	httpDuration = NewHistogramVec(
+		"http_request_duration_seconds",
+		"Длительность обработки HTTP-запросов.",
+		nil,
+		"method", "route", "status",
+	)
+)
+
+func init() {
+	MustRegister(httpRequests, httpDuration)
+}
+
+// Middleware возвращает промежуточный обработчик, который учитывает
+// количество и длительность запросов по методу, шаблону маршрута chi
+// и коду ответа.
+func Middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		rw := httputil.NewResponseRecorder(w)
+
+		next.ServeHTTP(rw, r)
+
+		route := UnmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+
+		labels := []string{methodLabel(r.Method), route, strconv.Itoa(rw.Status())}
+		httpRequests.With(labels...).Inc()
+		httpDuration.With(labels...).Observe(time.Since(start).Seconds())
+	}
+	return http.HandlerFunc(fn)
+}
+
+// methodLabel возвращает значение метки method.
+func methodLabel(method string) string {
+	if _, ok := knownMethods[method]; ok {
+		return method
+	}
+	return OtherMethod
+}
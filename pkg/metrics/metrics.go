@@ -0,0 +1,477 @@
+// Package metrics реализует счётчики, шкалы и гистограммы с метками и их
+// публикацию в текстовом формате Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ContentType определяет тип содержимого текстового формата Prometheus.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets определяет границы корзин гистограммы по умолчанию в секундах.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var nameRe = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Default определяет реестр метрик процесса, публикуемый Handler.
+var Default = NewRegistry()
+
+// Collector определяет семейство метрик реестра.
+type Collector interface {
+	// Name возвращает имя семейства метрик.
+	Name() string
+
+	write(w *bufio.Writer)
+}
+
+// Registry определяет реестр метрик.
+//
+// Структура потоко-безопасна.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry возвращает пустой реестр метрик.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// MustRegister добавляет семейства метрик в реестр и паникует, если имя
+// уже занято.
+func (r *Registry) MustRegister(cs ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range cs {
+		if _, ok := r.collectors[c.Name()]; ok {
+			panic(fmt.Sprintf("metrics: duplicate metric %q", c.Name()))
+		}
+		r.collectors[c.Name()] = c
+	}
+}
+
+// ServeHTTP возвращает метрики реестра в текстовом формате Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	r.write(bw)
+	_ = bw.Flush()
+}
+
+// write записывает метрики реестра, упорядоченные по имени.
+func (r *Registry) write(w *bufio.Writer) {
+	r.mu.RLock()
+	cs := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		cs = append(cs, c)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Name() < cs[j].Name() })
+
+	for _, c := range cs {
+		c.write(w)
+	}
+}
+
+// Handler возвращает обработчик, публикующий метрики реестра Default.
+func Handler() http.Handler {
+	return Default
+}
+
+// ServeMux возвращает обработчик для отдельного внутреннего сервера,
+// публикующий метрики реестра Default по пути /metrics.
+func ServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return mux
+}
+
+// MustRegister добавляет семейства метрик в реестр Default.
+func MustRegister(cs ...Collector) {
+	Default.MustRegister(cs...)
+}
+
+// desc определяет имя, описание, тип и метки семейства метрик.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func newDesc(name, help, kind string, labels []string) desc {
+	if !nameRe.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	for _, l := range labels {
+		if !nameRe.MatchString(l) || strings.HasPrefix(l, "__") || l == "le" {
+			panic(fmt.Sprintf("metrics: %s: invalid label name %q", name, l))
+		}
+	}
+	return desc{name: name, help: help, kind: kind, labels: labels}
+}
+
+// Name реализует интерфейс Collector.
+func (d desc) Name() string {
+	return d.name
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.kind)
+}
+
+// writeSample записывает значение метрики name с метками labels=values
+// и дополнительной меткой extra, если она задана.
+func writeSample(w *bufio.Writer, name string, labels, values []string, extra string, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extra != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l)
+			w.WriteString(`="`)
+			w.WriteString(escapeLabel(values[i]))
+			w.WriteByte('"')
+		}
+		if extra != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extra)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpReplacer.Replace(s) }
+func escapeLabel(s string) string { return labelReplacer.Replace(s) }
+
+// vec определяет набор метрик одного семейства с разными значениями меток.
+type vec[T any] struct {
+	desc
+
+	newMetric func() *T
+
+	mu       sync.RWMutex
+	children map[string]*child[T]
+}
+
+type child[T any] struct {
+	values []string
+	metric *T
+}
+
+func newVec[T any](d desc, newMetric func() *T) *vec[T] {
+	return &vec[T]{desc: d, newMetric: newMetric, children: make(map[string]*child[T])}
+}
+
+// with возвращает метрику для значений меток, создавая её при первом
+// обращении.
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s: expected %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c.metric
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	c, ok = v.children[key]
+	if !ok {
+		c = &child[T]{values: append([]string(nil), values...), metric: v.newMetric()}
+		v.children[key] = c
+	}
+	return c.metric
+}
+
+// sorted возвращает метрики набора, упорядоченные по значениям меток.
+func (v *vec[T]) sorted() []*child[T] {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.children))
+	for k := range v.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	children := make([]*child[T], len(keys))
+	for i, k := range keys {
+		children[i] = v.children[k]
+	}
+	v.mu.RUnlock()
+	return children
+}
+
+// value определяет атомарное значение с плавающей точкой.
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) load() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+func (v *value) store(f float64) {
+	v.bits.Store(math.Float64bits(f))
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if v.bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// Counter определяет монотонно возрастающий счётчик.
+type Counter struct {
+	v value
+}
+
+// Inc увеличивает счётчик на 1.
+func (c *Counter) Inc() {
+	c.v.add(1)
+}
+
+// Add увеличивает счётчик на delta; отрицательное значение приводит
+// к панике.
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.v.add(delta)
+}
+
+// Value возвращает текущее значение счётчика.
+func (c *Counter) Value() float64 {
+	return c.v.load()
+}
+
+// CounterVec определяет набор счётчиков с метками.
+type CounterVec struct {
+	*vec[Counter]
+}
+
+// NewCounterVec возвращает набор счётчиков с метками labels.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	d := newDesc(name, help, "counter", labels)
+	return &CounterVec{newVec(d, func() *Counter { return &Counter{} })}
+}
+
+// With возвращает счётчик для значений меток в порядке их объявления.
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.with(values)
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	for _, c := range v.sorted() {
+		writeSample(w, v.name, v.labels, c.values, "", c.metric.Value())
+	}
+}
+
+// Gauge определяет шкалу, значение которой может как расти, так и убывать.
+type Gauge struct {
+	v value
+}
+
+// Set устанавливает значение шкалы.
+func (g *Gauge) Set(f float64) {
+	g.v.store(f)
+}
+
+// Add изменяет значение шкалы на delta.
+func (g *Gauge) Add(delta float64) {
+	g.v.add(delta)
+}
+
+// Inc увеличивает значение шкалы на 1.
+func (g *Gauge) Inc() {
+	g.v.add(1)
+}
+
+// Dec уменьшает значение шкалы на 1.
+func (g *Gauge) Dec() {
+	g.v.add(-1)
+}
+
+// Value возвращает текущее значение шкалы.
+func (g *Gauge) Value() float64 {
+	return g.v.load()
+}
+
+// GaugeVec определяет набор шкал с метками.
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+// NewGaugeVec возвращает набор шкал с метками labels.
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	d := newDesc(name, help, "gauge", labels)
+	return &GaugeVec{newVec(d, func() *Gauge { return &Gauge{} })}
+}
+
+// With возвращает шкалу для значений меток в порядке их объявления.
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.with(values)
+}
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	for _, c := range v.sorted() {
+		writeSample(w, v.name, v.labels, c.values, "", c.metric.Value())
+	}
+}
+
+// Histogram определяет гистограмму наблюдаемых значений.
+type Histogram struct {
+	upper  []float64
+	counts []atomic.Uint64
+	count  atomic.Uint64
+	sum    value
+}
+
+// Observe добавляет наблюдение в гистограмму.
+func (h *Histogram) Observe(f float64) {
+	i := sort.SearchFloat64s(h.upper, f)
+	if i < len(h.counts) {
+		h.counts[i].Add(1)
+	}
+	h.sum.add(f)
+	h.count.Add(1)
+}
+
+// Count возвращает количество наблюдений.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// HistogramVec определяет набор гистограмм с метками.
+type HistogramVec struct {
+	*vec[Histogram]
+	buckets []float64
+}
+
+// NewHistogramVec возвращает набор гистограмм с верхними границами корзин
+// buckets и метками labels; nil означает DefBuckets.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	d := newDesc(name, help, "histogram", labels)
+	newHistogram := func() *Histogram {
+		return &Histogram{upper: buckets, counts: make([]atomic.Uint64, len(buckets))}
+	}
+	return &HistogramVec{vec: newVec(d, newHistogram), buckets: buckets}
+}
+
+// With возвращает гистограмму для значений меток в порядке их объявления.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.with(values)
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.writeHeader(w)
+	for _, c := range v.sorted() {
+		h := c.metric
+		count := h.count.Load()
+
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += h.counts[i].Load()
+			writeSample(w, v.name+"_bucket", v.labels, c.values, `le="`+formatFloat(upper)+`"`, float64(cumulative))
+		}
+		writeSample(w, v.name+"_bucket", v.labels, c.values, `le="+Inf"`, float64(count))
+		writeSample(w, v.name+"_sum", v.labels, c.values, "", h.sum.load())
+		writeSample(w, v.name+"_count", v.labels, c.values, "", float64(count))
+	}
+}
+
+// Sample определяет значение метрики с значениями меток в порядке их
+// объявления.
+type Sample struct {
+	Values []string
+	Value  float64
+}
+
+// Func определяет семейство метрик, значения которых вычисляются при
+// каждом сборе.
+type Func struct {
+	desc
+	collect func() []Sample
+}
+
+// NewGaugeFunc возвращает семейство шкал, значения которых возвращает
+// collect при каждом сборе; значения упорядочиваются по меткам.
+func NewGaugeFunc(name, help string, labels []string, collect func() []Sample) *Func {
+	return &Func{desc: newDesc(name, help, "gauge", labels), collect: collect}
+}
+
+// NewCounterFunc возвращает семейство счётчиков, значения которых
+// возвращает collect при каждом сборе.
+func NewCounterFunc(name, help string, labels []string, collect func() []Sample) *Func {
+	return &Func{desc: newDesc(name, help, "counter", labels), collect: collect}
+}
+
+func (f *Func) write(w *bufio.Writer) {
+	samples := f.collect()
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].Values, "\xff") < strings.Join(samples[j].Values, "\xff")
+	})
+
+	f.writeHeader(w)
+	for _, s := range samples {
+		if len(s.Values) != len(f.labels) {
+			continue
+		}
+		writeSample(w, f.name, f.labels, s.Values, "", s.Value)
+	}
+}
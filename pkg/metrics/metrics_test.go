@@ -0,0 +1,115 @@
+package metrics_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/metrics"
+)
+
+func scrape(t *testing.T, h http.Handler) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, metrics.ContentType, rec.Header().Get("Content-Type"))
+
+	return rec.Body.String()
+}
+
+func TestRegistry(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	requests := metrics.NewCounterVec("requests_total", "Requests.", "code")
+	depth := metrics.NewGaugeVec("queue_depth", "Queue depth.")
+	latency := metrics.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	stats := metrics.NewGaugeFunc("pool_size", "Pool size.", []string{"pool"}, func() []metrics.Sample {
+		return []metrics.Sample{{Values: []string{`a"b`}, Value: 3}}
+	})
+
+	reg.MustRegister(requests, depth, latency, stats)
+
+	requests.With("200").Inc()
+	requests.With("200").Add(2)
+	requests.With("500").Inc()
+	depth.With().Set(7)
+	depth.With().Dec()
+	latency.With("get").Observe(0.05)
+	latency.With("get").Observe(0.5)
+	latency.With("get").Observe(5)
+
+	want := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="get",le="0.1"} 1
+latency_seconds_bucket{op="get",le="1"} 2
+latency_seconds_bucket{op="get",le="+Inf"} 3
+latency_seconds_sum{op="get"} 5.55
+latency_seconds_count{op="get"} 3
+# HELP pool_size Pool size.
+# TYPE pool_size gauge
+pool_size{pool="a\"b"} 3
+# HELP queue_depth Queue depth.
+# TYPE queue_depth gauge
+queue_depth 6
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{code="200"} 3
+requests_total{code="500"} 1
+`
+	require.Equal(t, want, scrape(t, reg))
+
+	require.Panics(t, func() { reg.MustRegister(metrics.NewCounterVec("requests_total", "")) })
+	require.Panics(t, func() { requests.With() })
+	require.Panics(t, func() { requests.With("200").Add(-1) })
+	require.Panics(t, func() { metrics.NewCounterVec("bad-name", "") })
+}
+
+func TestNewDBStats(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.MustRegister(metrics.NewDBStats(func() sql.DBStats {
+		return sql.DBStats{OpenConnections: 4, InUse: 1, Idle: 3, WaitDuration: 1500 * time.Millisecond}
+	})...)
+
+	out := scrape(t, reg)
+	require.Contains(t, out, "db_open_connections 4\n")
+	require.Contains(t, out, "db_in_use_connections 1\n")
+	require.Contains(t, out, "db_wait_duration_seconds_total 1.5\n")
+}
+
+func TestMiddleware(t *testing.T) {
+	mux := chi.NewRouter()
+	mux.Use(metrics.Middleware)
+	mux.Get("/orders/{number}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, target := range []string{"/orders/1", "/orders/2", "/unknown"} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, http.NoBody))
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("FOO", "/unknown", http.NoBody))
+
+	out := scrape(t, metrics.Handler())
+	require.Contains(t, out, `http_requests_total{method="OTHER",route="unmatched",status="405"} 1`)
+	require.NotContains(t, out, `method="FOO"`)
+	require.Contains(t, out, `http_requests_total{method="GET",route="/orders/{number}",status="204"} 2`)
+	require.Contains(t, out, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	require.Contains(t, out, `http_request_duration_seconds_count{method="GET",route="/orders/{number}",status="204"} 2`)
+}
+
+func TestServeMux(t *testing.T) {
+	mux := metrics.ServeMux()
+
+	require.Contains(t, scrape(t, mux), "http_requests_total")
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,381 @@
+// Package openapi реализует подмножество OpenAPI 3.0, достаточное для
+// публикации спецификации API и проверки входящих запросов по ней.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Методы HTTP, операции которых поддерживаются спецификацией.
+var methods = []string{
+	http.MethodGet,
+	http.MethodPut,
+	http.MethodPost,
+	http.MethodDelete,
+	http.MethodPatch,
+}
+
+// Document определяет спецификацию OpenAPI.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	raw    []byte
+	routes []*route
+}
+
+// PathItem определяет операции пути.
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters"`
+	Get        *Operation   `json:"get"`
+	Put        *Operation   `json:"put"`
+	Post       *Operation   `json:"post"`
+	Delete     *Operation   `json:"delete"`
+	Patch      *Operation   `json:"patch"`
+}
+
+// operation возвращает операцию пути для метода method.
+func (p *PathItem) operation(method string) *Operation {
+	switch method {
+	case http.MethodGet:
+		return p.Get
+	case http.MethodPut:
+		return p.Put
+	case http.MethodPost:
+		return p.Post
+	case http.MethodDelete:
+		return p.Delete
+	case http.MethodPatch:
+		return p.Patch
+	default:
+		return nil
+	}
+}
+
+// Operation определяет операцию API.
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// Parameter определяет параметр пути или запроса.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"` // path, query или header.
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody определяет тело запроса.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// MediaType определяет схему тела запроса для типа содержимого.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components определяет переиспользуемые схемы.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema определяет схему значения; additionalProperties поддерживается
+// только в логической форме.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []any              `json:"enum"`
+	Pattern              string             `json:"pattern"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	Items                *Schema            `json:"items"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+
+	pattern *regexp.Regexp
+}
+
+// Route определяет метод и шаблон пути операции.
+type Route struct {
+	Method string
+	Path   string
+}
+
+func (r Route) String() string {
+	return r.Method + " " + r.Path
+}
+
+// route определяет операцию, сопоставляемую с путём запроса.
+type route struct {
+	method    string
+	path      string
+	segments  []string
+	params    []*Parameter
+	operation *Operation
+}
+
+// static возвращает количество неизменяемых сегментов шаблона пути.
+func (r *route) static() int {
+	n := 0
+	for _, s := range r.segments {
+		if !isTemplate(s) {
+			n++
+		}
+	}
+	return n
+}
+
+// match сопоставляет путь запроса с шаблоном и возвращает значения
+// параметров пути.
+func (r *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+
+	var params map[string]string
+
+	for i, s := range r.segments {
+		if !isTemplate(s) {
+			if s != segments[i] {
+				return nil, false
+			}
+			continue
+		}
+		if segments[i] == "" {
+			return nil, false
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[s[1:len(s)-1]] = segments[i]
+	}
+
+	return params, true
+}
+
+func isTemplate(segment string) bool {
+	return strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// Parse разбирает спецификацию в формате JSON и разрешает ссылки на схемы
+// компонентов.
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("decoding the document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version: %q", doc.OpenAPI)
+	}
+
+	doc.raw = data
+
+	for name, schema := range doc.Components.Schemas {
+		err = doc.resolve(schema, map[*Schema]bool{})
+		if err != nil {
+			return nil, fmt.Errorf("components.schemas.%s: %w", name, err)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("path %q must start with /", path)
+		}
+		for _, method := range methods {
+			op := item.operation(method)
+			if op == nil {
+				continue
+			}
+
+			r := &route{
+				method:    method,
+				path:      path,
+				segments:  strings.Split(path, "/"),
+				params:    append(append([]*Parameter(nil), item.Parameters...), op.Parameters...),
+				operation: op,
+			}
+
+			err = doc.resolveOperation(r)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+
+			doc.routes = append(doc.routes, r)
+		}
+	}
+
+	sort.Slice(doc.routes, func(i, j int) bool {
+		a, b := doc.routes[i], doc.routes[j]
+		if a.static() != b.static() {
+			return a.static() > b.static()
+		}
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return a.method < b.method
+	})
+
+	return &doc, nil
+}
+
+// MustParse аналогичен Parse, но паникует при ошибке.
+func MustParse(data []byte) *Document {
+	doc, err := Parse(data)
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// resolveOperation проверяет параметры и разрешает схемы операции.
+func (doc *Document) resolveOperation(r *route) error {
+	for _, p := range r.params {
+		switch p.In {
+		case "path":
+			if !strings.Contains(r.path, "{"+p.Name+"}") {
+				return fmt.Errorf("path parameter %q is not in the path", p.Name)
+			}
+		case "query", "header":
+		default:
+			return fmt.Errorf("parameter %q: unsupported location %q", p.Name, p.In)
+		}
+		if p.Schema == nil {
+			return fmt.Errorf("parameter %q: schema is required", p.Name)
+		}
+		err := doc.resolve(p.Schema, map[*Schema]bool{})
+		if err != nil {
+			return fmt.Errorf("parameter %q: %w", p.Name, err)
+		}
+	}
+
+	if r.operation.RequestBody == nil {
+		return nil
+	}
+	for contentType, mt := range r.operation.RequestBody.Content {
+		if mt == nil || mt.Schema == nil {
+			continue
+		}
+		err := doc.resolve(mt.Schema, map[*Schema]bool{})
+		if err != nil {
+			return fmt.Errorf("request body %s: %w", contentType, err)
+		}
+	}
+
+	return nil
+}
+
+// resolve заменяет ссылки на схемы компонентов самими схемами и компилирует
+// регулярные выражения.
+func (doc *Document) resolve(s *Schema, visited map[*Schema]bool) error {
+	if visited[s] {
+		return nil
+	}
+	visited[s] = true
+
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		if !ok {
+			return fmt.Errorf("unsupported reference: %q", s.Ref)
+		}
+		target, ok := doc.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("unresolved reference: %q", s.Ref)
+		}
+		err := doc.resolve(target, visited)
+		if err != nil {
+			return err
+		}
+		*s = *target
+		return nil
+	}
+
+	if s.Pattern != "" && s.pattern == nil {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("compiling pattern: %w", err)
+		}
+		s.pattern = re
+	}
+
+	if s.Items != nil {
+		err := doc.resolve(s.Items, visited)
+		if err != nil {
+			return fmt.Errorf("items: %w", err)
+		}
+	}
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return errors.New("properties: " + name + ": schema is empty")
+		}
+		err := doc.resolve(prop, visited)
+		if err != nil {
+			return fmt.Errorf("properties.%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Routes возвращает операции спецификации, упорядоченные по пути и методу.
+func (doc *Document) Routes() []Route {
+	routes := make([]Route, len(doc.routes))
+	for i, r := range doc.routes {
+		routes[i] = Route{Method: r.method, Path: r.path}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Find возвращает операцию для метода и пути запроса и значения параметров
+// пути.
+func (doc *Document) Find(method, path string) (*Operation, map[string]string, bool) {
+	r, params, ok := doc.find(method, path)
+	if !ok {
+		return nil, nil, false
+	}
+	return r.operation, params, true
+}
+
+func (doc *Document) find(method, path string) (*route, map[string]string, bool) {
+	segments := strings.Split(path, "/")
+	for _, r := range doc.routes {
+		if r.method != method {
+			continue
+		}
+		params, ok := r.match(segments)
+		if ok {
+			return r, params, true
+		}
+	}
+	return nil, nil, false
+}
+
+// ServeHTTP возвращает исходный документ спецификации.
+func (doc *Document) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(doc.raw)
+}
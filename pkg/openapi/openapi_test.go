@@ -0,0 +1,302 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/openapi"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+)
+
+const spec = `{
+	"openapi": "3.0.3",
+	"info": {"title": "test", "version": "1.0.0"},
+	"paths": {
+		"/api/orders": {
+			"post": {
+				"requestBody": {
+					"required": true,
+					"content": {"text/plain": {"schema": {"type": "string", "pattern": "^[0-9]+$"}}}
+				}
+			},
+			"put": {
+				"requestBody": {
+					"content": {
+						"text/plain": {"schema": {"type": "string"}},
+						"application/json": {"schema": {"type": "object"}}
+					}
+				}
+			}
+		},
+		"/api/orders/{number}": {
+			"parameters": [{"name": "number", "in": "path", "required": true, "schema": {"type": "string"}}],
+			"get": {}
+		},
+		"/api/orders/latest": {
+			"get": {}
+		},
+		"/api/goods": {
+			"post": {
+				"requestBody": {
+					"required": true,
+					"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Match"}}}
+				}
+			}
+		},
+		"/api/events": {
+			"get": {
+				"parameters": [
+					{"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1}},
+					{"name": "type", "in": "query", "schema": {"type": "array", "items": {"type": "string", "enum": ["a", "b"]}}},
+					{"name": "user_id", "in": "query", "schema": {"type": "string", "format": "uuid"}}
+				]
+			}
+		}
+	},
+	"components": {
+		"schemas": {
+			"Match": {
+				"type": "object",
+				"required": ["match", "reward"],
+				"additionalProperties": false,
+				"properties": {
+					"match": {"type": "string", "minLength": 1},
+					"reward": {"type": "number", "minimum": 0},
+					"reward_type": {"$ref": "#/components/schemas/RewardType"}
+				}
+			},
+			"RewardType": {"type": "string", "enum": ["%", "pt"]}
+		}
+	}
+}`
+
+func TestParse(t *testing.T) {
+	t.Run("routes", func(t *testing.T) {
+		doc, err := openapi.Parse([]byte(spec))
+		require.NoError(t, err)
+
+		require.Equal(t, []openapi.Route{
+			{Method: http.MethodGet, Path: "/api/events"},
+			{Method: http.MethodPost, Path: "/api/goods"},
+			{Method: http.MethodPost, Path: "/api/orders"},
+			{Method: http.MethodPut, Path: "/api/orders"},
+			{Method: http.MethodGet, Path: "/api/orders/latest"},
+			{Method: http.MethodGet, Path: "/api/orders/{number}"},
+		}, doc.Routes())
+	})
+
+	t.Run("unresolved reference", func(t *testing.T) {
+		_, err := openapi.Parse([]byte(`{
+			"openapi": "3.0.3",
+			"paths": {"/": {"post": {"requestBody": {"content": {
+				"application/json": {"schema": {"$ref": "#/components/schemas/Missing"}}
+			}}}}}
+		}`))
+		require.Error(t, err)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := openapi.Parse([]byte(`{"swagger": "2.0"}`))
+		require.Error(t, err)
+	})
+}
+
+func TestDocument_Find(t *testing.T) {
+	doc := openapi.MustParse([]byte(spec))
+
+	_, params, ok := doc.Find(http.MethodGet, "/api/orders/42")
+	require.True(t, ok)
+	require.Equal(t, map[string]string{"number": "42"}, params)
+
+	_, params, ok = doc.Find(http.MethodGet, "/api/orders/latest")
+	require.True(t, ok)
+	require.Empty(t, params)
+
+	_, _, ok = doc.Find(http.MethodDelete, "/api/orders/42")
+	require.False(t, ok)
+
+	_, _, ok = doc.Find(http.MethodGet, "/api/orders/")
+	require.False(t, ok)
+
+	// Путь сопоставляется точно: завершающая косая черта значима.
+	_, _, ok = doc.Find(http.MethodPost, "/api/orders/")
+	require.False(t, ok)
+}
+
+func TestValidate(t *testing.T) {
+	doc := openapi.MustParse([]byte(spec))
+
+	var body string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	})
+	h := openapi.Validate(doc)(next)
+
+	testCases := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		code        string
+		fields      []string
+	}{
+		{
+			name:        "valid text",
+			method:      http.MethodPost,
+			target:      "/api/orders",
+			contentType: "text/plain; charset=utf-8",
+			body:        "12345",
+		},
+		{
+			name:        "text pattern",
+			method:      http.MethodPost,
+			target:      "/api/orders",
+			contentType: "text/plain",
+			body:        "12a45",
+			code:        openapi.CodeSchemaViolation,
+			fields:      []string{"body"},
+		},
+		{
+			name:   "unsupported content type",
+			method: http.MethodPost,
+			target: "/api/orders",
+			body:   "12345",
+			code:   openapi.CodeUnsupportedContentType,
+		},
+		{
+			name:        "body required",
+			method:      http.MethodPost,
+			target:      "/api/orders",
+			contentType: "text/plain",
+			code:        openapi.CodeSchemaViolation,
+		},
+		{
+			name:   "valid json without content type",
+			method: http.MethodPost,
+			target: "/api/goods",
+			body:   `{"match":"Bork","reward":10,"reward_type":"%"}`,
+		},
+		{
+			name:   "ambiguous content type",
+			method: http.MethodPut,
+			target: "/api/orders",
+			body:   `{}`,
+			code:   openapi.CodeUnsupportedContentType,
+		},
+		{
+			name:        "malformed json",
+			method:      http.MethodPost,
+			target:      "/api/goods",
+			contentType: "application/json",
+			body:        `{"match":`,
+			code:        openapi.CodeMalformedBody,
+		},
+		{
+			name:        "json schema",
+			method:      http.MethodPost,
+			target:      "/api/goods",
+			contentType: "application/json",
+			body:        `{"match":"","reward_type":"x","extra":true}`,
+			code:        openapi.CodeSchemaViolation,
+			fields:      []string{"body.reward", "body.extra", "body.match", "body.reward_type"},
+		},
+		{
+			name:   "valid query",
+			method: http.MethodGet,
+			target: "/api/events?limit=10&type=a&type=b&user_id=6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		},
+		{
+			name:   "invalid query",
+			method: http.MethodGet,
+			target: "/api/events?limit=0&type=c&user_id=1",
+			code:   openapi.CodeSchemaViolation,
+			fields: []string{"query.limit", "query.type[0]", "query.user_id"},
+		},
+		{
+			name:   "unknown route",
+			method: http.MethodGet,
+			target: "/api/unknown?limit=x",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body = ""
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			h.ServeHTTP(rec, req)
+
+			if tc.code == "" {
+				require.Equal(t, http.StatusOK, rec.Code)
+				require.Equal(t, tc.body, body)
+				return
+			}
+
+			require.Equal(t, http.StatusBadRequest, rec.Code)
+			require.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+			var p struct {
+				Code   string               `json:"code"`
+				Errors []openapi.FieldError `json:"errors"`
+			}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+			require.Equal(t, tc.code, p.Code)
+
+			fields := make([]string, len(p.Errors))
+			for i, f := range p.Errors {
+				fields[i] = f.Field
+			}
+			require.Equal(t, len(tc.fields), len(fields))
+			require.ElementsMatch(t, tc.fields, fields)
+		})
+	}
+}
+
+func TestValidate_BodyTooLarge(t *testing.T) {
+	doc := openapi.MustParse([]byte(spec))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := openapi.Validate(doc)(next)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader("1234567890"))
+	req.Header.Set("Content-Type", "text/plain")
+	req.Body = http.MaxBytesReader(rec, req.Body, 4)
+
+	h.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	var p struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	require.Equal(t, openapi.CodeBodyTooLarge, p.Code)
+}
+
+func TestDocument_ServeHTTP(t *testing.T) {
+	doc := openapi.MustParse([]byte(spec))
+
+	rec := httptest.NewRecorder()
+	doc.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", http.NoBody))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.JSONEq(t, spec, rec.Body.String())
+}
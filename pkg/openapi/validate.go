@@ -0,0 +1,408 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+)
+
+// Коды ошибок проверки запроса.
+const (
+	CodeUnsupportedContentType = "unsupported_content_type"
+	CodeMalformedBody          = "malformed_body"
+	CodeSchemaViolation        = "schema_violation"
+	CodeBodyTooLarge           = "body_too_large"
+)
+
+// FieldError определяет нарушение схемы в параметре или поле тела запроса.
+type FieldError struct {
+	Field   string `json:"field"`   // Параметр или поле: path.id, query.limit, header.X-Code, body.login.
+	Message string `json:"message"` // Описание нарушения.
+}
+
+// RequestError определяет ошибку проверки запроса по спецификации.
+type RequestError struct {
+	// Код ответа; по умолчанию http.StatusBadRequest.
+	Status int
+
+	Code   string
+	Detail string
+	Fields []FieldError
+}
+
+func (err *RequestError) Error() string {
+	if len(err.Fields) == 0 {
+		return err.Detail
+	}
+	msgs := make([]string, len(err.Fields))
+	for i, f := range err.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return err.Detail + ": " + strings.Join(msgs, "; ")
+}
+
+// Validate возвращает промежуточный обработчик, который отклоняет
+// с http.StatusBadRequest запросы, не соответствующие операции
+// спецификации; запросы без операции в спецификации пропускаются. Тело
+// запроса, превысившее ограничение http.MaxBytesReader, отклоняется
+// с http.StatusRequestEntityTooLarge.
+func Validate(doc *Document) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			err := doc.ValidateRequest(r)
+			if err != nil {
+				writeRequestError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func writeRequestError(w http.ResponseWriter, r *http.Request, err *RequestError) {
+	logger := logging.FromContext(r.Context())
+	logger.Error(err.Error())
+
+	status := err.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+
+	p := problem.New(status, err.Code, err.Detail)
+	if len(err.Fields) > 0 {
+		p.Errors = err.Fields
+	}
+
+	werr := p.Write(w, r)
+	if werr != nil {
+		logger.Error(werr.Error())
+	}
+}
+
+// ValidateRequest проверяет параметры и тело запроса по операции
+// спецификации; прочитанное тело запроса заменяется копией.
+func (doc *Document) ValidateRequest(r *http.Request) *RequestError {
+	rt, pathParams, ok := doc.find(r.Method, r.URL.Path)
+	if !ok {
+		return nil
+	}
+
+	var fields []FieldError
+
+	query := r.URL.Query()
+	for _, p := range rt.params {
+		field := p.In + "." + p.Name
+
+		var values []string
+		switch p.In {
+		case "path":
+			if v, ok := pathParams[p.Name]; ok {
+				values = []string{v}
+			}
+		case "query":
+			values = query[p.Name]
+		case "header":
+			values = r.Header.Values(p.Name)
+		}
+
+		if len(values) == 0 {
+			if p.Required {
+				fields = append(fields, FieldError{Field: field, Message: "is required"})
+			}
+			continue
+		}
+
+		fields = validateParameter(fields, field, values, p.Schema)
+	}
+
+	if len(fields) > 0 {
+		return &RequestError{
+			Code:   CodeSchemaViolation,
+			Detail: "request parameters do not match the API specification",
+			Fields: fields,
+		}
+	}
+
+	if rt.operation.RequestBody == nil {
+		return nil
+	}
+
+	return validateBody(r, rt.operation.RequestBody)
+}
+
+// validateParameter проверяет значения параметра, приводя их к типу схемы.
+func validateParameter(fields []FieldError, field string, values []string, s *Schema) []FieldError {
+	if s.Type == "array" {
+		items := make([]any, 0, len(values))
+		for _, v := range values {
+			value, err := parseParameter(v, s.Items)
+			if err != nil {
+				return append(fields, FieldError{Field: field, Message: err.Error()})
+			}
+			items = append(items, value)
+		}
+		return validateValue(fields, field, items, s)
+	}
+
+	value, err := parseParameter(values[0], s)
+	if err != nil {
+		return append(fields, FieldError{Field: field, Message: err.Error()})
+	}
+	return validateValue(fields, field, value, s)
+}
+
+// parseParameter приводит строковое значение параметра к типу схемы.
+func parseParameter(v string, s *Schema) (any, error) {
+	if s == nil {
+		return v, nil
+	}
+	switch s.Type {
+	case "integer", "number":
+		_, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a %s", s.Type)
+		}
+		return json.Number(v), nil
+	case "boolean":
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		return b, nil
+	default:
+		return v, nil
+	}
+}
+
+// validateBody проверяет тип содержимого и тело запроса.
+func validateBody(r *http.Request, body *RequestBody) *RequestError {
+	contentType := r.Header.Get("Content-Type")
+
+	var mediaType string
+	if contentType == "" {
+		// Клиенты JSON API исторически не передают Content-Type; если
+		// операция принимает и другие типы, то тип тела не угадывается.
+		if _, ok := body.Content["application/json"]; ok && len(body.Content) == 1 {
+			mediaType = "application/json"
+		}
+	} else {
+		mt, _, err := mime.ParseMediaType(contentType)
+		if err == nil {
+			mediaType = mt
+		}
+	}
+
+	media, ok := body.Content[mediaType]
+	if !ok {
+		supported := make([]string, 0, len(body.Content))
+		for mt := range body.Content {
+			supported = append(supported, mt)
+		}
+		sort.Strings(supported)
+		return &RequestError{
+			Code: CodeUnsupportedContentType,
+			Detail: fmt.Sprintf("content type %q is not supported, expected %s",
+				contentType, strings.Join(supported, " or ")),
+		}
+	}
+
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return &RequestError{
+				Status: http.StatusRequestEntityTooLarge,
+				Code:   CodeBodyTooLarge,
+				Detail: fmt.Sprintf("request body exceeds %d bytes", maxBytesErr.Limit),
+			}
+		}
+		return &RequestError{Code: CodeMalformedBody, Detail: "reading request body failed"}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+
+	if len(b) == 0 {
+		if body.Required {
+			return &RequestError{Code: CodeSchemaViolation, Detail: "request body is required"}
+		}
+		return nil
+	}
+	if media == nil || media.Schema == nil {
+		return nil
+	}
+
+	var value any
+	if isJSON(mediaType) {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		err = dec.Decode(&value)
+		if err != nil {
+			return &RequestError{Code: CodeMalformedBody, Detail: "request body is not valid JSON"}
+		}
+	} else {
+		value = string(b)
+	}
+
+	fields := validateValue(nil, "body", value, media.Schema)
+	if len(fields) > 0 {
+		return &RequestError{
+			Code:   CodeSchemaViolation,
+			Detail: "request body does not match the API specification",
+			Fields: fields,
+		}
+	}
+
+	return nil
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// validateValue проверяет значение по схеме и дополняет fields найденными
+// нарушениями.
+func validateValue(fields []FieldError, field string, value any, s *Schema) []FieldError {
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return fields
+		}
+		return append(fields, FieldError{Field: field, Message: "must not be null"})
+	}
+
+	fail := func(format string, args ...any) []FieldError {
+		return append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fields = append(fields, FieldError{Field: field + "." + name, Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			v := obj[name]
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fields = append(fields, FieldError{Field: field + "." + name, Message: "is not allowed"})
+				}
+				continue
+			}
+			fields = validateValue(fields, field+"."+name, v, prop)
+		}
+		return fields
+
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.Items != nil {
+			for i, v := range arr {
+				fields = validateValue(fields, field+"["+strconv.Itoa(i)+"]", v, s.Items)
+			}
+		}
+		return fields
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		n := utf8.RuneCountInString(str)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			return fail("must match %s", s.Pattern)
+		}
+		if msg := validateFormat(str, s.Format); msg != "" {
+			return fail("%s", msg)
+		}
+
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return fail("must be a %s", s.Type)
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return fail("must be a %s", s.Type)
+		}
+		if s.Type == "integer" {
+			if _, err = num.Int64(); err != nil {
+				return fail("must be an integer")
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fail("must be at most %v", *s.Maximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(value, s.Enum) {
+		return fail("must be one of %v", s.Enum)
+	}
+
+	return fields
+}
+
+// validateFormat проверяет строку по формату схемы; неизвестные форматы
+// не проверяются.
+func validateFormat(str, format string) string {
+	switch format {
+	case "uuid":
+		if _, err := uuid.Parse(str); err != nil {
+			return "must be a UUID"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return "must be an RFC 3339 date-time"
+		}
+	}
+	return ""
+}
+
+func inEnum(value any, enum []any) bool {
+	s := fmt.Sprint(value)
+	for _, e := range enum {
+		if fmt.Sprint(e) == s {
+			return true
+		}
+	}
+	return false
+}
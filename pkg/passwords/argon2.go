@@ -0,0 +1,143 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Префикс хеш-суммы argon2id в формате PHC.
+const argon2idPrefix = "$argon2id$"
+
+var _ Algorithm = Argon2id{}
+
+// Argon2id определяет алгоритм хеширования argon2id. Хеш-сумма хранится
+// в формате PHC: $argon2id$v=19$m=19456,t=2,p=1$<соль>$<хеш>.
+//
+// Нулевые значения параметров заменяются рекомендациями OWASP.
+type Argon2id struct {
+	Memory  uint32 // Объём памяти в КиБ.
+	Time    uint32 // Количество проходов.
+	Threads uint8  // Степень параллелизма.
+	SaltLen uint32 // Длина соли в байтах.
+	KeyLen  uint32 // Длина хеша в байтах.
+}
+
+func (a Argon2id) withDefaults() Argon2id {
+	if a.Memory == 0 {
+		a.Memory = 19 * 1024
+	}
+	if a.Time == 0 {
+		a.Time = 2
+	}
+	if a.Threads == 0 {
+		a.Threads = 1
+	}
+	if a.SaltLen == 0 {
+		a.SaltLen = 16
+	}
+	if a.KeyLen == 0 {
+		a.KeyLen = 32
+	}
+	return a
+}
+
+// Hash реализует интерфейс Algorithm.
+func (a Argon2id) Hash(password []byte) (string, error) {
+	a = a.withDefaults()
+
+	salt := make([]byte, a.SaltLen)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return "", fmt.Errorf("reading random bytes: %w", err)
+	}
+
+	key := argon2.IDKey(password, salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+
+	return a.encode(salt, key), nil
+}
+
+// Compare реализует интерфейс Algorithm.
+func (Argon2id) Compare(hashedPassword string, password []byte) bool {
+	params, salt, key, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// Identify реализует интерфейс Algorithm.
+func (Argon2id) Identify(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, argon2idPrefix)
+}
+
+// Outdated реализует интерфейс Algorithm.
+func (a Argon2id) Outdated(hashedPassword string) bool {
+	params, _, _, err := decodeArgon2id(hashedPassword)
+	if err != nil {
+		return true
+	}
+	return params != a.withDefaults()
+}
+
+func (a Argon2id) encode(salt, key []byte) string {
+	b64 := base64.RawStdEncoding
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		a.Memory,
+		a.Time,
+		a.Threads,
+		b64.EncodeToString(salt),
+		b64.EncodeToString(key),
+	)
+}
+
+func decodeArgon2id(hashedPassword string) (params Argon2id, salt, key []byte, err error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || "$"+parts[1]+"$" != argon2idPrefix {
+		return Argon2id{}, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	_, err = fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("parsing version: %w", err)
+	}
+	if version != argon2.Version {
+		return Argon2id{}, nil, nil, fmt.Errorf("unsupported argon2 version: %d", version)
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("parsing parameters: %w", err)
+	}
+
+	b64 := base64.RawStdEncoding
+
+	salt, err = b64.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("decoding salt: %w", err)
+	}
+
+	key, err = b64.DecodeString(parts[5])
+	if err != nil {
+		return Argon2id{}, nil, nil, fmt.Errorf("decoding key: %w", err)
+	}
+	if len(key) == 0 {
+		return Argon2id{}, nil, nil, errors.New("key is empty")
+	}
+
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+
+	return params, salt, key, nil
+}
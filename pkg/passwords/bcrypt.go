@@ -0,0 +1,52 @@
+package passwords
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Максимальная длина пароля, которую учитывает bcrypt.
+const maxBcryptLen = 72
+
+var _ Algorithm = Bcrypt{}
+
+// Bcrypt определяет алгоритм хеширования bcrypt.
+type Bcrypt struct {
+	// Стоимость хеширования.
+	//
+	// По умолчанию bcrypt.DefaultCost.
+	Cost int
+}
+
+func (b Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
+}
+
+// Hash реализует интерфейс Algorithm.
+func (b Bcrypt) Hash(password []byte) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword(password, b.cost())
+	return string(hashedPassword), err
+}
+
+// Compare реализует интерфейс Algorithm.
+func (Bcrypt) Compare(hashedPassword string, password []byte) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), password)
+	return err == nil
+}
+
+// Identify реализует интерфейс Algorithm.
+func (Bcrypt) Identify(hashedPassword string) bool {
+	return strings.HasPrefix(hashedPassword, "$2a$") ||
+		strings.HasPrefix(hashedPassword, "$2b$") ||
+		strings.HasPrefix(hashedPassword, "$2y$")
+}
+
+// Outdated реализует интерфейс Algorithm.
+func (b Bcrypt) Outdated(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != b.cost()
+}
@@ -1,20 +1,129 @@
 package passwords
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 )
 
-const cost = bcrypt.MinCost
+// ErrUnknownHash возвращается, если хеш-сумма пароля получена неизвестным
+// алгоритмом.
+var ErrUnknownHash = errors.New("unknown password hash")
 
-// Hash возвращает хеш пароля. Длина пароля должна составлять не более
-// 72 символов.
-func Hash(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), cost)
-	return string(hashedPassword), err
+// Algorithm описывает интерфейс алгоритма хеширования паролей.
+type Algorithm interface {
+	// Hash возвращает хеш-сумму пароля.
+	Hash(password []byte) (string, error)
+
+	// Compare сравнивает пароль и его хеш-сумму.
+	Compare(hashedPassword string, password []byte) bool
+
+	// Identify возвращает true, если хеш-сумма получена этим алгоритмом.
+	Identify(hashedPassword string) bool
+
+	// Outdated возвращает true, если хеш-сумма получена этим алгоритмом
+	// с параметрами, отличными от текущих.
+	Outdated(hashedPassword string) bool
+}
+
+// Option устанавливает не обязательные свойства для Hasher.
+type Option func(*Hasher)
+
+// WithPepper устанавливает серверный секрет, который подмешивается к паролю
+// перед хешированием и не хранится в БД.
+//
+// NOTE: смена секрета делает недействительными все хеш-суммы, полученные
+// с предыдущим секретом.
+func WithPepper(pepper []byte) Option {
+	return func(h *Hasher) {
+		h.pepper = pepper
+	}
+}
+
+// WithLegacy добавляет алгоритмы, хеш-суммы которых проверяются, но больше
+// не создаются.
+func WithLegacy(algorithms ...Algorithm) Option {
+	return func(h *Hasher) {
+		h.legacy = append(h.legacy, algorithms...)
+	}
+}
+
+// Hasher определяет хеширование паролей: новые хеш-суммы создаются основным
+// алгоритмом, а хеш-суммы устаревших алгоритмов и параметров проверяются
+// и помечаются для пересчёта.
+//
+// Перед хешированием пароль заменяется на HMAC-SHA256 от серверного секрета,
+// поэтому длина пароля не ограничивается алгоритмом.
+type Hasher struct {
+	algorithm Algorithm
+	legacy    []Algorithm
+	pepper    []byte
+}
+
+// New возвращает новый экземпляр Hasher с основным алгоритмом algorithm.
+// Хеш-суммы bcrypt проверяются всегда.
+func New(algorithm Algorithm, opts ...Option) *Hasher {
+	h := &Hasher{algorithm: algorithm}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if _, ok := algorithm.(Bcrypt); !ok {
+		h.legacy = append(h.legacy, Bcrypt{})
+	}
+	return h
 }
 
-// Compare сравнивает пароль и его хеш.
-func Compare(hashedPassword string, password string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
-	return err == nil
+// Hash возвращает хеш-сумму пароля.
+func (h *Hasher) Hash(password string) (string, error) {
+	return h.algorithm.Hash(h.prepare(password))
+}
+
+// Verify сравнивает пароль и его хеш-сумму; rehash равен true, если пароль
+// верен, а хеш-сумма получена устаревшим алгоритмом или с устаревшими
+// параметрами и должна быть пересчитана.
+func (h *Hasher) Verify(hashedPassword, password string) (ok, rehash bool) {
+	algorithm := h.identify(hashedPassword)
+	if algorithm == nil {
+		return false, false
+	}
+
+	if algorithm.Compare(hashedPassword, h.prepare(password)) {
+		return true, algorithm != h.algorithm || algorithm.Outdated(hashedPassword)
+	}
+
+	// Хеш-суммы bcrypt, созданные до перехода на Hasher, получены
+	// из пароля без подготовки.
+	if _, ok := algorithm.(Bcrypt); ok && len(password) <= maxBcryptLen {
+		if algorithm.Compare(hashedPassword, []byte(password)) {
+			return true, true
+		}
+	}
+
+	return false, false
+}
+
+// identify возвращает алгоритм, которым получена хеш-сумма.
+func (h *Hasher) identify(hashedPassword string) Algorithm {
+	if h.algorithm.Identify(hashedPassword) {
+		return h.algorithm
+	}
+	for _, algorithm := range h.legacy {
+		if algorithm.Identify(hashedPassword) {
+			return algorithm
+		}
+	}
+	return nil
+}
+
+// prepare возвращает HMAC-SHA256 пароля в base64.
+func (h *Hasher) prepare(password string) []byte {
+	mac := hmac.New(sha256.New, h.pepper)
+	mac.Write([]byte(password))
+	sum := mac.Sum(nil)
+
+	buf := make([]byte, base64.StdEncoding.EncodedLen(len(sum)))
+	base64.StdEncoding.Encode(buf, sum)
+
+	return buf
 }
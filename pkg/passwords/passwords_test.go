@@ -4,47 +4,113 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/sergeizaitcev/gophermart/pkg/passwords"
 	"github.com/sergeizaitcev/gophermart/pkg/randutil"
 )
 
+// Параметры argon2id, ускоряющие тесты.
+var fastArgon2id = passwords.Argon2id{Memory: 64, Time: 1}
+
 func TestPasswords(t *testing.T) {
-	testCases := []struct {
+	algorithms := []struct {
 		name      string
-		password  string
-		wantError bool
+		algorithm passwords.Algorithm
+	}{
+		{name: "bcrypt", algorithm: passwords.Bcrypt{Cost: bcrypt.MinCost}},
+		{name: "argon2id", algorithm: fastArgon2id},
+	}
+
+	testCases := []struct {
+		name     string
+		password string
 	}{
 		{
-			name:      "small",
-			password:  randutil.String(10),
-			wantError: false,
+			name:     "small",
+			password: randutil.String(10),
 		},
 		{
-			name:      "medium",
-			password:  randutil.String(50),
-			wantError: false,
+			name:     "medium",
+			password: randutil.String(50),
 		},
 		{
-			name:      "large",
-			password:  randutil.String(73),
-			wantError: true,
+			name:     "large",
+			password: randutil.String(200),
 		},
 	}
 
-	for _, tc := range testCases {
-		tc := tc
+	for _, alg := range algorithms {
+		for _, tc := range testCases {
+			alg, tc := alg, tc
 
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
+			t.Run(alg.name+"/"+tc.name, func(t *testing.T) {
+				t.Parallel()
 
-			hashedPassword, err := passwords.Hash(tc.password)
+				hasher := passwords.New(alg.algorithm, passwords.WithPepper(randutil.Bytes(32)))
 
-			if tc.wantError {
-				require.Error(t, err)
-			} else {
-				require.True(t, passwords.Compare(hashedPassword, tc.password))
-			}
-		})
+				hashedPassword, err := hasher.Hash(tc.password)
+				require.NoError(t, err)
+
+				ok, rehash := hasher.Verify(hashedPassword, tc.password)
+				require.True(t, ok)
+				require.False(t, rehash)
+
+				// Пароль, отличающийся только после 72-го байта.
+				ok, _ = hasher.Verify(hashedPassword, tc.password+"x")
+				require.False(t, ok)
+			})
+		}
 	}
 }
+
+func TestArgon2idFormat(t *testing.T) {
+	hasher := passwords.New(fastArgon2id)
+
+	hashedPassword, err := hasher.Hash("password")
+	require.NoError(t, err)
+	require.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`, hashedPassword)
+}
+
+func TestRehash(t *testing.T) {
+	t.Run("legacy bcrypt", func(t *testing.T) {
+		legacy, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+		require.NoError(t, err)
+
+		hasher := passwords.New(fastArgon2id, passwords.WithPepper(randutil.Bytes(32)))
+
+		ok, rehash := hasher.Verify(string(legacy), "password")
+		require.True(t, ok)
+		require.True(t, rehash)
+
+		ok, rehash = hasher.Verify(string(legacy), "invalid")
+		require.False(t, ok)
+		require.False(t, rehash)
+	})
+
+	t.Run("bcrypt cost", func(t *testing.T) {
+		hashedPassword, err := passwords.New(passwords.Bcrypt{Cost: bcrypt.MinCost}).Hash("password")
+		require.NoError(t, err)
+
+		ok, rehash := passwords.New(passwords.Bcrypt{Cost: bcrypt.MinCost + 1}).
+			Verify(hashedPassword, "password")
+		require.True(t, ok)
+		require.True(t, rehash)
+	})
+
+	t.Run("argon2id parameters", func(t *testing.T) {
+		hashedPassword, err := passwords.New(fastArgon2id).Hash("password")
+		require.NoError(t, err)
+
+		ok, rehash := passwords.New(passwords.Argon2id{Memory: 128, Time: 1}).
+			Verify(hashedPassword, "password")
+		require.True(t, ok)
+		require.True(t, rehash)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		ok, rehash := passwords.New(fastArgon2id).Verify("plain", "plain")
+		require.False(t, ok)
+		require.False(t, rehash)
+	})
+}
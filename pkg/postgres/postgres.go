@@ -7,11 +7,14 @@ import (
 	"time"
 
 	_ "github.com/lib/pq"
+
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
 )
 
-// Connect выполняет подключение к postgres.
+// Connect выполняет подключение к postgres. Запросы с участком трассы
+// в контексте оборачиваются в дочерние участки.
 func Connect(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", dsn)
+	db, err := tracing.OpenDB("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("connection to the database: %w", err)
 	}
@@ -0,0 +1,117 @@
+// Package problem реализует ответы об ошибках в формате RFC 7807
+// (application/problem+json) со стабильными машиночитаемыми кодами.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+)
+
+// ContentType определяет тип содержимого ответа об ошибке.
+const ContentType = "application/problem+json"
+
+// Problem определяет тело ответа об ошибке.
+type Problem struct {
+	Type      string `json:"type,omitempty"`       // URI типа ошибки; пуст для about:blank.
+	Title     string `json:"title"`                // Краткое описание кода ответа.
+	Status    int    `json:"status"`               // Код ответа.
+	Detail    string `json:"detail,omitempty"`     // Описание ошибки для человека.
+	Instance  string `json:"instance,omitempty"`   // Путь запроса.
+	Code      string `json:"code"`                 // Стабильный код ошибки.
+	RequestID string `json:"request_id,omitempty"` // Идентификатор запроса.
+	Errors    any    `json:"errors,omitempty"`     // Ошибки валидации полей.
+}
+
+// Mapping определяет соответствие ошибки стабильному коду и описанию.
+type Mapping struct {
+	Err    error
+	Code   string
+	Detail string
+}
+
+// Lookup возвращает первое соответствие, ошибка которого совпадает с err
+// по errors.Is.
+func Lookup(err error, mappings []Mapping) (Mapping, bool) {
+	if err == nil {
+		return Mapping{}, false
+	}
+	for _, m := range mappings {
+		if errors.Is(err, m.Err) {
+			return m, true
+		}
+	}
+	return Mapping{}, false
+}
+
+// Коды ошибок по умолчанию для кодов ответа.
+var defaultCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthenticated",
+	http.StatusPaymentRequired:       "payment_required",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusUnsupportedMediaType:  "unsupported_media_type",
+	http.StatusUnprocessableEntity:   "unprocessable_entity",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+// DefaultCode возвращает код ошибки по умолчанию для кода ответа.
+func DefaultCode(status int) string {
+	code, ok := defaultCodes[status]
+	if !ok {
+		if status >= http.StatusInternalServerError {
+			return "internal_error"
+		}
+		return "error"
+	}
+	return code
+}
+
+// New возвращает ошибку с кодом ответа status и стабильным кодом code;
+// пустой code заменяется кодом по умолчанию.
+func New(status int, code, detail string) *Problem {
+	if code == "" {
+		code = DefaultCode(status)
+	}
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// FromError возвращает ошибку с кодом ответа status, код и описание которой
+// берутся из первого подходящего соответствия; описание неизвестных ошибок
+// не раскрывается.
+func FromError(status int, err error, mappings []Mapping) *Problem {
+	m, _ := Lookup(err, mappings)
+	return New(status, m.Code, m.Detail)
+}
+
+// Write записывает ошибку в ответ, дополняя её путём и идентификатором
+// запроса.
+func (p *Problem) Write(w http.ResponseWriter, r *http.Request) error {
+	if r != nil {
+		if p.Instance == "" {
+			p.Instance = r.URL.Path
+		}
+		if p.RequestID == "" {
+			p.RequestID = logging.RequestID(r.Context())
+		}
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+
+	return json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,60 @@
+package problem_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+)
+
+var errNotDigits = errors.New("not digits")
+
+var mappings = []problem.Mapping{
+	{Err: errNotDigits, Code: "not_digits", Detail: "order number must contain only digits"},
+}
+
+func TestFromError(t *testing.T) {
+	t.Run("known", func(t *testing.T) {
+		err := fmt.Errorf("validating: %w", errNotDigits)
+		p := problem.FromError(http.StatusUnprocessableEntity, err, mappings)
+
+		require.Equal(t, "not_digits", p.Code)
+		require.Equal(t, "order number must contain only digits", p.Detail)
+		require.Equal(t, http.StatusUnprocessableEntity, p.Status)
+		require.Equal(t, "Unprocessable Entity", p.Title)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		p := problem.FromError(http.StatusInternalServerError, errors.New("secret"), mappings)
+
+		require.Equal(t, "internal_error", p.Code)
+		require.Empty(t, p.Detail)
+	})
+}
+
+func TestProblem_Write(t *testing.T) {
+	ctx := logging.WithRequestID(context.Background(), "request-1")
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", http.NoBody).WithContext(ctx)
+
+	err := problem.New(http.StatusConflict, "", "").Write(rec, req)
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+	var p problem.Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	require.Equal(t, "conflict", p.Code)
+	require.Equal(t, "/api/orders", p.Instance)
+	require.Equal(t, "request-1", p.RequestID)
+}
@@ -90,6 +90,28 @@ func (fifo *FIFO[T]) Dequeue(ctx context.Context) (value T, err error) {
 	return value, nil
 }
 
+// Drain извлекает все элементы из очереди, не блокируясь.
+func (fifo *FIFO[T]) Drain() []T {
+	fifo.lazyInit()
+
+	list := <-fifo.list
+
+	values := make([]T, 0, list.size)
+	for list.size > 0 {
+		values = append(values, list.Pop())
+	}
+
+	// Очередь пуста, поэтому уведомление для потребителей сбрасывается.
+	select {
+	case <-fifo.waiters:
+	default:
+	}
+
+	fifo.list <- list
+
+	return values
+}
+
 // linkedNode определяет узел односвязанного списка.
 type linkedNode[T any] struct {
 	value T
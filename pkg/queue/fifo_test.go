@@ -40,3 +40,30 @@ func TestFIFO(t *testing.T) {
 
 	assert.Empty(t, fifo.Size())
 }
+
+func TestFIFO_Drain(t *testing.T) {
+	var fifo queue.FIFO[int]
+
+	assert.Empty(t, fifo.Drain())
+
+	for i := 0; i < 3; i++ {
+		_ = fifo.Enqueue(context.Background(), i+1)
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, fifo.Drain())
+	assert.Empty(t, fifo.Size())
+
+	// После опустошения потребитель ждёт новый элемент.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := fifo.Dequeue(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_ = fifo.Enqueue(context.Background(), 4)
+
+	v, err := fifo.Dequeue(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, 4, v)
+	}
+}
@@ -0,0 +1,68 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JWK определяет открытый ключ в формате JSON Web Key (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS определяет набор открытых ключей в формате JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает открытые ключи асимметричных алгоритмов из набора,
+// упорядоченные по идентификатору. Секретные ключи HS256 не публикуются.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+
+	for _, key := range ks.keys {
+		jwk := JWK{
+			Use: "sig",
+			Alg: string(key.Algorithm),
+			Kid: key.ID,
+		}
+
+		switch pk := key.PrivateKey.(type) {
+		case ed25519.PrivateKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encodeBase64(pk.Public().(ed25519.PublicKey))
+		case *rsa.PrivateKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeBase64(pk.N.Bytes())
+			jwk.E = encodeBase64(big.NewInt(int64(pk.E)).Bytes())
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+
+	return set
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
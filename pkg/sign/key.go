@@ -0,0 +1,131 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algorithm определяет алгоритм подписи токена.
+type Algorithm string
+
+const (
+	HS256 Algorithm = "HS256" // HMAC с SHA-256.
+	EdDSA Algorithm = "EdDSA" // Ed25519.
+	RS256 Algorithm = "RS256" // RSASSA-PKCS1-v1_5 с SHA-256.
+)
+
+// Key определяет ключ подписи.
+type Key struct {
+	// Идентификатор ключа, передаваемый в заголовке kid.
+	ID string
+
+	// Алгоритм подписи.
+	//
+	// По умолчанию HS256.
+	Algorithm Algorithm
+
+	// Секретный ключ для HS256.
+	Secret []byte
+
+	// Закрытый ключ для EdDSA (ed25519.PrivateKey) и RS256 (*rsa.PrivateKey).
+	PrivateKey any
+}
+
+// ParsePrivateKey парсит закрытый ключ Ed25519 или RSA в формате PEM
+// (PKCS #8 или PKCS #1) и возвращает ключ подписи с соответствующим
+// алгоритмом.
+func ParsePrivateKey(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM data found")
+	}
+
+	var (
+		privateKey any
+		err        error
+	)
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("unsupported PEM block type: %q", block.Type)
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("parsing a private key: %w", err)
+	}
+
+	key := Key{ID: id, PrivateKey: privateKey}
+
+	switch privateKey.(type) {
+	case ed25519.PrivateKey:
+		key.Algorithm = EdDSA
+	case *rsa.PrivateKey:
+		key.Algorithm = RS256
+	default:
+		return Key{}, fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+
+	return key, nil
+}
+
+// validate возвращает ошибку, если ключ не соответствует алгоритму.
+func (k Key) validate() error {
+	switch k.Algorithm {
+	case HS256:
+		if len(k.Secret) == 0 {
+			return errors.New("secret is empty")
+		}
+	case EdDSA:
+		if _, ok := k.PrivateKey.(ed25519.PrivateKey); !ok {
+			return fmt.Errorf("%s requires ed25519.PrivateKey, got %T", k.Algorithm, k.PrivateKey)
+		}
+	case RS256:
+		if _, ok := k.PrivateKey.(*rsa.PrivateKey); !ok {
+			return fmt.Errorf("%s requires *rsa.PrivateKey, got %T", k.Algorithm, k.PrivateKey)
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm: %q", k.Algorithm)
+	}
+	return nil
+}
+
+// method возвращает метод подписи JWT.
+func (k Key) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case EdDSA:
+		return jwt.SigningMethodEdDSA
+	case RS256:
+		return jwt.SigningMethodRS256
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// signingKey возвращает ключ для подписи токена.
+func (k Key) signingKey() any {
+	if k.Algorithm == HS256 {
+		return k.Secret
+	}
+	return k.PrivateKey
+}
+
+// verificationKey возвращает ключ для проверки подписи токена.
+func (k Key) verificationKey() any {
+	switch pk := k.PrivateKey.(type) {
+	case ed25519.PrivateKey:
+		return pk.Public()
+	case *rsa.PrivateKey:
+		return pk.Public()
+	default:
+		return k.Secret
+	}
+}
@@ -0,0 +1,97 @@
+package sign
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownKey возвращается, если токен подписан ключом, отсутствующим
+// в наборе.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet определяет набор ключей подписи: новые токены подписываются активным
+// ключом, а остальные ключи используются только для проверки подписи до тех
+// пор, пока не будут удалены из набора.
+//
+// Структура потоко-безопасна.
+type KeySet struct {
+	mu     sync.RWMutex
+	active Key
+	keys   map[string]Key
+}
+
+// NewKeySet возвращает новый экземпляр KeySet с активным ключом active.
+func NewKeySet(active string, keys ...Key) (*KeySet, error) {
+	ks := &KeySet{}
+	err := ks.Update(active, keys...)
+	if err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Update атомарно заменяет все ключи в наборе и устанавливает активный ключ.
+// Ключи, отсутствующие в keys, выводятся из оборота: подписанные ими токены
+// перестают проходить проверку.
+func (ks *KeySet) Update(active string, keys ...Key) error {
+	m := make(map[string]Key, len(keys))
+	for _, key := range keys {
+		if key.Algorithm == "" {
+			key.Algorithm = HS256
+		}
+		err := key.validate()
+		if err != nil {
+			return fmt.Errorf("key %q: %w", key.ID, err)
+		}
+		if _, ok := m[key.ID]; ok {
+			return fmt.Errorf("duplicate key %q", key.ID)
+		}
+		m[key.ID] = key
+	}
+
+	activeKey, ok := m[active]
+	if !ok {
+		return fmt.Errorf("active key %q not found", active)
+	}
+
+	ks.mu.Lock()
+	ks.active = activeKey
+	ks.keys = m
+	ks.mu.Unlock()
+
+	return nil
+}
+
+// Active возвращает активный ключ.
+func (ks *KeySet) Active() Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active
+}
+
+// Lookup возвращает ключ по его идентификатору.
+func (ks *KeySet) Lookup(id string) (Key, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[id]
+	return key, ok
+}
+
+// algorithms возвращает список алгоритмов ключей из набора.
+func (ks *KeySet) algorithms() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	seen := make(map[Algorithm]bool, len(ks.keys))
+	algs := make([]string, 0, len(ks.keys))
+
+	for _, key := range ks.keys {
+		if !seen[key.Algorithm] {
+			seen[key.Algorithm] = true
+			algs = append(algs, string(key.Algorithm))
+		}
+	}
+
+	return algs
+}
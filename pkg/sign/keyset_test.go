@@ -0,0 +1,139 @@
+package sign_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/randutil"
+	"github.com/sergeizaitcev/gophermart/pkg/sign"
+)
+
+func TestKeySetRotation(t *testing.T) {
+	oldKey := sign.Key{ID: "2023-11-01", Secret: randutil.Bytes(32)}
+	newKey := sign.Key{ID: "2023-12-01", Secret: randutil.Bytes(32)}
+
+	keys, err := sign.NewKeySet(oldKey.ID, oldKey)
+	require.NoError(t, err)
+
+	signer := sign.NewWithKeySet(keys)
+	payload := randutil.String(64)
+
+	oldToken, err := signer.Sign(payload)
+	require.NoError(t, err)
+
+	t.Run("rotate", func(t *testing.T) {
+		require.NoError(t, keys.Update(newKey.ID, oldKey, newKey))
+		require.Equal(t, newKey.ID, keys.Active().ID)
+
+		got, err := signer.Parse(oldToken)
+		require.NoError(t, err)
+		require.Equal(t, payload, got)
+
+		newToken, err := signer.Sign(payload)
+		require.NoError(t, err)
+
+		got, err = signer.Parse(newToken)
+		require.NoError(t, err)
+		require.Equal(t, payload, got)
+	})
+
+	t.Run("retire", func(t *testing.T) {
+		require.NoError(t, keys.Update(newKey.ID, newKey))
+
+		_, err := signer.Parse(oldToken)
+		require.ErrorIs(t, err, sign.ErrUnknownKey)
+	})
+
+	t.Run("invalid update", func(t *testing.T) {
+		require.Error(t, keys.Update("unknown", newKey))
+		require.Error(t, keys.Update(newKey.ID, newKey, newKey))
+		require.Error(t, keys.Update("empty", sign.Key{ID: "empty"}))
+		require.Equal(t, newKey.ID, keys.Active().ID)
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys, err := sign.NewKeySet(
+		"ed",
+		sign.Key{ID: "ed", Algorithm: sign.EdDSA, PrivateKey: edKey},
+		sign.Key{ID: "rsa", Algorithm: sign.RS256, PrivateKey: rsaKey},
+		sign.Key{ID: "hmac", Secret: randutil.Bytes(32)},
+	)
+	require.NoError(t, err)
+
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 2)
+
+	require.Equal(t, "ed", jwks.Keys[0].Kid)
+	require.Equal(t, "OKP", jwks.Keys[0].Kty)
+	require.Equal(t, "Ed25519", jwks.Keys[0].Crv)
+	require.NotEmpty(t, jwks.Keys[0].X)
+
+	require.Equal(t, "rsa", jwks.Keys[1].Kid)
+	require.Equal(t, "RSA", jwks.Keys[1].Kty)
+	require.Equal(t, "RS256", jwks.Keys[1].Alg)
+	require.Equal(t, "AQAB", jwks.Keys[1].E)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		data      []byte
+		want      sign.Algorithm
+		wantError bool
+	}{
+		{
+			name: "ed25519 pkcs8",
+			data: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER}),
+			want: sign.EdDSA,
+		},
+		{
+			name: "rsa pkcs1",
+			data: pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+			}),
+			want: sign.RS256,
+		},
+		{
+			name:      "not pem",
+			data:      randutil.Bytes(32),
+			wantError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := sign.ParsePrivateKey("id", tc.data)
+
+			if tc.wantError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, "id", key.ID)
+				require.Equal(t, tc.want, key.Algorithm)
+			}
+		})
+	}
+}
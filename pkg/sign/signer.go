@@ -37,14 +37,25 @@ type Signer interface {
 
 // jwtSigner определеяет подписанта полезной нагрузки, основанного на JWT.
 type jwtSigner struct {
-	secret []byte
-	ttl    time.Duration
+	keys *KeySet
+	ttl  time.Duration
 }
 
-// New возвращает новый экземпляр Signer.
+// New возвращает новый экземпляр Signer с единственным ключом подписи.
 func New(secret []byte, opts ...Option) Signer {
+	key := Key{Algorithm: HS256, Secret: secret}
+	keys := &KeySet{
+		active: key,
+		keys:   map[string]Key{"": key},
+	}
+	return NewWithKeySet(keys, opts...)
+}
+
+// NewWithKeySet возвращает новый экземпляр Signer, который подписывает токены
+// активным ключом из набора и проверяет их ключом, указанным в заголовке kid.
+func NewWithKeySet(keys *KeySet, opts ...Option) Signer {
 	s := &jwtSigner{
-		secret: secret,
+		keys: keys,
 	}
 	for _, opt := range opts {
 		opt(s)
@@ -60,9 +71,14 @@ func (s *jwtSigner) Sign(payload string) (token string, err error) {
 		claims.ExpiresAt = jwt.NewNumericDate(expirationTime)
 	}
 
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	key := s.keys.Active()
+
+	t := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		t.Header["kid"] = key.ID
+	}
 
-	token, err = t.SignedString(s.secret)
+	token, err = t.SignedString(key.signingKey())
 	if err != nil {
 		return "", fmt.Errorf("signing the payload: %w", err)
 	}
@@ -82,7 +98,12 @@ func (s *jwtSigner) Parse(token string) (payload string, err error) {
 func (s *jwtSigner) parseToken(token string) (claims, error) {
 	var c claims
 
-	_, err := jwt.ParseWithClaims(token, &c, s.secretKey)
+	_, err := jwt.ParseWithClaims(
+		token,
+		&c,
+		s.verificationKey,
+		jwt.WithValidMethods(s.keys.algorithms()),
+	)
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
 			err = ErrTokenExpired
@@ -93,9 +114,24 @@ func (s *jwtSigner) parseToken(token string) (claims, error) {
 	return c, nil
 }
 
-func (s *jwtSigner) secretKey(t *jwt.Token) (any, error) {
-	if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+// verificationKey возвращает ключ проверки подписи, указанный в заголовке kid;
+// алгоритм токена должен совпадать с алгоритмом ключа.
+func (s *jwtSigner) verificationKey(t *jwt.Token) (any, error) {
+	// Токены без заголовка kid, выпущенные до перехода на набор ключей,
+	// проверяются активным ключом.
+	key := s.keys.Active()
+
+	kid, ok := t.Header["kid"].(string)
+	if ok {
+		key, ok = s.keys.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+		}
+	}
+
+	if t.Method.Alg() != string(key.Algorithm) {
 		return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 	}
-	return s.secret, nil
+
+	return key.verificationKey(), nil
 }
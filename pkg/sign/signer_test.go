@@ -1,9 +1,13 @@
 package sign_test
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/require"
 
 	"github.com/sergeizaitcev/gophermart/pkg/randutil"
@@ -58,3 +62,76 @@ func TestSigner(t *testing.T) {
 		})
 	}
 }
+
+func TestAsymmetricSigner(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		key  sign.Key
+	}{
+		{
+			name: "eddsa",
+			key:  sign.Key{ID: "ed", Algorithm: sign.EdDSA, PrivateKey: edKey},
+		},
+		{
+			name: "rs256",
+			key:  sign.Key{ID: "rsa", Algorithm: sign.RS256, PrivateKey: rsaKey},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keys, err := sign.NewKeySet(tc.key.ID, tc.key)
+			require.NoError(t, err)
+
+			signer := sign.NewWithKeySet(keys)
+			payload := randutil.String(128)
+
+			token, err := signer.Sign(payload)
+			require.NoError(t, err)
+
+			got, err := signer.Parse(token)
+			require.NoError(t, err)
+			require.Equal(t, payload, got)
+		})
+	}
+}
+
+func TestUnexpectedAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	keys, err := sign.NewKeySet(
+		"rsa",
+		sign.Key{ID: "rsa", Algorithm: sign.RS256, PrivateKey: rsaKey},
+		sign.Key{ID: "hmac", Secret: randutil.Bytes(32)},
+	)
+	require.NoError(t, err)
+
+	signer := sign.NewWithKeySet(keys)
+
+	// Подделка токена: HMAC с открытым ключом RSA в качестве секрета.
+	publicKey := rsaKey.Public().(*rsa.PublicKey)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"payload": "forged"})
+	forged.Header["kid"] = "rsa"
+
+	token, err := forged.SignedString(publicKey.N.Bytes())
+	require.NoError(t, err)
+
+	_, err = signer.Parse(token)
+	require.Error(t, err)
+
+	// Токен без подписи.
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"payload": "forged"})
+
+	token, err = unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	_, err = signer.Parse(token)
+	require.Error(t, err)
+}
@@ -0,0 +1,308 @@
+package throttling
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+)
+
+// Заголовки ответа с состоянием ограничения частоты запросов.
+const (
+	LimitHeader     = "X-RateLimit-Limit"     // Размер корзины.
+	RemainingHeader = "X-RateLimit-Remaining" // Оставшиеся запросы.
+	ResetHeader     = "X-RateLimit-Reset"     // Секунды до полного восстановления.
+)
+
+// Rate определяет допустимое количество запросов за период; нулевое
+// значение снимает ограничение.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseRate разбирает ограничение в формате "100/1m"; пустая строка и "0"
+// снимают ограничение.
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Rate{}, nil
+	}
+
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate %q: expected requests/period", s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		return Rate{}, fmt.Errorf("rate %q: invalid number of requests", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("rate %q: invalid period", s)
+	}
+
+	return Rate{Requests: n, Per: d}, nil
+}
+
+// IsZero возвращает true, если ограничение снято.
+func (r Rate) IsZero() bool {
+	return r.Requests <= 0 || r.Per <= 0
+}
+
+func (r Rate) String() string {
+	if r.IsZero() {
+		return "0"
+	}
+	return strconv.Itoa(r.Requests) + "/" + r.Per.String()
+}
+
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Rate) UnmarshalText(text []byte) error {
+	parsed, err := ParseRate(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// RouteRates определяет ограничения для маршрутов вида "POST /api/user/login".
+type RouteRates map[string]Rate
+
+// ParseRouteRates разбирает ограничения маршрутов в формате
+// "POST /api/user/login=10/1m,GET /api/user/orders=60/1m".
+func ParseRouteRates(s string) (RouteRates, error) {
+	rates := make(RouteRates)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("route rate %q: expected route=rate", item)
+		}
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || method == "" || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("route rate %q: expected METHOD /path", item)
+		}
+
+		r, err := ParseRate(value)
+		if err != nil {
+			return nil, err
+		}
+		rates[strings.ToUpper(method)+" "+path] = r
+	}
+	return rates, nil
+}
+
+func (rr RouteRates) String() string {
+	routes := make([]string, 0, len(rr))
+	for route := range rr {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	items := make([]string, len(routes))
+	for i, route := range routes {
+		items[i] = route + "=" + rr[route].String()
+	}
+	return strings.Join(items, ",")
+}
+
+func (rr RouteRates) MarshalText() ([]byte, error) {
+	return []byte(rr.String()), nil
+}
+
+func (rr *RouteRates) UnmarshalText(text []byte) error {
+	parsed, err := ParseRouteRates(string(text))
+	if err != nil {
+		return err
+	}
+	*rr = parsed
+	return nil
+}
+
+// Decision определяет результат проверки ограничения.
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // Время до полного восстановления корзины.
+	RetryAfter time.Duration // Время до следующего разрешённого запроса.
+}
+
+// KeyedLimiter определяет ограничитель частоты запросов с отдельной
+// корзиной токенов на каждый ключ. Корзины, не использовавшиеся дольше
+// времени простоя, удаляются.
+type KeyedLimiter struct {
+	rate  rate.Limit
+	burst int
+	idle  time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewKeyedLimiter возвращает ограничитель с корзиной r.Requests токенов,
+// полностью восстанавливающейся за r.Per. Время простоя idle не может быть
+// меньше r.Per, иначе удаление корзины сбрасывало бы ограничение.
+// Возвращает nil, если ограничение снято.
+func NewKeyedLimiter(r Rate, idle time.Duration) *KeyedLimiter {
+	if r.IsZero() {
+		return nil
+	}
+	if idle < r.Per {
+		idle = r.Per
+	}
+	return &KeyedLimiter{
+		rate:    rate.Limit(float64(r.Requests) / r.Per.Seconds()),
+		burst:   r.Requests,
+		idle:    idle,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow расходует токен корзины ключа key и возвращает результат проверки.
+func (l *KeyedLimiter) Allow(key string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	allowed := b.limiter.AllowN(now, 1)
+	tokens := math.Max(b.limiter.TokensAt(now), 0)
+
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     l.burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     l.duration(float64(l.burst) - tokens),
+	}
+	if !allowed {
+		d.RetryAfter = l.duration(1 - tokens)
+	}
+
+	return d
+}
+
+// Len возвращает количество корзин.
+func (l *KeyedLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// duration возвращает время восстановления tokens токенов.
+func (l *KeyedLimiter) duration(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / float64(l.rate) * float64(time.Second))
+}
+
+// sweep удаляет корзины, простаивающие дольше idle; выполняется не чаще
+// одного раза за idle.
+func (l *KeyedLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idle {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.idle {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Limit возвращает промежуточный обработчик, который ограничивает частоту
+// запросов по ключу, возвращаемому key, и отклоняет превысившие ограничение
+// запросы с http.StatusTooManyRequests и заголовком Retry-After. Запросы
+// с пустым ключом и запросы при nil-ограничителе пропускаются.
+func Limit(l *KeyedLimiter, key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			k := key(r)
+			if k == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			d := l.Allow(k)
+			WriteHeaders(w, d)
+
+			if !d.Allowed {
+				WriteLimited(w, r, d)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// WriteHeaders устанавливает заголовки X-RateLimit-*, если ответ ещё
+// не содержит более строгого ограничения.
+func WriteHeaders(w http.ResponseWriter, d Decision) {
+	h := w.Header()
+	if s := h.Get(RemainingHeader); s != "" {
+		remaining, err := strconv.Atoi(s)
+		if err == nil && remaining <= d.Remaining {
+			return
+		}
+	}
+	h.Set(LimitHeader, strconv.Itoa(d.Limit))
+	h.Set(RemainingHeader, strconv.Itoa(d.Remaining))
+	h.Set(ResetHeader, strconv.Itoa(seconds(d.Reset)))
+}
+
+// WriteLimited возвращает http.StatusTooManyRequests с заголовком
+// Retry-After.
+func WriteLimited(w http.ResponseWriter, r *http.Request, d Decision) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds(d.RetryAfter), 1)))
+
+	p := problem.New(http.StatusTooManyRequests, "rate_limited", "request rate limit exceeded")
+
+	err := p.Write(w, r)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err.Error())
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,131 @@
+package throttling_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+	"github.com/sergeizaitcev/gophermart/pkg/throttling"
+)
+
+func TestParseRate(t *testing.T) {
+	r, err := throttling.ParseRate("100/1m")
+	require.NoError(t, err)
+	require.Equal(t, throttling.Rate{Requests: 100, Per: time.Minute}, r)
+	require.Equal(t, "100/1m0s", r.String())
+
+	r, err = throttling.ParseRate("0")
+	require.NoError(t, err)
+	require.True(t, r.IsZero())
+
+	for _, s := range []string{"100", "x/1m", "100/x", "-1/1m", "10/0s"} {
+		_, err = throttling.ParseRate(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestParseRouteRates(t *testing.T) {
+	rates, err := throttling.ParseRouteRates("post /api/user/login=10/1m, GET /api/user/orders=60/1m")
+	require.NoError(t, err)
+	require.Equal(t, throttling.RouteRates{
+		"POST /api/user/login": {Requests: 10, Per: time.Minute},
+		"GET /api/user/orders": {Requests: 60, Per: time.Minute},
+	}, rates)
+	require.Equal(t, "GET /api/user/orders=60/1m0s,POST /api/user/login=10/1m0s", rates.String())
+
+	for _, s := range []string{"POST /api/user/login", "/api/user/login=10/1m", "POST api=10/1m"} {
+		_, err = throttling.ParseRouteRates(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestKeyedLimiter(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		require.Nil(t, throttling.NewKeyedLimiter(throttling.Rate{}, time.Minute))
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		l := throttling.NewKeyedLimiter(throttling.Rate{Requests: 2, Per: time.Hour}, 0)
+
+		d := l.Allow("a")
+		require.True(t, d.Allowed)
+		require.Equal(t, 2, d.Limit)
+		require.Equal(t, 1, d.Remaining)
+		require.Greater(t, d.Reset, time.Duration(0))
+
+		d = l.Allow("a")
+		require.True(t, d.Allowed)
+		require.Equal(t, 0, d.Remaining)
+
+		d = l.Allow("a")
+		require.False(t, d.Allowed)
+		require.Greater(t, d.RetryAfter, 29*time.Minute)
+
+		require.True(t, l.Allow("b").Allowed)
+	})
+
+	t.Run("evict idle buckets", func(t *testing.T) {
+		l := throttling.NewKeyedLimiter(throttling.Rate{Requests: 1, Per: 10 * time.Millisecond}, 0)
+
+		l.Allow("a")
+		l.Allow("b")
+		require.Equal(t, 2, l.Len())
+
+		time.Sleep(20 * time.Millisecond)
+
+		l.Allow("c")
+		require.Equal(t, 1, l.Len())
+	})
+}
+
+func TestLimit(t *testing.T) {
+	l := throttling.NewKeyedLimiter(throttling.Rate{Requests: 1, Per: time.Minute}, 0)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := throttling.Limit(l, func(r *http.Request) string {
+		return r.Header.Get("X-Key")
+	})(next)
+
+	do := func(key string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.Header.Set("X-Key", key)
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("a")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "1", rec.Header().Get(throttling.LimitHeader))
+	require.Equal(t, "0", rec.Header().Get(throttling.RemainingHeader))
+	require.Equal(t, "60", rec.Header().Get(throttling.ResetHeader))
+
+	rec = do("a")
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "60", rec.Header().Get("Retry-After"))
+	require.Equal(t, problem.ContentType, rec.Header().Get("Content-Type"))
+
+	require.Equal(t, http.StatusOK, do("").Code)
+	require.Equal(t, http.StatusOK, do("").Code)
+}
+
+func TestWriteHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	throttling.WriteHeaders(rec, throttling.Decision{Limit: 10, Remaining: 3})
+	throttling.WriteHeaders(rec, throttling.Decision{Limit: 100, Remaining: 50})
+
+	require.Equal(t, "10", rec.Header().Get(throttling.LimitHeader))
+	require.Equal(t, "3", rec.Header().Get(throttling.RemainingHeader))
+
+	throttling.WriteHeaders(rec, throttling.Decision{Limit: 5, Remaining: 0})
+
+	require.Equal(t, "5", rec.Header().Get(throttling.LimitHeader))
+	require.Equal(t, "0", rec.Header().Get(throttling.RemainingHeader))
+}
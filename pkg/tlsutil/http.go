@@ -0,0 +1,50 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/sergeizaitcev/gophermart/pkg/logging"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+)
+
+// CodeClientCertificateRequired — код проблемы для запроса без проверенного
+// сертификата клиента.
+const CodeClientCertificateRequired = "client_certificate_required"
+
+// OptionalClientCert возвращает копию c, в которой сертификат клиента
+// проверяется, только если клиент его предъявил. Используется вместе
+// с RequireClientCert, когда часть маршрутов, например, проверки состояния,
+// должна оставаться доступной без сертификата. Если c равен nil или не
+// проверяет сертификаты клиентов, то он возвращается без изменений.
+func OptionalClientCert(c *tls.Config) *tls.Config {
+	if c == nil || c.ClientCAs == nil {
+		return c
+	}
+	c = c.Clone()
+	c.ClientAuth = tls.VerifyClientCertIfGiven
+	return c
+}
+
+// RequireClientCert отвечает http.StatusForbidden на запросы без
+// проверенного сертификата клиента, кроме запросов к путям exempt.
+func RequireClientCert(next http.Handler, exempt ...string) http.Handler {
+	skip := make(map[string]struct{}, len(exempt))
+	for _, path := range exempt {
+		skip[path] = struct{}{}
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := skip[r.URL.Path]; ok || (r.TLS != nil && len(r.TLS.VerifiedChains) > 0) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		p := problem.New(http.StatusForbidden, CodeClientCertificateRequired, "client certificate is required")
+		if err := p.Write(w, r); err != nil {
+			logging.FromContext(r.Context()).Error(err.Error())
+		}
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,186 @@
+// Package tlsutil содержит вспомогательные функции для настройки TLS
+// и взаимной аутентификации по сертификатам.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Интервал, чаще которого файлы сертификата не проверяются на изменение.
+const checkInterval = time.Second
+
+// KeyPair определяет сертификат с закрытым ключом, который перечитывается
+// с диска при изменении файлов, поэтому обновлённый сертификат
+// применяется к новым соединениям без перезапуска процесса.
+//
+// Структура потоко-безопасна.
+type KeyPair struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	version fileVersion
+	checked time.Time
+}
+
+// fileVersion определяет состояние файлов сертификата и ключа.
+type fileVersion struct {
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+}
+
+// LoadKeyPair загружает сертификат и закрытый ключ в формате PEM.
+func LoadKeyPair(certFile, keyFile string) (*KeyPair, error) {
+	kp := &KeyPair{certFile: certFile, keyFile: keyFile}
+
+	version, err := kp.stat()
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading a key pair: %w", err)
+	}
+
+	kp.cert = &cert
+	kp.version = version
+	kp.checked = time.Now()
+
+	return kp, nil
+}
+
+func (kp *KeyPair) stat() (fileVersion, error) {
+	cert, err := os.Stat(kp.certFile)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("reading a certificate: %w", err)
+	}
+	key, err := os.Stat(kp.keyFile)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("reading a private key: %w", err)
+	}
+	return fileVersion{
+		certMod:  cert.ModTime(),
+		keyMod:   key.ModTime(),
+		certSize: cert.Size(),
+		keySize:  key.Size(),
+	}, nil
+}
+
+// Certificate возвращает текущий сертификат, перечитывая его, если файлы
+// изменились. Если новый сертификат не удалось загрузить, например, когда
+// файлы перезаписываются не одновременно, используется прежний.
+func (kp *KeyPair) Certificate() *tls.Certificate {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(kp.checked) < checkInterval {
+		return kp.cert
+	}
+	kp.checked = now
+
+	version, err := kp.stat()
+	if err != nil || version == kp.version {
+		return kp.cert
+	}
+
+	cert, err := tls.LoadX509KeyPair(kp.certFile, kp.keyFile)
+	if err != nil {
+		slog.Warn(err.Error(), slog.String("scope", "reloading a certificate"))
+		return kp.cert
+	}
+
+	kp.cert = &cert
+	kp.version = version
+
+	slog.Info("certificate reloaded", slog.String("path", kp.certFile))
+
+	return kp.cert
+}
+
+// GetCertificate реализует tls.Config.GetCertificate.
+func (kp *KeyPair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return kp.Certificate(), nil
+}
+
+// GetClientCertificate реализует tls.Config.GetClientCertificate.
+func (kp *KeyPair) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return kp.Certificate(), nil
+}
+
+// LoadCertPool загружает пул корневых сертификатов в формате PEM.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading a file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no certificates found")
+	}
+
+	return pool, nil
+}
+
+// ServerConfig возвращает конфигурацию TLS сервера с сертификатом kp.
+// Если clientCAs не nil, то клиент обязан предъявить сертификат,
+// подписанный одним из clientCAs.
+func ServerConfig(kp *KeyPair, clientCAs *x509.CertPool) *tls.Config {
+	c := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: kp.GetCertificate,
+	}
+	if clientCAs != nil {
+		c.ClientCAs = clientCAs
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c
+}
+
+// ClientConfig возвращает конфигурацию TLS клиента. Если kp не nil,
+// то клиент предъявляет сертификат kp; если rootCAs не nil, то сертификат
+// сервера проверяется по rootCAs вместо системных корневых сертификатов.
+func ClientConfig(kp *KeyPair, rootCAs *x509.CertPool) *tls.Config {
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    rootCAs,
+	}
+	if kp != nil {
+		c.GetClientCertificate = kp.GetClientCertificate
+	}
+	return c
+}
+
+// LoadServerConfig возвращает конфигурацию TLS сервера с сертификатом
+// из certFile и keyFile и, если clientCAFile не пуст, с проверкой
+// сертификатов клиентов. Если certFile пуст, то возвращается nil.
+func LoadServerConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+
+	kp, err := LoadKeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientCAs *x509.CertPool
+	if clientCAFile != "" {
+		clientCAs, err = LoadCertPool(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client CAs: %w", err)
+		}
+	}
+
+	return ServerConfig(kp, clientCAs), nil
+}
@@ -0,0 +1,266 @@
+package tlsutil_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httpserver"
+	"github.com/sergeizaitcev/gophermart/pkg/problem"
+	"github.com/sergeizaitcev/gophermart/pkg/tlsutil"
+)
+
+// authority определяет тестовый удостоверяющий центр.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &authority{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue выпускает сертификат name и записывает его с ключом в dir.
+func (a *authority) issue(t *testing.T, dir, name string, serial int64) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+func (a *authority) pool(t *testing.T) *x509.CertPool {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(path, a.pem, 0o600))
+
+	pool, err := tlsutil.LoadCertPool(path)
+	require.NoError(t, err)
+
+	return pool
+}
+
+func serialOf(t *testing.T, cert *tls.Certificate) int64 {
+	t.Helper()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+
+	return leaf.SerialNumber.Int64()
+}
+
+func TestKeyPair_Reload(t *testing.T) {
+	ca := newAuthority(t)
+	dir := t.TempDir()
+
+	certFile, keyFile := ca.issue(t, dir, "server", 2)
+
+	kp, err := tlsutil.LoadKeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, serialOf(t, kp.Certificate()))
+
+	ca.issue(t, dir, "server", 3)
+
+	// Время изменения файлов сдвигается явно, чтобы не зависеть
+	// от точности часов файловой системы.
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+
+	require.Eventually(t, func() bool {
+		return serialOf(t, kp.Certificate()) == 3
+	}, 3*time.Second, 100*time.Millisecond)
+
+	// Повреждённый сертификат не заменяет действующий.
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+
+	time.Sleep(1100 * time.Millisecond)
+	require.EqualValues(t, 3, serialOf(t, kp.Certificate()))
+}
+
+func TestLoadCertPool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(path, []byte("no certificates"), 0o600))
+
+	_, err := tlsutil.LoadCertPool(path)
+	require.Error(t, err)
+
+	_, err = tlsutil.LoadCertPool(filepath.Join(t.TempDir(), "missing.pem"))
+	require.Error(t, err)
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newAuthority(t)
+	other := newAuthority(t)
+	dir := t.TempDir()
+
+	serverKP, err := tlsutil.LoadKeyPair(ca.issue(t, dir, "accrual", 2))
+	require.NoError(t, err)
+
+	clientKP, err := tlsutil.LoadKeyPair(ca.issue(t, dir, "gophermart", 3))
+	require.NoError(t, err)
+
+	strangerKP, err := tlsutil.LoadKeyPair(other.issue(t, dir, "stranger", 4))
+	require.NoError(t, err)
+
+	srv := httpserver.New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLSConfig = tlsutil.ServerConfig(serverKP, ca.pool(t))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		require.NoError(t, srv.Shutdown())
+		<-errc
+	})
+
+	url := "https://" + l.Addr().String()
+
+	get := func(kp *tlsutil.KeyPair, roots *x509.CertPool) (*http.Response, error) {
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsutil.ClientConfig(kp, roots)},
+			Timeout:   5 * time.Second,
+		}
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, http.NoBody)
+		require.NoError(t, err)
+		return client.Do(req)
+	}
+
+	res, err := get(clientKP, ca.pool(t))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Сервер не доверяет клиенту без сертификата и клиенту с сертификатом
+	// другого удостоверяющего центра.
+	_, err = get(nil, ca.pool(t))
+	require.Error(t, err)
+
+	_, err = get(strangerKP, ca.pool(t))
+	require.Error(t, err)
+
+	// Клиент не доверяет серверу без пользовательского корневого сертификата.
+	_, err = get(clientKP, nil)
+	require.Error(t, err)
+}
+
+func TestRequireClientCert(t *testing.T) {
+	ca := newAuthority(t)
+	dir := t.TempDir()
+
+	serverKP, err := tlsutil.LoadKeyPair(ca.issue(t, dir, "accrual", 2))
+	require.NoError(t, err)
+
+	clientKP, err := tlsutil.LoadKeyPair(ca.issue(t, dir, "gophermart", 3))
+	require.NoError(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv := httpserver.New(tlsutil.RequireClientCert(ok, "/healthz"))
+	srv.TLSConfig = tlsutil.OptionalClientCert(tlsutil.ServerConfig(serverKP, ca.pool(t)))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		require.NoError(t, srv.Shutdown())
+		<-errc
+	})
+
+	get := func(kp *tlsutil.KeyPair, path string) *http.Response {
+		client := &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsutil.ClientConfig(kp, ca.pool(t))},
+			Timeout:   5 * time.Second,
+		}
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://"+l.Addr().String()+path, http.NoBody)
+		require.NoError(t, err)
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+
+	// Проверка состояния доступна без сертификата, остальные маршруты — нет.
+	require.Equal(t, http.StatusOK, get(nil, "/healthz").StatusCode)
+
+	res := get(nil, "/api/orders")
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+	require.Equal(t, problem.ContentType, res.Header.Get("Content-Type"))
+
+	require.Equal(t, http.StatusOK, get(clientKP, "/api/orders").StatusCode)
+}
@@ -0,0 +1,155 @@
+// Package totp реализует одноразовые пароли на основе времени (RFC 6238).
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Параметры по умолчанию, совместимые с распространёнными приложениями
+// аутентификации.
+const (
+	DefaultPeriod = 30 * time.Second
+	DefaultDigits = 6
+	DefaultSkew   = 1
+)
+
+// Длина секрета в байтах (RFC 4226, рекомендуемая длина для HMAC-SHA1).
+const secretSize = 20
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Config определяет параметры генерации кодов; нулевые значения заменяются
+// значениями по умолчанию, отрицательный Skew отключает отклонение.
+type Config struct {
+	Period time.Duration // Период действия кода.
+	Digits int           // Количество цифр в коде.
+	Skew   int           // Допустимое отклонение в периодах в обе стороны.
+}
+
+func (c Config) withDefaults() Config {
+	if c.Period <= 0 {
+		c.Period = DefaultPeriod
+	}
+	if c.Digits <= 0 {
+		c.Digits = DefaultDigits
+	}
+	switch {
+	case c.Skew == 0:
+		c.Skew = DefaultSkew
+	case c.Skew < 0:
+		c.Skew = 0
+	}
+	return c
+}
+
+// Counter возвращает номер периода для времени t.
+func (c Config) Counter(t time.Time) uint64 {
+	c = c.withDefaults()
+	return uint64(t.Unix() / int64(c.Period/time.Second))
+}
+
+// Code возвращает код для секрета и времени t.
+func (c Config) Code(secret []byte, t time.Time) string {
+	c = c.withDefaults()
+	return hotp(secret, c.Counter(t), c.Digits)
+}
+
+// Verify проверяет код для секрета и времени t с учётом допустимого
+// отклонения и возвращает номер периода, которому соответствует код.
+//
+// Для защиты от повторного использования кода вызывающая сторона должна
+// хранить последний принятый номер периода и отклонять коды с номером,
+// не превышающим его.
+func (c Config) Verify(secret []byte, code string, t time.Time) (counter uint64, ok bool) {
+	c = c.withDefaults()
+	if len(code) != c.Digits {
+		return 0, false
+	}
+
+	current := c.Counter(t)
+	for i := -c.Skew; i <= c.Skew; i++ {
+		counter := current + uint64(i)
+		want := hotp(secret, counter, c.Digits)
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI возвращает otpauth URI для добавления секрета в приложение
+// аутентификации.
+func (c Config) URI(issuer, account string, secret []byte) string {
+	c = c.withDefaults()
+
+	params := url.Values{}
+	params.Set("secret", EncodeSecret(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", strconv.Itoa(c.Digits))
+	params.Set("period", strconv.Itoa(int(c.Period/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: params.Encode(),
+	}
+
+	return u.String()
+}
+
+// GenerateSecret генерирует новый секрет.
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, secretSize)
+	_, err := io.ReadFull(rand.Reader, secret)
+	if err != nil {
+		return nil, fmt.Errorf("reading random bytes: %w", err)
+	}
+	return secret, nil
+}
+
+// EncodeSecret кодирует секрет в base32 без выравнивания.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// DecodeSecret декодирует секрет из base32; регистр и выравнивание
+// не учитываются.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.TrimRight(strings.ToUpper(s), "=")
+	secret, err := encoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("base32 decoding: %w", err)
+	}
+	return secret, nil
+}
+
+// hotp вычисляет код HOTP (RFC 4226).
+func hotp(secret []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(math.Pow10(digits))
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
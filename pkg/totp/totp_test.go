@@ -0,0 +1,76 @@
+package totp_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/totp"
+)
+
+// Тестовые векторы RFC 6238, приложение B (SHA1).
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	c := totp.Config{Digits: 8}
+
+	for _, tc := range testCases {
+		got := c.Code(rfcSecret, time.Unix(tc.unix, 0))
+		require.Equal(t, tc.want, got, tc.unix)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	c := totp.Config{}
+	now := time.Unix(1700000000, 0)
+	code := c.Code(rfcSecret, now)
+
+	counter, ok := c.Verify(rfcSecret, code, now)
+	require.True(t, ok)
+	require.Equal(t, c.Counter(now), counter)
+
+	_, ok = c.Verify(rfcSecret, code, now.Add(totp.DefaultPeriod))
+	require.True(t, ok, "skew")
+
+	_, ok = c.Verify(rfcSecret, code, now.Add(3*totp.DefaultPeriod))
+	require.False(t, ok, "expired")
+
+	_, ok = c.Verify(rfcSecret, "12345", now)
+	require.False(t, ok, "length")
+}
+
+func TestSecret(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 20)
+
+	decoded, err := totp.DecodeSecret(totp.EncodeSecret(secret))
+	require.NoError(t, err)
+	require.Equal(t, secret, decoded)
+}
+
+func TestURI(t *testing.T) {
+	uri := totp.Config{}.URI("Gophermart", "login", rfcSecret)
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.Equal(t, "/Gophermart:login", u.Path)
+	require.Equal(t, totp.EncodeSecret(rfcSecret), u.Query().Get("secret"))
+	require.Equal(t, "6", u.Query().Get("digits"))
+}
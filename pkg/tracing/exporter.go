@@ -0,0 +1,270 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+)
+
+// Exporter определяет получателя завершённых участков.
+type Exporter interface {
+	// Export отправляет пакет участков.
+	Export(ctx context.Context, spans []SpanData) error
+
+	// Shutdown освобождает ресурсы экспортёра.
+	Shutdown(ctx context.Context) error
+}
+
+// Экспортёры, поддерживаемые Setup.
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Setup создаёт трассировщик сервиса service с экспортёром exporter
+// и устанавливает его трассировщиком процесса. Для ExporterOTLP endpoint
+// определяет адрес OTLP/HTTP, для ExporterFile — путь к файлу.
+func Setup(service, exporter, endpoint string) (*Tracer, error) {
+	var (
+		exp Exporter
+		err error
+	)
+
+	switch exporter {
+	case "", ExporterNone:
+	case ExporterStdout:
+		exp = NewWriterExporter(service, os.Stdout)
+	case ExporterFile:
+		exp, err = NewFileExporter(service, endpoint)
+	case ExporterOTLP:
+		exp, err = NewOTLPExporter(service, endpoint, nil)
+	default:
+		err = fmt.Errorf("unsupported trace exporter: %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := NewTracer(exp)
+	SetDefault(t)
+
+	return t, nil
+}
+
+// writerExporter записывает каждый пакет участков строкой в формате
+// OTLP/JSON, который читает, например, приёмник otlpjsonfile
+// OpenTelemetry Collector.
+type writerExporter struct {
+	service string
+
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewWriterExporter возвращает экспортёр, записывающий участки в w.
+func NewWriterExporter(service string, w io.Writer) Exporter {
+	return &writerExporter{service: service, w: w}
+}
+
+// NewFileExporter возвращает экспортёр, дописывающий участки в файл path.
+func NewFileExporter(service, path string) (Exporter, error) {
+	if path == "" {
+		return nil, fmt.Errorf("the trace file path must not be empty")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening the trace file: %w", err)
+	}
+	return &writerExporter{service: service, w: f, closer: f}, nil
+}
+
+func (e *writerExporter) Export(_ context.Context, spans []SpanData) error {
+	b, err := json.Marshal(encodeOTLP(e.service, spans))
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err = e.w.Write(append(b, '\n'))
+	return err
+}
+
+func (e *writerExporter) Shutdown(context.Context) error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// otlpExporter отправляет участки по OTLP/HTTP в кодировке JSON.
+type otlpExporter struct {
+	service  string
+	endpoint string
+	client   *http.Client
+}
+
+// NewOTLPExporter возвращает экспортёр, отправляющий участки по OTLP/HTTP
+// на endpoint; если путь не указан, используется /v1/traces. При nil-клиенте
+// используется http.DefaultClient.
+func NewOTLPExporter(service, endpoint string, client *http.Client) (Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint: %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &otlpExporter{service: service, endpoint: u.String(), client: client}, nil
+}
+
+func (e *otlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	b, err := json.Marshal(encodeOTLP(e.service, spans))
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("creating a request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending spans: %w", err)
+	}
+	defer httputil.GracefulClose(res)
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("sending spans: unexpected status %s", res.Status)
+	}
+
+	return nil
+}
+
+func (e *otlpExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// Структуры OTLP/JSON: идентификаторы кодируются в hex, 64-битные числа —
+// строками.
+type (
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		ParentSpanID      string      `json:"parentSpanId,omitempty"`
+		Name              string      `json:"name"`
+		Kind              SpanKind    `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []otlpAttr  `json:"attributes,omitempty"`
+		Status            *otlpStatus `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+)
+
+// Код статуса участка с ошибкой в OTLP.
+const otlpStatusError = 2
+
+const scopeName = "github.com/sergeizaitcev/gophermart/pkg/tracing"
+
+func encodeOTLP(service string, spans []SpanData) otlpTraces {
+	encoded := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        encodeAttrs(s.Attributes),
+		}
+		if s.ParentSpanID.IsValid() {
+			span.ParentSpanID = s.ParentSpanID.String()
+		}
+		if s.Error != "" {
+			span.Status = &otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		encoded[i] = span
+	}
+
+	return otlpTraces{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: encodeAttrs([]Attr{String("service.name", service)}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scopeName},
+				Spans: encoded,
+			}},
+		}},
+	}
+}
+
+func encodeAttrs(attrs []Attr) []otlpAttr {
+	encoded := make([]otlpAttr, 0, len(attrs))
+	for _, a := range attrs {
+		var v otlpValue
+		switch value := a.Value.(type) {
+		case string:
+			v.StringValue = &value
+		case int64:
+			s := strconv.FormatInt(value, 10)
+			v.IntValue = &s
+		case bool:
+			v.BoolValue = &value
+		default:
+			s := fmt.Sprint(value)
+			v.StringValue = &s
+		}
+		encoded = append(encoded, otlpAttr{Key: a.Key, Value: v})
+	}
+	return encoded
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sergeizaitcev/gophermart/pkg/httputil"
+)
+
+// TraceparentHeader определяет заголовок W3C Trace Context.
+const TraceparentHeader = "traceparent"
+
+// Middleware возвращает промежуточный обработчик, который продолжает трассу
+// из заголовка traceparent или начинает новую и оборачивает обработку
+// запроса серверным участком с именем "METHOD шаблон-маршрута".
+//
+// Решение о выборке (флаг sampled) принимается от вызывающей стороны,
+// поэтому Middleware предназначен для внутренних сервисов; для публичного
+// API используется PublicMiddleware.
+func Middleware(next http.Handler) http.Handler {
+	return middleware(next, true)
+}
+
+// PublicMiddleware работает как Middleware, но не доверяет флагу sampled
+// клиента: идентификатор трассы сохраняется, а участок записывается всегда,
+// чтобы клиент не мог исключить свои запросы из трассировки.
+func PublicMiddleware(next http.Handler) http.Handler {
+	return middleware(next, false)
+}
+
+func middleware(next http.Handler, trustSampling bool) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if sc, err := ParseTraceparent(r.Header.Get(TraceparentHeader)); err == nil {
+			if !trustSampling {
+				// Корневые участки сервиса выбираются всегда.
+				sc.Sampled = true
+			}
+			ctx = ContextWithRemote(ctx, sc)
+		}
+
+		ctx, span := Start(ctx, r.Method, KindServer,
+			String("http.request.method", r.Method),
+			String("url.path", r.URL.Path),
+		)
+		defer span.End()
+
+		rw := httputil.NewResponseRecorder(w)
+
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(ctx); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				span.SetName(r.Method + " " + pattern)
+				span.SetAttributes(String("http.route", pattern))
+			}
+		}
+
+		status := rw.Status()
+		span.SetAttributes(Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.RecordError(errStatus(status))
+		}
+	}
+	return http.HandlerFunc(fn)
+}
+
+// Extract возвращает контекст с удалённым родителем из заголовка
+// traceparent; некорректный заголовок игнорируется.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemote(ctx, sc)
+}
+
+// Inject устанавливает заголовок traceparent по текущему участку ctx.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if sc.IsValid() {
+		h.Set(TraceparentHeader, sc.Traceparent())
+	}
+}
+
+type errStatus int
+
+func (e errStatus) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// OpenDB открывает БД драйвером driverName и оборачивает запросы
+// в клиентские участки. Участки создаются, только если в контексте запроса
+// уже есть участок, поэтому фоновые запросы без трассы не порождают
+// отдельных трасс.
+func OpenDB(driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := db.Driver()
+	_ = db.Close()
+
+	var connector driver.Connector
+	if dc, ok := drv.(driver.DriverContext); ok {
+		connector, err = dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("open connector: %w", err)
+		}
+	} else {
+		connector = dsnConnector{dsn: dsn, driver: drv}
+	}
+
+	return sql.OpenDB(tracedConnector{connector}), nil
+}
+
+// startQuery начинает участок запроса query, если в ctx есть участок.
+func startQuery(ctx context.Context, query string) (*Span, bool) {
+	if SpanFromContext(ctx) == nil {
+		return nil, false
+	}
+	_, span := Start(ctx, queryName(query), KindClient,
+		String("db.system", "postgresql"),
+		String("db.statement", query),
+	)
+	return span, true
+}
+
+// queryName возвращает первое ключевое слово запроса: SELECT, INSERT и т.д.
+func queryName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+func endQuery(span *Span, err error) {
+	if err != nil && err != driver.ErrSkip {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type tracedConnector struct {
+	driver.Connector
+}
+
+func (c tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{conn}, nil
+}
+
+// tracedConn оборачивает соединение драйвера; недоступные у исходного
+// соединения возможности сообщаются database/sql через driver.ErrSkip.
+type tracedConn struct {
+	driver.Conn
+}
+
+var (
+	_ driver.ConnPrepareContext = (*tracedConn)(nil)
+	_ driver.ConnBeginTx        = (*tracedConn)(nil)
+	_ driver.ExecerContext      = (*tracedConn)(nil)
+	_ driver.QueryerContext     = (*tracedConn)(nil)
+	_ driver.Pinger             = (*tracedConn)(nil)
+	_ driver.SessionResetter    = (*tracedConn)(nil)
+	_ driver.Validator          = (*tracedConn)(nil)
+	_ driver.NamedValueChecker  = (*tracedConn)(nil)
+)
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tracedStmt{Stmt: stmt, query: query}, nil
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bt.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // Запасной вариант для старых драйверов.
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span, ok := startQuery(ctx, query)
+	if !ok {
+		return ec.ExecContext(ctx, query, args)
+	}
+	res, err := ec.ExecContext(ctx, query, args)
+	endQuery(span, err)
+	return res, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	span, ok := startQuery(ctx, query)
+	if !ok {
+		return qc.QueryContext(ctx, query, args)
+	}
+	rows, err := qc.QueryContext(ctx, query, args)
+	endQuery(span, err)
+	return rows, err
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.Conn.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *tracedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// tracedStmt оборачивает подготовленный запрос. Проверку аргументов
+// database/sql выполняет через соединение, поэтому NamedValueChecker здесь
+// не реализуется.
+type tracedStmt struct {
+	driver.Stmt
+	query string
+}
+
+var (
+	_ driver.StmtExecContext  = (*tracedStmt)(nil)
+	_ driver.StmtQueryContext = (*tracedStmt)(nil)
+)
+
+func (s *tracedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	span, _ := startQuery(ctx, s.query)
+	var (
+		res driver.Result
+		err error
+	)
+	if sec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = sec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			res, err = s.Stmt.Exec(values) //nolint:staticcheck // Запасной вариант для старых драйверов.
+		}
+	}
+	endQuery(span, err)
+	return res, err
+}
+
+func (s *tracedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	span, _ := startQuery(ctx, s.query)
+	var (
+		rows driver.Rows
+		err  error
+	)
+	if sqc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = sqc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			rows, err = s.Stmt.Query(values) //nolint:staticcheck // Запасной вариант для старых драйверов.
+		}
+	}
+	endQuery(span, err)
+	return rows, err
+}
+
+func namedToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
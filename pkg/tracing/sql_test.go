@@ -0,0 +1,67 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
+)
+
+var errFake = errors.New("fake: relation does not exist")
+
+// fakeDriver выполняет любые запросы, кроме запросов к таблице missing.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if query == "DELETE FROM missing" {
+		return nil, errFake
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func init() {
+	sql.Register("tracing-fake", fakeDriver{})
+}
+
+func TestOpenDB(t *testing.T) {
+	spans := setDefault(t)
+
+	db, err := tracing.OpenDB("tracing-fake", "")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Без участка в контексте запросы не трассируются.
+	_, err = db.ExecContext(context.Background(), "UPDATE orders SET status = $1", "NEW")
+	require.NoError(t, err)
+
+	ctx, parent := tracing.Start(context.Background(), "parent", tracing.KindInternal)
+	_, err = db.ExecContext(ctx, "\n\tinsert INTO orders VALUES ($1)", 1)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "DELETE FROM missing")
+	require.ErrorIs(t, err, errFake)
+	parent.End()
+
+	got := spans()
+	require.Len(t, got, 3)
+
+	insert := byName(t, got, "INSERT")
+	require.Equal(t, tracing.KindClient, insert.Kind)
+	require.Equal(t, parent.SpanContext().SpanID, insert.ParentSpanID)
+	require.Contains(t, insert.Attributes, tracing.String("db.system", "postgresql"))
+
+	del := byName(t, got, "DELETE")
+	require.Equal(t, errFake.Error(), del.Error)
+}
@@ -0,0 +1,449 @@
+// Package tracing реализует распределённую трассировку с передачей
+// контекста в заголовке W3C traceparent и экспортом участков в OTLP или
+// в файл.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID определяет идентификатор трассы.
+type TraceID [16]byte
+
+// IsValid возвращает true, если идентификатор не нулевой.
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID определяет идентификатор участка трассы.
+type SpanID [8]byte
+
+// IsValid возвращает true, если идентификатор не нулевой.
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext определяет передаваемую между сервисами часть участка.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid возвращает true, если идентификаторы трассы и участка заданы.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent возвращает значение заголовка traceparent.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent разбирает значение заголовка traceparent. Версии новее
+// 00 принимаются, если начинаются с полей версии 00.
+func ParseTraceparent(s string) (SpanContext, error) {
+	const size = 55 // 2+1+32+1+16+1+2
+
+	s = strings.TrimSpace(s)
+	if len(s) < size || (len(s) > size && s[size] != '-') {
+		return SpanContext{}, errors.New("traceparent: invalid length")
+	}
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return SpanContext{}, errors.New("traceparent: invalid format")
+	}
+
+	version, err := decodeHex(s[0:2], 1)
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(s) != size) {
+		return SpanContext{}, errors.New("traceparent: invalid version")
+	}
+
+	var sc SpanContext
+
+	traceID, err := decodeHex(s[3:35], 16)
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent: trace id: %w", err)
+	}
+	spanID, err := decodeHex(s[36:52], 8)
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent: parent id: %w", err)
+	}
+	flags, err := decodeHex(s[53:55], 1)
+	if err != nil {
+		return SpanContext{}, fmt.Errorf("traceparent: flags: %w", err)
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, errors.New("traceparent: zero trace or parent id")
+	}
+
+	return sc, nil
+}
+
+// decodeHex декодирует n байт из строки в нижнем регистре.
+func decodeHex(s string, n int) ([]byte, error) {
+	if strings.ToLower(s) != s {
+		return nil, errors.New("must be lowercase hex")
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != n {
+		return nil, errors.New("invalid hex")
+	}
+	return b, nil
+}
+
+// SpanKind определяет роль участка во взаимодействии сервисов.
+type SpanKind int
+
+// Значения совпадают с SpanKind в OTLP.
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// Attr определяет атрибут участка; значение — строка, целое число или
+// логическое значение.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// String возвращает строковый атрибут.
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Int возвращает целочисленный атрибут.
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: int64(value)}
+}
+
+// Bool возвращает логический атрибут.
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// SpanData определяет завершённый участок для экспорта.
+type SpanData struct {
+	Name         string
+	Kind         SpanKind
+	TraceID      TraceID
+	SpanID       SpanID
+	ParentSpanID SpanID
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attr
+	Error        string // Описание ошибки, если участок завершился ошибкой.
+}
+
+// Span определяет участок трассы.
+//
+// Структура потоко-безопасна; методы nil-участка ничего не делают.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext возвращает передаваемую часть участка.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName изменяет имя участка, например, когда шаблон маршрута становится
+// известен после маршрутизации.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttributes добавляет атрибуты участка.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError отмечает участок как завершившийся ошибкой err; nil
+// игнорируется.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// End завершает участок и передаёт его на экспорт; повторные вызовы
+// игнорируются.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.sc.Sampled {
+		s.tracer.export(data)
+	}
+}
+
+// Tracer определяет источник участков сервиса.
+type Tracer struct {
+	exporter Exporter
+
+	mu     sync.RWMutex
+	spans  chan SpanData
+	done   chan struct{}
+	closed bool
+}
+
+// Параметры пакетной отправки участков.
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+
+	// Ошибки экспорта пишутся в журнал не чаще одного раза за интервал,
+	// чтобы недоступный приёмник не засорял журнал.
+	exportErrorInterval = time.Minute
+)
+
+// NewTracer возвращает трассировщик, отправляющий завершённые участки
+// в exporter пакетами в фоне; участки, не поместившиеся в очередь,
+// отбрасываются. При nil-экспортёре участки не экспортируются, но
+// контекст трассировки передаётся.
+func NewTracer(exporter Exporter) *Tracer {
+	t := &Tracer{exporter: exporter}
+	if exporter != nil {
+		t.spans = make(chan SpanData, queueSize)
+		t.done = make(chan struct{})
+		go t.run()
+	}
+	return t
+}
+
+// Start начинает участок name, дочерний по отношению к участку из ctx,
+// и возвращает контекст с новым участком.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attr) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	sc := SpanContext{TraceID: parent.TraceID, Sampled: true}
+	if parent.IsValid() {
+		sc.Sampled = parent.Sampled
+	} else {
+		_, _ = rand.Read(sc.TraceID[:])
+	}
+	_, _ = rand.Read(sc.SpanID[:])
+
+	s := &Span{
+		tracer: t,
+		sc:     sc,
+		data: SpanData{
+			Name:         name,
+			Kind:         kind,
+			TraceID:      sc.TraceID,
+			SpanID:       sc.SpanID,
+			ParentSpanID: parent.SpanID,
+			Start:        time.Now(),
+			Attributes:   append([]Attr(nil), attrs...),
+		},
+	}
+
+	return context.WithValue(ctx, keySpan{}, s), s
+}
+
+func (t *Tracer) export(data SpanData) {
+	if t.spans == nil {
+		return
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.closed {
+		return
+	}
+	select {
+	case t.spans <- data:
+	default:
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+
+	var (
+		lastError time.Time
+		failed    int // Потерянные пакеты с последней записи в журнал.
+	)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), flushInterval)
+		defer cancel()
+
+		err := t.exporter.Export(ctx, batch)
+		if err != nil {
+			failed++
+			if now := time.Now(); now.Sub(lastError) >= exportErrorInterval {
+				slog.Error(err.Error(),
+					slog.String("scope", "trace export"),
+					slog.Int("failed_batches", failed),
+				)
+				lastError = now
+				failed = 0
+			}
+		}
+
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-t.spans:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, data)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown отправляет накопленные участки и закрывает экспортёр; участки,
+// завершённые после вызова, не экспортируются.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.spans)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return t.exporter.Shutdown(ctx)
+}
+
+var global atomic.Pointer[Tracer]
+
+func init() {
+	global.Store(NewTracer(nil))
+}
+
+// SetDefault устанавливает трассировщик процесса.
+func SetDefault(t *Tracer) {
+	global.Store(t)
+}
+
+// Default возвращает трассировщик процесса; по умолчанию участки
+// не экспортируются.
+func Default() *Tracer {
+	return global.Load()
+}
+
+// Start начинает участок трассировщиком процесса.
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attr) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind, attrs...)
+}
+
+type (
+	keySpan   struct{}
+	keyRemote struct{}
+)
+
+// SpanFromContext возвращает текущий участок или nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(keySpan{}).(*Span)
+	return s
+}
+
+// ContextWithRemote возвращает контекст, в котором родителем следующего
+// участка будет sc, например, полученный из заголовка traceparent или
+// сохранённый вместе с задачей в очереди. Невалидный sc игнорируется.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, keySpan{}, (*Span)(nil))
+	return context.WithValue(ctx, keyRemote{}, sc)
+}
+
+// SpanContextFromContext возвращает передаваемую часть текущего участка
+// или удалённого родителя.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if s := SpanFromContext(ctx); s != nil {
+		return s.sc
+	}
+	sc, _ := ctx.Value(keyRemote{}).(SpanContext)
+	return sc
+}
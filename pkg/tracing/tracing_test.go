@@ -0,0 +1,301 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/tracing"
+)
+
+// recorder запоминает экспортированные участки.
+type recorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (r *recorder) Export(_ context.Context, spans []tracing.SpanData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *recorder) Shutdown(context.Context) error { return nil }
+
+// setDefault устанавливает трассировщик процесса на время теста
+// и возвращает функцию, которая останавливает его и возвращает
+// экспортированные участки.
+func setDefault(t *testing.T) func() []tracing.SpanData {
+	t.Helper()
+
+	rec := &recorder{}
+	tracer := tracing.NewTracer(rec)
+
+	prev := tracing.Default()
+	tracing.SetDefault(tracer)
+	t.Cleanup(func() { tracing.SetDefault(prev) })
+
+	return func() []tracing.SpanData {
+		require.NoError(t, tracer.Shutdown(context.Background()))
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		return rec.spans
+	}
+}
+
+func byName(t *testing.T, spans []tracing.SpanData, name string) tracing.SpanData {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("span %q not found", name)
+	return tracing.SpanData{}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := tracing.ParseTraceparent(header)
+	require.NoError(t, err)
+	require.True(t, sc.Sampled)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	require.Equal(t, header, sc.Traceparent())
+
+	sc, err = tracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future")
+	require.NoError(t, err)
+	require.False(t, sc.Sampled)
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01",
+	}
+	for _, s := range invalid {
+		_, err := tracing.ParseTraceparent(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestTracer_Start(t *testing.T) {
+	spans := setDefault(t)
+
+	ctx, parent := tracing.Start(context.Background(), "parent", tracing.KindInternal)
+	_, child := tracing.Start(ctx, "child", tracing.KindClient, tracing.Int("n", 1))
+	child.RecordError(context.Canceled)
+	child.End()
+	parent.End()
+	parent.End()
+
+	// Задача из очереди продолжает трассу сохранённого участка.
+	queued := parent.SpanContext()
+	_, resumed := tracing.Start(tracing.ContextWithRemote(context.Background(), queued), "resumed", tracing.KindInternal)
+	resumed.End()
+
+	got := spans()
+	require.Len(t, got, 3)
+
+	p := byName(t, got, "parent")
+	c := byName(t, got, "child")
+	r := byName(t, got, "resumed")
+
+	require.False(t, p.ParentSpanID.IsValid())
+	require.Equal(t, p.TraceID, c.TraceID)
+	require.Equal(t, p.SpanID, c.ParentSpanID)
+	require.Equal(t, "context canceled", c.Error)
+	require.Equal(t, []tracing.Attr{tracing.Int("n", 1)}, c.Attributes)
+	require.Equal(t, p.TraceID, r.TraceID)
+	require.Equal(t, p.SpanID, r.ParentSpanID)
+}
+
+func TestMiddleware(t *testing.T) {
+	spans := setDefault(t)
+
+	var outgoing http.Header
+
+	mux := chi.NewRouter()
+	mux.Use(tracing.Middleware)
+	mux.Get("/orders/{number}", func(w http.ResponseWriter, r *http.Request) {
+		outgoing = make(http.Header)
+		tracing.Inject(r.Context(), outgoing)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/42", http.NoBody)
+	req.Header.Set(tracing.TraceparentHeader, incoming)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	got := spans()
+	require.Len(t, got, 1)
+
+	s := got[0]
+	require.Equal(t, "GET /orders/{number}", s.Name)
+	require.Equal(t, tracing.KindServer, s.Kind)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", s.ParentSpanID.String())
+	require.Contains(t, s.Attributes, tracing.String("http.route", "/orders/{number}"))
+	require.Contains(t, s.Attributes, tracing.Int("http.response.status_code", http.StatusBadGateway))
+	require.NotEmpty(t, s.Error)
+
+	sc, err := tracing.ParseTraceparent(outgoing.Get(tracing.TraceparentHeader))
+	require.NoError(t, err)
+	require.Equal(t, s.TraceID, sc.TraceID)
+	require.Equal(t, s.SpanID, sc.SpanID)
+}
+
+func TestPublicMiddleware(t *testing.T) {
+	const unsampled = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+
+	testCases := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		exported   int
+	}{
+		{name: "internal", middleware: tracing.Middleware, exported: 0},
+		{name: "public", middleware: tracing.PublicMiddleware, exported: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spans := setDefault(t)
+
+			h := tc.middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.Header.Set(tracing.TraceparentHeader, unsampled)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			got := spans()
+			require.Len(t, got, tc.exported)
+			for _, s := range got {
+				require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.TraceID.String())
+			}
+		})
+	}
+}
+
+// failingExporter возвращает ошибку при каждом экспорте.
+type failingExporter struct{}
+
+func (failingExporter) Export(context.Context, []tracing.SpanData) error {
+	return errors.New("collector unavailable")
+}
+
+func (failingExporter) Shutdown(context.Context) error { return nil }
+
+func TestTracer_ExportError(t *testing.T) {
+	var buf bytes.Buffer
+
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	tracer := tracing.NewTracer(failingExporter{})
+	_, span := tracer.Start(context.Background(), "span", tracing.KindInternal)
+	span.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	require.Contains(t, buf.String(), "collector unavailable")
+	require.Contains(t, buf.String(), "failed_batches=1")
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+
+	tracer := tracing.NewTracer(tracing.NewWriterExporter("gophermart", &buf))
+	_, span := tracer.Start(context.Background(), "upload", tracing.KindServer,
+		tracing.String("order", "12345678903"),
+		tracing.Int("attempt", 2),
+		tracing.Bool("retry", true),
+	)
+	span.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	var out struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []map[string]any `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID    string           `json:"traceId"`
+					Name       string           `json:"name"`
+					Kind       int              `json:"kind"`
+					Attributes []map[string]any `json:"attributes"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	require.Len(t, out.ResourceSpans, 1)
+
+	rs := out.ResourceSpans[0]
+	require.Equal(t, map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "gophermart"}}, rs.Resource.Attributes[0])
+
+	s := rs.ScopeSpans[0].Spans[0]
+	require.Equal(t, span.SpanContext().TraceID.String(), s.TraceID)
+	require.Equal(t, "upload", s.Name)
+	require.Equal(t, int(tracing.KindServer), s.Kind)
+	require.Equal(t, []map[string]any{
+		{"key": "order", "value": map[string]any{"stringValue": "12345678903"}},
+		{"key": "attempt", "value": map[string]any{"intValue": "2"}},
+		{"key": "retry", "value": map[string]any{"boolValue": true}},
+	}, s.Attributes)
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		path string
+		body []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ = json.Marshal(r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	exp, err := tracing.NewOTLPExporter("accrual", srv.URL, srv.Client())
+	require.NoError(t, err)
+
+	require.NoError(t, exp.Export(context.Background(), []tracing.SpanData{{Name: "x"}}))
+	require.Equal(t, "/v1/traces", path)
+	require.JSONEq(t, `"application/json"`, string(body))
+
+	_, err = tracing.NewOTLPExporter("accrual", "localhost:4318", nil)
+	require.Error(t, err)
+}
+
+func TestSetup(t *testing.T) {
+	prev := tracing.Default()
+	t.Cleanup(func() { tracing.SetDefault(prev) })
+
+	tracer, err := tracing.Setup("gophermart", tracing.ExporterNone, "")
+	require.NoError(t, err)
+	require.Same(t, tracer, tracing.Default())
+
+	_, err = tracing.Setup("gophermart", "jaeger", "")
+	require.Error(t, err)
+
+	_, err = tracing.Setup("gophermart", tracing.ExporterFile, "")
+	require.Error(t, err)
+}
@@ -0,0 +1,139 @@
+// Package ttlcache реализует ограниченный по размеру кеш в памяти с временем
+// жизни записей.
+package ttlcache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry определяет запись кеша.
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// Cache определяет потокобезопасный кеш с временем жизни записей; при
+// превышении размера вытесняются давно не использованные записи.
+type Cache[K comparable, V any] struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	items    map[K]*list.Element
+	order    *list.List // В начале — последние использованные записи.
+}
+
+// New возвращает новый экземпляр Cache, хранящий не более capacity записей
+// в течение ttl.
+func New[K comparable, V any](capacity int, ttl time.Duration) *Cache[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache[K, V]{
+		ttl:      ttl,
+		capacity: capacity,
+		items:    make(map[K]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+// Get возвращает значение по ключу, если оно есть и не истекло.
+func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return value, false
+	}
+
+	e := elem.Value.(*entry[K, V])
+	if !time.Now().Before(e.expiresAt) {
+		c.remove(elem)
+		return value, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return e.value, true
+}
+
+// Set сохраняет значение по ключу.
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL сохраняет значение по ключу с временем жизни ttl вместо
+// заданного для кеша.
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry[K, V])
+		e.value = value
+		e.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	for c.order.Len() >= c.capacity {
+		c.remove(c.order.Back())
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+}
+
+// Delete удаляет значение по ключу.
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// DeleteFunc удаляет все записи, для которых del возвращает true.
+func (c *Cache[K, V]) DeleteFunc(del func(key K, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		e := elem.Value.(*entry[K, V])
+		if del(e.key, e.value) {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Purge удаляет все записи.
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[K]*list.Element, c.capacity)
+	c.order.Init()
+}
+
+// Len возвращает количество записей, включая истёкшие, но ещё не удалённые.
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache[K, V]) remove(elem *list.Element) {
+	e := c.order.Remove(elem).(*entry[K, V])
+	delete(c.items, e.key)
+}
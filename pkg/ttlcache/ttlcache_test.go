@@ -0,0 +1,79 @@
+package ttlcache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergeizaitcev/gophermart/pkg/ttlcache"
+)
+
+func TestCache(t *testing.T) {
+	c := ttlcache.New[string, int](2, time.Minute)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	v, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	// "b" давно не использовалась и вытесняется.
+	c.Set("c", 3)
+	require.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("b")
+	require.False(t, ok)
+
+	c.Set("a", 10)
+	v, ok = c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, 10, v)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	require.False(t, ok)
+
+	c.Purge()
+	require.Zero(t, c.Len())
+}
+
+func TestCache_TTL(t *testing.T) {
+	c := ttlcache.New[string, int](10, 10*time.Millisecond)
+
+	c.Set("a", 1)
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok := c.Get("a")
+	require.False(t, ok)
+	require.Zero(t, c.Len())
+}
+
+func TestCache_SetWithTTL(t *testing.T) {
+	c := ttlcache.New[string, int](10, time.Minute)
+
+	c.SetWithTTL("a", 1, 10*time.Millisecond)
+	c.Set("b", 2)
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok := c.Get("a")
+	require.False(t, ok)
+
+	_, ok = c.Get("b")
+	require.True(t, ok)
+}
+
+func TestCache_DeleteFunc(t *testing.T) {
+	c := ttlcache.New[string, int](10, time.Minute)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 1)
+
+	c.DeleteFunc(func(_ string, v int) bool { return v == 1 })
+
+	require.Equal(t, 1, c.Len())
+	_, ok := c.Get("b")
+	require.True(t, ok)
+}